@@ -33,6 +33,11 @@ func main() {
 		log.Fatal("init redis failed:", err)
 	}
 
+	// a failed message has nowhere to go without it and would be skipped
+	if config.GetKafkaConfig().DLQTopic == "" {
+		log.Fatal("KafkaConfig.DLQTopic is required")
+	}
+
 	alikafka.InitKafka()
 
 	serv := solalter.NewAlterService()
@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solrpc"
 )
 
 // one database one instance
@@ -30,6 +31,9 @@ type KafkaConfig struct {
 	HistoryGroupID  string
 	BitQueryTopic   string
 	BitQueryGroupID string
+	// DLQTopic parks the messages whose handlers failed, their offsets commit after it. required,
+	// the consumer does not start without it
+	DLQTopic string
 
 	Protocol string
 	Username string
@@ -56,7 +60,7 @@ type SolServer struct {
 	CoingeckoAPIKey  string
 	ThreadNum        int
 	MergeInterval    int
-	QuickNodeURL     string
+	QuickNodeURL     string // only read when RPCConfig lists no endpoint
 	ServerConfigList string
 }
 
@@ -66,6 +70,7 @@ type Config struct {
 	KafkaConf        KafkaConfig      `mapstructure:"KafkaConfig"`
 	SolConf          SolServer        `mapstructure:"SolServer"`
 	RedisConf        RedisConfig      `mapstructure:"RedisConfig"`
+	RPCConf          solrpc.Config    `mapstructure:"RPCConfig"`
 }
 
 var (
@@ -161,3 +166,9 @@ func GetSolDataConfig() SolServer {
 	defer configMutex.RUnlock()
 	return config.SolConf
 }
+
+func GetRPCConfig() solrpc.Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.RPCConf
+}
@@ -0,0 +1,59 @@
+package alikafka
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/config"
+)
+
+// the headers a parked message gets on top of its own
+const (
+	HeaderDLQError     = "dlq-error"
+	HeaderDLQTopic     = "dlq-topic"
+	HeaderDLQPartition = "dlq-partition"
+	HeaderDLQOffset    = "dlq-offset"
+)
+
+var ErrNoDLQ = errors.New("no dlq topic")
+
+// Park copies msg with the reason to the dlq topic and returns once kafka acked it, the offset of
+// msg can be committed after
+func Park(msg *kafka.Message, reason error) error {
+	topic := config.GetKafkaConfig().DLQTopic
+	if topic == "" {
+		return ErrNoDLQ
+	}
+
+	from := ""
+	if msg.TopicPartition.Topic != nil {
+		from = *msg.TopicPartition.Topic
+	}
+
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQError, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(from)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(int(msg.TopicPartition.Partition)))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(msg.TopicPartition.Offset.String())},
+	)
+
+	report := make(chan kafka.Event, 1)
+	err := GetKafkaProInst().Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	}, report)
+	if err != nil {
+		return fmt.Errorf("park message failed: %w", err)
+	}
+
+	ev := (<-report).(*kafka.Message)
+	if ev.TopicPartition.Error != nil {
+		return fmt.Errorf("park message failed: %w", ev.TopicPartition.Error)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package alikafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+// Headers puts the contract headers of solmsg on a kafka message
+func Headers(in []solmsg.Header) []kafka.Header {
+	res := make([]kafka.Header, 0, len(in))
+	for _, v := range in {
+		res = append(res, kafka.Header(v))
+	}
+	return res
+}
+
+// ContractHeaders reads the headers of a received message for solmsg.Unmarshal
+func ContractHeaders(msg *kafka.Message) []solmsg.Header {
+	res := make([]solmsg.Header, 0, len(msg.Headers))
+	for _, v := range msg.Headers {
+		res = append(res, solmsg.Header(v))
+	}
+	return res
+}
@@ -42,8 +42,7 @@ func GetKafkaInst() *kafka.Consumer {
 		var kafkaconf = &kafka.ConfigMap{
 			"api.version.request":       "true",
 			"auto.offset.reset":         "latest",
-			"enable.auto.commit":        true,
-			"auto.commit.interval.ms":   1000,
+			"enable.auto.commit":        false,
 			"heartbeat.interval.ms":     3000,
 			"session.timeout.ms":        30000,
 			"max.poll.interval.ms":      120000,
@@ -94,8 +93,7 @@ func GetKafkaExKOLInst() *kafka.Consumer {
 		var kafkaconf = &kafka.ConfigMap{
 			"api.version.request":       "true",
 			"auto.offset.reset":         "latest",
-			"enable.auto.commit":        true,
-			"auto.commit.interval.ms":   1000,
+			"enable.auto.commit":        false,
 			"heartbeat.interval.ms":     3000,
 			"session.timeout.ms":        30000,
 			"max.poll.interval.ms":      120000,
@@ -146,8 +144,7 @@ func GetKafkaHistoryInst() *kafka.Consumer {
 		var kafkaconf = &kafka.ConfigMap{
 			"api.version.request":       "true",
 			"auto.offset.reset":         "latest",
-			"enable.auto.commit":        true,
-			"auto.commit.interval.ms":   1000,
+			"enable.auto.commit":        false,
 			"heartbeat.interval.ms":     3000,
 			"session.timeout.ms":        30000,
 			"max.poll.interval.ms":      120000,
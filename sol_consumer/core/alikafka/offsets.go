@@ -0,0 +1,245 @@
+package alikafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+)
+
+const (
+	commitInterval = time.Second
+	// drainTimeout stays under max.poll.interval.ms, a rebalance waits this long for in flight work
+	drainTimeout = 60 * time.Second
+)
+
+type committer interface {
+	CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+type partitionOffsets struct {
+	// inflight holds the offsets read and not committable yet, in read order
+	inflight []kafka.Offset
+	done     map[kafka.Offset]bool
+	// next is the offset to commit, the one after the last message done with every one before it
+	next  kafka.Offset
+	dirty bool
+}
+
+// Offsets commits a consumer by hand. every message read is tracked and marked done once its
+// handlers succeeded or it is parked in the dlq. workers finish messages in any order, a
+// partition only commits up to the first message still in flight, so a crash redelivers what
+// was not done and nothing is lost. messages can be handled twice, never skipped
+type Offsets struct {
+	consumer committer
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	parts map[partitionKey]*partitionOffsets
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewOffsets(consumer committer) *Offsets {
+	o := &Offsets{
+		consumer: consumer,
+		parts:    make(map[partitionKey]*partitionOffsets),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+func keyOf(tp kafka.TopicPartition) partitionKey {
+	topic := ""
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return partitionKey{topic: topic, partition: tp.Partition}
+}
+
+// Track registers msg as in flight, call it from the read loop in read order
+func (o *Offsets) Track(msg *kafka.Message) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := keyOf(msg.TopicPartition)
+	part, ok := o.parts[key]
+	if !ok {
+		part = &partitionOffsets{done: make(map[kafka.Offset]bool), next: kafka.OffsetInvalid}
+		o.parts[key] = part
+	}
+	part.inflight = append(part.inflight, msg.TopicPartition.Offset)
+}
+
+// Done marks msg handled, the committable offset moves past every done message at the head
+func (o *Offsets) Done(msg *kafka.Message) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	part, ok := o.parts[keyOf(msg.TopicPartition)]
+	if !ok {
+		// the partition was revoked and forgotten, its new owner handles the message again
+		return
+	}
+
+	part.done[msg.TopicPartition.Offset] = true
+	for len(part.inflight) > 0 && part.done[part.inflight[0]] {
+		delete(part.done, part.inflight[0])
+		part.next = part.inflight[0] + 1
+		part.inflight = part.inflight[1:]
+		part.dirty = true
+	}
+	o.cond.Broadcast()
+}
+
+// Commit commits the partitions that moved since the last commit
+func (o *Offsets) Commit() error {
+	o.mu.Lock()
+	offsets := make([]kafka.TopicPartition, 0)
+	for key, part := range o.parts {
+		if !part.dirty {
+			continue
+		}
+
+		topic := key.topic
+		offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: key.partition, Offset: part.next})
+		part.dirty = false
+	}
+	o.mu.Unlock()
+
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	_, err := o.consumer.CommitOffsets(offsets)
+	if err != nil {
+		// the next commit sends them again
+		o.mu.Lock()
+		for _, v := range offsets {
+			if part, ok := o.parts[keyOf(v)]; ok && part.next == v.Offset {
+				part.dirty = true
+			}
+		}
+		o.mu.Unlock()
+	}
+	return err
+}
+
+func (o *Offsets) idle(keys map[partitionKey]bool) bool {
+	for key, part := range o.parts {
+		if (keys == nil || keys[key]) && len(part.inflight) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Drain waits for the in flight messages of the partitions, all of them for nil, and commits
+// what got done. on ctx end the rest stays uncommitted and is read again
+func (o *Offsets) Drain(ctx context.Context, partitions []kafka.TopicPartition) error {
+	var keys map[partitionKey]bool
+	if partitions != nil {
+		keys = make(map[partitionKey]bool, len(partitions))
+		for _, v := range partitions {
+			keys[keyOf(v)] = true
+		}
+	}
+
+	// wakes the wait below when ctx ends
+	stop := context.AfterFunc(ctx, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.cond.Broadcast()
+	})
+	defer stop()
+
+	o.mu.Lock()
+	for !o.idle(keys) && ctx.Err() == nil {
+		o.cond.Wait()
+	}
+	o.mu.Unlock()
+
+	err := o.Commit()
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Forget drops the partitions after they were revoked, late Done calls for them are ignored
+func (o *Offsets) Forget(partitions []kafka.TopicPartition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, v := range partitions {
+		delete(o.parts, keyOf(v))
+	}
+	o.cond.Broadcast()
+}
+
+// Rebalance is the rebalance callback of the consumer. revoked partitions finish their in
+// flight messages and commit before another consumer gets them
+func (o *Offsets) Rebalance(c *kafka.Consumer, ev kafka.Event) error {
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		o.Forget(e.Partitions)
+	case kafka.RevokedPartitions:
+		if c.AssignmentLost() {
+			// another consumer owns them already, a commit would be refused
+			o.Forget(e.Partitions)
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+
+		err := o.Drain(ctx, e.Partitions)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Partitions": e.Partitions, "ErrMsg": err}).Error("Offsets drain revoked partitions failed")
+		}
+		o.Forget(e.Partitions)
+	}
+	return nil
+}
+
+// Start commits the done messages every commitInterval until Stop
+func (o *Offsets) Start() {
+	go func() {
+		defer close(o.done)
+
+		ticker := time.NewTicker(commitInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-o.stop:
+				return
+			case <-ticker.C:
+				err := o.Commit()
+				if err != nil {
+					logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("Offsets commit failed")
+				}
+			}
+		}
+	}()
+}
+
+// Stop drains every partition, commits and stops the commit loop
+func (o *Offsets) Stop(ctx context.Context) error {
+	err := o.Drain(ctx, nil)
+
+	close(o.stop)
+	<-o.done
+	return err
+}
@@ -0,0 +1,121 @@
+package alikafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+type fakeCommitter struct {
+	mu      sync.Mutex
+	fail    bool
+	commits map[int32]kafka.Offset
+}
+
+func (c *fakeCommitter) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fail {
+		return nil, errors.New("coordinator moved")
+	}
+	for _, v := range offsets {
+		c.commits[v.Partition] = v.Offset
+	}
+	return offsets, nil
+}
+
+func (c *fakeCommitter) committed(partition int32) kafka.Offset {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.commits[partition]
+	if !ok {
+		return kafka.OffsetInvalid
+	}
+	return v
+}
+
+func testMessage(partition int32, offset kafka.Offset) *kafka.Message {
+	topic := "swap"
+	return &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset}}
+}
+
+func TestOffsetsCommitInOrder(t *testing.T) {
+	c := &fakeCommitter{commits: make(map[int32]kafka.Offset)}
+	o := NewOffsets(c)
+
+	msgs := []*kafka.Message{testMessage(0, 10), testMessage(0, 11), testMessage(0, 12), testMessage(1, 5)}
+	for _, v := range msgs {
+		o.Track(v)
+	}
+
+	// a later message done first commits nothing, the one before it is still in flight
+	o.Done(msgs[1])
+	o.Done(msgs[3])
+	if err := o.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.committed(0); got != kafka.OffsetInvalid {
+		t.Errorf("partition 0 committed %v", got)
+	}
+	if got := c.committed(1); got != 6 {
+		t.Errorf("partition 1 committed %v", got)
+	}
+
+	o.Done(msgs[0])
+	o.Commit()
+	if got := c.committed(0); got != 12 {
+		t.Errorf("partition 0 committed %v, want 12", got)
+	}
+
+	// a failed commit is sent again by the next one
+	c.fail = true
+	o.Done(msgs[2])
+	if err := o.Commit(); err == nil {
+		t.Fatal("commit should fail")
+	}
+	c.fail = false
+	o.Commit()
+	if got := c.committed(0); got != 13 {
+		t.Errorf("partition 0 committed %v, want 13", got)
+	}
+}
+
+func TestOffsetsDrain(t *testing.T) {
+	c := &fakeCommitter{commits: make(map[int32]kafka.Offset)}
+	o := NewOffsets(c)
+
+	slow, other := testMessage(0, 1), testMessage(1, 1)
+	o.Track(slow)
+	o.Track(other)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		o.Done(slow)
+	}()
+
+	// the revoked partition waits for its message, the other one is not waited for
+	err := o.Drain(context.Background(), []kafka.TopicPartition{slow.TopicPartition})
+	if err != nil || c.committed(0) != 2 {
+		t.Fatalf("got %v, committed %v", err, c.committed(0))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := o.Drain(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v", err)
+	}
+
+	// a message done after its partition was revoked is left for the new owner
+	o.Forget([]kafka.TopicPartition{other.TopicPartition})
+	o.Done(other)
+	o.Commit()
+	if got := c.committed(1); got != kafka.OffsetInvalid {
+		t.Errorf("forgotten partition committed %v", got)
+	}
+}
@@ -3,32 +3,49 @@ package model
 import (
 	"time"
 
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
 	"github.com/uptrace/bun"
 )
 
-type SolSwapData struct {
-	TxHash    string `json:"tx_hash"`
-	Source    string `json:"source"`
-	Timestamp int    `json:"timestamp"`
-	Type      string `json:"type"`
-	Date      string `json:"date"`
-
-	FromToken        string  `json:"from_token"`
-	FromTokenAccount string  `json:"fromTokenAccount"`
-	FromUserAccount  string  `json:"fromUserAccount"`
-	FromTokenAmount  float64 `json:"from_token_amount"`
-
-	ToToken        string  `json:"to_token"`
-	ToTokenAccount string  `json:"toTokenAccount"`
-	ToUserAccount  string  `json:"toUserAccount"`
-	ToTokenAmount  float64 `json:"to_token_amount"`
-
-	TradeLabel string `json:"trade_label"`
-	IsDCATrade bool   `json:"is_dca_trade"`
+// the kafka payloads are the shared contract of solmsg, the names stay for the code using them
+const (
+	LabelError             = solmsg.LabelError
+	LabelNone              = solmsg.LabelNone
+	LabelFirstBuy          = solmsg.LabelFirstBuy
+	LabelFreshBuy          = solmsg.LabelFreshBuy
+	LabelSellAll           = solmsg.LabelSellAll
+	LabelAccumulate        = solmsg.LabelAccumulate
+	LabelReEntry           = solmsg.LabelReEntry
+	LabelPartialSell       = solmsg.LabelPartialSell
+	LabelRoundTrip         = solmsg.LabelRoundTrip
+	DCAEventOpen           = solmsg.DCAEventOpen
+	DCAEventFill           = solmsg.DCAEventFill
+	DCAEventClose          = solmsg.DCAEventClose
+	DCAEventWithdraw       = solmsg.DCAEventWithdraw
+	DCAEventEndAndClose    = solmsg.DCAEventEndAndClose
+	LimitOrderEventPlace   = solmsg.LimitOrderEventPlace
+	LimitOrderEventCancel  = solmsg.LimitOrderEventCancel
+	LimitOrderEventFill    = solmsg.LimitOrderEventFill
+	PumpCurveEventBuy      = solmsg.PumpCurveEventBuy
+	PumpCurveEventSell     = solmsg.PumpCurveEventSell
+	PumpCurveEventComplete = solmsg.PumpCurveEventComplete
+	PumpCurveEventMigrate  = solmsg.PumpCurveEventMigrate
+	MintBurnEventMint      = solmsg.MintBurnEventMint
+	MintBurnEventBurn      = solmsg.MintBurnEventBurn
+	LiquidityEventAdd      = solmsg.LiquidityEventAdd
+	LiquidityEventRemove   = solmsg.LiquidityEventRemove
+)
 
-	WalletCounts    int           `json:"wallet_counts"`
-	TransferDetails []SolSwapData `json:"transfer_details"`
-}
+type (
+	SolSwapData     = solmsg.SolSwapData
+	PositionInfo    = solmsg.PositionInfo
+	DCAEvent        = solmsg.DCAEvent
+	RouteLeg        = solmsg.RouteLeg
+	LimitOrderEvent = solmsg.LimitOrderEvent
+	PumpCurve       = solmsg.PumpCurve
+	MintBurnEvent   = solmsg.MintBurnEvent
+	LiquidityEvent  = solmsg.LiquidityEvent
+)
 
 type SolTrackedInfo struct {
 	Label string `json:"label"`
@@ -75,6 +92,19 @@ type SolTrackedAddress struct {
 	TgTxFirstBuy bool `bun:"tg_push_first_buy"`
 	TgTxFreshBuy bool `bun:"tg_push_fresh_buy"`
 	TgTxSellAll  bool `bun:"tg_push_sell_all"`
+
+	TgTxAccumulate  bool `bun:"tg_push_accumulate"`
+	TgTxReEntry     bool `bun:"tg_push_re_entry"`
+	TgTxPartialSell bool `bun:"tg_push_partial_sell"`
+	TgTxRoundTrip   bool `bun:"tg_push_round_trip"`
+
+	TgTxDCAOpen     bool `bun:"tg_push_dca_open"`
+	TgTxDCAComplete bool `bun:"tg_push_dca_complete"`
+	TgTxLimitPlace  bool `bun:"tg_push_limit_place"`
+	TgTxLimitFill   bool `bun:"tg_push_limit_fill"`
+	TgTxGraduate    bool `bun:"tg_push_graduate"`
+	TgTxMintBurn    bool `bun:"tg_push_mint_burn"`
+	TgTxLiquidity   bool `bun:"tg_push_liquidity"`
 }
 
 type TgBotInfo struct {
@@ -160,12 +190,15 @@ type SolTxRecord struct {
 	FromUserAccount  string  `bun:"from_user_account,pk,notnull"`
 	FromTokenAmount  float64 `bun:"from_token_amount"`
 	FromTokenSymbol  string  `bun:"from_token_symbol"`
+	// FromTokenExact is the exact decimal amount, from_token_amount stays for old readers
+	FromTokenExact string `bun:"from_token_exact"`
 
 	ToToken        string  `bun:"to_token,pk,notnull"`
 	ToTokenAccount string  `bun:"to_token_account"`
 	ToUserAccount  string  `bun:"to_user_account,pk,notnull"`
 	ToTokenAmount  float64 `bun:"to_token_amount"`
 	ToTokenSymbol  string  `bun:"to_token_symbol"`
+	ToTokenExact   string  `bun:"to_token_exact"`
 
 	CreateAt        time.Time     `bun:"create_at,nullzero"`
 	Value           string        `bun:"value"`
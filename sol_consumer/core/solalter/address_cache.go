@@ -44,6 +44,19 @@ type TrackedAddrCache struct {
 	TgTxFirstBuy bool `json:"tg_push_first_buy"`
 	TgTxFreshBuy bool `json:"tg_push_fresh_buy"`
 	TgTxSellAll  bool `json:"tg_push_sell_all"`
+
+	TgTxAccumulate  bool `json:"tg_push_accumulate"`
+	TgTxReEntry     bool `json:"tg_push_re_entry"`
+	TgTxPartialSell bool `json:"tg_push_partial_sell"`
+	TgTxRoundTrip   bool `json:"tg_push_round_trip"`
+
+	TgTxDCAOpen     bool `json:"tg_push_dca_open"`
+	TgTxDCAComplete bool `json:"tg_push_dca_complete"`
+	TgTxLimitPlace  bool `json:"tg_push_limit_place"`
+	TgTxLimitFill   bool `json:"tg_push_limit_fill"`
+	TgTxGraduate    bool `json:"tg_push_graduate"`
+	TgTxMintBurn    bool `json:"tg_push_mint_burn"`
+	TgTxLiquidity   bool `json:"tg_push_liquidity"`
 }
 
 func delItem(chain, address string) error {
@@ -168,6 +181,17 @@ func UpdateAllTrackAddrCache() error {
 			TgTxFirstBuy:      item.TgTxFirstBuy,
 			TgTxFreshBuy:      item.TgTxFreshBuy,
 			TgTxSellAll:       item.TgTxSellAll,
+			TgTxAccumulate:    item.TgTxAccumulate,
+			TgTxReEntry:       item.TgTxReEntry,
+			TgTxPartialSell:   item.TgTxPartialSell,
+			TgTxRoundTrip:     item.TgTxRoundTrip,
+			TgTxDCAOpen:       item.TgTxDCAOpen,
+			TgTxDCAComplete:   item.TgTxDCAComplete,
+			TgTxLimitPlace:    item.TgTxLimitPlace,
+			TgTxLimitFill:     item.TgTxLimitFill,
+			TgTxGraduate:      item.TgTxGraduate,
+			TgTxMintBurn:      item.TgTxMintBurn,
+			TgTxLiquidity:     item.TgTxLiquidity,
 		}
 
 		cache = append(cache, data)
@@ -230,6 +254,17 @@ func SetTrackAddrCache(chain, targetAddr string) ([]TrackedAddrCache, error) {
 			TgTxFirstBuy:      item.TgTxFirstBuy,
 			TgTxFreshBuy:      item.TgTxFreshBuy,
 			TgTxSellAll:       item.TgTxSellAll,
+			TgTxAccumulate:    item.TgTxAccumulate,
+			TgTxReEntry:       item.TgTxReEntry,
+			TgTxPartialSell:   item.TgTxPartialSell,
+			TgTxRoundTrip:     item.TgTxRoundTrip,
+			TgTxDCAOpen:       item.TgTxDCAOpen,
+			TgTxDCAComplete:   item.TgTxDCAComplete,
+			TgTxLimitPlace:    item.TgTxLimitPlace,
+			TgTxLimitFill:     item.TgTxLimitFill,
+			TgTxGraduate:      item.TgTxGraduate,
+			TgTxMintBurn:      item.TgTxMintBurn,
+			TgTxLiquidity:     item.TgTxLiquidity,
 		}
 
 		resCache = append(resCache, data)
@@ -9,6 +9,7 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/db"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
 )
 
 type SolAltertData struct {
@@ -35,9 +36,13 @@ type SolAltertData struct {
 	HoldersCount     int64  `json:"holder_count"`
 	Direction        string `json:"direction"`
 	TradeLabel       string `json:"trade_label"`
+	BuyCount         int    `json:"buy_count,omitempty"`
+	SellPercent      string `json:"sell_percent,omitempty"`
+	HoldSeconds      int    `json:"hold_seconds,omitempty"`
 	IsDCATrade       bool   `json:"is_dca_trade"`
 	TotalSupply      string `json:"supply"`
 	WalletCounta     int    `json:"wallet_counts"`
+	CurveProgress    string `json:"curve_progress,omitempty"`
 }
 
 type ExchangeAltertData struct {
@@ -58,24 +63,7 @@ type KOLAltertData struct {
 	TgPushCA   bool   `json:"tg_push"`
 }
 
-type RawBitqueryAltertData struct {
-	DEX string `json:"dex"`
-
-	Chain     string `json:"chain"`
-	TxHash    string `json:"hash"`
-	Timestamp string `json:"timestamp"`
-
-	FromAddress     string `json:"from_address"`
-	FromToken       string `json:"from_token_address"`
-	FromTokenSymbol string `json:"from_token_symbol"`
-	FromTokenAmount string `json:"from_token_amount"`
-	ToAddress       string `json:"to_address"`
-	ToToken         string `json:"to_token_address"`
-	ToTokenSymbol   string `json:"to_token_symbol"`
-	ToTokenAmount   string `json:"to_token_amount"`
-	Value           string `json:"value"`
-	Signer          string `json:"signer"`
-}
+type RawBitqueryAltertData = solmsg.RawBitqueryAltertData
 
 func InsertAlertRecord(txs *model.SolAlterRecord) error {
 
@@ -1,17 +1,20 @@
 package solalter
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/sirupsen/logrus"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/config"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/alikafka"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
 )
 
 type AlterService struct {
@@ -21,8 +24,25 @@ type AlterService struct {
 	TxHashMap    sync.Map
 	Mutex        sync.Mutex
 	ServerConfig string
+
+	// txMsgMap holds the kafka messages of the legs in TxHashMap, under Mutex too
+	txMsgMap        sync.Map
+	bitqueryOffsets *alikafka.Offsets
+
+	offsets  []*alikafka.Offsets
+	readers  sync.WaitGroup
+	stopping atomic.Bool
 }
 
+const (
+	// readTimeout bounds a read so the loops see Close
+	readTimeout  = time.Second
+	closeTimeout = 30 * time.Second
+
+	parkAttempts = 3
+	parkBackoff  = time.Second
+)
+
 func NewAlterService() *AlterService {
 	rule := make(map[string]bool)
 
@@ -43,6 +63,7 @@ func (serv *AlterService) Start() {
 	if strings.Contains(serv.ServerConfig, "sol") {
 		serv.SubSolSwap()
 		serv.SubSolHistoryTxs()
+		ListenTrackAddrChanges()
 
 		ticker := time.NewTicker(10 * time.Minute)
 
@@ -98,249 +119,345 @@ func (serv *AlterService) Start() {
 	}
 }
 
+// Close stops reading, lets the handlers finish what was read and commits it before the
+// consumers close
 func (serv *AlterService) Close() {
+	serv.stopping.Store(true)
+	serv.readers.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	for _, offsets := range serv.offsets {
+		err := offsets.Stop(ctx)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("Close drain kafka messages failed")
+		}
+	}
+
 	alikafka.GetKafkaInst().Close()
 	alikafka.GetKafkaExKOLInst().Close()
 	alikafka.GetKafkaHistoryInst().Close()
 	alikafka.GetKafkaBitqueryInst().Close()
 }
 
-func (serv *AlterService) SubSolSwap() {
-	go func() {
-		cfg := config.GetKafkaConfig()
-		consumer := alikafka.GetKafkaInst()
+// consume reads topic until Close and hands every message to handle in read order. the
+// offset of a message commits after handle passed it to finish, see alikafka.Offsets
+func (serv *AlterService) consume(name string, consumer *kafka.Consumer, topic string, handle func(msg *kafka.Message, offsets *alikafka.Offsets)) *alikafka.Offsets {
+	offsets := alikafka.NewOffsets(consumer)
+	serv.offsets = append(serv.offsets, offsets)
 
-		consumer.SubscribeTopics([]string{cfg.Topic}, nil)
+	consumer.SubscribeTopics([]string{topic}, offsets.Rebalance)
+	offsets.Start()
 
-		semaphore := make(chan struct{}, serv.MaxNum)
+	serv.readers.Add(1)
+	go func() {
+		defer serv.readers.Done()
 
-		for {
-			msg, err := consumer.ReadMessage(-1)
+		for !serv.stopping.Load() {
+			msg, err := consumer.ReadMessage(readTimeout)
 			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubSolSwap read kafka message failed")
-				continue
-			}
+				var kerr kafka.Error
+				if errors.As(err, &kerr) && kerr.IsTimeout() {
+					continue
+				}
 
-			rawdata := msg.Value
-			var res []model.SolSwapData
-			err = json.Unmarshal(rawdata, &res)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Data": rawdata}).Error("SubSolSwap unmarshal kafka message failed")
+				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error(name + " read kafka message failed")
 				continue
 			}
 
-			logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubSolSwap receive kafka message success")
-
-			go func(data []model.SolSwapData) {
-				addrMap := make(map[string]string, 0)
-				for _, v := range data {
-					address := v.FromUserAccount
-					txhash := v.TxHash
-
-					if address != "" && txhash != "" {
-						addrMap[address] = txhash
-					}
-				}
-
-				for k, v := range addrMap {
-					err := CheckAddressRateLimit("solana", k, v)
-					if err != nil {
-						logger.Logrus.WithFields(logrus.Fields{"Address": k, "TxHash": v, "ErrMsg": err}).Error("SubSolSwap CheckAddressRateLimit failed")
-						continue
-					}
-				}
-
-				err = handleSolSaveRecord(data, serv.TokenRule)
-				if err != nil {
-					logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("SubSolSwap handleSolSaveRecord failed")
-					return
-				}
-
-				logger.Logrus.WithFields(logrus.Fields{"Data": data}).Info("SubSolSwap check blacklist ratelimit and insert record success")
-			}(res)
-
-			for _, data := range res {
-				semaphore <- struct{}{}
+			offsets.Track(msg)
+			handle(msg, offsets)
+		}
+	}()
+	return offsets
+}
 
-				go func(data model.SolSwapData) {
-					defer func() { <-semaphore }()
+// finish marks msg done, a failed one after it is parked in the dlq. parking is tried
+// parkAttempts times, after that the message is logged with its payload and skipped, its offset
+// commits and it is not handled again. it is never left uncommitted, that would stop the commits
+// of its partition behind it until a restart, which reads it and stalls again
+func finish(name string, offsets *alikafka.Offsets, msg *kafka.Message, err error) {
+	finishPart(name, offsets, msg, msg, err)
+}
 
-					startTime := time.Now().Unix()
-					err := handleAddressSwapRule(data, serv.TokenRule)
-					if err != nil && !errors.Is(err, ErrRateLimit) {
-						err = handleAddressSwapRule(data, serv.TokenRule)
-						if err != nil {
-							logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("SubSolSwap handle swap rule failed")
-							return
-						}
-					}
+// finishPart is finish parking part in place of msg, a message with the part of the batch of msg
+// that failed
+func finishPart(name string, offsets *alikafka.Offsets, msg, part *kafka.Message, err error) {
+	if err != nil {
+		var perr error
+		wait := parkBackoff
+		for i := 0; i < parkAttempts; i++ {
+			if i > 0 {
+				time.Sleep(wait)
+				wait *= 2
+			}
 
-					endTime := time.Now().Unix()
-					logger.Logrus.WithFields(logrus.Fields{"TimeINterval: s": endTime - startTime, "Data": data}).Info("SubSolSwap handle swap rule success")
-				}(data)
+			perr = alikafka.Park(part, err)
+			if perr == nil {
+				break
 			}
 		}
-	}()
+
+		if perr != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Offset": msg.TopicPartition, "Key": string(msg.Key), "Data": string(part.Value), "ErrMsg": err, "ParkErr": perr}).Error(name + " park failed message failed, message skipped")
+		} else {
+			logger.Logrus.WithFields(logrus.Fields{"Offset": msg.TopicPartition, "ErrMsg": err}).Warn(name + " parked failed message")
+		}
+	}
+	offsets.Done(msg)
 }
 
-func (serv *AlterService) SubExchange() {
-	go func() {
+// SubSolSwap records and alerts the live swaps, the batches of one wallet in the order they were
+// traded and different wallets in parallel, see walletShards
+func (serv *AlterService) SubSolSwap() {
+	cfg := config.GetKafkaConfig()
+	shards := newWalletShards(serv.MaxNum)
+
+	serv.consume("SubSolSwap", alikafka.GetKafkaInst(), cfg.Topic, func(msg *kafka.Message, offsets *alikafka.Offsets) {
+		rawdata := msg.Value
+		var res []model.SolSwapData
+		_, err := solmsg.Unmarshal(solmsg.SolSwapBatch, alikafka.ContractHeaders(msg), rawdata, &res)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Data": rawdata}).Error("SubSolSwap unmarshal kafka message failed")
+			finish("SubSolSwap", offsets, msg, err)
+			return
+		}
 
-		cfg := config.GetKafkaConfig()
-		consumer := alikafka.GetKafkaExKOLInst()
+		logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubSolSwap receive kafka message success")
 
-		consumer.SubscribeTopics([]string{cfg.ExKOLTopic}, nil)
-		semaphore := make(chan struct{}, serv.MaxNum)
+		shards.Submit(batchWallet(res), func() {
+			failed, err := serv.handleSolSwapBatch(res)
+			finishPart("SubSolSwap", offsets, msg, swapPart(msg, res, failed), err)
+		})
+	})
+}
 
-		for {
-			msg, err := consumer.ReadMessage(-1)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange read kafka message failed")
-				continue
-			}
+// swapPart is msg with only the failed swaps of its batch, replaying it does not alert the
+// others again. msg itself when the whole batch failed or the part cannot be encoded
+func swapPart(msg *kafka.Message, batch, failed []model.SolSwapData) *kafka.Message {
+	if len(failed) == 0 || len(failed) == len(batch) {
+		return msg
+	}
 
-			rawdata := msg.Value
-			var res RawTopicData
-			err = json.Unmarshal(rawdata, &res)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
-				continue
-			}
+	data, headers, err := solmsg.Marshal(solmsg.SolSwapBatch, failed)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Offset": msg.TopicPartition, "ErrMsg": err}).Error("SubSolSwap encode failed swaps failed, whole batch parked")
+		return msg
+	}
 
-			logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubExchange receive kafka message success")
+	part := *msg
+	part.Value = data
+	part.Headers = alikafka.Headers(headers)
+	return &part
+}
 
-			semaphore <- struct{}{}
+// handleSolSwapBatch saves the batch before its alerts go out, one swap after another. every
+// swap is tried, failed holds the swaps to park, all of them when the save failed since none was
+// alerted then, else the ones whose alert failed. err is the first failure
+func (serv *AlterService) handleSolSwapBatch(data []model.SolSwapData) (failed []model.SolSwapData, err error) {
+	addrMap := make(map[string]string, 0)
+	for _, v := range data {
+		address := v.FromUserAccount
+		txhash := v.TxHash
+
+		if address != "" && txhash != "" {
+			addrMap[address] = txhash
+		}
+	}
 
-			go func(data RawTopicData) {
-				defer func() { <-semaphore }()
+	for k, v := range addrMap {
+		err := CheckAddressRateLimit("solana", k, v)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Address": k, "TxHash": v, "ErrMsg": err}).Error("SubSolSwap CheckAddressRateLimit failed")
+			continue
+		}
+	}
 
-				if res.Type == "exchange" {
-					val, err := res.Unmarshall()
-					if err != nil {
-						logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
-						return
-					}
+	err = handleSolSaveRecord(data, serv.TokenRule)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("SubSolSwap handleSolSaveRecord failed")
+		return data, err
+	}
+	logger.Logrus.WithFields(logrus.Fields{"Data": data}).Info("SubSolSwap check blacklist ratelimit and insert record success")
 
-					err = handleExchangeAlert(val)
-					if err != nil {
-						err = handleExchangeAlert(val)
-						if err != nil {
-							logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleExchangeAlert failed")
-							return
-						}
-					}
+	return alertSwaps(data, func(v model.SolSwapData) error {
+		return handleAddressSwapRule(v, serv.TokenRule)
+	})
+}
 
-					logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleExchangeAlert success")
+// skippedAlert is an alert that is not sent and never will be, a rate limited wallet or one no
+// user tracks. it is not a failure
+func skippedAlert(err error) bool {
+	return errors.Is(err, ErrRateLimit) || errors.Is(err, ErrNotRegistered) || errors.Is(err, ErrNotFound)
+}
+
+// alertSwaps alerts every swap of data, a failed one is tried again once. failed holds the swaps
+// whose alert failed both times
+func alertSwaps(data []model.SolSwapData, alert func(model.SolSwapData) error) (failed []model.SolSwapData, res error) {
+	for _, v := range data {
+		startTime := time.Now().Unix()
+		err := alert(v)
+		if err != nil && !skippedAlert(err) {
+			err = alert(v)
+			if err != nil && !skippedAlert(err) {
+				logger.Logrus.WithFields(logrus.Fields{"Data": v, "ErrMsg": err}).Error("SubSolSwap handle swap rule failed")
+				failed = append(failed, v)
+				if res == nil {
+					res = err
 				}
+				continue
+			}
+		}
 
-				if res.Type == "KOL" {
-					val, err := res.UnmarshallKOL()
-					if err != nil {
-						logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
-						return
-					}
+		endTime := time.Now().Unix()
+		logger.Logrus.WithFields(logrus.Fields{"TimeINterval: s": endTime - startTime, "Data": v}).Info("SubSolSwap handle swap rule success")
+	}
 
-					err = handleKOLAlert(val)
-					if err != nil {
-						err = handleKOLAlert(val)
-						if err != nil {
-							logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleKOLAlert failed")
-							return
-						}
-					}
+	return failed, res
+}
 
-					logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleKOLAlert success")
-				}
+func (serv *AlterService) SubExchange() {
+	cfg := config.GetKafkaConfig()
+	semaphore := make(chan struct{}, serv.MaxNum)
+
+	serv.consume("SubExchange", alikafka.GetKafkaExKOLInst(), cfg.ExKOLTopic, func(msg *kafka.Message, offsets *alikafka.Offsets) {
+		rawdata := msg.Value
+		var res RawTopicData
+		_, err := solmsg.Unmarshal(solmsg.TopicData, alikafka.ContractHeaders(msg), rawdata, &res)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
+			finish("SubExchange", offsets, msg, err)
+			return
+		}
 
-				if res.Type == "publish_call" {
-					val, err := res.UnmarshallCurated()
-					if err != nil {
-						logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
-						return
-					}
+		logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubExchange receive kafka message success")
 
-					err = handleCuratedCalls(val)
-					if err != nil {
-						err = handleCuratedCalls(val)
-						if err != nil {
-							logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleCuratedCalls failed")
-							return
-						}
-					}
+		semaphore <- struct{}{}
 
-					logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleCuratedCalls success")
+		go func() {
+			defer func() { <-semaphore }()
 
-				}
+			err := handleTopicData(res)
+			finish("SubExchange", offsets, msg, err)
+		}()
+	})
+}
 
-				if res.Type == "fomo_call" {
-					val, err := res.UnmarshallFomo()
-					if err != nil {
-						logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
-						return
-					}
+// handleTopicData runs the handler of the message type, a failed handler gets one retry
+func handleTopicData(res RawTopicData) error {
+	if res.Type == "exchange" {
+		val, err := res.Unmarshall()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
+			return err
+		}
 
-					err = handleFomoCalls(val)
-					if err != nil {
-						err = handleFomoCalls(val)
-						if err != nil {
-							logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleFomoCalls failed")
-							return
-						}
-					}
+		err = handleExchangeAlert(val)
+		if err != nil {
+			err = handleExchangeAlert(val)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleExchangeAlert failed")
+				return err
+			}
+		}
 
-					logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleFomoCalls success")
+		logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleExchangeAlert success")
+	}
 
-				}
-			}(res)
+	if res.Type == "KOL" {
+		val, err := res.UnmarshallKOL()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
+			return err
 		}
-	}()
-}
 
-func (serv *AlterService) SubSolHistoryTxs() {
-	go func() {
-
-		cfg := config.GetKafkaConfig()
-		consumer := alikafka.GetKafkaHistoryInst()
+		err = handleKOLAlert(val)
+		if err != nil {
+			err = handleKOLAlert(val)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleKOLAlert failed")
+				return err
+			}
+		}
 
-		consumer.SubscribeTopics([]string{cfg.HistoryTopic}, nil)
+		logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleKOLAlert success")
+	}
 
-		semaphore := make(chan struct{}, serv.MaxNum)
+	if res.Type == "publish_call" {
+		val, err := res.UnmarshallCurated()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
+			return err
+		}
 
-		for {
-			msg, err := consumer.ReadMessage(-1)
+		err = handleCuratedCalls(val)
+		if err != nil {
+			err = handleCuratedCalls(val)
 			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubSolHistoryTxs read kafka message failed")
-				continue
+				logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleCuratedCalls failed")
+				return err
 			}
+		}
+
+		logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleCuratedCalls success")
 
-			rawdata := msg.Value
+	}
+
+	if res.Type == "fomo_call" {
+		val, err := res.UnmarshallFomo()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubExchange unmarshal kafka message failed")
+			return err
+		}
 
-			var res []model.SolSwapData
-			err = json.Unmarshal(rawdata, &res)
+		err = handleFomoCalls(val)
+		if err != nil {
+			err = handleFomoCalls(val)
 			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Data": rawdata}).Error("SubSolHistoryTxs unmarshal kafka message failed")
-				continue
+				logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("SubExchange handleFomoCalls failed")
+				return err
 			}
+		}
 
-			logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubSolHistoryTxs receive kafka message success")
-
-			semaphore <- struct{}{}
+		logger.Logrus.WithFields(logrus.Fields{"Data": val}).Info("SubExchange handleFomoCalls success")
 
-			go func(data []model.SolSwapData) {
-				defer func() { <-semaphore }()
+	}
 
-				err := HandleHeliusHisData(data, serv.TokenRule)
-				if err != nil {
-					logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("SubSolHistoryTxs HandleHeliusHisData failed")
-					return
-				}
+	return nil
+}
 
-				logger.Logrus.WithFields(logrus.Fields{"Data": data}).Info("SubSolHistoryTxs HandleHeliusHisData success")
-			}(res)
+func (serv *AlterService) SubSolHistoryTxs() {
+	cfg := config.GetKafkaConfig()
+	shards := newWalletShards(serv.MaxNum)
+
+	serv.consume("SubSolHistoryTxs", alikafka.GetKafkaHistoryInst(), cfg.HistoryTopic, func(msg *kafka.Message, offsets *alikafka.Offsets) {
+		rawdata := msg.Value
+
+		var res []model.SolSwapData
+		_, err := solmsg.Unmarshal(solmsg.SolSwapBatch, alikafka.ContractHeaders(msg), rawdata, &res)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Data": rawdata}).Error("SubSolHistoryTxs unmarshal kafka message failed")
+			finish("SubSolHistoryTxs", offsets, msg, err)
+			return
 		}
-	}()
+
+		logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubSolHistoryTxs receive kafka message success")
+
+		shards.Submit(batchWallet(res), func() {
+			err := HandleHeliusHisData(res, serv.TokenRule)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"Data": res, "ErrMsg": err}).Error("SubSolHistoryTxs HandleHeliusHisData failed")
+			} else {
+				logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubSolHistoryTxs HandleHeliusHisData success")
+			}
+			finish("SubSolHistoryTxs", offsets, msg, err)
+		})
+	})
 }
 
+// HandleEvmTxMerge merges the legs SubBitQuery collected per tx hash, the messages of the legs
+// are done once the merged tx is saved and alerted
 func (serv *AlterService) HandleEvmTxMerge() {
 	go func() {
 		ticker := time.NewTicker(time.Duration(serv.MInterval) * time.Second)
@@ -366,29 +483,13 @@ func (serv *AlterService) HandleEvmTxMerge() {
 
 				logger.Logrus.WithFields(logrus.Fields{"Data": txlist}).Info("handleEvmTxMerge info")
 
-				go func(data []RawBitqueryAltertData) {
-					err := handleEVMSaveRecord(data, serv.TokenRule)
-					if err != nil {
-						logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("HandleEvmTxMerge handleEVMSaveRecord failed")
-						return
+				msgs, _ := serv.txMsgMap.LoadAndDelete(txhash)
+				go func(data []RawBitqueryAltertData, msgs []*kafka.Message) {
+					err := serv.handleEvmTx(data)
+					for _, msg := range msgs {
+						finish("SubBitQuery", serv.bitqueryOffsets, msg, err)
 					}
-
-					logger.Logrus.WithFields(logrus.Fields{"Data": data}).Info("HandleEvmTxMerge handleEVMSaveRecord success")
-				}(handleData)
-
-				for _, v := range handleData {
-					go func(data RawBitqueryAltertData) {
-						startTime := time.Now().Unix()
-						err := handleBitQueryRule(data, serv.TokenRule)
-						if err != nil {
-							logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("SubBitQuery handle swap rule failed")
-							return
-						}
-
-						endTime := time.Now().Unix()
-						logger.Logrus.WithFields(logrus.Fields{"TimeINterval: s": endTime - startTime, "Data": data}).Info("SubBitQuery handle swap rule success")
-					}(v)
-				}
+				}(handleData, asMessages(msgs))
 
 				serv.TxHashMap.Delete(txhash)
 				return true
@@ -399,48 +500,91 @@ func (serv *AlterService) HandleEvmTxMerge() {
 	}()
 }
 
-func (serv *AlterService) SubBitQuery() {
+func asMessages(v any) []*kafka.Message {
+	msgs, _ := v.([]*kafka.Message)
+	return msgs
+}
+
+// handleEvmTx saves the legs and runs the rule of each in parallel, the error is the first failure
+func (serv *AlterService) handleEvmTx(data []RawBitqueryAltertData) error {
+	var mu sync.Mutex
+	var res error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if res == nil {
+			res = err
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		cfg := config.GetKafkaConfig()
-		consumer := alikafka.GetKafkaBitqueryInst()
+		defer wg.Done()
 
-		consumer.SubscribeTopics([]string{cfg.BitQueryTopic}, nil)
+		err := handleEVMSaveRecord(data, serv.TokenRule)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("HandleEvmTxMerge handleEVMSaveRecord failed")
+			fail(err)
+			return
+		}
 
-		for {
-			msg, err := consumer.ReadMessage(-1)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubBitQuery read kafka message failed")
-				continue
-			}
+		logger.Logrus.WithFields(logrus.Fields{"Data": data}).Info("HandleEvmTxMerge handleEVMSaveRecord success")
+	}()
 
-			rawdata := msg.Value
+	for _, v := range data {
+		wg.Add(1)
+		go func(data RawBitqueryAltertData) {
+			defer wg.Done()
 
-			var res RawBitqueryAltertData
-			err = json.Unmarshal(rawdata, &res)
+			startTime := time.Now().Unix()
+			err := handleBitQueryRule(data, serv.TokenRule)
 			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubBitQuery unmarshal kafka message failed")
-				continue
+				logger.Logrus.WithFields(logrus.Fields{"Data": data, "ErrMsg": err}).Error("SubBitQuery handle swap rule failed")
+				fail(err)
+				return
 			}
 
-			logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubBitQuery receive kafka message success")
+			endTime := time.Now().Unix()
+			logger.Logrus.WithFields(logrus.Fields{"TimeINterval: s": endTime - startTime, "Data": data}).Info("SubBitQuery handle swap rule success")
+		}(v)
+	}
 
-			serv.Mutex.Lock()
+	wg.Wait()
+	return res
+}
 
-			if value, ok := serv.TxHashMap.Load(res.TxHash); ok {
-				txlist := value.([]RawBitqueryAltertData)
-				txlist = append(txlist, res)
-				serv.TxHashMap.Store(res.TxHash, txlist)
-			} else {
-				serv.TxHashMap.Store(res.TxHash, []RawBitqueryAltertData{res})
-			}
+// SubBitQuery collects the legs of each tx hash for HandleEvmTxMerge, a message commits after
+// the merged tx is handled, not when it is read
+func (serv *AlterService) SubBitQuery() {
+	cfg := config.GetKafkaConfig()
 
-			serv.Mutex.Unlock()
+	serv.bitqueryOffsets = serv.consume("SubBitQuery", alikafka.GetKafkaBitqueryInst(), cfg.BitQueryTopic, func(msg *kafka.Message, offsets *alikafka.Offsets) {
+		rawdata := msg.Value
 
-			_, err = consumer.CommitMessage(msg)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubBitQuery CommitMessage failed")
-				continue
-			}
+		var res RawBitqueryAltertData
+		_, err := solmsg.Unmarshal(solmsg.BitqueryTrade, alikafka.ContractHeaders(msg), rawdata, &res)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("SubBitQuery unmarshal kafka message failed")
+			finish("SubBitQuery", offsets, msg, err)
+			return
 		}
-	}()
+
+		logger.Logrus.WithFields(logrus.Fields{"Data": res}).Info("SubBitQuery receive kafka message success")
+
+		serv.Mutex.Lock()
+
+		if value, ok := serv.TxHashMap.Load(res.TxHash); ok {
+			txlist := value.([]RawBitqueryAltertData)
+			txlist = append(txlist, res)
+			serv.TxHashMap.Store(res.TxHash, txlist)
+		} else {
+			serv.TxHashMap.Store(res.TxHash, []RawBitqueryAltertData{res})
+		}
+
+		value, _ := serv.txMsgMap.Load(res.TxHash)
+		serv.txMsgMap.Store(res.TxHash, append(asMessages(value), msg))
+
+		serv.Mutex.Unlock()
+	})
 }
@@ -17,15 +17,72 @@ import (
 )
 
 const (
-	LabelError    string = "error"
-	LabelNone     string = "none"
-	LabelFirstBuy string = "first_buy"
-	LabelFreshBuy string = "fresh_buy"
-	LabelSellAll  string = "sell_all"
+	LabelError       string = "error"
+	LabelNone        string = "none"
+	LabelFirstBuy    string = "first_buy"
+	LabelFreshBuy    string = "fresh_buy"
+	LabelSellAll     string = "sell_all"
+	LabelAccumulate  string = "accumulate"
+	LabelReEntry     string = "re_entry"
+	LabelPartialSell string = "partial_sell"
+	LabelRoundTrip   string = "round_trip"
 )
 
+// pushBuyLabel is true when the address asked for buys of this label, a re-entry is a fresh buy
+// of a token the wallet sold out of before. TgTxFreshBuy has always pushed first buys too, the
+// addresses that set it keep getting them
+func pushBuyLabel(pref TrackedAddrCache, label string) bool {
+	switch label {
+	case LabelFirstBuy:
+		return pref.TgTxFirstBuy || pref.TgTxFreshBuy
+	case LabelFreshBuy:
+		return pref.TgTxFreshBuy
+	case LabelReEntry:
+		return pref.TgTxReEntry || pref.TgTxFreshBuy
+	case LabelAccumulate:
+		return pref.TgTxAccumulate
+	}
+	return false
+}
+
+// pushSellLabel is true when the address asked for sells of this label, a round trip is a sell all
+// soon after the buy
+func pushSellLabel(pref TrackedAddrCache, label string) bool {
+	switch label {
+	case LabelSellAll:
+		return pref.TgTxSellAll
+	case LabelRoundTrip:
+		return pref.TgTxRoundTrip || pref.TgTxSellAll
+	case LabelPartialSell:
+		return pref.TgTxPartialSell
+	}
+	return false
+}
+
+// positionFields spreads the position of a swap over the alert fields, all empty when unknown
+func positionFields(val model.SolSwapData) (int, string, int) {
+	if val.Position == nil {
+		return 0, "", 0
+	}
+
+	percent := ""
+	if val.Position.SellPercent > 0 {
+		percent = strconv.FormatFloat(val.Position.SellPercent, 'f', -1, 64)
+	}
+	return val.Position.BuyCount, percent, val.Position.HoldSeconds
+}
+
 var ErrRateLimit = errors.New("rate limit")
 var ErrNotFound = errors.New("address not found")
+var ErrNotRegistered = errors.New("address not register")
+
+// curveProgress is the pump.fun bonding curve share sold after the trade, empty off the curve
+func curveProgress(val model.SolSwapData) string {
+	if val.PumpCurve == nil {
+		return ""
+	}
+	return strconv.FormatFloat(val.PumpCurve.Progress, 'f', 2, 64)
+}
 
 func checkTimestamp(t int) error {
 	now := time.Now().Unix()
@@ -61,7 +118,7 @@ func handleSOlSend(val model.SolSwapData) error {
 			return fmt.Errorf("to token,%s, %v", totokenAddress, err)
 		}
 
-		fromtokenValue := calswapValue(val.FromTokenAmount, tokenMeta.Price)
+		fromtokenValue := amountValue(fromAmount(val), tokenMeta.Price)
 		alterData := SolAltertData{
 			Source:           val.Source,
 			Date:             val.Date,
@@ -69,11 +126,11 @@ func handleSOlSend(val model.SolSwapData) error {
 			TxHash:           val.TxHash,
 			FromToken:        val.FromToken,
 			FromTokenSymbol:  tokenMeta.Symbol,
-			FromTokenAmount:  strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64),
+			FromTokenAmount:  fromAmountText(val),
 			FromTokenDecimal: tokenMeta.Decimals,
 			ToToken:          val.ToToken,
 			ToTokenSymbol:    tokenMeta.Symbol,
-			ToTokenAmount:    strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64),
+			ToTokenAmount:    toAmountText(val),
 			ToTokenDecimal:   tokenMeta.Decimals,
 			Value:            strconv.FormatFloat(fromtokenValue, 'f', -1, 64),
 			Price:            strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64),
@@ -144,9 +201,9 @@ func handleSOlSend(val model.SolSwapData) error {
 				continue
 			}
 
-			botbody := ConstructSendBotMessage("solana", fromData.Label, val.FromUserAccount, val.ToUserAccount, val.FromToken, strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64), tokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, fromData.ListID, fromData.IsAddrPublic)
+			botbody := ConstructSendBotMessage("solana", fromData.Label, val.FromUserAccount, val.ToUserAccount, val.FromToken, fromAmountText(val), tokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, fromData.ListID, fromData.IsAddrPublic)
 			if val.WalletCounts > 1 {
-				botbody = ConstructSendBotMessageNoTo("solana", fromData.Label, val.FromUserAccount, val.ToUserAccount, val.FromToken, strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64), tokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, fromData.ListID, val.WalletCounts, fromData.IsAddrPublic)
+				botbody = ConstructSendBotMessageNoTo("solana", fromData.Label, val.FromUserAccount, val.ToUserAccount, val.FromToken, fromAmountText(val), tokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, fromData.ListID, val.WalletCounts, fromData.IsAddrPublic)
 			}
 
 			err = HandleTgBotMessage(fromData.ListID, botbody, "Solana", val.FromToken, val.Timestamp, true)
@@ -172,7 +229,7 @@ func handleSOlSend(val model.SolSwapData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", "solana", val.FromUserAccount)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.FromUserAccount)
 }
 
 func handleSolReceived(val model.SolSwapData) error {
@@ -205,7 +262,7 @@ func handleSolReceived(val model.SolSwapData) error {
 			return fmt.Errorf("from token,%s, %v", fromtokenAddress, err)
 		}
 
-		totokenValue := calswapValue(val.ToTokenAmount, tokenMeta.Price)
+		totokenValue := amountValue(toAmount(val), tokenMeta.Price)
 		alterData := SolAltertData{
 			Source:           val.Source,
 			Date:             val.Date,
@@ -213,11 +270,11 @@ func handleSolReceived(val model.SolSwapData) error {
 			TxHash:           val.TxHash,
 			FromToken:        val.FromToken,
 			FromTokenSymbol:  tokenMeta.Symbol,
-			FromTokenAmount:  strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64),
+			FromTokenAmount:  fromAmountText(val),
 			FromTokenDecimal: tokenMeta.Decimals,
 			ToToken:          val.ToToken,
 			ToTokenSymbol:    tokenMeta.Symbol,
-			ToTokenAmount:    strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64),
+			ToTokenAmount:    toAmountText(val),
 			ToTokenDecimal:   tokenMeta.Decimals,
 			Value:            strconv.FormatFloat(totokenValue, 'f', -1, 64),
 			Price:            strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64),
@@ -288,9 +345,9 @@ func handleSolReceived(val model.SolSwapData) error {
 				continue
 			}
 
-			botbody := ConstructReceivedBotMessage("solana", val.FromUserAccount, toData.Label, val.ToUserAccount, val.ToToken, strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64), fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, toData.ListID, toData.IsAddrPublic)
+			botbody := ConstructReceivedBotMessage("solana", val.FromUserAccount, toData.Label, val.ToUserAccount, val.ToToken, toAmountText(val), fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, toData.ListID, toData.IsAddrPublic)
 			if val.WalletCounts > 1 {
-				botbody = ConstructReceivedBotMessageNoFrom("solana", val.FromUserAccount, toData.Label, val.ToUserAccount, val.ToToken, strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64), fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, toData.ListID, val.WalletCounts, toData.IsAddrPublic)
+				botbody = ConstructReceivedBotMessageNoFrom("solana", val.FromUserAccount, toData.Label, val.ToUserAccount, val.ToToken, toAmountText(val), fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), strconv.FormatFloat(tokenMeta.Price, 'f', -1, 64), val.TxHash, toData.ListID, val.WalletCounts, toData.IsAddrPublic)
 			}
 
 			err = HandleTgBotMessage(toData.ListID, botbody, "Solana", val.ToToken, val.Timestamp, true)
@@ -317,7 +374,7 @@ func handleSolReceived(val model.SolSwapData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", "solana", val.ToUserAccount)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.ToUserAccount)
 }
 
 func handleSOlBuyOptimize(val model.SolSwapData) error {
@@ -346,15 +403,16 @@ func handleSOlBuyOptimize(val model.SolSwapData) error {
 			return fmt.Errorf("from coin,%s,%v", val.FromToken, err)
 		}
 
-		fromtokenValue := calswapValue(val.FromTokenAmount, fromtokenMeta.Price)
+		fromtokenValue := amountValue(fromAmount(val), fromtokenMeta.Price)
 
 		mc := strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
 		toprice := strconv.FormatFloat(totokenMeta.Price, 'f', -1, 64)
 		if isFloatEqual(fromtokenValue) && totokenMeta.TotalSupply != "" {
-			toprice = strconv.FormatFloat(fromtokenValue/val.ToTokenAmount, 'f', -1, 64)
+			toprice = strconv.FormatFloat(unitPrice(fromtokenValue, toAmount(val)), 'f', -1, 64)
 			mc = strconv.FormatFloat(calValue(toprice, totokenMeta.TotalSupply), 'f', -1, 64)
 		}
 
+		buyCount, sellPercent, holdSeconds := positionFields(val)
 		alterData := SolAltertData{
 			Source:           val.Source,
 			Date:             val.Date,
@@ -362,11 +420,11 @@ func handleSOlBuyOptimize(val model.SolSwapData) error {
 			TxHash:           val.TxHash,
 			FromToken:        val.FromToken,
 			FromTokenSymbol:  fromtokenMeta.Symbol,
-			FromTokenAmount:  strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64),
+			FromTokenAmount:  fromAmountText(val),
 			FromTokenDecimal: fromtokenMeta.Decimals,
 			ToToken:          val.ToToken,
 			ToTokenSymbol:    totokenMeta.Symbol,
-			ToTokenAmount:    strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64),
+			ToTokenAmount:    toAmountText(val),
 			ToTokenDecimal:   totokenMeta.Decimals,
 			Value:            strconv.FormatFloat(fromtokenValue, 'f', -1, 64),
 			Price:            toprice,
@@ -378,8 +436,12 @@ func handleSOlBuyOptimize(val model.SolSwapData) error {
 			HoldersCount:     totokenMeta.HoldersCount,
 			Direction:        "Bought",
 			TradeLabel:       val.TradeLabel,
+			BuyCount:         buyCount,
+			SellPercent:      sellPercent,
+			HoldSeconds:      holdSeconds,
 			IsDCATrade:       val.IsDCATrade,
 			TotalSupply:      totokenMeta.TotalSupply,
+			CurveProgress:    curveProgress(val),
 		}
 
 		alby, err := json.Marshal(&alterData)
@@ -481,9 +543,7 @@ func handleSOlBuyOptimize(val model.SolSwapData) error {
 
 			if fromData.TgTxBuy {
 				isTgSend = true
-			} else if fromData.TgTxFirstBuy && val.TradeLabel == LabelFirstBuy {
-				isTgSend = true
-			} else if fromData.TgTxFreshBuy && val.TradeLabel == LabelFirstBuy {
+			} else if pushBuyLabel(fromData, val.TradeLabel) {
 				isTgSend = true
 			} else {
 				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Data": fromData, "TradeLabel": val.TradeLabel}).Info("handleSOlBuyOptimize no need push tx to tg bot")
@@ -491,7 +551,7 @@ func handleSOlBuyOptimize(val model.SolSwapData) error {
 			}
 
 			if isTgSend {
-				botbody := ConstructBuyBotMessage("solana", fromData.Label, val.FromUserAccount, strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64), fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64), tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, val.TradeLabel, mc)
+				botbody := ConstructBuyBotMessage("solana", fromData.Label, val.FromUserAccount, fromAmountText(val), fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), toAmountText(val), tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, val.TradeLabel, val.Position, mc)
 
 				err = HandleTgBotMessage(fromData.ListID, botbody, "Solana", totokenAddress, val.Timestamp, true)
 				if err != nil {
@@ -518,7 +578,7 @@ func handleSOlBuyOptimize(val model.SolSwapData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", "solana", val.FromUserAccount)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.FromUserAccount)
 }
 
 func handleSolSoldOptimize(val model.SolSwapData) error {
@@ -547,15 +607,16 @@ func handleSolSoldOptimize(val model.SolSwapData) error {
 			return fmt.Errorf("to coin,%s, %v", val.ToToken, err)
 		}
 
-		totokenValue := calswapValue(val.ToTokenAmount, totokenMeta.Price)
+		totokenValue := amountValue(toAmount(val), totokenMeta.Price)
 
 		mc := strconv.FormatFloat(fromtokenMeta.Mc, 'f', -1, 64)
 		fromprice := strconv.FormatFloat(fromtokenMeta.Price, 'f', -1, 64)
 		if isFloatEqual(totokenValue) && fromtokenMeta.TotalSupply != "" {
-			fromprice := strconv.FormatFloat(totokenValue/val.FromTokenAmount, 'f', -1, 64)
+			fromprice = strconv.FormatFloat(unitPrice(totokenValue, fromAmount(val)), 'f', -1, 64)
 			mc = strconv.FormatFloat(calValue(fromprice, fromtokenMeta.TotalSupply), 'f', -1, 64)
 		}
 
+		buyCount, sellPercent, holdSeconds := positionFields(val)
 		alterData := SolAltertData{
 			Source:           val.Source,
 			Date:             val.Date,
@@ -563,11 +624,11 @@ func handleSolSoldOptimize(val model.SolSwapData) error {
 			TxHash:           val.TxHash,
 			FromToken:        val.FromToken,
 			FromTokenSymbol:  fromtokenMeta.Symbol,
-			FromTokenAmount:  strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64),
+			FromTokenAmount:  fromAmountText(val),
 			FromTokenDecimal: fromtokenMeta.Decimals,
 			ToToken:          val.ToToken,
 			ToTokenSymbol:    totokenMeta.Symbol,
-			ToTokenAmount:    strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64),
+			ToTokenAmount:    toAmountText(val),
 			ToTokenDecimal:   totokenMeta.Decimals,
 			Value:            strconv.FormatFloat(totokenValue, 'f', -1, 64),
 			Price:            fromprice,
@@ -579,8 +640,12 @@ func handleSolSoldOptimize(val model.SolSwapData) error {
 			HoldersCount:     fromtokenMeta.HoldersCount,
 			Direction:        "Sold",
 			TradeLabel:       val.TradeLabel,
+			BuyCount:         buyCount,
+			SellPercent:      sellPercent,
+			HoldSeconds:      holdSeconds,
 			IsDCATrade:       val.IsDCATrade,
 			TotalSupply:      fromtokenMeta.TotalSupply,
+			CurveProgress:    curveProgress(val),
 		}
 
 		alby, err := json.Marshal(&alterData)
@@ -662,7 +727,7 @@ func handleSolSoldOptimize(val model.SolSwapData) error {
 			}
 
 			if !isFloatEqual(fromtokenMeta.Price) {
-				fromtokenMeta.Price = totokenValue / val.FromTokenAmount
+				fromtokenMeta.Price = unitPrice(totokenValue, fromAmount(val))
 			}
 
 			record := model.SolAlterRecord{
@@ -686,7 +751,7 @@ func handleSolSoldOptimize(val model.SolSwapData) error {
 
 			if toData.TgTxSold {
 				isTgSend = true
-			} else if toData.TgTxSellAll && val.TradeLabel == LabelSellAll {
+			} else if pushSellLabel(toData, val.TradeLabel) {
 				isTgSend = true
 			} else {
 				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Data": toData, "TradeLabel": val.TradeLabel}).Info("handleSolSoldOptimize no need push tx to tg bot")
@@ -694,7 +759,7 @@ func handleSolSoldOptimize(val model.SolSwapData) error {
 			}
 
 			if isTgSend {
-				botbody := ConstructSoldBotMessage("solana", toData.Label, val.FromUserAccount, strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64), fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64), totokenMeta.Symbol, fromprice, val.TxHash, val.FromToken, toData.ListID, fromtokenAddress, toData.IsAddrPublic, val.TradeLabel, mc)
+				botbody := ConstructSoldBotMessage("solana", toData.Label, val.FromUserAccount, fromAmountText(val), fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), toAmountText(val), totokenMeta.Symbol, fromprice, val.TxHash, val.FromToken, toData.ListID, fromtokenAddress, toData.IsAddrPublic, val.TradeLabel, val.Position, mc)
 
 				err = HandleTgBotMessage(toData.ListID, botbody, "Solana", fromtokenAddress, val.Timestamp, true)
 				if err != nil {
@@ -720,7 +785,7 @@ func handleSolSoldOptimize(val model.SolSwapData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", "solana", val.ToUserAccount)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.ToUserAccount)
 }
 
 func handleSOlBuy(val model.SolSwapData) error {
@@ -749,15 +814,16 @@ func handleSOlBuy(val model.SolSwapData) error {
 			return fmt.Errorf("from token,%s,%v", val.FromToken, err)
 		}
 
-		fromtokenValue := calswapValue(val.FromTokenAmount, fromtokenMeta.Price)
+		fromtokenValue := amountValue(fromAmount(val), fromtokenMeta.Price)
 
 		mc := strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
 		toprice := strconv.FormatFloat(totokenMeta.Price, 'f', -1, 64)
 		if isFloatEqual(fromtokenValue) && totokenMeta.TotalSupply != "" {
-			toprice = strconv.FormatFloat(fromtokenValue/val.ToTokenAmount, 'f', -1, 64)
+			toprice = strconv.FormatFloat(unitPrice(fromtokenValue, toAmount(val)), 'f', -1, 64)
 			mc = strconv.FormatFloat(calValue(toprice, totokenMeta.TotalSupply), 'f', -1, 64)
 		}
 
+		buyCount, sellPercent, holdSeconds := positionFields(val)
 		alterData := SolAltertData{
 			Source:           val.Source,
 			Date:             val.Date,
@@ -765,11 +831,11 @@ func handleSOlBuy(val model.SolSwapData) error {
 			TxHash:           val.TxHash,
 			FromToken:        val.FromToken,
 			FromTokenSymbol:  fromtokenMeta.Symbol,
-			FromTokenAmount:  strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64),
+			FromTokenAmount:  fromAmountText(val),
 			FromTokenDecimal: fromtokenMeta.Decimals,
 			ToToken:          val.ToToken,
 			ToTokenSymbol:    totokenMeta.Symbol,
-			ToTokenAmount:    strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64),
+			ToTokenAmount:    toAmountText(val),
 			ToTokenDecimal:   totokenMeta.Decimals,
 			Value:            strconv.FormatFloat(fromtokenValue, 'f', -1, 64),
 			Price:            strconv.FormatFloat(fromtokenMeta.Price, 'f', -1, 64),
@@ -781,8 +847,12 @@ func handleSOlBuy(val model.SolSwapData) error {
 			HoldersCount:     totokenMeta.HoldersCount,
 			Direction:        "Bought",
 			TradeLabel:       val.TradeLabel,
+			BuyCount:         buyCount,
+			SellPercent:      sellPercent,
+			HoldSeconds:      holdSeconds,
 			IsDCATrade:       val.IsDCATrade,
 			TotalSupply:      totokenMeta.TotalSupply,
+			CurveProgress:    curveProgress(val),
 		}
 
 		alby, err := json.Marshal(&alterData)
@@ -897,9 +967,7 @@ func handleSOlBuy(val model.SolSwapData) error {
 
 			if fromData.TgTxBuy {
 				isTgSend = true
-			} else if fromData.TgTxFirstBuy && val.TradeLabel == LabelFirstBuy {
-				isTgSend = true
-			} else if fromData.TgTxFreshBuy && val.TradeLabel == LabelFirstBuy {
+			} else if pushBuyLabel(fromData, val.TradeLabel) {
 				isTgSend = true
 			} else {
 				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Data": fromData, "TradeLabel": val.TradeLabel}).Info("handleSOlBuy no need push tx to tg bot")
@@ -907,7 +975,7 @@ func handleSOlBuy(val model.SolSwapData) error {
 			}
 
 			if isTgSend {
-				botbody := ConstructBuyBotMessage("solana", fromData.Label, val.FromUserAccount, strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64), fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64), tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, val.TradeLabel, mc)
+				botbody := ConstructBuyBotMessage("solana", fromData.Label, val.FromUserAccount, fromAmountText(val), fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), toAmountText(val), tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, val.TradeLabel, val.Position, mc)
 
 				err = HandleTgBotMessage(fromData.ListID, botbody, "Solana", totokenAddress, val.Timestamp, true)
 				if err != nil {
@@ -934,7 +1002,7 @@ func handleSOlBuy(val model.SolSwapData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", "solana", val.FromUserAccount)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.FromUserAccount)
 }
 
 func handleSOlCreate(val model.SolSwapData) error {
@@ -958,11 +1026,11 @@ func handleSOlCreate(val model.SolSwapData) error {
 			TxHash:           val.TxHash,
 			FromToken:        val.FromToken,
 			FromTokenSymbol:  "",
-			FromTokenAmount:  strconv.FormatFloat(val.FromTokenAmount, 'f', -1, 64),
+			FromTokenAmount:  fromAmountText(val),
 			FromTokenDecimal: 0,
 			ToToken:          val.ToToken,
 			ToTokenSymbol:    "",
-			ToTokenAmount:    strconv.FormatFloat(val.ToTokenAmount, 'f', -1, 64),
+			ToTokenAmount:    toAmountText(val),
 			ToTokenDecimal:   0,
 			Value:            "",
 			Price:            "",
@@ -1035,7 +1103,541 @@ func handleSOlCreate(val model.SolSwapData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", "solana", val.FromUserAccount)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.FromUserAccount)
+}
+
+// dcaAlertDirection maps a dca record to its alert, withdraws and fills have none of their own
+func dcaAlertDirection(val model.SolSwapData) string {
+	if val.DCA == nil {
+		return ""
+	}
+
+	switch val.DCA.Event {
+	case model.DCAEventOpen:
+		return "DCAOpen"
+	case model.DCAEventClose, model.DCAEventEndAndClose:
+		if val.DCA.Completed {
+			return "DCAComplete"
+		}
+		return "DCAClose"
+	}
+
+	return ""
+}
+
+func handleSolDCA(val model.SolSwapData) error {
+	err := checkTimestamp(val.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	direction := dcaAlertDirection(val)
+	if direction == "" {
+		return nil
+	}
+
+	fromDataList, err := GetTrackedAddrFromCache("solana", val.FromUserAccount)
+	if err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Data": fromDataList, "TxHash": val.TxHash}).Info("handleSolDCA from user account info")
+
+	if len(fromDataList) > 0 {
+		fromSymbol, toSymbol := "", ""
+		fromMeta, err := GetSolMetaDataCache("solana", val.FromToken)
+		if err == nil {
+			fromSymbol = fromMeta.Symbol
+		}
+		toMeta, err := GetSolMetaDataCache("solana", val.ToToken)
+		if err == nil {
+			toSymbol = toMeta.Symbol
+		}
+
+		alterData := SolAltertData{
+			Source:           val.Source,
+			Date:             val.Date,
+			Type:             val.Type,
+			TxHash:           val.TxHash,
+			FromToken:        val.FromToken,
+			FromTokenSymbol:  fromSymbol,
+			FromTokenAmount:  fromAmountText(val),
+			FromTokenDecimal: val.DCA.InDecimals,
+			ToToken:          val.ToToken,
+			ToTokenSymbol:    toSymbol,
+			ToTokenAmount:    toAmountText(val),
+			ToTokenDecimal:   val.DCA.OutDecimals,
+			Value:            "",
+			Price:            "",
+			FromAccount:      val.FromUserAccount,
+			ToAccount:        val.ToUserAccount,
+			Direction:        direction,
+		}
+
+		alby, err := json.Marshal(&alterData)
+		if err != nil {
+			return fmt.Errorf("marshal from alert data failed,%v", err)
+		}
+
+		writerecords := make([]model.SolAlterRecord, 0)
+
+		for _, fromData := range fromDataList {
+			logger.Logrus.WithFields(logrus.Fields{"Data": fromData, "FromUser": val.FromUserAccount, "TxHash": val.TxHash}).Info("handleSolDCA from list data")
+
+			if !fromData.TxBuySell {
+				logger.Logrus.WithFields(logrus.Fields{"Type": val.Type, "TxBuySell": fromData.TxBuySell, "TxHash": val.TxHash}).Error("handleSolDCA input type and Tx type not match")
+
+				continue
+			}
+
+			record := model.SolAlterRecord{
+				ListID:        fromData.ListID,
+				UserAccount:   fromData.UserAccount,
+				Type:          "address",
+				Chain:         "solana",
+				TokenAddress:  val.ToToken,
+				TokenSymbol:   toSymbol,
+				MarketCap:     "",
+				PriceChange1H: "",
+				Security:      "safe",
+				Data:          string(alby),
+				Timestamp:     fmt.Sprintf("%d", val.Timestamp),
+				CreateAt:      time.Now(),
+			}
+
+			writerecords = append(writerecords, record)
+
+			botbody := ""
+			if direction == "DCAOpen" && fromData.TgTxDCAOpen {
+				botbody = ConstructDCAOpenBotMessage("solana", fromData.Label, val.FromUserAccount, alterData.FromTokenAmount, fromSymbol, toSymbol, val.ToToken,
+					val.DCA.CyclesTotal, val.DCA.CycleFrequency, fromData.IsAddrPublic)
+			} else if direction == "DCAComplete" && fromData.TgTxDCAComplete {
+				botbody = ConstructDCACompleteBotMessage("solana", fromData.Label, val.FromUserAccount, fromSymbol, toSymbol, val.ToToken,
+					val.DCA.CyclesFilled, fromData.IsAddrPublic)
+			}
+
+			if botbody == "" {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Direction": direction}).Info("handleSolDCA no need push tx to tg bot")
+				continue
+			}
+
+			err = HandleTgBotMessage(fromData.ListID, botbody, "Solana", val.ToToken, val.Timestamp, true)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TxHash": val.TxHash, "ErrMsg": err}).Error("handleSolDCA handle bot failed")
+				continue
+			}
+
+			logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TgMsg": botbody, "TxHash": val.TxHash}).Info("handleSolDCA handle bot success")
+		}
+
+		err = BatchInsertAlertRecords(writerecords)
+		if err != nil {
+			err = BatchInsertAlertRecords(writerecords)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "ErrMsg": err, "Records": writerecords}).Error("handleSolDCA batch insert alert record failed")
+				return err
+			}
+		}
+
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Records": writerecords}).Info("handleSolDCA batch insert alert record success")
+
+		return nil
+	}
+
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.FromUserAccount)
+}
+
+func handleSolLimitOrder(val model.SolSwapData) error {
+	err := checkTimestamp(val.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	// cancels only go to the tx records
+	if val.LimitOrder == nil || val.LimitOrder.Event == model.LimitOrderEventCancel {
+		return nil
+	}
+
+	fromDataList, err := GetTrackedAddrFromCache("solana", val.FromUserAccount)
+	if err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Data": fromDataList, "TxHash": val.TxHash}).Info("handleSolLimitOrder from user account info")
+
+	if len(fromDataList) > 0 {
+		fromSymbol, toSymbol := "", ""
+		fromMeta, err := GetSolMetaDataCache("solana", val.FromToken)
+		if err == nil {
+			fromSymbol = fromMeta.Symbol
+		}
+		toMeta, err := GetSolMetaDataCache("solana", val.ToToken)
+		if err == nil {
+			toSymbol = toMeta.Symbol
+		}
+
+		direction := "LimitPlace"
+		if val.LimitOrder.Event == model.LimitOrderEventFill {
+			direction = "LimitFill"
+		}
+
+		alterData := SolAltertData{
+			Source:           val.Source,
+			Date:             val.Date,
+			Type:             val.Type,
+			TxHash:           val.TxHash,
+			FromToken:        val.FromToken,
+			FromTokenSymbol:  fromSymbol,
+			FromTokenAmount:  fromAmountText(val),
+			FromTokenDecimal: val.LimitOrder.InDecimals,
+			ToToken:          val.ToToken,
+			ToTokenSymbol:    toSymbol,
+			ToTokenAmount:    toAmountText(val),
+			ToTokenDecimal:   val.LimitOrder.OutDecimals,
+			Value:            "",
+			Price:            strconv.FormatFloat(val.LimitOrder.Price, 'f', -1, 64),
+			FromAccount:      val.FromUserAccount,
+			ToAccount:        val.ToUserAccount,
+			Direction:        direction,
+		}
+
+		alby, err := json.Marshal(&alterData)
+		if err != nil {
+			return fmt.Errorf("marshal from alert data failed,%v", err)
+		}
+
+		writerecords := make([]model.SolAlterRecord, 0)
+
+		for _, fromData := range fromDataList {
+			logger.Logrus.WithFields(logrus.Fields{"Data": fromData, "FromUser": val.FromUserAccount, "TxHash": val.TxHash}).Info("handleSolLimitOrder from list data")
+
+			if !fromData.TxBuySell {
+				logger.Logrus.WithFields(logrus.Fields{"Type": val.Type, "TxBuySell": fromData.TxBuySell, "TxHash": val.TxHash}).Error("handleSolLimitOrder input type and Tx type not match")
+
+				continue
+			}
+
+			record := model.SolAlterRecord{
+				ListID:        fromData.ListID,
+				UserAccount:   fromData.UserAccount,
+				Type:          "address",
+				Chain:         "solana",
+				TokenAddress:  val.ToToken,
+				TokenSymbol:   toSymbol,
+				MarketCap:     "",
+				PriceChange1H: "",
+				Security:      "safe",
+				Data:          string(alby),
+				Timestamp:     fmt.Sprintf("%d", val.Timestamp),
+				CreateAt:      time.Now(),
+			}
+
+			writerecords = append(writerecords, record)
+
+			if (direction == "LimitPlace" && !fromData.TgTxLimitPlace) || (direction == "LimitFill" && !fromData.TgTxLimitFill) {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Direction": direction}).Info("handleSolLimitOrder no need push tx to tg bot")
+				continue
+			}
+
+			botbody := ConstructLimitOrderBotMessage("solana", fromData.Label, val.FromUserAccount, val.LimitOrder.Event, alterData.FromTokenAmount, fromSymbol,
+				alterData.ToTokenAmount, toSymbol, val.ToToken, alterData.Price, val.LimitOrder.ExpiredAt, fromData.IsAddrPublic)
+
+			err = HandleTgBotMessage(fromData.ListID, botbody, "Solana", val.ToToken, val.Timestamp, true)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TxHash": val.TxHash, "ErrMsg": err}).Error("handleSolLimitOrder handle bot failed")
+				continue
+			}
+
+			logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TgMsg": botbody, "TxHash": val.TxHash}).Info("handleSolLimitOrder handle bot success")
+		}
+
+		err = BatchInsertAlertRecords(writerecords)
+		if err != nil {
+			err = BatchInsertAlertRecords(writerecords)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "ErrMsg": err, "Records": writerecords}).Error("handleSolLimitOrder batch insert alert record failed")
+				return err
+			}
+		}
+
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Records": writerecords}).Info("handleSolLimitOrder batch insert alert record success")
+
+		return nil
+	}
+
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.FromUserAccount)
+}
+
+func handleSolGraduate(val model.SolSwapData) error {
+	err := checkTimestamp(val.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	if val.PumpCurve == nil {
+		return fmt.Errorf("pump curve missing, %s", val.TxHash)
+	}
+
+	fromDataList, err := GetTrackedAddrFromCache("solana", val.FromUserAccount)
+	if err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Data": fromDataList, "TxHash": val.TxHash}).Info("handleSolGraduate from user account info")
+
+	if len(fromDataList) > 0 {
+		toSymbol, mc := "", ""
+		toMeta, err := GetSolMetaDataCache("solana", val.ToToken)
+		if err == nil {
+			toSymbol = toMeta.Symbol
+			mc = strconv.FormatFloat(toMeta.Mc, 'f', -1, 64)
+		}
+
+		alterData := SolAltertData{
+			Source:           val.Source,
+			Date:             val.Date,
+			Type:             val.Type,
+			TxHash:           val.TxHash,
+			FromToken:        val.FromToken,
+			FromTokenSymbol:  "SOL",
+			FromTokenAmount:  fromAmountText(val),
+			FromTokenDecimal: 9,
+			ToToken:          val.ToToken,
+			ToTokenSymbol:    toSymbol,
+			ToTokenAmount:    toAmountText(val),
+			ToTokenDecimal:   6,
+			Value:            "",
+			Price:            "",
+			MarketCap:        mc,
+			FromAccount:      val.FromUserAccount,
+			ToAccount:        val.ToUserAccount,
+			Direction:        "Graduate",
+			CurveProgress:    curveProgress(val),
+		}
+
+		alby, err := json.Marshal(&alterData)
+		if err != nil {
+			return fmt.Errorf("marshal from alert data failed,%v", err)
+		}
+
+		writerecords := make([]model.SolAlterRecord, 0)
+
+		for _, fromData := range fromDataList {
+			logger.Logrus.WithFields(logrus.Fields{"Data": fromData, "FromUser": val.FromUserAccount, "TxHash": val.TxHash}).Info("handleSolGraduate from list data")
+
+			if !fromData.TxBuySell {
+				logger.Logrus.WithFields(logrus.Fields{"Type": val.Type, "TxBuySell": fromData.TxBuySell, "TxHash": val.TxHash}).Error("handleSolGraduate input type and Tx type not match")
+
+				continue
+			}
+
+			record := model.SolAlterRecord{
+				ListID:        fromData.ListID,
+				UserAccount:   fromData.UserAccount,
+				Type:          "address",
+				Chain:         "solana",
+				TokenAddress:  val.ToToken,
+				TokenSymbol:   toSymbol,
+				MarketCap:     mc,
+				PriceChange1H: "",
+				Security:      "safe",
+				Data:          string(alby),
+				Timestamp:     fmt.Sprintf("%d", val.Timestamp),
+				CreateAt:      time.Now(),
+			}
+
+			writerecords = append(writerecords, record)
+
+			if !fromData.TgTxGraduate {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash}).Info("handleSolGraduate no need push tx to tg bot")
+				continue
+			}
+
+			botbody := ConstructGraduateBotMessage("solana", fromData.Label, val.FromUserAccount, toSymbol, val.ToToken, val.PumpCurve.MigratedTo, mc, fromData.IsAddrPublic)
+
+			err = HandleTgBotMessage(fromData.ListID, botbody, "Solana", val.ToToken, val.Timestamp, true)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TxHash": val.TxHash, "ErrMsg": err}).Error("handleSolGraduate handle bot failed")
+				continue
+			}
+
+			logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TgMsg": botbody, "TxHash": val.TxHash}).Info("handleSolGraduate handle bot success")
+		}
+
+		err = BatchInsertAlertRecords(writerecords)
+		if err != nil {
+			err = BatchInsertAlertRecords(writerecords)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "ErrMsg": err, "Records": writerecords}).Error("handleSolGraduate batch insert alert record failed")
+				return err
+			}
+		}
+
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Records": writerecords}).Info("handleSolGraduate batch insert alert record success")
+
+		return nil
+	}
+
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", val.FromUserAccount)
+}
+
+// mintBurnDirection maps a mint, burn or lp record to its alert
+func mintBurnDirection(typ string) string {
+	switch typ {
+	case "MINT":
+		return "Mint"
+	case "BURN":
+		return "Burn"
+	case "ADDLIQUIDITY":
+		return "AddLiquidity"
+	case "REMOVELIQUIDITY":
+		return "RemoveLiquidity"
+	}
+
+	return ""
+}
+
+// mintBurnAccount is the tracked side of a mint, burn or lp record, the receiver when someone else minted
+func mintBurnAccount(val model.SolSwapData) (string, []TrackedAddrCache, error) {
+	fromDataList, err := GetTrackedAddrFromCache("solana", val.FromUserAccount)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(fromDataList) > 0 || val.Type != "MINT" || val.ToUserAccount == "" || val.ToUserAccount == val.FromUserAccount {
+		return val.FromUserAccount, fromDataList, nil
+	}
+
+	toDataList, err := GetTrackedAddrFromCache("solana", val.ToUserAccount)
+	return val.ToUserAccount, toDataList, err
+}
+
+func handleSolMintBurn(val model.SolSwapData) error {
+	err := checkTimestamp(val.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	direction := mintBurnDirection(val.Type)
+	if direction == "" {
+		return fmt.Errorf("unknown mint burn type %s, %s", val.Type, val.TxHash)
+	}
+
+	account, fromDataList, err := mintBurnAccount(val)
+	if err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Data": fromDataList, "TxHash": val.TxHash}).Info("handleSolMintBurn from user account info")
+
+	if len(fromDataList) > 0 {
+		fromSymbol, toSymbol, mc := "", "", ""
+		fromMeta, err := GetSolMetaDataCache("solana", val.FromToken)
+		if err == nil {
+			fromSymbol = fromMeta.Symbol
+			mc = strconv.FormatFloat(fromMeta.Mc, 'f', -1, 64)
+		}
+		toMeta, err := GetSolMetaDataCache("solana", val.ToToken)
+		if err == nil {
+			toSymbol = toMeta.Symbol
+		}
+
+		fromDecimal, toDecimal := 0, 0
+		if val.MintBurn != nil {
+			fromDecimal, toDecimal = val.MintBurn.Decimals, val.MintBurn.Decimals
+		} else if val.Liquidity != nil {
+			fromDecimal, toDecimal = val.Liquidity.DecimalsA, val.Liquidity.DecimalsB
+		}
+
+		alterData := SolAltertData{
+			Source:           val.Source,
+			Date:             val.Date,
+			Type:             val.Type,
+			TxHash:           val.TxHash,
+			FromToken:        val.FromToken,
+			FromTokenSymbol:  fromSymbol,
+			FromTokenAmount:  fromAmountText(val),
+			FromTokenDecimal: fromDecimal,
+			ToToken:          val.ToToken,
+			ToTokenSymbol:    toSymbol,
+			ToTokenAmount:    toAmountText(val),
+			ToTokenDecimal:   toDecimal,
+			Value:            "",
+			Price:            "",
+			MarketCap:        mc,
+			FromAccount:      val.FromUserAccount,
+			ToAccount:        val.ToUserAccount,
+			Direction:        direction,
+		}
+
+		alby, err := json.Marshal(&alterData)
+		if err != nil {
+			return fmt.Errorf("marshal from alert data failed,%v", err)
+		}
+
+		writerecords := make([]model.SolAlterRecord, 0)
+
+		for _, fromData := range fromDataList {
+			logger.Logrus.WithFields(logrus.Fields{"Data": fromData, "FromUser": account, "TxHash": val.TxHash}).Info("handleSolMintBurn from list data")
+
+			if !fromData.TxMintBurn {
+				logger.Logrus.WithFields(logrus.Fields{"Type": val.Type, "TxMintBurn": fromData.TxMintBurn, "TxHash": val.TxHash}).Error("handleSolMintBurn input type and Tx type not match")
+
+				continue
+			}
+
+			record := model.SolAlterRecord{
+				ListID:        fromData.ListID,
+				UserAccount:   fromData.UserAccount,
+				Type:          "address",
+				Chain:         "solana",
+				TokenAddress:  val.FromToken,
+				TokenSymbol:   fromSymbol,
+				MarketCap:     mc,
+				PriceChange1H: "",
+				Security:      "safe",
+				Data:          string(alby),
+				Timestamp:     fmt.Sprintf("%d", val.Timestamp),
+				CreateAt:      time.Now(),
+			}
+
+			writerecords = append(writerecords, record)
+
+			push := fromData.TgTxMintBurn
+			if val.Liquidity != nil {
+				push = fromData.TgTxLiquidity
+			}
+			if !push {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash}).Info("handleSolMintBurn no need push tx to tg bot")
+				continue
+			}
+
+			botbody := ConstructMintBurnBotMessage("solana", fromData.Label, account, direction, alterData.FromTokenAmount, fromSymbol, val.FromToken,
+				alterData.ToTokenAmount, toSymbol, val.ToToken, fromData.IsAddrPublic)
+
+			err = HandleTgBotMessage(fromData.ListID, botbody, "Solana", val.FromToken, val.Timestamp, true)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TxHash": val.TxHash, "ErrMsg": err}).Error("handleSolMintBurn handle bot failed")
+				continue
+			}
+
+			logger.Logrus.WithFields(logrus.Fields{"ListID": fromData.ListID, "TgMsg": botbody, "TxHash": val.TxHash}).Info("handleSolMintBurn handle bot success")
+		}
+
+		err = BatchInsertAlertRecords(writerecords)
+		if err != nil {
+			err = BatchInsertAlertRecords(writerecords)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "ErrMsg": err, "Records": writerecords}).Error("handleSolMintBurn batch insert alert record failed")
+				return err
+			}
+		}
+
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "Records": writerecords}).Info("handleSolMintBurn batch insert alert record success")
+
+		return nil
+	}
+
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", account)
 }
 
 func handleAddressSwapRule(val model.SolSwapData, tokenRule map[string]bool) error {
@@ -1061,53 +1663,50 @@ func handleAddressSwapRule(val model.SolSwapData, tokenRule map[string]bool) err
 		}
 	} else if val.Type == "CREATE" {
 		return handleSOlCreate(val)
+	} else if val.Type == "OPENDCA" || val.Type == "CLOSEDCA" {
+		return handleSolDCA(val)
+	} else if val.Type == "PLACELIMITORDER" || val.Type == "FILLLIMITORDER" {
+		return handleSolLimitOrder(val)
+	} else if val.Type == "GRADUATE" {
+		return handleSolGraduate(val)
+	} else if mintBurnDirection(val.Type) != "" {
+		return handleSolMintBurn(val)
 	}
 
 	return nil
 }
 
+// calValue multiplies two decimal literals exactly, only the result is rounded to a float
 func calValue(amt, price string) float64 {
-	a := new(big.Float)
-	b := new(big.Float)
-
-	if _, ok := a.SetString(amt); !ok {
+	a, ok := new(big.Rat).SetString(amt)
+	if !ok {
 		return 0
 	}
-	if _, ok := b.SetString(price); !ok {
+	b, ok := new(big.Rat).SetString(price)
+	if !ok {
 		return 0
 	}
 
-	result := new(big.Float).Mul(a, b)
-
-	data, _ := result.Float64()
+	data, _ := new(big.Rat).Mul(a, b).Float64()
 	return data
 }
 
 func calTokenPrice(val, amt string) float64 {
-	a := new(big.Float)
-	b := new(big.Float)
-
-	if _, ok := a.SetString(val); !ok {
+	a, ok := new(big.Rat).SetString(val)
+	if !ok {
 		return 0
 	}
-	if _, ok := b.SetString(amt); !ok {
+	b, ok := new(big.Rat).SetString(amt)
+	if !ok || b.Sign() == 0 {
 		return 0
 	}
 
-	result := new(big.Float).Quo(a, b)
-
-	data, _ := result.Float64()
+	data, _ := new(big.Rat).Quo(a, b).Float64()
 	return data
 }
 
 func calswapValue(amt, price float64) float64 {
-	a := big.NewFloat(amt)
-	b := big.NewFloat(price)
-
-	result := new(big.Float).Mul(a, b)
-
-	data, _ := result.Float64()
-	return data
+	return amountValue(floatRat(amt), price)
 }
 
 func handleEVMBuyWithBirdeye(val RawBitqueryAltertData) error {
@@ -1244,7 +1843,7 @@ func handleEVMBuyWithBirdeye(val RawBitqueryAltertData) error {
 				continue
 			}
 
-			botbody := ConstructBuyBotMessage(val.Chain, fromData.Label, val.FromAddress, val.FromTokenAmount, fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), val.ToTokenAmount, tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, "", mc)
+			botbody := ConstructBuyBotMessage(val.Chain, fromData.Label, val.FromAddress, val.FromTokenAmount, fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), val.ToTokenAmount, tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, "", nil, mc)
 
 			err = HandleTgBotMessage(fromData.ListID, botbody, val.Chain, totokenAddress, int(unixTimestamp), true)
 			if err != nil {
@@ -1270,7 +1869,7 @@ func handleEVMBuyWithBirdeye(val RawBitqueryAltertData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", val.Chain, val.FromAddress)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, val.Chain, val.FromAddress)
 }
 
 func handleEVMBuy(val RawBitqueryAltertData) error {
@@ -1439,7 +2038,7 @@ func handleEVMBuy(val RawBitqueryAltertData) error {
 				continue
 			}
 
-			botbody := ConstructBuyBotMessage(val.Chain, fromData.Label, val.FromAddress, val.FromTokenAmount, fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), val.ToTokenAmount, tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, "", mc)
+			botbody := ConstructBuyBotMessage(val.Chain, fromData.Label, val.FromAddress, val.FromTokenAmount, fromtokenMeta.Symbol, strconv.FormatFloat(fromtokenValue, 'f', -1, 64), val.ToTokenAmount, tosymbol, toprice, val.TxHash, val.FromToken, fromData.ListID, totokenAddress, fromData.IsAddrPublic, "", nil, mc)
 
 			err = HandleTgBotMessage(fromData.ListID, botbody, val.Chain, totokenAddress, int(unixTimestamp), true)
 			if err != nil {
@@ -1465,7 +2064,7 @@ func handleEVMBuy(val RawBitqueryAltertData) error {
 		return nil
 	}
 
-	return fmt.Errorf("from address not register,%s, %s", val.Chain, val.FromAddress)
+	return fmt.Errorf("from %w,%s, %s", ErrNotRegistered, val.Chain, val.FromAddress)
 }
 
 func handleEVMSold(val RawBitqueryAltertData) error {
@@ -1634,7 +2233,7 @@ func handleEVMSold(val RawBitqueryAltertData) error {
 				continue
 			}
 
-			botbody := ConstructSoldBotMessage(val.Chain, toData.Label, val.FromAddress, val.FromTokenAmount, fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), val.ToTokenAmount, totokenMeta.Symbol, fromprice, val.TxHash, val.FromToken, toData.ListID, fromtokenAddress, toData.IsAddrPublic, "", strconv.FormatFloat(fromtokenMeta.Mc, 'f', -1, 64))
+			botbody := ConstructSoldBotMessage(val.Chain, toData.Label, val.FromAddress, val.FromTokenAmount, fromsymbol, strconv.FormatFloat(totokenValue, 'f', -1, 64), val.ToTokenAmount, totokenMeta.Symbol, fromprice, val.TxHash, val.FromToken, toData.ListID, fromtokenAddress, toData.IsAddrPublic, "", nil, strconv.FormatFloat(fromtokenMeta.Mc, 'f', -1, 64))
 
 			err = HandleTgBotMessage(toData.ListID, botbody, val.Chain, fromtokenAddress, int(unixTimestamp), true)
 			if err != nil {
@@ -1660,7 +2259,7 @@ func handleEVMSold(val RawBitqueryAltertData) error {
 		return nil
 	}
 
-	return fmt.Errorf("to address not register,%s, %s", val.Chain, val.ToAddress)
+	return fmt.Errorf("to %w,%s, %s", ErrNotRegistered, val.Chain, val.ToAddress)
 }
 
 func handleBitQueryRule(val RawBitqueryAltertData, tokenRule map[string]bool) error {
@@ -1932,12 +2531,14 @@ func handleSolSaveRecord(list []model.SolSwapData, tokenRule map[string]bool) er
 			FromUserAccount:  v.FromUserAccount,
 			FromTokenAmount:  v.FromTokenAmount,
 			FromTokenSymbol:  fromtokenMeta.Symbol,
+			FromTokenExact:   fromAmountText(v),
 
 			ToToken:        v.ToToken,
 			ToTokenAccount: v.ToTokenAccount,
 			ToUserAccount:  v.ToUserAccount,
 			ToTokenAmount:  v.ToTokenAmount,
 			ToTokenSymbol:  totokenMeta.Symbol,
+			ToTokenExact:   toAmountText(v),
 
 			CreateAt:        time.Now(),
 			Value:           "",
@@ -1959,7 +2560,7 @@ func handleSolSaveRecord(list []model.SolSwapData, tokenRule map[string]bool) er
 
 			if took && !fromok {
 				// handleSolSold(v)
-				totokenValue := calswapValue(v.ToTokenAmount, totokenMeta.Price)
+				totokenValue := amountValue(toAmount(v), totokenMeta.Price)
 
 				item.Value = strconv.FormatFloat(totokenValue, 'f', -1, 64)
 				item.MarketCap = strconv.FormatFloat(fromtokenMeta.Mc, 'f', -1, 64)
@@ -1969,7 +2570,7 @@ func handleSolSaveRecord(list []model.SolSwapData, tokenRule map[string]bool) er
 				item.Supply = fromtokenMeta.TotalSupply
 			} else {
 				// handleSOlBuy(v)
-				fromtokenValue := calswapValue(v.FromTokenAmount, fromtokenMeta.Price)
+				fromtokenValue := amountValue(fromAmount(v), fromtokenMeta.Price)
 
 				item.Value = strconv.FormatFloat(fromtokenValue, 'f', -1, 64)
 				item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
@@ -1987,7 +2588,7 @@ func handleSolSaveRecord(list []model.SolSwapData, tokenRule map[string]bool) er
 				}
 
 				//handleSOlSend
-				fromtokenValue := calswapValue(v.FromTokenAmount, totokenMeta.Price)
+				fromtokenValue := amountValue(fromAmount(v), totokenMeta.Price)
 
 				item.Value = strconv.FormatFloat(fromtokenValue, 'f', -1, 64)
 				item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
@@ -1997,7 +2598,7 @@ func handleSolSaveRecord(list []model.SolSwapData, tokenRule map[string]bool) er
 				item.Supply = totokenMeta.TotalSupply
 			} else {
 				//handleSolReceived
-				totokenValue := calswapValue(v.ToTokenAmount, totokenMeta.Price)
+				totokenValue := amountValue(toAmount(v), totokenMeta.Price)
 
 				item.Value = strconv.FormatFloat(totokenValue, 'f', -1, 64)
 				item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
@@ -2008,7 +2609,7 @@ func handleSolSaveRecord(list []model.SolSwapData, tokenRule map[string]bool) er
 			}
 		} else if v.Type == "CREATE" {
 			// handleSOlCreate(v)
-			fromtokenValue := calswapValue(v.FromTokenAmount, fromtokenMeta.Price)
+			fromtokenValue := amountValue(fromAmount(v), fromtokenMeta.Price)
 
 			item.Value = strconv.FormatFloat(fromtokenValue, 'f', -1, 64)
 			item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
@@ -2016,6 +2617,67 @@ func handleSolSaveRecord(list []model.SolSwapData, tokenRule map[string]bool) er
 			item.Change1HPrice = strconv.FormatFloat(totokenMeta.Change1hPrice, 'f', -1, 64)
 			item.Direction = "Create"
 			item.Supply = totokenMeta.TotalSupply
+		} else if v.Type == "OPENDCA" {
+			// handleSolDCA(v)
+			fromtokenValue := amountValue(fromAmount(v), fromtokenMeta.Price)
+
+			item.Value = strconv.FormatFloat(fromtokenValue, 'f', -1, 64)
+			item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
+			item.Price = strconv.FormatFloat(fromtokenMeta.Price, 'f', -1, 64)
+			item.Change1HPrice = strconv.FormatFloat(totokenMeta.Change1hPrice, 'f', -1, 64)
+			item.Direction = "DCAOpen"
+			item.Supply = totokenMeta.TotalSupply
+		} else if v.Type == "CLOSEDCA" || v.Type == "WITHDRAWDCA" {
+			// both sides go back to the user, unused input and the output bought so far
+			returnValue := amountValue(fromAmount(v), fromtokenMeta.Price) + amountValue(toAmount(v), totokenMeta.Price)
+
+			item.Value = strconv.FormatFloat(returnValue, 'f', -1, 64)
+			item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
+			item.Price = strconv.FormatFloat(totokenMeta.Price, 'f', -1, 64)
+			item.Change1HPrice = strconv.FormatFloat(totokenMeta.Change1hPrice, 'f', -1, 64)
+			item.Direction = "DCAClose"
+			if v.Type == "WITHDRAWDCA" {
+				item.Direction = "DCAWithdraw"
+			}
+			item.Supply = totokenMeta.TotalSupply
+		} else if v.Type == "PLACELIMITORDER" || v.Type == "FILLLIMITORDER" || v.Type == "CANCELLIMITORDER" {
+			// handleSolLimitOrder(v)
+			fromtokenValue := amountValue(fromAmount(v), fromtokenMeta.Price)
+
+			item.Value = strconv.FormatFloat(fromtokenValue, 'f', -1, 64)
+			item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
+			item.Price = strconv.FormatFloat(fromtokenMeta.Price, 'f', -1, 64)
+			item.Change1HPrice = strconv.FormatFloat(totokenMeta.Change1hPrice, 'f', -1, 64)
+			item.Direction = "LimitPlace"
+			if v.Type == "FILLLIMITORDER" {
+				item.Direction = "LimitFill"
+			} else if v.Type == "CANCELLIMITORDER" {
+				item.Direction = "LimitCancel"
+			}
+			item.Supply = totokenMeta.TotalSupply
+		} else if v.Type == "GRADUATE" {
+			// handleSolGraduate(v)
+			fromtokenValue := amountValue(fromAmount(v), fromtokenMeta.Price)
+
+			item.Value = strconv.FormatFloat(fromtokenValue, 'f', -1, 64)
+			item.MarketCap = strconv.FormatFloat(totokenMeta.Mc, 'f', -1, 64)
+			item.Price = strconv.FormatFloat(totokenMeta.Price, 'f', -1, 64)
+			item.Change1HPrice = strconv.FormatFloat(totokenMeta.Change1hPrice, 'f', -1, 64)
+			item.Direction = "Graduate"
+			item.Supply = totokenMeta.TotalSupply
+		} else if mintBurnDirection(v.Type) != "" {
+			// handleSolMintBurn(v), lp records carry both pool sides
+			tokenValue := amountValue(fromAmount(v), fromtokenMeta.Price)
+			if v.Liquidity != nil {
+				tokenValue += amountValue(toAmount(v), totokenMeta.Price)
+			}
+
+			item.Value = strconv.FormatFloat(tokenValue, 'f', -1, 64)
+			item.MarketCap = strconv.FormatFloat(fromtokenMeta.Mc, 'f', -1, 64)
+			item.Price = strconv.FormatFloat(fromtokenMeta.Price, 'f', -1, 64)
+			item.Change1HPrice = strconv.FormatFloat(fromtokenMeta.Change1hPrice, 'f', -1, 64)
+			item.Direction = mintBurnDirection(v.Type)
+			item.Supply = fromtokenMeta.TotalSupply
 		} else {
 			continue
 		}
@@ -2192,12 +2854,14 @@ func handleEVMSaveRecord(list []RawBitqueryAltertData, tokenRule map[string]bool
 			FromUserAccount:  v.FromAddress,
 			FromTokenAmount:  fromamt,
 			FromTokenSymbol:  fromtokenMeta.Symbol,
+			FromTokenExact:   v.FromTokenAmount,
 
 			ToToken:        v.ToToken,
 			ToTokenAccount: "",
 			ToUserAccount:  v.ToAddress,
 			ToTokenAmount:  toamt,
 			ToTokenSymbol:  totokenMeta.Symbol,
+			ToTokenExact:   v.ToTokenAmount,
 
 			CreateAt:        time.Now(),
 			Value:           "",
@@ -15,6 +15,8 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/redis"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+	"os"
 )
 
 func TestCurated(t *testing.T) {
@@ -818,3 +820,76 @@ func TestAddBlackList(t *testing.T) {
 		}
 	}
 }
+
+func TestExactAmountValue(t *testing.T) {
+	val := model.SolSwapData{
+		FromTokenAmount:    123456789.12345679,
+		FromTokenRawAmount: "123456789123456789",
+		FromTokenDecimals:  9,
+		ToTokenAmount:      0.3,
+	}
+
+	if got := fromAmountText(val); got != "123456789.123456789" {
+		t.Errorf("from text %s", got)
+	}
+	// no raw amount, the float literal as before
+	if got := toAmountText(val); got != "0.3" {
+		t.Errorf("to text %s", got)
+	}
+	if got := legText("1500000000", 9, 1.5); got != "1.5" {
+		t.Errorf("trimmed %s", got)
+	}
+
+	// the float product of these is 0.30000000000000004
+	if got := amountValue(legAmount("1", 1, 0), 3); got != 0.3 {
+		t.Errorf("value %v", got)
+	}
+	if got := calValue("0.1", "3"); got != 0.3 {
+		t.Errorf("calValue %v", got)
+	}
+	if got := unitPrice(1, legAmount("0", 6, 0)); got != 0 {
+		t.Errorf("zero amount price %v", got)
+	}
+	if got := calTokenPrice("1", "0"); got != 0 {
+		t.Errorf("zero amount token price %v", got)
+	}
+}
+
+// TestSwapContractVersions reads the swap batch golden messages of solmsg the way SubSolSwap does,
+// the v1 message without headers still prices from its float amount
+func TestSwapContractVersions(t *testing.T) {
+	cases := []struct {
+		version int
+		headers []solmsg.Header
+		from    string
+	}{
+		{1, nil, "1.5"},
+		{2, solmsg.Headers(solmsg.SolSwapBatch), "1.5"},
+	}
+
+	for _, c := range cases {
+		data, err := os.ReadFile(fmt.Sprintf("../../../solmsg/testdata/%s.v%d.json", solmsg.SolSwapBatch, c.version))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var res []model.SolSwapData
+		version, err := solmsg.Unmarshal(solmsg.SolSwapBatch, c.headers, data, &res)
+		if err != nil || version != c.version || len(res) == 0 {
+			t.Fatalf("v%d: got v%d, %v", c.version, version, err)
+		}
+		if got := fromAmountText(res[0]); got != c.from {
+			t.Errorf("v%d: from %s", c.version, got)
+		}
+	}
+
+	// the raw amount of v2 keeps what the float drops
+	data, _ := os.ReadFile("../../../solmsg/testdata/sol_swap_batch.v2.json")
+	var res []model.SolSwapData
+	if _, err := solmsg.Unmarshal(solmsg.SolSwapBatch, solmsg.Headers(solmsg.SolSwapBatch), data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if got := toAmountText(res[0]); got != "123456.789012" {
+		t.Errorf("to %s", got)
+	}
+}
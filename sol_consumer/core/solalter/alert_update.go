@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/big"
 	"strconv"
 
 	"github.com/sirupsen/logrus"
@@ -14,21 +13,9 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
 )
 
+// mulValue is the unit price of a recorded alert, value over the exact amount
 func mulValue(vale, amount string) float64 {
-	a := new(big.Float)
-	b := new(big.Float)
-
-	if _, ok := a.SetString(vale); !ok {
-		return 0
-	}
-	if _, ok := b.SetString(amount); !ok {
-		return 0
-	}
-
-	result := new(big.Float).Quo(a, b)
-
-	data, _ := result.Float64()
-	return data
+	return calTokenPrice(vale, amount)
 }
 
 func UpdateRecords() {
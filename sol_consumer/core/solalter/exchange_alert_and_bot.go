@@ -9,12 +9,11 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/db"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/redis"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
 )
 
-type RawTopicData struct {
-	Type string `json:"type"`
-	Data string `json:"data"`
-}
+// RawTopicData keeps the decoders of the exchange and kol payloads on the shared message
+type RawTopicData solmsg.RawTopicData
 
 type RawExchangeData struct {
 	AnnouncementTime string `json:"announcement_time"`
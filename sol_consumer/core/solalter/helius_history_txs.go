@@ -71,12 +71,14 @@ func HandleHeliusHisData(in []model.SolSwapData, tokenRule map[string]bool) erro
 			FromUserAccount:  v.FromUserAccount,
 			FromTokenAmount:  v.FromTokenAmount,
 			FromTokenSymbol:  fromsymbol,
+			FromTokenExact:   fromAmountText(v),
 
 			ToToken:        v.ToToken,
 			ToTokenAccount: v.ToTokenAccount,
 			ToUserAccount:  v.ToUserAccount,
 			ToTokenAmount:  v.ToTokenAmount,
 			ToTokenSymbol:  tosymbol,
+			ToTokenExact:   toAmountText(v),
 
 			CreateAt:        time.Now(),
 			Value:           "",
@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
@@ -15,6 +16,7 @@ import (
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/config"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/redis"
+	"github.com/thescopedao/solana_dex_subscribe/solrpc"
 )
 
 type RPCTokenMeta struct {
@@ -39,12 +41,31 @@ func toDecimal(amount string, decimal int) float64 {
 	return res
 }
 
-func getSolanaRPCMeta(tokenAddress string) (*RPCTokenMeta, error) {
-	headers := make(map[string]string)
-	headers["Content-Type"] = "application/json"
+var (
+	rpcOnce   sync.Once
+	rpcClient *solrpc.Client
+	rpcErr    error
+)
+
+// getRPCClient builds the shared solana rpc client once, the single QuickNodeURL of older
+// configs stands in when RPCConfig lists no endpoint
+func getRPCClient() (*solrpc.Client, error) {
+	rpcOnce.Do(func() {
+		cfg := config.GetRPCConfig()
+		if len(cfg.Endpoints) == 0 && config.GetSolDataConfig().QuickNodeURL != "" {
+			cfg.Endpoints = []solrpc.Endpoint{{URL: config.GetSolDataConfig().QuickNodeURL}}
+		}
+		rpcClient, rpcErr = solrpc.New(cfg)
+	})
+	return rpcClient, rpcErr
+}
 
-	url := config.GetSolDataConfig().QuickNodeURL
-	client := rpc.NewWithHeaders(url, headers)
+func getSolanaRPCMeta(tokenAddress string) (*RPCTokenMeta, error) {
+	routed, err := getRPCClient()
+	if err != nil {
+		return nil, err
+	}
+	client := routed.RPC()
 
 	pubKey, err := solana.PublicKeyFromBase58(tokenAddress)
 	if err != nil {
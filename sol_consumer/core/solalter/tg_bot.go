@@ -16,25 +16,14 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/db"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/redis"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
 )
 
-type TgButtonInfo struct {
-	Text string `json:"text"`
-	URL  string `json:"url"`
-}
-
-type TgMarkup struct {
-	InlineKeyboard [][]TgButtonInfo `json:"inline_keyboard"`
-}
-
-type TgMessage struct {
-	Webhook     string   `json:"webhook"`
-	ChatID      []string `json:"chat_id"`
-	Msg         string   `json:"msg"`
-	ReplyMarkup TgMarkup `json:"reply_markup"`
-	CreateTime  int      `json:"create_time"`
-	KeepTime    int      `json:"keep_time"`
-}
+type (
+	TgButtonInfo = solmsg.TgButtonInfo
+	TgMarkup     = solmsg.TgMarkup
+	TgMessage    = solmsg.TgMessage
+)
 
 func makeMarkup(listid, chain, token string) TgMarkup {
 	dexschain := chain
@@ -227,7 +216,186 @@ func ConstructCreateBotMessage(chain, fromLabel, fromAccount, toTokenSymbol, lis
 	return ll + tt + tail
 }
 
-func ConstructBuyBotMessage(chain, fromLabel, fromAccount, fromAmount, fromSymbol, fromValue, toAmount, toTokenSymbol, price, txHash, fromToken, listid, totoken string, ispublic bool, tradeLabel, tomc string) string {
+// formatDCAFrequency renders the cycle frequency in the largest whole unit, 3600 -> 1h
+func formatDCAFrequency(seconds int64) string {
+	switch {
+	case seconds <= 0:
+		return ""
+	case seconds%86400 == 0:
+		return fmt.Sprintf("%dd", seconds/86400)
+	case seconds%3600 == 0:
+		return fmt.Sprintf("%dh", seconds/3600)
+	case seconds%60 == 0:
+		return fmt.Sprintf("%dm", seconds/60)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+func solAddressMessageHead(chain, fromLabel, fromAccount string, ispublic bool) (string, string) {
+	dispchain := chain
+	if strings.ToLower(chain) == "solana" {
+		dispchain = "Solana"
+	}
+
+	fromaddr := fromAccount
+	if !ispublic {
+		fromaddr = "PrivateAddress"
+	}
+
+	ll := fmt.Sprintf("*Address Alert\n🦜%s*\n\n", EscapeSpecialCharacters("#"+fromaddr))
+	if fromLabel != "" {
+		if ispublic {
+			ll = "*Address Alert*\n🦜" + EscapeSpecialCharacters("#") + fmt.Sprintf("[*%s*](%s)\n\n", EscapeSpecialCharacters(fromLabel+" ("+fromaddr+")"), EscapeSpecialCharacters(fmt.Sprintf("https://solscan.io/account/%s", fromAccount)))
+		} else {
+			ll = "*Address Alert*\n🦜" + EscapeSpecialCharacters("#") + fmt.Sprintf("*%s*\n\n", EscapeSpecialCharacters(fromLabel+" ("+fromaddr+")"))
+		}
+	}
+
+	return ll, dispchain
+}
+
+func ConstructDCAOpenBotMessage(chain, fromLabel, fromAccount, inAmount, inSymbol, outSymbol, outToken string, cycles int, frequency int64, ispublic bool) string {
+	ll, dispchain := solAddressMessageHead(chain, fromLabel, fromAccount, ispublic)
+
+	plan := ""
+	if cycles > 0 && frequency > 0 {
+		plan = fmt.Sprintf(" over %d cycles every %s", cycles, formatDCAFrequency(frequency))
+	}
+
+	tt := "⏳*DCA Opened:* " + EscapeSpecialCharacters(fmt.Sprintf("%s $%s into $%s(%s)%s\n\n", inAmount, inSymbol, outSymbol, outToken, plan)) + fmt.Sprintf("*Chain:* %s\n", dispchain)
+	tail := "*Notifier:* " + EscapeSpecialCharacters("lmk.fun")
+
+	return ll + tt + tail
+}
+
+func ConstructDCACompleteBotMessage(chain, fromLabel, fromAccount, inSymbol, outSymbol, outToken string, cycles int, ispublic bool) string {
+	ll, dispchain := solAddressMessageHead(chain, fromLabel, fromAccount, ispublic)
+
+	filled := ""
+	if cycles > 0 {
+		filled = fmt.Sprintf(" after %d cycles", cycles)
+	}
+
+	tt := "✅*DCA Completed:* " + EscapeSpecialCharacters(fmt.Sprintf("$%s into $%s(%s)%s\n\n", inSymbol, outSymbol, outToken, filled)) + fmt.Sprintf("*Chain:* %s\n", dispchain)
+	tail := "*Notifier:* " + EscapeSpecialCharacters("lmk.fun")
+
+	return ll + tt + tail
+}
+
+func ConstructLimitOrderBotMessage(chain, fromLabel, fromAccount, event, inAmount, inSymbol, outAmount, outSymbol, outToken, price string, expiredAt int64, ispublic bool) string {
+	ll, dispchain := solAddressMessageHead(chain, fromLabel, fromAccount, ispublic)
+
+	tl := "📌*Limit Order:* "
+	if event == model.LimitOrderEventFill {
+		tl = "🎯*Limit Filled:* "
+	}
+
+	expiry := ""
+	if expiredAt > 0 {
+		expiry = fmt.Sprintf("*Expires:* %s\n", EscapeSpecialCharacters(time.Unix(expiredAt, 0).UTC().Format("2006-01-02 15:04 UTC")))
+	}
+
+	tt := tl + EscapeSpecialCharacters(fmt.Sprintf("%s $%s for %s $%s(%s)\n", inAmount, inSymbol, outAmount, outSymbol, outToken)) +
+		fmt.Sprintf("*Price:* %s\n", EscapeSpecialCharacters(fmt.Sprintf("%s $%s per $%s", price, outSymbol, inSymbol))) + expiry + "\n" + fmt.Sprintf("*Chain:* %s\n", dispchain)
+	tail := "*Notifier:* " + EscapeSpecialCharacters("lmk.fun")
+
+	return ll + tt + tail
+}
+
+func ConstructGraduateBotMessage(chain, fromLabel, fromAccount, tokenSymbol, token, migratedTo, mcap string, ispublic bool) string {
+	ll, dispchain := solAddressMessageHead(chain, fromLabel, fromAccount, ispublic)
+
+	venue := "completed the Pump.fun bonding curve"
+	if migratedTo == "raydium" {
+		venue = "migrated from Pump.fun to Raydium"
+	} else if migratedTo == "pumpswap" {
+		venue = "migrated from Pump.fun to PumpSwap"
+	}
+
+	tt := "🎓*Graduated:* " + EscapeSpecialCharacters(fmt.Sprintf("$%s(%s) %s\n", tokenSymbol, token, venue))
+	if mcap != "" {
+		tt += fmt.Sprintf("*Market Cap:* %s\n", EscapeSpecialCharacters("$"+convertMcap(mcap)))
+	}
+	tt += "\n" + fmt.Sprintf("*Chain:* %s\n", dispchain)
+	tail := "*Notifier:* " + EscapeSpecialCharacters("lmk.fun")
+
+	return ll + tt + tail
+}
+
+func ConstructMintBurnBotMessage(chain, fromLabel, fromAccount, direction, amount, tokenSymbol, token, amountB, tokenSymbolB, tokenB string, ispublic bool) string {
+	ll, dispchain := solAddressMessageHead(chain, fromLabel, fromAccount, ispublic)
+
+	tt := ""
+	switch direction {
+	case "Mint":
+		tt = "🪙*Minted:* " + EscapeSpecialCharacters(fmt.Sprintf("%s $%s(%s)\n", amount, tokenSymbol, token))
+	case "Burn":
+		tt = "🔥*Burned:* " + EscapeSpecialCharacters(fmt.Sprintf("%s $%s(%s)\n", amount, tokenSymbol, token))
+	default:
+		tl := "💧*Added Liquidity:* "
+		if direction == "RemoveLiquidity" {
+			tl = "🏜*Removed Liquidity:* "
+		}
+		tt = tl + EscapeSpecialCharacters(fmt.Sprintf("%s $%s(%s) + %s $%s(%s)\n", amount, tokenSymbol, token, amountB, tokenSymbolB, tokenB))
+	}
+
+	tt += "\n" + fmt.Sprintf("*Chain:* %s\n", dispchain)
+	tail := "*Notifier:* " + EscapeSpecialCharacters("lmk.fun")
+
+	return ll + tt + tail
+}
+
+// ordinal writes 2 as 2nd, 3 as 3rd and 11 as 11th
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+func buyHeader(tradeLabel string, position *model.PositionInfo) string {
+	switch tradeLabel {
+	case LabelFirstBuy:
+		return "💎*First Buy:*"
+	case LabelFreshBuy:
+		return "🌱*Fresh Buy:* "
+	case LabelReEntry:
+		return "🔁*Re\\-entry:* "
+	case LabelAccumulate:
+		if position != nil && position.BuyCount > 1 {
+			return fmt.Sprintf("➕*Added %s:* ", EscapeSpecialCharacters("("+ordinal(position.BuyCount)+" buy)"))
+		}
+		return "➕*Added:* "
+	}
+	return "🔥*Bought:* "
+}
+
+func soldHeader(tradeLabel string, position *model.PositionInfo) string {
+	switch tradeLabel {
+	case LabelSellAll:
+		return "🗑*Sell All:*"
+	case LabelRoundTrip:
+		if position != nil && position.HoldSeconds > 0 {
+			return fmt.Sprintf("🔄*Round Trip %s:* ", EscapeSpecialCharacters("(held "+formatDCAFrequency(int64(position.HoldSeconds))+")"))
+		}
+		return "🔄*Round Trip:* "
+	case LabelPartialSell:
+		if position != nil && position.SellPercent > 0 {
+			return fmt.Sprintf("✂️*Sold %s:* ", EscapeSpecialCharacters(strconv.FormatFloat(position.SellPercent, 'f', -1, 64)+"%"))
+		}
+	}
+	return "🗑*Sold:* "
+}
+
+func ConstructBuyBotMessage(chain, fromLabel, fromAccount, fromAmount, fromSymbol, fromValue, toAmount, toTokenSymbol, price, txHash, fromToken, listid, totoken string, ispublic bool, tradeLabel string, position *model.PositionInfo, tomc string) string {
 	dexschain := chain
 	dispchain := chain
 
@@ -262,10 +430,7 @@ func ConstructBuyBotMessage(chain, fromLabel, fromAccount, fromAmount, fromSymbo
 			ll = "*Address Alert*\n🦜" + EscapeSpecialCharacters("#") + fmt.Sprintf("*%s*\n\n", EscapeSpecialCharacters(fromLabel+" ("+fromaddr+")"))
 		}
 	}
-	tl := "🔥*Bought:* "
-	if tradeLabel == LabelFirstBuy {
-		tl = "💎*First Buy:*"
-	}
+	tl := buyHeader(tradeLabel, position)
 
 	tt := tl + EscapeSpecialCharacters(fmt.Sprintf("%s $%s($%s) for %s $%s\n", toAmount, toTokenSymbol, fromValue, fromAmount, fromSymbol)) + fmt.Sprintf("*Price:* %s\n*Market Cap:* %s\n\n", EscapeSpecialCharacters("$"+price), EscapeSpecialCharacters("$"+convertMcap(tomc))) + fmt.Sprintf("*Chain:* %s\n", dispchain)
 
@@ -274,7 +439,7 @@ func ConstructBuyBotMessage(chain, fromLabel, fromAccount, fromAmount, fromSymbo
 	return ll + tt + tail
 }
 
-func ConstructSoldBotMessage(chain, fromLabel, fromAccount, fromAmount, fromSymbol, toValue, toAmount, toTokenSymbol, price, txHash, toToken, listid, fromtoken string, ispublic bool, tradeLabel, frommc string) string {
+func ConstructSoldBotMessage(chain, fromLabel, fromAccount, fromAmount, fromSymbol, toValue, toAmount, toTokenSymbol, price, txHash, toToken, listid, fromtoken string, ispublic bool, tradeLabel string, position *model.PositionInfo, frommc string) string {
 	dexschain := chain
 	dispchain := chain
 
@@ -309,10 +474,7 @@ func ConstructSoldBotMessage(chain, fromLabel, fromAccount, fromAmount, fromSymb
 			ll = "*Address Alert*\n🦜" + EscapeSpecialCharacters("#") + fmt.Sprintf("*%s*\n\n", EscapeSpecialCharacters(fromLabel+" ("+fromaddr+")"))
 		}
 	}
-	tl := "🗑*Sold:* "
-	if tradeLabel == LabelSellAll {
-		tl = "🗑*Sell All:*"
-	}
+	tl := soldHeader(tradeLabel, position)
 	tt := tl + EscapeSpecialCharacters(fmt.Sprintf("%s $%s($%s) for %s $%s\n", fromAmount, fromSymbol, toValue, toAmount, toTokenSymbol)) + fmt.Sprintf("*Price:* %s\n*Market Cap:* %s\n\n", EscapeSpecialCharacters("$"+price), EscapeSpecialCharacters("$"+convertMcap(frommc))) + fmt.Sprintf("*Chain:* %s\n", dispchain)
 
 	tail := "*Notifier:* " + EscapeSpecialCharacters("lmk.fun")
@@ -594,7 +756,7 @@ func ConstructKOLMessage(listid, author, sentiment, chain, contractAddr, tokensy
 }
 
 func SendKafkaBotMsg(in *TgMessage) error {
-	data, err := json.Marshal(&in)
+	data, headers, err := solmsg.Marshal(solmsg.TgBotMessage, in)
 	if err != nil {
 		return err
 	}
@@ -602,7 +764,8 @@ func SendKafkaBotMsg(in *TgMessage) error {
 	cfg := config.GetKafkaConfig()
 	err = alikafka.GetKafkaProInst().Produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &cfg.ProducerTopic, Partition: kafka.PartitionAny},
-		Value:          []byte(data),
+		Value:          data,
+		Headers:        alikafka.Headers(headers),
 	}, nil)
 	if err != nil {
 		return fmt.Errorf("failed to produce message: %w", err)
@@ -42,7 +42,7 @@ func TestAddrSendBotMsg(t *testing.T) {
 	totoken := "2qEHjDLDLbuBgRYvsxhc5D6uDWAivNFZGan56P1tpump"
 	tomc := "2532000000000000000"
 
-	msg := ConstructSoldBotMessage("solana", fromLabel, fromAccount, fromAmount, fromSymbol, fromValue, toAmount, toTokenSymbol, price, txHash, fromToken, listid, totoken, true, LabelFirstBuy, tomc)
+	msg := ConstructSoldBotMessage("solana", fromLabel, fromAccount, fromAmount, fromSymbol, fromValue, toAmount, toTokenSymbol, price, txHash, fromToken, listid, totoken, true, LabelFirstBuy, nil, tomc)
 
 	fmt.Printf("\nmsg:\n%s\n", msg)
 
@@ -630,3 +630,123 @@ func TestHandleBotTg(t *testing.T) {
 		log.Fatal("HandleTgBotMessage failed:", err)
 	}
 }
+
+func TestDCABotMsg(t *testing.T) {
+	user := "EYTAQDCxSLyxQD8TwEMhMAcxgBmvYe1zoW5X6ftV8Y6"
+	token := "FTUf6mWA8MDVFBpCQVxxDJE7YSGAuTfseZ9QdoyfsT1c"
+
+	msg := ConstructDCAOpenBotMessage("solana", "whale", user, "50", "SOL", "WIF", token, 10, 3600, true)
+	if !strings.Contains(msg, EscapeSpecialCharacters("50 $SOL into $WIF("+token+") over 10 cycles every 1h")) {
+		t.Errorf("unexpected open msg:\n%s", msg)
+	}
+
+	msg = ConstructDCACompleteBotMessage("solana", "", user, "SOL", "WIF", token, 10, false)
+	if !strings.Contains(msg, "DCA Completed") || strings.Contains(msg, user) {
+		t.Errorf("unexpected complete msg:\n%s", msg)
+	}
+
+	for seconds, want := range map[int64]string{60: "1m", 90: "90s", 7200: "2h", 172800: "2d"} {
+		if got := formatDCAFrequency(seconds); got != want {
+			t.Errorf("formatDCAFrequency(%d) got %s, want %s", seconds, got, want)
+		}
+	}
+}
+
+func TestLimitOrderBotMsg(t *testing.T) {
+	user := "7Ywvr3QnDuCXoV23a543WoHd1iwy3kkrx7PwtUeVL7Y5"
+	token := "CuZ2sWgDAnRj1nmBE7pGRFSRks5pTkjGNaMYk46FLxQH"
+
+	msg := ConstructLimitOrderBotMessage("solana", "whale", user, model.LimitOrderEventPlace, "2", "SOL", "4000", "WIF", token, "2000", 1717186400, true)
+	if !strings.Contains(msg, "Limit Order") || !strings.Contains(msg, EscapeSpecialCharacters("2024-05-31 20:13 UTC")) {
+		t.Errorf("unexpected place msg:\n%s", msg)
+	}
+
+	msg = ConstructLimitOrderBotMessage("solana", "", user, model.LimitOrderEventFill, "1", "SOL", "2000", "WIF", token, "2000", 0, true)
+	if !strings.Contains(msg, "Limit Filled") || strings.Contains(msg, "Expires") {
+		t.Errorf("unexpected fill msg:\n%s", msg)
+	}
+}
+
+func TestGraduateBotMsg(t *testing.T) {
+	user := "BdCbScX1tx4g54LWaqykUWyLXGeSZubSjcgnDKEoph3Z"
+	token := "7D2C6WaUBiku7Rob9xDth9yVt9nhCEKTnUh7Y7L1qM7V"
+
+	msg := ConstructGraduateBotMessage("solana", "whale", user, "PEPE", token, "pumpswap", "69000", true)
+	if !strings.Contains(msg, "Graduated") || !strings.Contains(msg, "PumpSwap") || !strings.Contains(msg, "Market Cap") {
+		t.Errorf("unexpected migrate msg:\n%s", msg)
+	}
+
+	msg = ConstructGraduateBotMessage("solana", "", user, "PEPE", token, "", "", false)
+	if !strings.Contains(msg, "bonding curve") || strings.Contains(msg, "Market Cap") {
+		t.Errorf("unexpected complete msg:\n%s", msg)
+	}
+}
+
+func TestMintBurnBotMsg(t *testing.T) {
+	user := "Fq3mT8vBzXcN2kLpY7dRsG5jWhE9aUoK4iQ6tHbV1xZe"
+	token := "HqB7uswoVg4suaQiDP3wjxob1G5WdZ144zhdStwMCq7e"
+
+	msg := ConstructMintBurnBotMessage("solana", "dev", user, "Mint", "1000000", "DOG", token, "1000000", "DOG", token, true)
+	if !strings.Contains(msg, "Minted") || !strings.Contains(msg, EscapeSpecialCharacters("1000000 $DOG("+token+")")) {
+		t.Errorf("unexpected mint msg:\n%s", msg)
+	}
+
+	msg = ConstructMintBurnBotMessage("solana", "", user, "Burn", "250", "DOG", token, "", "", "", false)
+	if !strings.Contains(msg, "Burned") || strings.Contains(msg, user) {
+		t.Errorf("unexpected burn msg:\n%s", msg)
+	}
+
+	msg = ConstructMintBurnBotMessage("solana", "", user, "RemoveLiquidity", "5000", "DOG", token, "2", "SOL", "So11111111111111111111111111111111111111112", true)
+	if !strings.Contains(msg, "Removed Liquidity") || !strings.Contains(msg, EscapeSpecialCharacters(" + 2 $SOL(")) {
+		t.Errorf("unexpected liquidity msg:\n%s", msg)
+	}
+}
+
+func TestTradeLabelBotMsg(t *testing.T) {
+	user := "9atg38QyMRuFUhbr7SLR8yTvhFUsQsoQonfZpb2MLAwD"
+	token := "2qEHjDLDLbuBgRYvsxhc5D6uDWAivNFZGan56P1tpump"
+	sol := "So11111111111111111111111111111111111111112"
+
+	buy := func(label string, position *model.PositionInfo) string {
+		return ConstructBuyBotMessage("solana", "whale", user, "1", "SOL", "200", "1000", "Pnut", "0.2", "", sol, "", token, true, label, position, "200000")
+	}
+	sold := func(label string, position *model.PositionInfo) string {
+		return ConstructSoldBotMessage("solana", "whale", user, "1000", "Pnut", "200", "1", "SOL", "0.2", "", sol, "", token, true, label, position, "200000")
+	}
+
+	for msg, want := range map[string]string{
+		buy(LabelAccumulate, &model.PositionInfo{BuyCount: 3}): "*Added \\(3rd buy\\):*",
+		buy(LabelAccumulate, &model.PositionInfo{}):            "*Added:*",
+		buy(LabelReEntry, &model.PositionInfo{BuyCount: 1}):    "*Re\\-entry:*",
+		buy(LabelNone, nil): "*Bought:*",
+		sold(LabelPartialSell, &model.PositionInfo{SellPercent: 62.5}):                "*Sold 62\\.5%:*",
+		sold(LabelRoundTrip, &model.PositionInfo{SellPercent: 100, HoldSeconds: 300}): "*Round Trip \\(held 5m\\):*",
+		sold(LabelSellAll, &model.PositionInfo{SellPercent: 100}):                     "*Sell All:*",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("want %s in msg:\n%s", want, msg)
+		}
+	}
+
+	if got := ordinal(2) + ordinal(11) + ordinal(21) + ordinal(112); got != "2nd11th21st112th" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestPushTradeLabel(t *testing.T) {
+	pref := TrackedAddrCache{TgTxFreshBuy: true, TgTxSellAll: true}
+	if !pushBuyLabel(pref, LabelFreshBuy) || !pushBuyLabel(pref, LabelReEntry) || pushBuyLabel(pref, LabelAccumulate) || !pushBuyLabel(pref, LabelFirstBuy) {
+		t.Errorf("buy labels of %+v", pref)
+	}
+	if pref := (TrackedAddrCache{TgTxFirstBuy: true}); !pushBuyLabel(pref, LabelFirstBuy) || pushBuyLabel(pref, LabelFreshBuy) {
+		t.Errorf("buy labels of %+v", pref)
+	}
+	if !pushSellLabel(pref, LabelRoundTrip) || pushSellLabel(pref, LabelPartialSell) {
+		t.Errorf("sell labels of %+v", pref)
+	}
+
+	pref = TrackedAddrCache{TgTxAccumulate: true, TgTxPartialSell: true, TgTxRoundTrip: true}
+	if !pushBuyLabel(pref, LabelAccumulate) || !pushSellLabel(pref, LabelPartialSell) || pushSellLabel(pref, LabelSellAll) || !pushSellLabel(pref, LabelRoundTrip) {
+		t.Errorf("labels of %+v", pref)
+	}
+}
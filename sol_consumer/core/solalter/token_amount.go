@@ -0,0 +1,80 @@
+package solalter
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
+)
+
+// legAmount is the exact amount of one side of a swap, the float of messages from producers that
+// do not send the raw amount is read as its shortest literal
+func legAmount(raw string, decimals int, f float64) *big.Rat {
+	if raw != "" {
+		v, ok := new(big.Int).SetString(raw, 10)
+		if ok {
+			return new(big.Rat).SetFrac(v, pow10(decimals))
+		}
+	}
+	return floatRat(f)
+}
+
+func fromAmount(val model.SolSwapData) *big.Rat {
+	return legAmount(val.FromTokenRawAmount, val.FromTokenDecimals, val.FromTokenAmount)
+}
+
+func toAmount(val model.SolSwapData) *big.Rat {
+	return legAmount(val.ToTokenRawAmount, val.ToTokenDecimals, val.ToTokenAmount)
+}
+
+// legText is the amount as shown and stored, exact when the raw amount is known
+func legText(raw string, decimals int, f float64) string {
+	if raw == "" {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	text := legAmount(raw, decimals, f).FloatString(max(decimals, 0))
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	return text
+}
+
+func fromAmountText(val model.SolSwapData) string {
+	return legText(val.FromTokenRawAmount, val.FromTokenDecimals, val.FromTokenAmount)
+}
+
+func toAmountText(val model.SolSwapData) string {
+	return legText(val.ToTokenRawAmount, val.ToTokenDecimals, val.ToTokenAmount)
+}
+
+// amountValue is amt times price, only the result is rounded to a float
+func amountValue(amt *big.Rat, price float64) float64 {
+	res, _ := new(big.Rat).Mul(amt, floatRat(price)).Float64()
+	return res
+}
+
+// unitPrice is the price one unit of amt got for value, 0 for a zero amount
+func unitPrice(value float64, amt *big.Rat) float64 {
+	if amt.Sign() == 0 {
+		return 0
+	}
+	res, _ := new(big.Rat).Quo(floatRat(value), amt).Float64()
+	return res
+}
+
+func floatRat(f float64) *big.Rat {
+	v, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return v
+}
+
+func pow10(n int) *big.Int {
+	if n <= 0 {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,131 @@
+package solalter
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/db"
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// trackingChannel is notified with a payload like {"chain":"solana","address":"<addr>"} by the
+// trigger the producer ships in sol_producer/sql/lmk_tracking_changed.sql, an empty payload asks
+// for a full reload
+const trackingChannel = "lmk_tracking_changed"
+
+// trackingDebounce folds a bulk change into one round of cache refreshes
+const trackingDebounce = time.Second
+
+// the address ends up in a raw query, anything else than a chain name and base58 is dropped
+var (
+	chainPattern   = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+	addressPattern = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)
+)
+
+type trackingChange struct {
+	Chain   string `json:"chain"`
+	Address string `json:"address"`
+}
+
+// parseTrackingChanges dedups the payloads of one burst, full is set when one of them names no address
+func parseTrackingChanges(payloads []string) ([]trackingChange, bool) {
+	full := false
+	seen := make(map[trackingChange]bool)
+	res := make([]trackingChange, 0)
+
+	for _, v := range payloads {
+		var item trackingChange
+		if v == "" || json.Unmarshal([]byte(v), &item) != nil || item.Address == "" {
+			full = true
+			continue
+		}
+
+		item.Chain = strings.ToLower(item.Chain)
+		if !chainPattern.MatchString(item.Chain) || !addressPattern.MatchString(item.Address) {
+			logger.Logrus.WithFields(logrus.Fields{"Payload": v}).Error("tracking change payload invalid")
+			continue
+		}
+
+		if !seen[item] {
+			seen[item] = true
+			res = append(res, item)
+		}
+	}
+
+	return res, full
+}
+
+func refreshTrackAddrCache(payloads []string) {
+	changes, full := parseTrackingChanges(payloads)
+
+	if full {
+		err := UpdateAllTrackAddrCache()
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("tracking change reload all address cache failed")
+		}
+	}
+
+	// the full reload never drops an untracked address, so the named ones are always refreshed
+	for _, v := range changes {
+		err := delItem(v.Chain, v.Address)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Chain": v.Chain, "Address": v.Address, "ErrMsg": err}).Error("tracking change delete address cache failed")
+
+			continue
+		}
+
+		// not found means untracked, the deleted key is all that was needed
+		_, err = SetTrackAddrCache(v.Chain, v.Address)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Chain": v.Chain, "Address": v.Address, "ErrMsg": err}).Info("tracking change address not cached")
+
+			continue
+		}
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Data": changes, "Full": full}).Info("tracking change address cache refreshed")
+}
+
+// ListenTrackAddrChanges refreshes the address cache within seconds of a tracking change,
+// the 10 minute reload stays for notifications lost while the listener reconnects
+func ListenTrackAddrChanges() {
+	ln := pgdriver.NewListener(db.GetDB())
+
+	// the listener keeps the channel and listens again whenever it reconnects
+	err := ln.Listen(context.Background(), trackingChannel)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Channel": trackingChannel, "ErrMsg": err}).Error("listen tracking changes failed")
+	}
+
+	go coalesce(ln.Channel(), trackingDebounce, refreshTrackAddrCache)
+}
+
+// coalesce calls fn once per burst of notifications until in is closed
+func coalesce(in <-chan pgdriver.Notification, wait time.Duration, fn func(payloads []string)) {
+	for n := range in {
+		payloads := []string{n.Payload}
+		timer := time.NewTimer(wait)
+
+	burst:
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					timer.Stop()
+					fn(payloads)
+					return
+				}
+				payloads = append(payloads, n.Payload)
+			case <-timer.C:
+				break burst
+			}
+		}
+
+		fn(payloads)
+	}
+}
@@ -0,0 +1,55 @@
+package solalter
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+func TestParseTrackingChanges(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "sol_consumer.log"))
+
+	addr := "C3x9ivNXsXqiixFyyCmyhHEAo1WfZSEfZCcUyZnKRJAA"
+	payloads := []string{
+		`{"chain":"Solana","address":"` + addr + `"}`,
+		`{"chain":"solana","address":"` + addr + `"}`,
+		`{"chain":"solana","address":"x' OR '1'='1"}`,
+	}
+
+	changes, full := parseTrackingChanges(payloads)
+	want := []trackingChange{{Chain: "solana", Address: addr}}
+	if full || !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %v %v", changes, full)
+	}
+
+	changes, full = parseTrackingChanges(append(payloads, ""))
+	if !full || len(changes) != 1 {
+		t.Errorf("empty payload should reload all, got %v %v", changes, full)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	in := make(chan pgdriver.Notification)
+	bursts := make(chan []string, 4)
+	go coalesce(in, 50*time.Millisecond, func(payloads []string) {
+		bursts <- payloads
+	})
+
+	// a bulk change is one round of refreshes, a closed listener flushes what it still holds
+	in <- pgdriver.Notification{Channel: trackingChannel, Payload: "a"}
+	in <- pgdriver.Notification{Channel: trackingChannel, Payload: "b"}
+	close(in)
+
+	select {
+	case got := <-bursts:
+		if !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no refresh after burst")
+	}
+}
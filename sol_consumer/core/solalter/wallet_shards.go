@@ -0,0 +1,73 @@
+package solalter
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
+)
+
+const walletQueueSize = 100
+
+// walletShards is the execution model of the swap topics. the producer keys a batch by the
+// wallet that made it, so the batches of one wallet come off one partition in trade order.
+// a batch runs on the worker its wallet hashes to and a worker runs its batches one after
+// another, so the buy of a wallet is recorded and alerted before the sell that follows it.
+// batches of different wallets run on different workers in parallel. a full queue blocks
+// Submit, which stops the read loop instead of growing goroutines
+type walletShards struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+func newWalletShards(workers int) *walletShards {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	s := &walletShards{queues: make([]chan func(), workers)}
+	for i := range s.queues {
+		s.queues[i] = make(chan func(), walletQueueSize)
+
+		s.wg.Add(1)
+		go func(queue chan func()) {
+			defer s.wg.Done()
+			for job := range queue {
+				job()
+			}
+		}(s.queues[i])
+	}
+	return s
+}
+
+func (s *walletShards) Submit(wallet string, job func()) {
+	h := fnv.New32a()
+	h.Write([]byte(wallet))
+	s.queues[h.Sum32()%uint32(len(s.queues))] <- job
+}
+
+// Close runs the submitted jobs and returns once they are done
+func (s *walletShards) Close() {
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	s.wg.Wait()
+}
+
+// batchWallet is the wallet a batch is ordered by, the same one the producer keys it with
+func batchWallet(data []model.SolSwapData) string {
+	for _, v := range data {
+		if v.FromUserAccount != "" {
+			return v.FromUserAccount
+		}
+	}
+	for _, v := range data {
+		if v.ToUserAccount != "" {
+			return v.ToUserAccount
+		}
+	}
+	if len(data) > 0 {
+		return data[0].TxHash
+	}
+	return ""
+}
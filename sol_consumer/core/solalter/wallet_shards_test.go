@@ -0,0 +1,142 @@
+package solalter
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/alikafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_consumer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+func TestWalletShardsOrder(t *testing.T) {
+	shards := newWalletShards(4)
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	want := make(map[string][]int)
+
+	var running, peak int32
+	for i := 0; i < 200; i++ {
+		wallet := fmt.Sprintf("wallet%d", i%10)
+		want[wallet] = append(want[wallet], i)
+
+		shards.Submit(wallet, func() {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+
+			// a slow earlier trade must still finish before the next one of its wallet starts
+			time.Sleep(time.Duration(rand.Intn(300)) * time.Microsecond)
+
+			mu.Lock()
+			got[wallet] = append(got[wallet], i)
+			mu.Unlock()
+			atomic.AddInt32(&running, -1)
+		})
+	}
+	shards.Close()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wallet order broken, got %v", got)
+	}
+	if peak < 2 {
+		t.Errorf("wallets never ran in parallel")
+	}
+}
+
+func TestBatchWallet(t *testing.T) {
+	cases := []struct {
+		in   []model.SolSwapData
+		want string
+	}{
+		{[]model.SolSwapData{{TxHash: "tx", FromUserAccount: "wallet", ToUserAccount: "wallet"}}, "wallet"},
+		{[]model.SolSwapData{{TxHash: "tx", ToUserAccount: "receiver"}}, "receiver"},
+		{[]model.SolSwapData{{TxHash: "tx"}}, "tx"},
+		{nil, ""},
+	}
+
+	for _, c := range cases {
+		if got := batchWallet(c.in); got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+}
+
+// TestAlertSwapsPart parks only the swaps whose alert failed, a skipped alert is not a failure
+func TestAlertSwapsPart(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "sol_consumer.log"))
+
+	data, err := os.ReadFile("../../../solmsg/testdata/sol_swap_batch.v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden []model.SolSwapData
+	if _, err := solmsg.Unmarshal(solmsg.SolSwapBatch, solmsg.Headers(solmsg.SolSwapBatch), data, &golden); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := map[string]error{
+		"sent":      nil,
+		"untracked": fmt.Errorf("from %w,%s, %s", ErrNotRegistered, "solana", "x"),
+		"notfound":  ErrNotFound,
+		"ratelimit": ErrRateLimit,
+		"down":      errors.New("db down"),
+		"flaky":     errors.New("timeout"),
+	}
+	batch := make([]model.SolSwapData, 0)
+	for _, sig := range []string{"sent", "untracked", "notfound", "ratelimit", "down", "flaky"} {
+		v := golden[0]
+		v.TxHash = sig
+		batch = append(batch, v)
+	}
+
+	calls := make(map[string]int)
+	failed, err := alertSwaps(batch, func(v model.SolSwapData) error {
+		calls[v.TxHash]++
+		if v.TxHash == "flaky" && calls[v.TxHash] > 1 {
+			return nil
+		}
+		return errs[v.TxHash]
+	})
+	if len(failed) != 1 || failed[0].TxHash != "down" || err == nil {
+		t.Fatalf("failed %v, %v", failed, err)
+	}
+	if calls["down"] != 2 || calls["flaky"] != 2 || calls["untracked"] != 1 || calls["sent"] != 1 {
+		t.Errorf("calls %v", calls)
+	}
+
+	value, headers, err := solmsg.Marshal(solmsg.SolSwapBatch, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &kafka.Message{Key: []byte("wallet"), Value: value, Headers: alikafka.Headers(headers)}
+
+	var parked []model.SolSwapData
+	part := swapPart(msg, batch, failed)
+	if _, err := solmsg.Unmarshal(solmsg.SolSwapBatch, alikafka.ContractHeaders(part), part.Value, &parked); err != nil {
+		t.Fatal(err)
+	}
+	if len(parked) != 1 || parked[0].TxHash != "down" || string(part.Key) != "wallet" {
+		t.Errorf("parked %v", parked)
+	}
+
+	// a batch that failed as a whole parks as it came
+	if got := swapPart(msg, batch, batch); got != msg {
+		t.Error("whole batch re-encoded")
+	}
+}
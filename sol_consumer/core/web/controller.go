@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
@@ -48,15 +49,16 @@ func Run() {
 		}
 
 		go func() {
+			// Shutdown makes it return ErrServerClosed, Run has to return so the service drains
 			err := server.ListenAndServe()
-			if err != nil {
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
 				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Fatal("Server start failed")
 			}
 		}()
 
 		// Wait for interrupt signal to gracefully shutdown the server with
 		// a timeout of 5 seconds.
-		quit := make(chan os.Signal)
+		quit := make(chan os.Signal, 1)
 		// kill (no param) default send syscall.SIGTERM
 		// kill -2 is syscall.SIGINT
 		// kill -9 is syscall.SIGKILL but can't be caught, so don't need to add it
@@ -72,6 +74,6 @@ func Run() {
 			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err.Error()}).Error("Server forced to shutdown")
 		}
 
-		logger.Logrus.Info("Server start success")
+		logger.Logrus.Info("Server stopped")
 	}
 }
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
 
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/alikafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/geyser"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/rpcws"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/track"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
@@ -13,6 +17,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplay(os.Args[2:])
+		return
+	}
+
 	configPath := flag.String("config_path", "./", "config file")
 	logicLogFile := flag.String("logic_log_file", "./log/sol_producer.log", "logic log file")
 	flag.Parse()
@@ -30,9 +39,40 @@ func main() {
 
 	alikafka.InitKafka()
 
+	handler.StartRelay()
 	handler.SubAddrHistoryTxs()
+	handler.StartEnricher()
 
-	track.AddrTask()
+	switch source := config.GetIngestConfig().Source; source {
+	case "", "helius":
+		if cfg := config.GetHeliusConfig(); cfg.AuthHeader == "" && cfg.HMACSecret == "" {
+			if !cfg.AllowUnauthenticated {
+				log.Fatal("HeliusConfig.AuthHeader or HeliusConfig.HMACSecret is required")
+			}
+			log.Println("WARNING: HeliusConfig.AllowUnauthenticated is set, the webhook accepts calls from anyone")
+			logger.Logrus.Warn("main helius webhook accepts unauthenticated calls, HeliusConfig.AllowUnauthenticated is set")
+		}
+
+		mgr, err := track.StartWebhookManager()
+		if err != nil {
+			log.Fatal("start webhook manager failed:", err)
+		}
+		track.AddrTask(mgr.Sync)
+	case "geyser":
+		sub, err := geyser.Start(context.Background())
+		if err != nil {
+			log.Fatal("start geyser failed:", err)
+		}
+		track.AddrTask(sub.UpdateAccounts)
+	case "rpcws":
+		sub, err := rpcws.Start(context.Background())
+		if err != nil {
+			log.Fatal("start rpcws failed:", err)
+		}
+		track.AddrTask(sub.UpdateAccounts)
+	default:
+		log.Fatal("unknown ingest source:", source)
+	}
 
 	web.Run()
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+// parseReplayTime accepts RFC3339 or unix seconds
+func parseReplayTime(s string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config_path", "./", "config file")
+	logicLogFile := fs.String("logic_log_file", "./log/sol_producer_replay.log", "logic log file")
+	from := fs.String("from", "", "window start, RFC3339 or unix seconds")
+	to := fs.String("to", "", "window end, RFC3339 or unix seconds, default now")
+	signature := fs.String("signature", "", "only replay this signature")
+	topic := fs.String("topic", "", "kafka topic to publish to")
+	stdout := fs.Bool("stdout", false, "print parsed data instead of publishing")
+	label := fs.Bool("label", false, "fill trade labels from rpc, makes output non deterministic")
+	poolParams := fs.Bool("pool-params", false, "fill pool fee settings from rpc, makes output non deterministic")
+	fs.Parse(args)
+
+	logger.Init(*logicLogFile)
+	logger.SetLogLevel("info")
+
+	err := config.LoadConf(*configPath)
+	if err != nil {
+		log.Fatal("load config failed:", err)
+	}
+
+	if *from == "" {
+		log.Fatal("replay needs --from")
+	}
+
+	opts := handler.ReplayOptions{
+		Signature:  *signature,
+		Topic:      *topic,
+		Label:      *label,
+		PoolParams: *poolParams,
+		To:         time.Now(),
+	}
+
+	opts.From, err = parseReplayTime(*from)
+	if err != nil {
+		log.Fatal("invalid --from:", err)
+	}
+
+	if *to != "" {
+		opts.To, err = parseReplayTime(*to)
+		if err != nil {
+			log.Fatal("invalid --to:", err)
+		}
+	}
+
+	if *stdout {
+		opts.Out = os.Stdout
+	} else if *topic == "" {
+		log.Fatal("replay needs --topic or --stdout")
+	}
+
+	stats, err := handler.Replay(opts)
+	if err != nil {
+		log.Fatal("replay failed:", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "replay done, batches=%d txs=%d published=%d failed=%d\n", stats.Batches, stats.Txs, stats.Published, stats.Failed)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solrpc"
 )
 
 // one database one instance
@@ -51,6 +52,29 @@ type HeliusConfig struct {
 	TxTypes       string
 	DCAProgrameID string
 	ThreadData    []AddrThreadData
+
+	// AuthHeader is pushed to the helius webhook and must be echoed back in Authorization,
+	// RetiredAuthHeaders are still accepted while a rotation is in flight
+	AuthHeader         string
+	RetiredAuthHeaders []string
+	// HMACSecret signs the raw body, hex digest is read from SignatureHeader
+	HMACSecret         string
+	RetiredHMACSecrets []string
+	SignatureHeader    string
+	// AllowUnauthenticated accepts every webhook call while neither AuthHeader nor HMACSecret is
+	// set, for a local setup only. without it such a setup rejects every call
+	AllowUnauthenticated bool
+
+	// MaxAddrPerWebhook splits the tracked addresses over several webhooks, empty means 100000
+	MaxAddrPerWebhook int
+	// MaxBodyBytes rejects a larger webhook body with 413 before it is read, empty means 32MB
+	MaxBodyBytes int64
+}
+
+// AdminConfig guards /admin and /debug/vars, a call must send "Authorization: Bearer <Token>".
+// empty Token rejects every call
+type AdminConfig struct {
+	Token string
 }
 
 type DexNameConfig struct {
@@ -58,6 +82,85 @@ type DexNameConfig struct {
 	DexName         string
 }
 
+type DedupConfig struct {
+	TTLSeconds int
+	LRUSize    int
+}
+
+type ArchiveConfig struct {
+	Backend         string // "file" or "postgres", empty disables the archive
+	Dir             string
+	SegmentMaxBytes int64
+}
+
+type IngestConfig struct {
+	Source string // "helius", "geyser" or "rpcws", empty means helius
+}
+
+type GeyserConfig struct {
+	Endpoint   string
+	XToken     string
+	Insecure   bool
+	Commitment string // "processed", "confirmed" or "finalized", empty means confirmed
+	// MaxAccountsPerFilter splits the tracked addresses over several filters, servers cap the size of one
+	MaxAccountsPerFilter int
+	ReconnectSeconds     int
+}
+
+type RpcWSConfig struct {
+	WsEndpoint   string
+	HttpEndpoint string // getTransaction is fetched from here
+	Mode         string // "logs" or "account", empty means logs
+	Commitment   string // "confirmed" or "finalized", empty means confirmed
+	// MaxSubsPerConn shards the subscriptions over several connections, providers cap them per socket
+	MaxSubsPerConn   int
+	FetchWorkers     int
+	ReconnectSeconds int
+}
+
+type BackfillConfig struct {
+	Workers int // jobs run at once, empty means 20
+	// RequestsPerSecond is the helius request budget shared by every job, empty means 10
+	RequestsPerSecond float64
+	PageSize          int // empty means 50
+	TargetTxs         int // depth of a job queued from kafka, empty means 100
+	MaxAttempts       int // failed fetches of one page before the job fails, empty means 5
+}
+
+type LedgerConfig struct {
+	Size       int // tracked (wallet, mint) pairs, empty means 200000
+	TTLSeconds int // a pair is seeded again this long after its seed, empty means 86400
+	// FlipWindowSeconds turns a sell all this soon after the position opened into a round trip, empty means 600
+	FlipWindowSeconds int
+}
+
+type EnrichConfig struct {
+	Workers   int // transactions parsed, labelled and published at once, empty means 8
+	QueueSize int // transactions waiting per worker, empty means 1000
+	// EnqueueTimeoutMs is how long a webhook waits on a full queue before helius is asked to retry, empty means 1000
+	EnqueueTimeoutMs int
+	DrainSeconds     int // shutdown waits this long for the queued transactions, empty means 30
+	// MaxAttempts are the tries of a transaction that failed to publish, after them it stays in the
+	// intake journal for the next start. empty means 5
+	MaxAttempts int
+	RetryMs     int // wait after the first failed try, it doubles up to 30s. empty means 1000
+}
+
+// OutboxConfig keeps the webhook transactions from before the ack until they are handled, and
+// the parsed batches until kafka acked them, a relay drains those with retries
+type OutboxConfig struct {
+	// Backend is "file" or "postgres", empty means file. "none" keeps nothing, a transaction
+	// queued when the process dies or a publish that fails after the ack is lost
+	Backend   string
+	Dir       string // file backend, empty means ./outbox
+	BatchSize int    // records sent per relay pass, empty means 500
+	// RetryMs is the wait after a pass with failed records, it doubles up to 30s. empty means 1000
+	RetryMs int
+	// MaxAttempts moves a record that failed that often to the dead letter, the records of its key
+	// behind it go on. empty means 20
+	MaxAttempts int
+}
+
 // struct decode must has tag
 type Config struct {
 	PostgresqlConfig PostgresqlConfig `mapstructure:"PostgresqlConfig"`
@@ -65,6 +168,18 @@ type Config struct {
 	KafkaConf        KafkaConfig      `mapstructure:"KafkaConfig"`
 	HeliusConf       HeliusConfig     `mapstructure:"HeliusConfig"`
 	DexConf          []DexNameConfig  `mapstructure:"DexConfig"`
+	DedupConf        DedupConfig      `mapstructure:"DedupConfig"`
+	ArchiveConf      ArchiveConfig    `mapstructure:"ArchiveConfig"`
+	IngestConf       IngestConfig     `mapstructure:"IngestConfig"`
+	GeyserConf       GeyserConfig     `mapstructure:"GeyserConfig"`
+	RpcWSConf        RpcWSConfig      `mapstructure:"RpcWSConfig"`
+	BackfillConf     BackfillConfig   `mapstructure:"BackfillConfig"`
+	LedgerConf       LedgerConfig     `mapstructure:"LedgerConfig"`
+	EnrichConf       EnrichConfig     `mapstructure:"EnrichConfig"`
+	OutboxConf       OutboxConfig     `mapstructure:"OutboxConfig"`
+	AdminConf        AdminConfig      `mapstructure:"AdminConfig"`
+	// RPCConf lists the solana rpc endpoints, empty falls back to helius rpc with HeliusConfig.APIKey
+	RPCConf solrpc.Config `mapstructure:"RPCConfig"`
 }
 
 var (
@@ -166,3 +281,69 @@ func GetDexConfig() []DexNameConfig {
 	defer configMutex.RUnlock()
 	return config.DexConf
 }
+
+func GetDedupConfig() DedupConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.DedupConf
+}
+
+func GetArchiveConfig() ArchiveConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.ArchiveConf
+}
+
+func GetIngestConfig() IngestConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.IngestConf
+}
+
+func GetGeyserConfig() GeyserConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.GeyserConf
+}
+
+func GetRpcWSConfig() RpcWSConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.RpcWSConf
+}
+
+func GetBackfillConfig() BackfillConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.BackfillConf
+}
+
+func GetRPCConfig() solrpc.Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.RPCConf
+}
+
+func GetLedgerConfig() LedgerConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.LedgerConf
+}
+
+func GetEnrichConfig() EnrichConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.EnrichConf
+}
+
+func GetOutboxConfig() OutboxConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.OutboxConf
+}
+
+func GetAdminConfig() AdminConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config.AdminConf
+}
@@ -0,0 +1,15 @@
+package alikafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+// Headers puts the contract headers of solmsg on a kafka message
+func Headers(in []solmsg.Header) []kafka.Header {
+	res := make([]kafka.Header, 0, len(in))
+	for _, v := range in {
+		res = append(res, kafka.Header(v))
+	}
+	return res
+}
@@ -37,7 +37,10 @@ func GetKafkaInst() *kafka.Producer {
 			"linger.ms":           10,
 			"retries":             30,
 			"retry.backoff.ms":    1000,
-			"acks":                "1"}
+			// a retried batch is not written twice and one partition keeps the produce order
+			"enable.idempotence":                    true,
+			"max.in.flight.requests.per.connection": 5,
+			"acks":                                  "all"}
 		kafkaconf.SetKey("bootstrap.servers", cfg.Host)
 
 		switch cfg.Protocol {
@@ -148,7 +151,10 @@ func GetKafkaHistoryInst() *kafka.Producer {
 			"linger.ms":           10,
 			"retries":             30,
 			"retry.backoff.ms":    1000,
-			"acks":                "1"}
+			// a retried batch is not written twice and one partition keeps the produce order
+			"enable.idempotence":                    true,
+			"max.in.flight.requests.per.connection": 5,
+			"acks":                                  "all"}
 		kafkaconf.SetKey("bootstrap.servers", cfg.Host)
 
 		switch cfg.Protocol {
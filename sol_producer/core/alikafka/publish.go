@@ -0,0 +1,42 @@
+package alikafka
+
+import (
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// PublishAll produces the messages in order and waits for the delivery report of each, the
+// error at i is the one of msgs[i]. the producer is idempotent, messages of one key are written
+// in the order given
+func PublishAll(p *kafka.Producer, msgs []*kafka.Message) []error {
+	errs := make([]error, len(msgs))
+	reports := make(chan kafka.Event, len(msgs))
+
+	sent := 0
+	for i, msg := range msgs {
+		msg.Opaque = i
+		err := p.Produce(msg, reports)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to produce message: %w", err)
+			continue
+		}
+		sent++
+	}
+
+	// the delivery channel only gets the reports of these messages
+	for ; sent > 0; sent-- {
+		ev := (<-reports).(*kafka.Message)
+
+		i, _ := ev.Opaque.(int)
+		if ev.TopicPartition.Error != nil {
+			errs[i] = fmt.Errorf("delivery failed: %w", ev.TopicPartition.Error)
+		}
+	}
+	return errs
+}
+
+// Publish produces msg and returns once kafka has acked it
+func Publish(p *kafka.Producer, msg *kafka.Message) error {
+	return PublishAll(p, []*kafka.Message{msg})[0]
+}
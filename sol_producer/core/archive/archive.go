@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+// Batch is one accepted webhook body kept verbatim
+type Batch struct {
+	ReceivedAt int64           `json:"received_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type Store interface {
+	Append(b Batch) error
+	// Scan walks batches received in [from, to] in arrival order
+	Scan(from, to time.Time, fn func(Batch) error) error
+}
+
+var store Store
+var once sync.Once
+
+// GetStore returns the configured archive, nil when archiving is disabled
+func GetStore() Store {
+	once.Do(func() {
+		cfg := config.GetArchiveConfig()
+
+		switch cfg.Backend {
+		case "file":
+			store = newFileStore(cfg.Dir, cfg.SegmentMaxBytes)
+		case "postgres":
+			store = newPGStore()
+		case "":
+			store = nil
+		default:
+			logger.Logrus.WithFields(logrus.Fields{"Backend": cfg.Backend}).Error("unknown archive backend, archive disabled")
+			store = nil
+		}
+	})
+	return store
+}
+
+func Append(payload []byte) error {
+	s := GetStore()
+	if s == nil {
+		return nil
+	}
+
+	return s.Append(Batch{
+		ReceivedAt: time.Now().Unix(),
+		Payload:    json.RawMessage(payload),
+	})
+}
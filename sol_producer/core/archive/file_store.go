@@ -0,0 +1,186 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentTimeLayout      = "20060102T15"
+	defaultSegmentMaxBytes = 512 << 20
+	maxLineBytes           = 64 << 20
+)
+
+// fileStore appends batches as json lines into hourly segments,
+// a segment rolls over early once it passes maxBytes
+type fileStore struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+
+	file    *os.File
+	hour    string
+	seq     int
+	written int64
+}
+
+func newFileStore(dir string, maxBytes int64) *fileStore {
+	if dir == "" {
+		dir = "./archive"
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultSegmentMaxBytes
+	}
+
+	return &fileStore{dir: dir, maxBytes: maxBytes}
+}
+
+func segmentName(hour string, seq int) string {
+	return fmt.Sprintf("webhook-%s-%04d.jsonl", hour, seq)
+}
+
+func (s *fileStore) rotate(hour string) error {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	if hour != s.hour {
+		s.hour = hour
+		s.seq = 0
+	} else {
+		s.seq++
+	}
+
+	// never reopen a segment written by a previous process
+	for {
+		path := filepath.Join(s.dir, segmentName(s.hour, s.seq))
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+
+			s.file = f
+			s.written = 0
+			return nil
+		}
+		s.seq++
+	}
+}
+
+func (s *fileStore) Append(b Batch) error {
+	line, err := json.Marshal(&b)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hour := time.Unix(b.ReceivedAt, 0).UTC().Format(segmentTimeLayout)
+	if s.file == nil || hour != s.hour || s.written+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(hour); err != nil {
+			return fmt.Errorf("rotate archive segment failed, %v", err)
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("write archive segment failed, %v", err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) segments(from, to time.Time) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fromHour := from.UTC().Truncate(time.Hour)
+	res := make([]string, 0)
+	for _, v := range entries {
+		name := v.Name()
+		if v.IsDir() || !strings.HasPrefix(name, "webhook-") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, "webhook-"), ".jsonl"), "-")
+		if len(parts) != 2 {
+			continue
+		}
+
+		hour, err := time.Parse(segmentTimeLayout, parts[0])
+		if err != nil {
+			continue
+		}
+
+		if hour.Before(fromHour) || hour.After(to.UTC()) {
+			continue
+		}
+
+		res = append(res, name)
+	}
+
+	// layout and zero padded seq make lexical order the write order
+	sort.Strings(res)
+	return res, nil
+}
+
+func (s *fileStore) Scan(from, to time.Time, fn func(Batch) error) error {
+	names, err := s.segments(from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := s.scanFile(filepath.Join(s.dir, name), from, to, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *fileStore) scanFile(path string, from, to time.Time, fn func(Batch) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1<<20), maxLineBytes)
+	for scanner.Scan() {
+		var b Batch
+		if err := json.Unmarshal(scanner.Bytes(), &b); err != nil {
+			// a torn last line after a crash is skipped, not fatal
+			continue
+		}
+
+		if b.ReceivedAt < from.Unix() || b.ReceivedAt > to.Unix() {
+			continue
+		}
+
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
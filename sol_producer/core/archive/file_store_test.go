@@ -0,0 +1,43 @@
+package archive
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileStoreAppendScan(t *testing.T) {
+	dir := t.TempDir()
+	s := newFileStore(dir, 64)
+
+	base := time.Date(2024, 5, 1, 10, 59, 0, 0, time.UTC)
+	payloads := []string{`[{"signature":"a"}]`, `[{"signature":"b"}]`, `[{"signature":"c"}]`}
+	times := []time.Time{base, base.Add(30 * time.Second), base.Add(2 * time.Minute)}
+
+	for i := range payloads {
+		err := s.Append(Batch{ReceivedAt: times[i].Unix(), Payload: json.RawMessage(payloads[i])})
+		if err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	// small segment size forces a rollover inside the same hour
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("got %d segments, want 3", len(entries))
+	}
+
+	got := make([]string, 0)
+	err := s.Scan(base.Add(10*time.Second), base.Add(5*time.Minute), func(b Batch) error {
+		got = append(got, string(b.Payload))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+
+	if len(got) != 2 || got[0] != payloads[1] || got[1] != payloads[2] {
+		t.Errorf("got %v, want %v", got, payloads[1:])
+	}
+}
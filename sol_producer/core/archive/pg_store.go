@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"context"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/db"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+const pgScanPageSize = 500
+
+type pgStore struct{}
+
+func newPGStore() *pgStore {
+	return &pgStore{}
+}
+
+func (s *pgStore) Append(b Batch) error {
+	record := &model.WebhookArchiveRecord{
+		ReceivedAt: time.Unix(b.ReceivedAt, 0),
+		Payload:    b.Payload,
+	}
+
+	_, err := db.GetDB().NewInsert().Model(record).Exec(context.Background())
+	return err
+}
+
+func (s *pgStore) Scan(from, to time.Time, fn func(Batch) error) error {
+	lastID := int64(0)
+	for {
+		var records []model.WebhookArchiveRecord
+		err := db.GetDB().NewSelect().Model(&records).
+			Where("received_at >= ?", from).
+			Where("received_at <= ?", to).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(pgScanPageSize).
+			Scan(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for _, v := range records {
+			err := fn(Batch{ReceivedAt: v.ReceivedAt.Unix(), Payload: v.Payload})
+			if err != nil {
+				return err
+			}
+			lastID = v.ID
+		}
+
+		if len(records) < pgScanPageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/redis"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const (
+	ScopeLive    = "live"
+	ScopeHistory = "history"
+
+	defaultTTL     = 24 * time.Hour
+	defaultLRUSize = 100000
+)
+
+var cache *lru
+var once sync.Once
+
+func getLRU() *lru {
+	once.Do(func() {
+		size := config.GetDedupConfig().LRUSize
+		if size <= 0 {
+			size = defaultLRUSize
+		}
+
+		cache = newLRU(size)
+	})
+	return cache
+}
+
+func getTTL() time.Duration {
+	ttl := time.Duration(config.GetDedupConfig().TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	return ttl
+}
+
+func cacheKey(scope, signature string) string {
+	return fmt.Sprintf("dedup:solana:%s:%s", scope, signature)
+}
+
+// Claim marks the signature as published and returns false when another delivery already owns it.
+// redis errors fail open so an outage never drops data.
+func Claim(scope, signature string) bool {
+	if signature == "" {
+		return true
+	}
+
+	key := cacheKey(scope, signature)
+	now := time.Now()
+	ttl := getTTL()
+
+	if getLRU().contains(key, now) {
+		metrics.Incr("dedup_dropped_" + scope)
+		return false
+	}
+
+	claimed, err := redis.GetRedisInst().SetNX(context.Background(), key, now.Unix(), ttl).Result()
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Key": key, "ErrMsg": err}).Error("dedup Claim set redis failed")
+		claimed = true
+	}
+
+	getLRU().add(key, now.Add(ttl))
+
+	if !claimed {
+		metrics.Incr("dedup_dropped_" + scope)
+	}
+
+	return claimed
+}
+
+// Release drops a claim so a failed publish can be retried by the next delivery
+func Release(scope, signature string) {
+	if signature == "" {
+		return
+	}
+
+	key := cacheKey(scope, signature)
+	getLRU().remove(key)
+
+	err := redis.GetRedisInst().Del(context.Background(), key).Err()
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Key": key, "ErrMsg": err}).Error("dedup Release del redis failed")
+	}
+}
@@ -0,0 +1,76 @@
+package dedup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key    string
+	expire time.Time
+}
+
+// lru is a small ttl-aware lru so hot duplicates never reach redis
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (l *lru) contains(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return false
+	}
+
+	if now.After(elem.Value.(*lruEntry).expire) {
+		l.ll.Remove(elem)
+		delete(l.items, key)
+		return false
+	}
+
+	l.ll.MoveToFront(elem)
+	return true
+}
+
+func (l *lru) add(key string, expire time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		elem.Value.(*lruEntry).expire = expire
+		l.ll.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.ll.PushFront(&lruEntry{key: key, expire: expire})
+
+	for l.ll.Len() > l.size {
+		last := l.ll.Back()
+		l.ll.Remove(last)
+		delete(l.items, last.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.ll.Remove(elem)
+		delete(l.items, key)
+	}
+}
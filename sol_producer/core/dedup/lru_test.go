@@ -0,0 +1,35 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictAndExpire(t *testing.T) {
+	now := time.Now()
+	l := newLRU(2)
+
+	l.add("a", now.Add(time.Minute))
+	l.add("b", now.Add(time.Minute))
+	if !l.contains("a", now) {
+		t.Fatalf("a should be cached")
+	}
+
+	// b is now the oldest entry and gets evicted
+	l.add("c", now.Add(time.Minute))
+	if l.contains("b", now) {
+		t.Errorf("b should be evicted")
+	}
+	if !l.contains("a", now) || !l.contains("c", now) {
+		t.Errorf("a and c should be cached")
+	}
+
+	if l.contains("a", now.Add(2*time.Minute)) {
+		t.Errorf("a should be expired")
+	}
+
+	l.remove("c")
+	if l.contains("c", now) {
+		t.Errorf("c should be removed")
+	}
+}
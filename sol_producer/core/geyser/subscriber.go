@@ -0,0 +1,326 @@
+package geyser
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/archive"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const subscribeMethod = "/geyser.Geyser/Subscribe"
+
+var subscribeStream = grpc.StreamDesc{
+	StreamName:    "Subscribe",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// Subscriber streams transactions of the tracked addresses from a yellowstone geyser endpoint
+type Subscriber struct {
+	cfg    config.GeyserConfig
+	handle func(in []handler.HeliusData) error
+
+	mu       sync.Mutex
+	accounts []string
+	// changed wakes the live stream to resend its filters
+	changed chan struct{}
+}
+
+// NewSubscriber hands every decoded transaction to handle, usually handler.HandleData
+func NewSubscriber(cfg config.GeyserConfig, handle func(in []handler.HeliusData) error) *Subscriber {
+	return &Subscriber{
+		cfg:     cfg,
+		handle:  handle,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// UpdateAccounts replaces the tracked address set, a live stream picks it up without reconnecting
+func (s *Subscriber) UpdateAccounts(addrs []string) error {
+	accounts := append([]string(nil), addrs...)
+	sort.Strings(accounts)
+
+	s.mu.Lock()
+	s.accounts = accounts
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *Subscriber) request() (*SubscribeRequest, error) {
+	commitment, err := commitmentLevel(s.cfg.Commitment)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	accounts := s.accounts
+	s.mu.Unlock()
+
+	size := s.cfg.MaxAccountsPerFilter
+	if size <= 0 {
+		size = 10000
+	}
+
+	// an empty account_include matches every transaction on chain, no accounts means no filter
+	req := &SubscribeRequest{Filters: make(map[string][]string), Commitment: commitment}
+	for i := 0; i < len(accounts); i += size {
+		end := i + size
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		req.Filters["tracked_"+strconv.Itoa(i/size)] = accounts[i:end]
+	}
+	return req, nil
+}
+
+func (s *Subscriber) dial() (*grpc.ClientConn, error) {
+	creds := credentials.NewTLS(&tls.Config{})
+	if s.cfg.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	return grpc.NewClient(s.cfg.Endpoint,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(64*1024*1024)),
+	)
+}
+
+// Run keeps a stream open until ctx is done, reconnecting with backoff
+func (s *Subscriber) Run(ctx context.Context) {
+	base := time.Duration(s.cfg.ReconnectSeconds) * time.Second
+	if base <= 0 {
+		base = time.Second
+	}
+
+	delay := base
+	for {
+		start := time.Now()
+		err := s.subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		metrics.Incr("geyser_reconnect")
+		logger.Logrus.WithFields(logrus.Fields{"Endpoint": s.cfg.Endpoint, "ErrMsg": err, "Delay": delay.String()}).Error("geyser subscribe stream closed")
+
+		// a stream that stayed up for a while starts the backoff over
+		if time.Since(start) > time.Minute {
+			delay = base
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+}
+
+func (s *Subscriber) subscribe(ctx context.Context) error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if s.cfg.XToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-token", s.cfg.XToken)
+	}
+
+	stream, err := conn.NewStream(ctx, &subscribeStream, subscribeMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request()
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Endpoint": s.cfg.Endpoint, "Filters": len(req.Filters)}).Info("geyser subscribe stream opened")
+
+	updates := make(chan *SubscribeUpdate)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			update := &SubscribeUpdate{}
+			if err := stream.RecvMsg(update); err != nil {
+				recvErr <- err
+				return
+			}
+
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// grpc streams allow one sender, pings and filter changes are both sent from here
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-recvErr:
+			return err
+		case <-s.changed:
+			req, err := s.request()
+			if err != nil {
+				return err
+			}
+			if err := stream.SendMsg(req); err != nil {
+				return err
+			}
+			logger.Logrus.WithFields(logrus.Fields{"Filters": len(req.Filters)}).Info("geyser subscribe filters updated")
+		case update := <-updates:
+			if update.Ping {
+				if err := stream.SendMsg(&SubscribeRequest{PingID: 1}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if update.Transaction != nil {
+				s.handleTransaction(update)
+			}
+		}
+	}
+}
+
+func (s *Subscriber) handleTransaction(update *SubscribeUpdate) {
+	tx := update.Transaction
+	if tx.IsVote || tx.Meta.Failed {
+		return
+	}
+
+	blockTime := update.CreatedAt
+	if blockTime == 0 {
+		blockTime = time.Now().Unix()
+	}
+
+	data := toRawTransaction(tx, blockTime).HeliusData()
+	in := []handler.HeliusData{data}
+	metrics.Incr("geyser_tx_received")
+
+	body, err := json.Marshal(&in)
+	if err == nil {
+		err = archive.Append(body)
+	}
+	if err != nil {
+		metrics.Incr("archive_append_failed")
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": data.Signature, "ErrMsg": err}).Error("geyser archive raw data failed")
+	}
+
+	err = s.handle(in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": data.Signature, "ErrMsg": err}).Error("geyser handle transaction failed")
+	}
+}
+
+func toRawTransaction(tx *TransactionUpdate, blockTime int64) *handler.RawTransaction {
+	msg := tx.Tx.Message
+	meta := tx.Meta
+
+	keys := make([]string, 0, len(msg.AccountKeys)+len(meta.LoadedWritableAddresses)+len(meta.LoadedReadonlyAddresses))
+	for _, list := range [][][]byte{msg.AccountKeys, meta.LoadedWritableAddresses, meta.LoadedReadonlyAddresses} {
+		for _, v := range list {
+			keys = append(keys, base58.Encode(v))
+		}
+	}
+
+	res := &handler.RawTransaction{
+		Signature:         base58.Encode(tx.Signature),
+		Slot:              tx.Slot,
+		BlockTime:         blockTime,
+		Failed:            meta.Failed,
+		Fee:               meta.Fee,
+		AccountKeys:       keys,
+		Instructions:      make([]handler.RawInstruction, 0, len(msg.Instructions)),
+		InnerInstructions: make(map[int][]handler.RawInstruction),
+		PreBalances:       meta.PreBalances,
+		PostBalances:      meta.PostBalances,
+		PreTokenBalances:  toRawTokenBalances(meta.PreTokenBalances),
+		PostTokenBalances: toRawTokenBalances(meta.PostTokenBalances),
+	}
+
+	for _, ix := range msg.Instructions {
+		res.Instructions = append(res.Instructions, toRawInstruction(ix))
+	}
+	for _, inner := range meta.InnerInstructions {
+		for _, ix := range inner.Instructions {
+			res.InnerInstructions[int(inner.Index)] = append(res.InnerInstructions[int(inner.Index)], toRawInstruction(ix))
+		}
+	}
+
+	return res
+}
+
+func toRawInstruction(ix CompiledInstruction) handler.RawInstruction {
+	accounts := make([]int, 0, len(ix.Accounts))
+	for _, v := range ix.Accounts {
+		accounts = append(accounts, int(v))
+	}
+	return handler.RawInstruction{ProgramIDIndex: int(ix.ProgramIDIndex), Accounts: accounts, Data: ix.Data}
+}
+
+func toRawTokenBalances(in []TokenBalance) []handler.RawTokenBalance {
+	res := make([]handler.RawTokenBalance, 0, len(in))
+	for _, v := range in {
+		res = append(res, handler.RawTokenBalance{
+			AccountIndex: int(v.AccountIndex),
+			Mint:         v.Mint,
+			Owner:        v.Owner,
+			Decimals:     int(v.Decimals),
+			Amount:       v.Amount,
+		})
+	}
+	return res
+}
+
+// Start subscribes in the background, feed the tracked addresses through UpdateAccounts
+func Start(ctx context.Context) (*Subscriber, error) {
+	cfg := config.GetGeyserConfig()
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("geyser endpoint is empty")
+	}
+	if _, err := commitmentLevel(cfg.Commitment); err != nil {
+		return nil, err
+	}
+
+	s := NewSubscriber(cfg, handler.Submit)
+	go s.Run(ctx)
+	return s, nil
+}
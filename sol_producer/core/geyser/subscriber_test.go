@@ -0,0 +1,347 @@
+package geyser
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// recordedTx is a captured geyser transaction update, bytes fields are base58
+type recordedTx struct {
+	Slot                    uint64                `json:"slot"`
+	Signature               string                `json:"signature"`
+	AccountKeys             []string              `json:"account_keys"`
+	LoadedWritableAddresses []string              `json:"loaded_writable_addresses"`
+	LoadedReadonlyAddresses []string              `json:"loaded_readonly_addresses"`
+	Instructions            []recordedInstruction `json:"instructions"`
+	InnerInstructions       []struct {
+		Index        uint32                `json:"index"`
+		Instructions []recordedInstruction `json:"instructions"`
+	} `json:"inner_instructions"`
+	Fee               uint64                 `json:"fee"`
+	PreBalances       []uint64               `json:"pre_balances"`
+	PostBalances      []uint64               `json:"post_balances"`
+	PreTokenBalances  []recordedTokenBalance `json:"pre_token_balances"`
+	PostTokenBalances []recordedTokenBalance `json:"post_token_balances"`
+}
+
+type recordedInstruction struct {
+	ProgramIDIndex uint32 `json:"program_id_index"`
+	Accounts       []int  `json:"accounts"`
+	Data           string `json:"data"`
+}
+
+type recordedTokenBalance struct {
+	AccountIndex uint32 `json:"account_index"`
+	Mint         string `json:"mint"`
+	Decimals     uint32 `json:"decimals"`
+	Amount       string `json:"amount"`
+	Owner        string `json:"owner"`
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBase58(t *testing.T, b []byte, num protowire.Number, s string) []byte {
+	v, err := base58.Decode(s)
+	if err != nil {
+		t.Fatalf("decode %s failed: %v", s, err)
+	}
+	return appendMessage(b, num, v)
+}
+
+func encodeInstruction(t *testing.T, ix recordedInstruction) []byte {
+	accounts := make([]byte, 0, len(ix.Accounts))
+	for _, v := range ix.Accounts {
+		accounts = append(accounts, byte(v))
+	}
+
+	b := appendVarint(nil, 1, uint64(ix.ProgramIDIndex))
+	b = appendMessage(b, 2, accounts)
+	return appendBase58(t, b, 3, ix.Data)
+}
+
+func encodeTokenBalance(tb recordedTokenBalance) []byte {
+	var ui []byte
+	ui = appendVarint(ui, 2, uint64(tb.Decimals))
+	ui = appendMessage(ui, 3, []byte(tb.Amount))
+
+	b := appendVarint(nil, 1, uint64(tb.AccountIndex))
+	b = appendMessage(b, 2, []byte(tb.Mint))
+	b = appendMessage(b, 3, ui)
+	return appendMessage(b, 4, []byte(tb.Owner))
+}
+
+func packed(v []uint64) []byte {
+	var b []byte
+	for _, x := range v {
+		b = protowire.AppendVarint(b, x)
+	}
+	return b
+}
+
+// encodeUpdate builds the SubscribeUpdate bytes a yellowstone server would send
+func encodeUpdate(t *testing.T, rec recordedTx, createdAt int64) []byte {
+	var msg []byte
+	for _, v := range rec.AccountKeys {
+		msg = appendBase58(t, msg, 2, v)
+	}
+	for _, ix := range rec.Instructions {
+		msg = appendMessage(msg, 4, encodeInstruction(t, ix))
+	}
+	msg = appendVarint(msg, 5, 1)
+
+	tx := appendBase58(t, nil, 1, rec.Signature)
+	tx = appendMessage(tx, 2, msg)
+
+	meta := appendVarint(nil, 2, rec.Fee)
+	meta = appendMessage(meta, 3, packed(rec.PreBalances))
+	meta = appendMessage(meta, 4, packed(rec.PostBalances))
+	for _, inner := range rec.InnerInstructions {
+		b := appendVarint(nil, 1, uint64(inner.Index))
+		for _, ix := range inner.Instructions {
+			b = appendMessage(b, 2, encodeInstruction(t, ix))
+		}
+		meta = appendMessage(meta, 5, b)
+	}
+	for _, v := range rec.PreTokenBalances {
+		meta = appendMessage(meta, 7, encodeTokenBalance(v))
+	}
+	for _, v := range rec.PostTokenBalances {
+		meta = appendMessage(meta, 8, encodeTokenBalance(v))
+	}
+	for _, v := range rec.LoadedWritableAddresses {
+		meta = appendBase58(t, meta, 12, v)
+	}
+	for _, v := range rec.LoadedReadonlyAddresses {
+		meta = appendBase58(t, meta, 13, v)
+	}
+
+	info := appendBase58(t, nil, 1, rec.Signature)
+	info = appendMessage(info, 3, tx)
+	info = appendMessage(info, 4, meta)
+
+	update := appendMessage(nil, 1, info)
+	update = appendVarint(update, 2, rec.Slot)
+
+	b := appendMessage(nil, 1, []byte("tracked_0"))
+	b = appendMessage(b, 4, update)
+	return appendMessage(b, 11, appendVarint(nil, 1, uint64(createdAt)))
+}
+
+type rawMessage []byte
+
+func (m rawMessage) Marshal() ([]byte, error) {
+	return m, nil
+}
+
+// receivedRequest decodes what the fake server needs from a SubscribeRequest
+type receivedRequest struct {
+	accounts []string
+	ping     bool
+}
+
+func (r *receivedRequest) Unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 3:
+			return readFields(f.bytes, func(entry field) error {
+				if entry.num != 2 {
+					return nil
+				}
+				return readFields(entry.bytes, func(filter field) error {
+					if filter.num == 3 {
+						r.accounts = append(r.accounts, string(filter.bytes))
+					}
+					return nil
+				})
+			})
+		case 9:
+			r.ping = true
+		}
+		return nil
+	})
+}
+
+// fakeGeyser replays recorded updates once the first filter arrives and reports every request
+type fakeGeyser struct {
+	updates  [][]byte
+	requests chan *receivedRequest
+}
+
+func (f *fakeGeyser) subscribe(_ interface{}, stream grpc.ServerStream) error {
+	first := true
+	for {
+		req := &receivedRequest{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+		f.requests <- req
+
+		if first && len(req.accounts) > 0 {
+			first = false
+			// a ping first, the client has to answer it without dropping the stream
+			ping := appendMessage(nil, 6, nil)
+			if err := stream.SendMsg(rawMessage(ping)); err != nil {
+				return err
+			}
+			for _, v := range f.updates {
+				if err := stream.SendMsg(rawMessage(v)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func startFakeGeyser(t *testing.T, f *fakeGeyser) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "geyser.Geyser",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Subscribe",
+			Handler:       f.subscribe,
+			ServerStreams: true,
+			ClientStreams: true,
+		}},
+	}, f)
+
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func loadRecordedTx(t *testing.T, name string) recordedTx {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture %s failed: %v", name, err)
+	}
+
+	var rec recordedTx
+	if err := json.Unmarshal(body, &rec); err != nil {
+		t.Fatalf("decode fixture %s failed: %v", name, err)
+	}
+	return rec
+}
+
+func waitRequest(t *testing.T, f *fakeGeyser, ping bool) *receivedRequest {
+	t.Helper()
+
+	for {
+		select {
+		case req := <-f.requests:
+			if req.ping == ping {
+				return req
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no request with ping=%v", ping)
+			return nil
+		}
+	}
+}
+
+func TestSubscriberReplay(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "geyser.log"))
+
+	owner := "C3x9ivNXsXqiixFyyCmyhHEAo1WfZSEfZCcUyZnKRJAA"
+	rec := loadRecordedTx(t, "raydium_cpmm_sell.json")
+
+	f := &fakeGeyser{
+		updates:  [][]byte{encodeUpdate(t, rec, 1717000200)},
+		requests: make(chan *receivedRequest, 16),
+	}
+	addr := startFakeGeyser(t, f)
+
+	received := make(chan handler.HeliusData, 1)
+	s := NewSubscriber(config.GeyserConfig{Endpoint: addr, Insecure: true, MaxAccountsPerFilter: 1}, func(in []handler.HeliusData) error {
+		received <- in[0]
+		return nil
+	})
+	s.UpdateAccounts([]string{owner, "GpMZbSM2GgvTKHJirzeGfMFoaZ8UR2X7F4v8vHTvxFbL"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	req := waitRequest(t, f, false)
+	sort.Strings(req.accounts)
+	if len(req.accounts) != 2 || req.accounts[0] != owner {
+		t.Errorf("got accounts %v", req.accounts)
+	}
+	waitRequest(t, f, true)
+
+	var data handler.HeliusData
+	select {
+	case data = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transaction received")
+	}
+
+	if data.Signature != rec.Signature || data.FeePayer != owner || data.Timestamp != 1717000200 || data.Type != "UNKNOWN" {
+		t.Errorf("got %s %s %d %s", data.Signature, data.FeePayer, data.Timestamp, data.Type)
+	}
+	if len(data.TokenTransfers) != 2 || data.TokenTransfers[0].FromUserAccount != owner || data.TokenTransfers[0].TokenAmount != 5000 {
+		t.Errorf("got token transfers %+v", data.TokenTransfers)
+	}
+
+	// the raw tx goes through the same program keyed parsers as a webhook one
+	res, err := handler.ParseHeliusData(data)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(res) != 1 || res[0].Parser != "raydium" || res[0].FromTokenAmount != 5000 || res[0].ToTokenAmount != 2.718281828 || res[0].ToToken != handler.WSOLMint {
+		t.Errorf("got %+v", res)
+	}
+
+	// a new address set goes out on the live stream
+	s.UpdateAccounts([]string{"8maQgC4UabF6p5PKXBxKFxXb14YkkztQhGaLx7nG3uTm"})
+	req = waitRequest(t, f, false)
+	if len(req.accounts) != 1 || req.accounts[0] != "8maQgC4UabF6p5PKXBxKFxXb14YkkztQhGaLx7nG3uTm" {
+		t.Errorf("got updated accounts %v", req.accounts)
+	}
+}
+
+func TestSubscribeRequestWithoutAccounts(t *testing.T) {
+	s := NewSubscriber(config.GeyserConfig{Commitment: "finalized"}, nil)
+	req, err := s.request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Filters) != 0 || req.Commitment != CommitmentFinalized {
+		t.Errorf("got %+v", req)
+	}
+
+	s.cfg.Commitment = "latest"
+	if _, err := s.request(); err == nil {
+		t.Errorf("unknown commitment should fail")
+	}
+}
@@ -0,0 +1,393 @@
+package geyser
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the subset of yellowstone geyser.proto and solana-storage.proto the producer reads,
+// encoded by hand so no generated code is needed. field numbers follow the upstream protos.
+
+const (
+	CommitmentProcessed = 0
+	CommitmentConfirmed = 1
+	CommitmentFinalized = 2
+)
+
+// SubscribeRequest only asks for transactions touching the accounts, failed and vote txs are left out
+type SubscribeRequest struct {
+	// Filters maps a filter name to its account_include list
+	Filters    map[string][]string
+	Commitment int32
+	// PingID answers a server ping, a request with only a ping keeps the filters
+	PingID int32
+}
+
+type SubscribeUpdate struct {
+	Filters     []string
+	Transaction *TransactionUpdate
+	Ping        bool
+	Pong        bool
+	// CreatedAt is unix seconds, zero when the server does not send it
+	CreatedAt int64
+}
+
+type TransactionUpdate struct {
+	Slot      uint64
+	Signature []byte
+	IsVote    bool
+	Tx        Transaction
+	Meta      TransactionStatusMeta
+	Index     uint64
+}
+
+type Transaction struct {
+	Signatures [][]byte
+	Message    Message
+}
+
+type Message struct {
+	AccountKeys     [][]byte
+	RecentBlockhash []byte
+	Instructions    []CompiledInstruction
+	Versioned       bool
+}
+
+type CompiledInstruction struct {
+	ProgramIDIndex uint32
+	Accounts       []byte
+	Data           []byte
+}
+
+type InnerInstructions struct {
+	Index        uint32
+	Instructions []CompiledInstruction
+}
+
+type TokenBalance struct {
+	AccountIndex uint32
+	Mint         string
+	Decimals     uint32
+	Amount       string
+	Owner        string
+}
+
+type TransactionStatusMeta struct {
+	Failed                  bool
+	Fee                     uint64
+	PreBalances             []uint64
+	PostBalances            []uint64
+	InnerInstructions       []InnerInstructions
+	PreTokenBalances        []TokenBalance
+	PostTokenBalances       []TokenBalance
+	LoadedWritableAddresses [][]byte
+	LoadedReadonlyAddresses [][]byte
+}
+
+func (r *SubscribeRequest) Marshal() ([]byte, error) {
+	b := make([]byte, 0, 256)
+	for name, accounts := range r.Filters {
+		var filter []byte
+		filter = protowire.AppendTag(filter, 1, protowire.VarintType) // vote
+		filter = protowire.AppendVarint(filter, 0)
+		filter = protowire.AppendTag(filter, 2, protowire.VarintType) // failed
+		filter = protowire.AppendVarint(filter, 0)
+		for _, v := range accounts {
+			filter = protowire.AppendTag(filter, 3, protowire.BytesType)
+			filter = protowire.AppendString(filter, v)
+		}
+
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, name)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, filter)
+
+		b = protowire.AppendTag(b, 3, protowire.BytesType) // transactions
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	b = protowire.AppendTag(b, 6, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(r.Commitment))
+
+	if r.PingID != 0 {
+		var ping []byte
+		ping = protowire.AppendTag(ping, 1, protowire.VarintType)
+		ping = protowire.AppendVarint(ping, uint64(r.PingID))
+
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendBytes(b, ping)
+	}
+	return b, nil
+}
+
+type field struct {
+	num   protowire.Number
+	typ   protowire.Type
+	value uint64
+	bytes []byte
+}
+
+func readFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.value = uint64(v)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendUint64s reads a repeated uint64 field, packed or not
+func appendUint64s(dst []uint64, f field) ([]uint64, error) {
+	if f.typ == protowire.VarintType {
+		return append(dst, f.value), nil
+	}
+
+	b := f.bytes
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		dst = append(dst, v)
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+func (u *SubscribeUpdate) Unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			u.Filters = append(u.Filters, string(f.bytes))
+		case 4:
+			u.Transaction = &TransactionUpdate{}
+			return u.Transaction.unmarshal(f.bytes)
+		case 6:
+			u.Ping = true
+		case 9:
+			u.Pong = true
+		case 11:
+			return readFields(f.bytes, func(ts field) error {
+				if ts.num == 1 {
+					u.CreatedAt = int64(ts.value)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func (t *TransactionUpdate) unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return t.unmarshalInfo(f.bytes)
+		case 2:
+			t.Slot = f.value
+		}
+		return nil
+	})
+}
+
+func (t *TransactionUpdate) unmarshalInfo(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			t.Signature = f.bytes
+		case 2:
+			t.IsVote = f.value != 0
+		case 3:
+			return t.Tx.unmarshal(f.bytes)
+		case 4:
+			return t.Meta.unmarshal(f.bytes)
+		case 5:
+			t.Index = f.value
+		}
+		return nil
+	})
+}
+
+func (tx *Transaction) unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			tx.Signatures = append(tx.Signatures, f.bytes)
+		case 2:
+			return tx.Message.unmarshal(f.bytes)
+		}
+		return nil
+	})
+}
+
+func (m *Message) unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 2:
+			m.AccountKeys = append(m.AccountKeys, f.bytes)
+		case 3:
+			m.RecentBlockhash = f.bytes
+		case 4:
+			var ix CompiledInstruction
+			if err := ix.unmarshal(f.bytes); err != nil {
+				return err
+			}
+			m.Instructions = append(m.Instructions, ix)
+		case 5:
+			m.Versioned = f.value != 0
+		}
+		return nil
+	})
+}
+
+// unmarshal reads CompiledInstruction and InnerInstruction, they share the first three fields
+func (ix *CompiledInstruction) unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			ix.ProgramIDIndex = uint32(f.value)
+		case 2:
+			ix.Accounts = f.bytes
+		case 3:
+			ix.Data = f.bytes
+		}
+		return nil
+	})
+}
+
+func (in *InnerInstructions) unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			in.Index = uint32(f.value)
+		case 2:
+			var ix CompiledInstruction
+			if err := ix.unmarshal(f.bytes); err != nil {
+				return err
+			}
+			in.Instructions = append(in.Instructions, ix)
+		}
+		return nil
+	})
+}
+
+func (tb *TokenBalance) unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			tb.AccountIndex = uint32(f.value)
+		case 2:
+			tb.Mint = string(f.bytes)
+		case 3:
+			return readFields(f.bytes, func(ui field) error {
+				switch ui.num {
+				case 2:
+					tb.Decimals = uint32(ui.value)
+				case 3:
+					tb.Amount = string(ui.bytes)
+				}
+				return nil
+			})
+		case 4:
+			tb.Owner = string(f.bytes)
+		}
+		return nil
+	})
+}
+
+func (m *TransactionStatusMeta) unmarshal(b []byte) error {
+	return readFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.Failed = true
+		case 2:
+			m.Fee = f.value
+		case 3:
+			m.PreBalances, err = appendUint64s(m.PreBalances, f)
+		case 4:
+			m.PostBalances, err = appendUint64s(m.PostBalances, f)
+		case 5:
+			var in InnerInstructions
+			err = in.unmarshal(f.bytes)
+			m.InnerInstructions = append(m.InnerInstructions, in)
+		case 7:
+			var tb TokenBalance
+			err = tb.unmarshal(f.bytes)
+			m.PreTokenBalances = append(m.PreTokenBalances, tb)
+		case 8:
+			var tb TokenBalance
+			err = tb.unmarshal(f.bytes)
+			m.PostTokenBalances = append(m.PostTokenBalances, tb)
+		case 12:
+			m.LoadedWritableAddresses = append(m.LoadedWritableAddresses, f.bytes)
+		case 13:
+			m.LoadedReadonlyAddresses = append(m.LoadedReadonlyAddresses, f.bytes)
+		}
+		return err
+	})
+}
+
+// rawCodec hands the hand encoded messages to grpc without a proto registry
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(interface{ Marshal() ([]byte, error) })
+	if !ok {
+		return nil, fmt.Errorf("geyser codec cannot marshal %T", v)
+	}
+	return m.Marshal()
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(interface{ Unmarshal([]byte) error })
+	if !ok {
+		return fmt.Errorf("geyser codec cannot unmarshal %T", v)
+	}
+	return m.Unmarshal(data)
+}
+
+// Name keeps the content subtype a real yellowstone server expects
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+func commitmentLevel(s string) (int32, error) {
+	switch s {
+	case "processed":
+		return CommitmentProcessed, nil
+	case "", "confirmed":
+		return CommitmentConfirmed, nil
+	case "finalized":
+		return CommitmentFinalized, nil
+	}
+	return 0, fmt.Errorf("unknown commitment %s", s)
+}
@@ -0,0 +1,265 @@
+package ledger
+
+import (
+	"container/list"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+)
+
+const (
+	defaultTTL  = 24 * time.Hour
+	defaultSize = 200000
+	// maxApplied bounds the signatures a pair remembers, a redelivery comes long before that
+	// many later trades of the same pair
+	maxApplied = 32
+)
+
+// Holding is what the producer knows of one wallet's position in one mint
+type Holding struct {
+	Wallet string `json:"wallet"`
+	Mint   string `json:"mint"`
+	// Balance is in units of 10^-Decimals, the mint base units once a trade told the decimals.
+	// it is never nil and never shared with an earlier copy of the holding
+	Balance  *big.Int `json:"balance"`
+	Decimals int      `json:"decimals"`
+	// Held is true once the wallet had a token account for the mint, before the seed or since
+	Held  bool `json:"held"`
+	Buys  int  `json:"buys"`
+	Sells int  `json:"sells"`
+	// PositionBuys counts the buys since the balance went up from zero, zero when the ledger did
+	// not see the position open
+	PositionBuys int `json:"position_buys"`
+	// OpenedAt is the timestamp of the buy that opened the position, zero when it was not seen
+	OpenedAt int `json:"opened_at"`
+	// Exits counts the sells that emptied a position
+	Exits         int       `json:"exits"`
+	LastExitAt    int       `json:"last_exit_at"`
+	LastSignature string    `json:"last_signature"`
+	LastTimestamp int       `json:"last_timestamp"`
+	SeededAt      time.Time `json:"seeded_at"`
+}
+
+type applied struct {
+	signature string
+	before    Holding
+}
+
+type entry struct {
+	key     string
+	holding Holding
+	// applied are the latest signatures moved into the holding, with the holding before each
+	applied []applied
+}
+
+// Ledger keeps the holdings of the wallets the producer sees trade. an entry is seeded from the
+// chain the first time its pair shows up and then follows the parsed swaps and transfers. it
+// expires TTL after the seed so drift from movements the producer never sees heals by itself.
+type Ledger struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func New(size int, ttl time.Duration) *Ledger {
+	if size <= 0 {
+		size = defaultSize
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	return &Ledger{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func key(wallet, mint string) string {
+	return wallet + ":" + mint
+}
+
+// lookup must be called with mu held
+func (l *Ledger) lookup(wallet, mint string, now time.Time) *entry {
+	elem, ok := l.items[key(wallet, mint)]
+	if !ok {
+		return nil
+	}
+
+	e := elem.Value.(*entry)
+	if now.Sub(e.holding.SeededAt) > l.ttl {
+		l.ll.Remove(elem)
+		delete(l.items, e.key)
+		return nil
+	}
+
+	l.ll.MoveToFront(elem)
+	return e
+}
+
+// Get returns the holding when the pair is seeded and not expired
+func (l *Ledger) Get(wallet, mint string) (Holding, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.lookup(wallet, mint, time.Now())
+	if e == nil {
+		return Holding{}, false
+	}
+	return e.holding, true
+}
+
+// GetBefore returns the holding as it was before signature moved it, the current one when the
+// pair has not seen signature. a redelivered trade labels the same as the first delivery
+func (l *Ledger) GetBefore(wallet, mint, signature string) (Holding, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.lookup(wallet, mint, time.Now())
+	if e == nil {
+		return Holding{}, false
+	}
+	if a, ok := e.find(signature); ok {
+		return a.before, true
+	}
+	return e.holding, true
+}
+
+func (e *entry) find(signature string) (applied, bool) {
+	for _, v := range e.applied {
+		if v.signature == signature {
+			return v, true
+		}
+	}
+	return applied{}, false
+}
+
+// Seed sets the balance the pair had right before the trade the seed was read from, a pair
+// seeded meanwhile by a concurrent trade is kept
+func (l *Ledger) Seed(wallet, mint string, balance *big.Int, decimals int, held bool) Holding {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if e := l.lookup(wallet, mint, now); e != nil {
+		return e.holding
+	}
+
+	e := &entry{
+		key: key(wallet, mint),
+		holding: Holding{
+			Wallet:   wallet,
+			Mint:     mint,
+			Balance:  new(big.Int).Set(balance),
+			Decimals: max(decimals, 0),
+			Held:     held,
+			SeededAt: now,
+		},
+	}
+	l.items[e.key] = l.ll.PushFront(e)
+
+	for l.ll.Len() > l.size {
+		last := l.ll.Back()
+		l.ll.Remove(last)
+		delete(l.items, last.Value.(*entry).key)
+	}
+	return e.holding
+}
+
+const (
+	MoveBuy      = "buy"
+	MoveSell     = "sell"
+	MoveTransfer = "transfer"
+)
+
+// Apply moves the balance of a seeded pair by delta units of 10^-decimals, unseeded pairs are
+// left to their first trade. a signature already applied to the pair leaves it as it is
+func (l *Ledger) Apply(wallet, mint string, delta *big.Int, decimals int, move, signature string, timestamp int) (Holding, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.lookup(wallet, mint, time.Now())
+	if e == nil {
+		return Holding{}, false
+	}
+
+	if _, ok := e.find(signature); ok {
+		return e.holding, true
+	}
+	e.applied = append(e.applied, applied{signature: signature, before: e.holding})
+	if len(e.applied) > maxApplied {
+		e.applied = e.applied[1:]
+	}
+
+	h := &e.holding
+	pre := h.Balance
+	scale := max(h.Decimals, decimals, 0)
+	balance := new(big.Int).Add(rescale(pre, h.Decimals, scale), rescale(delta, decimals, scale))
+	// more going out than the ledger knows of comes from a movement it never saw
+	if balance.Sign() < 0 {
+		balance.SetInt64(0)
+	}
+	h.Balance, h.Decimals = balance, scale
+	if balance.Sign() > 0 {
+		h.Held = true
+	}
+
+	switch move {
+	case MoveBuy:
+		h.Buys++
+		if pre.Sign() == 0 {
+			h.PositionBuys = 1
+			h.OpenedAt = timestamp
+		} else if h.PositionBuys > 0 {
+			h.PositionBuys++
+		}
+	case MoveSell:
+		h.Sells++
+		if pre.Sign() > 0 && balance.Sign() == 0 {
+			h.Exits++
+			h.LastExitAt = timestamp
+		}
+	}
+	if balance.Sign() == 0 {
+		h.PositionBuys = 0
+		h.OpenedAt = 0
+	}
+	if timestamp >= h.LastTimestamp {
+		h.LastSignature = signature
+		h.LastTimestamp = timestamp
+	}
+	return *h, true
+}
+
+// rescale returns v, units of 10^-from, in units of 10^-to. to is never below from
+func rescale(v *big.Int, from, to int) *big.Int {
+	from = max(from, 0)
+	if to <= from {
+		return v
+	}
+	return new(big.Int).Mul(v, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(to-from)), nil))
+}
+
+func (l *Ledger) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+var inst *Ledger
+var once sync.Once
+
+// GetLedger is the ledger of the live pipeline
+func GetLedger() *Ledger {
+	once.Do(func() {
+		cfg := config.GetLedgerConfig()
+		inst = New(cfg.Size, time.Duration(cfg.TTLSeconds)*time.Second)
+	})
+	return inst
+}
@@ -0,0 +1,118 @@
+package ledger
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func n(v int64) *big.Int {
+	return big.NewInt(v)
+}
+
+func TestLedger(t *testing.T) {
+	l := New(2, time.Hour)
+
+	if _, ok := l.Apply("w", "m", n(5), 0, MoveBuy, "sig-0", 1); ok {
+		t.Errorf("unseeded pair moved")
+	}
+
+	l.Seed("w", "m", n(0), 0, false)
+	h, ok := l.Apply("w", "m", n(15), 1, MoveBuy, "sig-1", 10)
+	if !ok || h.Balance.Cmp(n(15)) != 0 || h.Decimals != 1 || !h.Held || h.Buys != 1 || h.LastSignature != "sig-1" {
+		t.Errorf("got %+v", h)
+	}
+
+	// a second seed keeps what the first one built
+	l.Seed("w", "m", n(100), 0, true)
+
+	// an older trade landing late moves the balance but not the last trade, a finer amount
+	// moves the balance to its scale
+	h, _ = l.Apply("w", "m", n(-50), 2, MoveSell, "sig-0", 5)
+	if h.Balance.Cmp(n(100)) != 0 || h.Decimals != 2 || h.Sells != 1 || h.LastSignature != "sig-1" {
+		t.Errorf("got %+v", h)
+	}
+
+	// one base unit left is still a position
+	h, _ = l.Apply("w", "m", n(-99), 2, MoveSell, "sig-2", 20)
+	if h.Balance.Cmp(n(1)) != 0 || h.Exits != 0 {
+		t.Errorf("got %+v", h)
+	}
+	h, _ = l.Apply("w", "m", n(-1), 2, MoveSell, "sig-3", 30)
+	if h.Balance.Sign() != 0 || !h.Held || h.Exits != 1 {
+		t.Errorf("got %+v", h)
+	}
+
+	// the least recently used pair goes first
+	l.Seed("a", "m", n(1), 0, true)
+	l.Get("w", "m")
+	l.Seed("b", "m", n(1), 0, true)
+	if _, ok := l.Get("a", "m"); ok || l.Len() != 2 {
+		t.Errorf("a should be evicted, got %d pairs", l.Len())
+	}
+	if _, ok := l.Get("w", "m"); !ok {
+		t.Errorf("w should be kept")
+	}
+}
+
+func TestLedgerExpire(t *testing.T) {
+	l := New(10, time.Millisecond)
+
+	l.Seed("w", "m", n(1), 0, true)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := l.Get("w", "m"); ok {
+		t.Errorf("expired pair returned")
+	}
+	if h := l.Seed("w", "m", n(3), 0, true); h.Balance.Cmp(n(3)) != 0 {
+		t.Errorf("got %+v", h)
+	}
+}
+
+func TestLedgerPosition(t *testing.T) {
+	l := New(10, time.Hour)
+
+	// a position open before the seed has no known buys
+	l.Seed("w", "m", n(2), 0, true)
+	h, _ := l.Apply("w", "m", n(1), 0, MoveBuy, "sig-1", 10)
+	if h.PositionBuys != 0 || h.OpenedAt != 0 {
+		t.Errorf("got %+v", h)
+	}
+
+	// selling more than the ledger knows of empties it
+	h, _ = l.Apply("w", "m", n(-4), 0, MoveSell, "sig-2", 20)
+	if h.Exits != 1 || h.LastExitAt != 20 || h.Balance.Sign() != 0 {
+		t.Errorf("got %+v", h)
+	}
+
+	h, _ = l.Apply("w", "m", n(1), 0, MoveBuy, "sig-3", 30)
+	h, _ = l.Apply("w", "m", n(1), 0, MoveBuy, "sig-4", 40)
+	if h.PositionBuys != 2 || h.OpenedAt != 30 {
+		t.Errorf("got %+v", h)
+	}
+
+	// sending everything away closes the position without an exit
+	h, _ = l.Apply("w", "m", n(-2), 0, MoveTransfer, "sig-5", 50)
+	if h.PositionBuys != 0 || h.OpenedAt != 0 || h.Exits != 1 {
+		t.Errorf("got %+v", h)
+	}
+}
+
+func TestLedgerRedelivery(t *testing.T) {
+	l := New(10, time.Hour)
+
+	l.Seed("w", "m", n(5), 0, true)
+	l.Apply("w", "m", n(-2), 0, MoveSell, "sig-1", 10)
+
+	// a redelivered trade moves nothing and reads the holding it was labelled from
+	h, _ := l.Apply("w", "m", n(-2), 0, MoveSell, "sig-1", 10)
+	if h.Balance.Cmp(n(3)) != 0 || h.Sells != 1 {
+		t.Errorf("got %+v", h)
+	}
+	if h, _ := l.GetBefore("w", "m", "sig-1"); h.Balance.Cmp(n(5)) != 0 || h.Sells != 0 {
+		t.Errorf("got %+v", h)
+	}
+	if h, _ := l.GetBefore("w", "m", "sig-2"); h.Balance.Cmp(n(3)) != 0 {
+		t.Errorf("got %+v", h)
+	}
+}
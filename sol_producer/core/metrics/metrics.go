@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// all producer counters live under one expvar map and are served on /debug/vars
+var counters = expvar.NewMap("sol_producer")
+
+func Incr(name string) {
+	counters.Add(name, 1)
+}
+
+func Add(name string, delta int64) {
+	counters.Add(name, delta)
+}
+
+func Set(name string, value int64) {
+	v := new(expvar.Int)
+	v.Set(value)
+	counters.Set(name, v)
+}
+
+func Get(name string) int64 {
+	v, ok := counters.Get(name).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+
+	return v.Value()
+}
+
+func Handler() http.Handler {
+	return expvar.Handler()
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type WebhookArchiveRecord struct {
+	bun.BaseModel `bun:"table:lmk_sol_webhook_archive,alias:wa"`
+
+	ID         int64           `bun:"id,pk,autoincrement"`
+	ReceivedAt time.Time       `bun:"received_at,notnull"`
+	Payload    json.RawMessage `bun:"payload,type:jsonb,notnull"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	BackfillQueued  = "queued"
+	BackfillRunning = "running"
+	BackfillDone    = "done"
+	BackfillFailed  = "failed"
+)
+
+// BackfillJob walks the history of one address page by page, the cursor is saved after every page
+type BackfillJob struct {
+	bun.BaseModel `bun:"table:lmk_sol_backfill_job,alias:bj"`
+
+	ID      int64  `bun:"id,pk,autoincrement" json:"id"`
+	Address string `bun:"address,notnull" json:"address"`
+	Status  string `bun:"status,notnull" json:"status"`
+	// BeforeSig is the oldest signature handled so far, the next page starts before it
+	BeforeSig       string `bun:"before_sig" json:"before_sig"`
+	OldestTimestamp int64  `bun:"oldest_timestamp" json:"oldest_timestamp"`
+	// TargetTxs and TargetTimestamp bound the depth, whichever is reached first ends the job
+	TargetTxs       int       `bun:"target_txs" json:"target_txs"`
+	TargetTimestamp int64     `bun:"target_timestamp" json:"target_timestamp"`
+	Fetched         int       `bun:"fetched" json:"fetched"`
+	Pages           int       `bun:"pages" json:"pages"`
+	Attempts        int       `bun:"attempts" json:"attempts"`
+	Error           string    `bun:"error" json:"error"`
+	CreatedAt       time.Time `bun:"created_at,notnull" json:"created_at"`
+	UpdatedAt       time.Time `bun:"updated_at,notnull" json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type KafkaOutboxRecord struct {
+	bun.BaseModel `bun:"table:lmk_sol_kafka_outbox,alias:ko"`
+
+	ID       int64           `bun:"id,pk,autoincrement"`
+	Topic    string          `bun:"topic,notnull"`
+	Key      string          `bun:"key"`
+	Value    json.RawMessage `bun:"value,type:jsonb,notnull"`
+	Headers  json.RawMessage `bun:"headers,type:jsonb"`
+	Attempts int             `bun:"attempts,notnull,default:0"`
+	CreateAt time.Time       `bun:"create_at,nullzero"`
+}
@@ -1,33 +1,43 @@
 package model
 
+import "github.com/thescopedao/solana_dex_subscribe/solmsg"
+
+// the kafka payloads are the shared contract of solmsg, the names stay for the code using them
 const (
-	LabelError    string = "error"
-	LabelNone     string = "none"
-	LabelFirstBuy string = "first_buy"
-	LabelFreshBuy string = "fresh_buy"
-	LabelSellAll  string = "sell_all"
+	LabelError             = solmsg.LabelError
+	LabelNone              = solmsg.LabelNone
+	LabelFirstBuy          = solmsg.LabelFirstBuy
+	LabelFreshBuy          = solmsg.LabelFreshBuy
+	LabelSellAll           = solmsg.LabelSellAll
+	LabelAccumulate        = solmsg.LabelAccumulate
+	LabelReEntry           = solmsg.LabelReEntry
+	LabelPartialSell       = solmsg.LabelPartialSell
+	LabelRoundTrip         = solmsg.LabelRoundTrip
+	DCAEventOpen           = solmsg.DCAEventOpen
+	DCAEventFill           = solmsg.DCAEventFill
+	DCAEventClose          = solmsg.DCAEventClose
+	DCAEventWithdraw       = solmsg.DCAEventWithdraw
+	DCAEventEndAndClose    = solmsg.DCAEventEndAndClose
+	LimitOrderEventPlace   = solmsg.LimitOrderEventPlace
+	LimitOrderEventCancel  = solmsg.LimitOrderEventCancel
+	LimitOrderEventFill    = solmsg.LimitOrderEventFill
+	PumpCurveEventBuy      = solmsg.PumpCurveEventBuy
+	PumpCurveEventSell     = solmsg.PumpCurveEventSell
+	PumpCurveEventComplete = solmsg.PumpCurveEventComplete
+	PumpCurveEventMigrate  = solmsg.PumpCurveEventMigrate
+	MintBurnEventMint      = solmsg.MintBurnEventMint
+	MintBurnEventBurn      = solmsg.MintBurnEventBurn
+	LiquidityEventAdd      = solmsg.LiquidityEventAdd
+	LiquidityEventRemove   = solmsg.LiquidityEventRemove
 )
 
-type SolSwapData struct {
-	TxHash    string `json:"tx_hash"`
-	Source    string `json:"source"`
-	Timestamp int    `json:"timestamp"`
-	Type      string `json:"type"`
-	Date      string `json:"date"`
-
-	FromToken        string  `json:"from_token"`
-	FromTokenAccount string  `json:"fromTokenAccount"`
-	FromUserAccount  string  `json:"fromUserAccount"`
-	FromTokenAmount  float64 `json:"from_token_amount"`
-
-	ToToken        string  `json:"to_token"`
-	ToTokenAccount string  `json:"toTokenAccount"`
-	ToUserAccount  string  `json:"toUserAccount"`
-	ToTokenAmount  float64 `json:"to_token_amount"`
-
-	TradeLabel      string        `json:"trade_label"`
-	IsDCATrade      bool          `json:"is_dca_trade"`
-	WalletCounts    int           `json:"wallet_counts"`
-	TransferDetails []SolSwapData `json:"transfer_details"`
-	DCAOpenData     string        `json:"dca_open_data"`
-}
+type (
+	SolSwapData     = solmsg.SolSwapData
+	PositionInfo    = solmsg.PositionInfo
+	DCAEvent        = solmsg.DCAEvent
+	RouteLeg        = solmsg.RouteLeg
+	LimitOrderEvent = solmsg.LimitOrderEvent
+	PumpCurve       = solmsg.PumpCurve
+	MintBurnEvent   = solmsg.MintBurnEvent
+	LiquidityEvent  = solmsg.LiquidityEvent
+)
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// WebhookRecord is a helius webhook owned by the producer
+type WebhookRecord struct {
+	bun.BaseModel `bun:"table:lmk_sol_webhook,alias:wh"`
+
+	WebhookID string    `bun:"webhook_id,pk"`
+	CreatedAt time.Time `bun:"created_at,notnull"`
+}
+
+// WebhookAddressRecord assigns one tracked address to the webhook that watches it
+type WebhookAddressRecord struct {
+	bun.BaseModel `bun:"table:lmk_sol_webhook_address,alias:wha"`
+
+	Address   string `bun:"address,pk"`
+	WebhookID string `bun:"webhook_id,notnull"`
+}
@@ -0,0 +1,250 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// deadDir is the dead letter of a file store, a directory under it
+const deadDir = "dead"
+
+// fileStore keeps one file per record, named by its zero padded id so a directory listing is
+// the add order. a record is written to a temp file, synced and renamed, a crash leaves either
+// the whole record or none. the files are synced outside mu, concurrent adds share one sync of
+// the directory that makes their renames durable
+type fileStore struct {
+	mu     sync.Mutex
+	dir    string
+	lastID int64
+
+	// renamed counts the renames in dir, synced is the count the last directory sync covered
+	renamed atomic.Int64
+	syncMu  sync.Mutex
+	synced  int64
+}
+
+// NewFileStore opens the records kept in dir
+func NewFileStore(dir string) (Store, error) {
+	return newFileStore(dir)
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, deadDir), 0755); err != nil {
+		return nil, err
+	}
+
+	s := &fileStore{dir: dir}
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		s.lastID = ids[len(ids)-1]
+	}
+	return s, nil
+}
+
+func recordName(id int64) string {
+	return fmt.Sprintf("%020d.json", id)
+}
+
+func (s *fileStore) ids() ([]int64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int64, 0, len(entries))
+	for _, v := range entries {
+		name := v.Name()
+		if v.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		res = append(res, id)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res, nil
+}
+
+func (s *fileStore) write(r *Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, recordName(r.ID))
+	f, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write outbox record failed, %v", err)
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return err
+	}
+	s.renamed.Add(1)
+	return nil
+}
+
+// syncDir makes the renames done so far durable. a caller that finds its rename covered by a
+// sync that ran while it waited returns without one of its own
+func (s *fileStore) syncDir() error {
+	target := s.renamed.Load()
+
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if s.synced >= target {
+		return nil
+	}
+
+	target = s.renamed.Load()
+	err := syncPath(s.dir)
+	if err != nil {
+		return err
+	}
+
+	s.synced = target
+	return nil
+}
+
+func syncPath(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("sync outbox dir failed, %v", err)
+	}
+	return nil
+}
+
+// Add takes the id under mu and writes the record outside it, the file syncs of concurrent adds
+// run side by side
+func (s *fileStore) Add(r *Record) error {
+	s.mu.Lock()
+	s.lastID++
+	r.ID = s.lastID
+	s.mu.Unlock()
+
+	err := s.write(r)
+	if err != nil {
+		return err
+	}
+	return s.syncDir()
+}
+
+func (s *fileStore) Pending(limit int) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	res := make([]Record, 0, len(ids))
+	for _, id := range ids {
+		data, err := os.ReadFile(filepath.Join(s.dir, recordName(id)))
+		if err != nil {
+			return nil, err
+		}
+
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("read outbox record %d failed, %v", id, err)
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (s *fileStore) Done(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		err := os.Remove(filepath.Join(s.dir, recordName(id)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) Failed(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		data, err := os.ReadFile(filepath.Join(s.dir, recordName(id)))
+		if err != nil {
+			return err
+		}
+
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+
+		r.Attempts++
+		if err := s.write(&r); err != nil {
+			return err
+		}
+	}
+	return s.syncDir()
+}
+
+func (s *fileStore) Dead(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		err := os.Rename(filepath.Join(s.dir, recordName(id)), filepath.Join(s.dir, deadDir, recordName(id)))
+		if err != nil {
+			return err
+		}
+		s.renamed.Add(1)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	err := syncPath(filepath.Join(s.dir, deadDir))
+	if err != nil {
+		return err
+	}
+	return s.syncDir()
+}
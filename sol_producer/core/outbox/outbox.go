@@ -0,0 +1,90 @@
+package outbox
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+// Record is one kafka message kept until kafka acked it
+type Record struct {
+	ID       int64           `json:"id"`
+	Topic    string          `json:"topic"`
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Headers  []solmsg.Header `json:"headers"`
+	Attempts int             `json:"attempts"`
+}
+
+type Store interface {
+	// Add keeps r before it returns and sets r.ID, ids grow in the order records are added
+	Add(r *Record) error
+	// Pending returns up to limit records not done yet, oldest first
+	Pending(limit int) ([]Record, error)
+	Done(ids []int64) error
+	// Failed counts one more failed attempt of the records
+	Failed(ids []int64) error
+	// Dead moves the records to the dead letter, they are no longer pending
+	Dead(ids []int64) error
+}
+
+const (
+	outboxTable = "lmk_sol_kafka_outbox"
+	intakeTable = "lmk_sol_webhook_intake"
+)
+
+var store Store
+var once sync.Once
+
+var intake Store
+var onceIntake sync.Once
+
+// open returns the store of one kind of record, sub is its directory under Dir for the file
+// backend and table its table for postgres
+func open(sub, table string) Store {
+	cfg := config.GetOutboxConfig()
+
+	switch cfg.Backend {
+	case "", "file":
+		base := cfg.Dir
+		if base == "" {
+			base = "./outbox"
+		}
+		dir := filepath.Join(base, sub)
+		s, err := NewFileStore(dir)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"Dir": dir, "ErrMsg": err}).Fatal("open outbox failed")
+		}
+		return s
+	case "postgres":
+		return newPGStore(table)
+	case "none":
+		return nil
+	default:
+		// running without the outbox would drop what it was asked to keep
+		logger.Logrus.WithFields(logrus.Fields{"Backend": cfg.Backend}).Fatal("unknown outbox backend")
+		return nil
+	}
+}
+
+// GetStore returns the outbox of the parsed batches, nil when messages are published directly
+func GetStore() Store {
+	once.Do(func() {
+		store = open("kafka", outboxTable)
+	})
+	return store
+}
+
+// GetIntake returns the journal of the webhook transactions not handled yet, a transaction is in
+// it before helius gets the ack. nil with the "none" backend
+func GetIntake() Store {
+	onceIntake.Do(func() {
+		intake = open("intake", intakeTable)
+	})
+	return intake
+}
@@ -0,0 +1,260 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+func addRecords(t *testing.T, s Store, keys ...string) {
+	t.Helper()
+
+	for _, k := range keys {
+		r := &Record{Topic: "swap", Key: k, Value: json.RawMessage(`[{"tx_hash":"` + k + `"}]`), Headers: solmsg.Headers(solmsg.SolSwapBatch)}
+		if err := s.Add(r); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+}
+
+func pendingKeys(t *testing.T, s Store) []string {
+	t.Helper()
+
+	records, err := s.Pending(100)
+	if err != nil {
+		t.Fatalf("pending failed: %v", err)
+	}
+
+	res := make([]string, 0, len(records))
+	for _, v := range records {
+		res = append(res, v.Key)
+	}
+	return res
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addRecords(t, s, "a", "b", "c")
+
+	records, _ := s.Pending(2)
+	if len(records) != 2 || records[0].Key != "a" || records[1].Key != "b" || string(records[0].Headers[0].Value) != solmsg.SolSwapBatch {
+		t.Fatalf("got %+v", records)
+	}
+
+	if err := s.Done([]int64{records[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Failed([]int64{records[1].ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restart picks up after the last id, not over a pending record
+	s, err = newFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addRecords(t, s, "d")
+
+	records, _ = s.Pending(10)
+	if got := pendingKeys(t, s); !reflect.DeepEqual(got, []string{"b", "c", "d"}) || records[0].Attempts != 1 {
+		t.Errorf("got %v, %+v", got, records)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "outbox.log"))
+
+	s, err := newFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addRecords(t, s, "a", "b", "c")
+
+	var mu sync.Mutex
+	sent := make([]string, 0)
+	failB := true
+	send := func(records []Record) []error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs := make([]error, len(records))
+		for i, v := range records {
+			if v.Key == "b" && failB {
+				failB = false
+				errs[i] = errors.New("broker down")
+				continue
+			}
+			sent = append(sent, v.Key)
+		}
+		return errs
+	}
+
+	r := NewRelay(config.OutboxConfig{BatchSize: 2, RetryMs: 10}, s, send)
+
+	// the failed record stays, the rest of its page is removed and the next page waits
+	if r.drain() {
+		t.Fatal("drain with a failed record should back off")
+	}
+	if got := pendingKeys(t, s); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Fatalf("pending %v", got)
+	}
+
+	r.Start()
+	addRecords(t, s, "d")
+	r.Notify()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pendingKeys(t, s)) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the retried record goes out ahead of the records added after it
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(sent, []string{"a", "b", "c", "d"}) {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+func TestRelayKeyOrder(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "outbox.log"))
+
+	s, err := newFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addRecords(t, s, "w1", "w1", "w2", "w2", "w1")
+
+	sent := make([]int64, 0)
+	failFirst := true
+	send := func(records []Record) []error {
+		errs := make([]error, len(records))
+		for i, v := range records {
+			if v.ID == 1 && failFirst {
+				failFirst = false
+				errs[i] = errors.New("broker down")
+				continue
+			}
+			sent = append(sent, v.ID)
+		}
+		return errs
+	}
+
+	r := NewRelay(config.OutboxConfig{BatchSize: 10}, s, send)
+
+	// w1 stops at its failed record, w2 still goes out in order
+	if r.drain() {
+		t.Fatal("drain with a failed record should back off")
+	}
+	if !reflect.DeepEqual(sent, []int64{3, 4}) {
+		t.Fatalf("sent %v", sent)
+	}
+
+	if !r.drain() {
+		t.Fatal("second drain should send the rest")
+	}
+	if !reflect.DeepEqual(sent, []int64{3, 4, 1, 2, 5}) {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+// TestRelayDeadLetter moves a record that keeps failing out of the way of its key
+func TestRelayDeadLetter(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "outbox.log"))
+
+	dir := t.TempDir()
+	s, err := newFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addRecords(t, s, "w1", "w1", "w2")
+
+	sent := make([]int64, 0)
+	send := func(records []Record) []error {
+		errs := make([]error, len(records))
+		for i, v := range records {
+			if v.ID == 1 {
+				errs[i] = errors.New("message too large")
+				continue
+			}
+			sent = append(sent, v.ID)
+		}
+		return errs
+	}
+
+	r := NewRelay(config.OutboxConfig{BatchSize: 10, MaxAttempts: 3}, s, send)
+
+	// w1 waits behind its failing record for two passes
+	for i := 0; i < 2; i++ {
+		if r.drain() {
+			t.Fatalf("pass %d should back off", i)
+		}
+	}
+	if !reflect.DeepEqual(sent, []int64{3}) {
+		t.Fatalf("sent %v", sent)
+	}
+
+	// the third failure moves it to the dead letter and w1 goes on in the same pass
+	if !r.drain() {
+		t.Fatal("pass after the dead letter should not back off")
+	}
+	if !reflect.DeepEqual(sent, []int64{3, 2}) || len(pendingKeys(t, s)) != 0 {
+		t.Errorf("sent %v, pending %v", sent, pendingKeys(t, s))
+	}
+
+	dead, err := newFileStore(filepath.Join(dir, deadDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := dead.Pending(10)
+	if len(records) != 1 || records[0].ID != 1 || records[0].Attempts != 2 {
+		t.Errorf("dead %+v", records)
+	}
+}
+
+// TestFileStoreConcurrentAdd gives every concurrent add its own id, all of them pending
+func TestFileStoreConcurrentAdd(t *testing.T) {
+	s, err := newFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addRecords(t, s, "a", "b", "c", "d")
+		}()
+	}
+	wg.Wait()
+
+	records, err := s.Pending(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 32 || records[31].ID != 32 {
+		t.Errorf("got %d records", len(records))
+	}
+	if s.synced != s.renamed.Load() {
+		t.Errorf("synced %d of %d renames", s.synced, s.renamed.Load())
+	}
+}
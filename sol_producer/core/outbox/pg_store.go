@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/db"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/uptrace/bun"
+)
+
+// pgStore keeps the records in table, the outbox and the intake share the row layout. the dead
+// letter is table with a _dead suffix
+type pgStore struct {
+	table string
+}
+
+func newPGStore(table string) *pgStore {
+	return &pgStore{table: table}
+}
+
+func (s *pgStore) tableExpr() string {
+	return s.table + " AS ko"
+}
+
+func (s *pgStore) Add(r *Record) error {
+	headers, err := json.Marshal(r.Headers)
+	if err != nil {
+		return err
+	}
+
+	record := &model.KafkaOutboxRecord{
+		Topic:    r.Topic,
+		Key:      r.Key,
+		Value:    r.Value,
+		Headers:  headers,
+		CreateAt: time.Now(),
+	}
+
+	_, err = db.GetDB().NewInsert().Model(record).ModelTableExpr(s.tableExpr()).Returning("id").Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	r.ID = record.ID
+	return nil
+}
+
+func (s *pgStore) Pending(limit int) ([]Record, error) {
+	var records []model.KafkaOutboxRecord
+	err := db.GetDB().NewSelect().Model(&records).ModelTableExpr(s.tableExpr()).
+		Order("id ASC").
+		Limit(limit).
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Record, 0, len(records))
+	for _, v := range records {
+		r := Record{ID: v.ID, Topic: v.Topic, Key: v.Key, Value: v.Value, Attempts: v.Attempts}
+		if len(v.Headers) > 0 {
+			if err := json.Unmarshal(v.Headers, &r.Headers); err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (s *pgStore) Done(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := db.GetDB().NewDelete().Model((*model.KafkaOutboxRecord)(nil)).ModelTableExpr(s.tableExpr()).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(context.Background())
+	return err
+}
+
+func (s *pgStore) Failed(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := db.GetDB().NewUpdate().Model((*model.KafkaOutboxRecord)(nil)).ModelTableExpr(s.tableExpr()).
+		Set("attempts = attempts + 1").
+		Where("id IN (?)", bun.In(ids)).
+		Exec(context.Background())
+	return err
+}
+
+func (s *pgStore) Dead(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return db.GetDB().RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewRaw("INSERT INTO ? SELECT * FROM ? WHERE id IN (?)", bun.Ident(s.table+"_dead"), bun.Ident(s.table), bun.In(ids)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewDelete().Model((*model.KafkaOutboxRecord)(nil)).ModelTableExpr(s.tableExpr()).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,203 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const (
+	maxRetryWait = 30 * time.Second
+	// idleWait is how often an idle relay looks for records added by another process
+	idleWait = 5 * time.Second
+)
+
+// Relay drains the outbox into kafka oldest first. send publishes the records and returns an
+// error per record, nil once kafka acked it. an acked record is removed, a failed one stays and
+// goes out again after a backoff, the newer records of its key wait behind it. one that failed
+// maxAttempts times is moved to the dead letter so its key goes on
+type Relay struct {
+	store Store
+	send  func([]Record) []error
+
+	batchSize   int
+	retryWait   time.Duration
+	maxAttempts int
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRelay(cfg config.OutboxConfig, store Store, send func([]Record) []error) *Relay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.RetryMs <= 0 {
+		cfg.RetryMs = 1000
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 20
+	}
+
+	return &Relay{
+		store:       store,
+		send:        send,
+		batchSize:   cfg.BatchSize,
+		retryWait:   time.Duration(cfg.RetryMs) * time.Millisecond,
+		maxAttempts: cfg.MaxAttempts,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Notify wakes the relay after an Add, it never blocks
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Relay) Start() {
+	go func() {
+		defer close(r.done)
+
+		wait := r.retryWait
+		for {
+			next := idleWait
+			if r.drain() {
+				wait = r.retryWait
+			} else {
+				next = wait
+				wait = min(wait*2, maxRetryWait)
+			}
+
+			select {
+			case <-r.stop:
+				r.drain()
+				return
+			case <-r.wake:
+			case <-time.After(next):
+			}
+		}
+	}()
+}
+
+// drain sends the pending records page by page, false when a record failed and the relay
+// should back off
+func (r *Relay) drain() bool {
+	for {
+		records, err := r.store.Pending(r.batchSize)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("Relay read outbox failed")
+			return false
+		}
+		if len(records) == 0 {
+			return true
+		}
+
+		ok := r.sendPage(records)
+		if !ok {
+			return false
+		}
+		if len(records) < r.batchSize {
+			return true
+		}
+	}
+}
+
+// nextWave takes the oldest record of every key not blocked, the rest stays for the next wave.
+// records of a blocked key are dropped from the page and wait in the outbox
+func nextWave(records []Record, blocked map[string]bool) ([]Record, []Record) {
+	wave := make([]Record, 0, len(records))
+	rest := make([]Record, 0)
+	inWave := make(map[string]bool)
+
+	for _, v := range records {
+		switch {
+		case blocked[v.Key]:
+		case inWave[v.Key]:
+			rest = append(rest, v)
+		default:
+			inWave[v.Key] = true
+			wave = append(wave, v)
+		}
+	}
+	return wave, rest
+}
+
+// sendPage sends a page in waves of one record per key, a record goes out only after the one
+// before it of the same key was acked. a failed record blocks its key for the rest of the page,
+// so the wallet order holds. a dead lettered one does not. false when a record failed
+func (r *Relay) sendPage(records []Record) bool {
+	blocked := make(map[string]bool)
+
+	for len(records) > 0 {
+		var wave []Record
+		wave, records = nextWave(records, blocked)
+		if len(wave) == 0 {
+			break
+		}
+
+		errs := r.send(wave)
+
+		done := make([]int64, 0, len(wave))
+		failed := make([]int64, 0)
+		dead := make([]int64, 0)
+		for i, v := range wave {
+			if errs[i] == nil {
+				done = append(done, v.ID)
+				continue
+			}
+
+			fields := logrus.Fields{"ID": v.ID, "Topic": v.Topic, "Key": v.Key, "Attempts": v.Attempts + 1, "ErrMsg": errs[i]}
+			if v.Attempts+1 >= r.maxAttempts {
+				dead = append(dead, v.ID)
+				logger.Logrus.WithFields(fields).Error("Relay send record failed, moved to the dead letter")
+				continue
+			}
+
+			blocked[v.Key] = true
+			failed = append(failed, v.ID)
+			logger.Logrus.WithFields(fields).Error("Relay send record failed")
+		}
+
+		// a record acked but not removed is sent again, delivery is at least once
+		if err := r.store.Done(done); err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("Relay remove sent records failed")
+			return false
+		}
+		if err := r.store.Failed(failed); err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("Relay count failed records failed")
+		}
+		// a record that cannot be moved stays pending and blocks its key until the next pass
+		if err := r.store.Dead(dead); err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("Relay move dead records failed")
+			return false
+		}
+
+		metrics.Add("outbox_sent", int64(len(done)))
+		metrics.Add("outbox_failed", int64(len(failed)))
+		metrics.Add("outbox_dead", int64(len(dead)))
+	}
+
+	return len(blocked) == 0
+}
+
+// Stop makes one last pass over the outbox, what it cannot send stays for the next start
+func (r *Relay) Stop(ctx context.Context) error {
+	close(r.stop)
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,350 @@
+package rpcws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const (
+	pingInterval = 30 * time.Second
+	// pongWait drops a connection that stopped answering pings, a silent socket would never error
+	pongWait = 2 * pingInterval
+)
+
+// wsMessage is either a response to one of our requests or a subscription notification
+type wsMessage struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+	Method string          `json:"method"`
+	Params struct {
+		Subscription uint64 `json:"subscription"`
+		Result       struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+	} `json:"params"`
+}
+
+type wsRequest struct {
+	Jsonrpc string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type logsValue struct {
+	Signature string `json:"signature"`
+	Err       any    `json:"err"`
+}
+
+// shard owns one websocket connection and the subscriptions of its share of the accounts
+type shard struct {
+	id int
+	s  *Subscriber
+
+	mu       sync.Mutex
+	accounts map[string]struct{}
+	// changed wakes the live connection to subscribe and unsubscribe the difference
+	changed chan struct{}
+}
+
+func newShard(id int, s *Subscriber) *shard {
+	return &shard{
+		id:       id,
+		s:        s,
+		accounts: make(map[string]struct{}),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+func (sh *shard) size() int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return len(sh.accounts)
+}
+
+func (sh *shard) set(account string, on bool) {
+	sh.mu.Lock()
+	if on {
+		sh.accounts[account] = struct{}{}
+	} else {
+		delete(sh.accounts, account)
+	}
+	sh.mu.Unlock()
+}
+
+func (sh *shard) wanted(account string) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	_, ok := sh.accounts[account]
+	return ok
+}
+
+func (sh *shard) snapshot() map[string]struct{} {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	res := make(map[string]struct{}, len(sh.accounts))
+	for k := range sh.accounts {
+		res[k] = struct{}{}
+	}
+	return res
+}
+
+func (sh *shard) notify() {
+	select {
+	case sh.changed <- struct{}{}:
+	default:
+	}
+}
+
+// run keeps the connection open until ctx is done, every reconnect subscribes the accounts again
+func (sh *shard) run(ctx context.Context) {
+	base := time.Duration(sh.s.cfg.ReconnectSeconds) * time.Second
+	if base <= 0 {
+		base = time.Second
+	}
+
+	delay := base
+	for {
+		start := time.Now()
+		err := sh.serve(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		metrics.Incr("rpcws_reconnect")
+		logger.Logrus.WithFields(logrus.Fields{"Shard": sh.id, "ErrMsg": err, "Delay": delay.String()}).Error("rpcws connection closed")
+
+		// a connection that stayed up for a while starts the backoff over
+		if time.Since(start) > time.Minute {
+			delay = base
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+}
+
+// connState tracks the subscriptions of one live connection, ids are only valid on that socket
+type connState struct {
+	conn   *websocket.Conn
+	nextID int
+	// pending maps a subscribe request id to its account until the server answers
+	pending map[int]string
+	subs    map[string]uint64
+	bySub   map[uint64]string
+}
+
+func (c *connState) send(method string, params ...interface{}) (int, error) {
+	c.nextID++
+	return c.nextID, c.conn.WriteJSON(&wsRequest{Jsonrpc: "2.0", ID: c.nextID, Method: method, Params: params})
+}
+
+func (sh *shard) subscribe(c *connState, account string) error {
+	var (
+		id  int
+		err error
+	)
+	if sh.s.cfg.Mode == "account" {
+		id, err = c.send("accountSubscribe", account, map[string]interface{}{"commitment": sh.s.commitment, "encoding": "base64"})
+	} else {
+		id, err = c.send("logsSubscribe", map[string]interface{}{"mentions": []string{account}}, map[string]interface{}{"commitment": sh.s.commitment})
+	}
+	if err != nil {
+		return err
+	}
+	c.pending[id] = account
+	return nil
+}
+
+func (sh *shard) unsubscribe(c *connState, account string) error {
+	sub, ok := c.subs[account]
+	if !ok {
+		return nil
+	}
+	delete(c.subs, account)
+	delete(c.bySub, sub)
+
+	method := "logsUnsubscribe"
+	if sh.s.cfg.Mode == "account" {
+		method = "accountUnsubscribe"
+	}
+	_, err := c.send(method, sub)
+	return err
+}
+
+// sync subscribes the accounts the shard gained and drops the ones it lost
+func (sh *shard) sync(c *connState) error {
+	want := sh.snapshot()
+
+	inflight := make(map[string]struct{}, len(c.pending))
+	for _, v := range c.pending {
+		inflight[v] = struct{}{}
+	}
+
+	for account := range want {
+		if _, ok := c.subs[account]; ok {
+			continue
+		}
+		if _, ok := inflight[account]; ok {
+			continue
+		}
+		if err := sh.subscribe(c, account); err != nil {
+			return err
+		}
+	}
+
+	for account := range c.subs {
+		if _, ok := want[account]; !ok {
+			if err := sh.unsubscribe(c, account); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (sh *shard) serve(ctx context.Context) error {
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, _, err := dialer.DialContext(ctx, sh.s.cfg.WsEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	msgs := make(chan *wsMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			_, body, err := conn.ReadMessage()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(pongWait))
+
+			msg := &wsMessage{}
+			if err := json.Unmarshal(body, msg); err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"Shard": sh.id, "Body": string(body), "ErrMsg": err}).Error("rpcws decode message failed")
+				continue
+			}
+
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	c := &connState{
+		conn:    conn,
+		pending: make(map[int]string),
+		subs:    make(map[string]uint64),
+		bySub:   make(map[uint64]string),
+	}
+	if err := sh.sync(c); err != nil {
+		return err
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Shard": sh.id, "Accounts": len(c.pending)}).Info("rpcws connection opened")
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	// gorilla allows one writer, subscriptions and pings are all written from here
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-recvErr:
+			return err
+		case <-sh.changed:
+			if err := sh.sync(c); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return err
+			}
+		case msg := <-msgs:
+			if err := sh.handleMessage(ctx, c, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (sh *shard) handleMessage(ctx context.Context, c *connState, msg *wsMessage) error {
+	if msg.Method == "" {
+		account, ok := c.pending[msg.ID]
+		if !ok {
+			// unsubscribe answers
+			return nil
+		}
+		delete(c.pending, msg.ID)
+
+		if msg.Error != nil {
+			metrics.Incr("rpcws_subscribe_failed")
+			logger.Logrus.WithFields(logrus.Fields{"Shard": sh.id, "Account": account, "ErrMsg": msg.Error.Message}).Error("rpcws subscribe failed")
+			return nil
+		}
+
+		var sub uint64
+		if err := json.Unmarshal(msg.Result, &sub); err != nil {
+			return err
+		}
+		c.subs[account] = sub
+		c.bySub[sub] = account
+
+		// the account was removed while the subscribe was in flight
+		if !sh.wanted(account) {
+			return sh.unsubscribe(c, account)
+		}
+		return nil
+	}
+
+	account, ok := c.bySub[msg.Params.Subscription]
+	if !ok {
+		return nil
+	}
+
+	switch msg.Method {
+	case "logsNotification":
+		var value logsValue
+		if err := json.Unmarshal(msg.Params.Result.Value, &value); err != nil {
+			return err
+		}
+		if value.Err != nil {
+			return nil
+		}
+		sh.s.enqueue(ctx, job{signature: value.Signature})
+	case "accountNotification":
+		sh.s.enqueue(ctx, job{account: account})
+	}
+	return nil
+}
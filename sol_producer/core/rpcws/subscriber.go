@@ -0,0 +1,333 @@
+package rpcws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/archive"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const recentSize = 10000
+
+// job is a signature from logsSubscribe or an account from accountSubscribe whose new signatures are unknown
+type job struct {
+	signature string
+	account   string
+}
+
+// Subscriber follows the tracked addresses over a standard solana rpc websocket and fetches
+// every transaction it is told about with getTransaction
+type Subscriber struct {
+	cfg        config.RpcWSConfig
+	commitment string
+	handle     func(in []handler.HeliusData) error
+
+	mu sync.Mutex
+	// ctx is set by Run, shards created later start right away
+	ctx      context.Context
+	shards   []*shard
+	assigned map[string]*shard
+
+	jobs   chan job
+	recent *recentSet
+
+	lastMu sync.Mutex
+	// last is the newest handled signature per account in account mode
+	last map[string]string
+}
+
+// NewSubscriber hands every fetched transaction to handle, usually handler.HandleData
+func NewSubscriber(cfg config.RpcWSConfig, handle func(in []handler.HeliusData) error) (*Subscriber, error) {
+	commitment, err := commitmentLevel(cfg.Commitment)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Mode != "" && cfg.Mode != "logs" && cfg.Mode != "account" {
+		return nil, fmt.Errorf("unknown rpcws mode %s", cfg.Mode)
+	}
+
+	return &Subscriber{
+		cfg:        cfg,
+		commitment: commitment,
+		handle:     handle,
+		assigned:   make(map[string]*shard),
+		jobs:       make(chan job, 1024),
+		recent:     newRecentSet(recentSize),
+		last:       make(map[string]string),
+	}, nil
+}
+
+// UpdateAccounts replaces the tracked address set. accounts keep their connection, new ones fill
+// the first connection with room and open another one when all are full.
+func (s *Subscriber) UpdateAccounts(addrs []string) error {
+	size := s.cfg.MaxSubsPerConn
+	if size <= 0 {
+		size = 1000
+	}
+
+	want := make(map[string]struct{}, len(addrs))
+	for _, v := range addrs {
+		want[v] = struct{}{}
+	}
+	accounts := make([]string, 0, len(want))
+	for k := range want {
+		accounts = append(accounts, k)
+	}
+	sort.Strings(accounts)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := make(map[*shard]struct{})
+	for account, sh := range s.assigned {
+		if _, ok := want[account]; !ok {
+			sh.set(account, false)
+			delete(s.assigned, account)
+			changed[sh] = struct{}{}
+		}
+	}
+
+	for _, account := range accounts {
+		if _, ok := s.assigned[account]; ok {
+			continue
+		}
+
+		var target *shard
+		for _, sh := range s.shards {
+			if sh.size() < size {
+				target = sh
+				break
+			}
+		}
+		if target == nil {
+			target = newShard(len(s.shards), s)
+			s.shards = append(s.shards, target)
+			if s.ctx != nil {
+				go target.run(s.ctx)
+			}
+		}
+
+		target.set(account, true)
+		s.assigned[account] = target
+		changed[target] = struct{}{}
+	}
+
+	for sh := range changed {
+		sh.notify()
+	}
+
+	metrics.Set("rpcws_connections", int64(len(s.shards)))
+	return nil
+}
+
+// Run opens every shard connection and fetches transactions until ctx is done
+func (s *Subscriber) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	for _, sh := range s.shards {
+		go sh.run(ctx)
+	}
+	s.mu.Unlock()
+
+	workers := s.cfg.FetchWorkers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-s.jobs:
+					s.process(ctx, j)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// enqueue blocks while the fetch workers are behind, the shard stops reading until they catch up
+func (s *Subscriber) enqueue(ctx context.Context, j job) {
+	select {
+	case s.jobs <- j:
+	case <-ctx.Done():
+	}
+}
+
+func (s *Subscriber) process(ctx context.Context, j job) {
+	if j.account == "" {
+		s.fetch(ctx, j.signature)
+		return
+	}
+
+	s.lastMu.Lock()
+	until := s.last[j.account]
+	s.lastMu.Unlock()
+
+	// without a previous signature only the change that fired the notification is fetched
+	limit := 20
+	if until == "" {
+		limit = 1
+	}
+
+	sigs, err := getSignaturesForAddress(ctx, s.cfg.HttpEndpoint, j.account, until, s.commitment, limit)
+	if err != nil {
+		metrics.Incr("rpcws_fetch_failed")
+		logger.Logrus.WithFields(logrus.Fields{"Account": j.account, "ErrMsg": err}).Error("rpcws getSignaturesForAddress failed")
+		return
+	}
+	if len(sigs) == 0 {
+		return
+	}
+
+	s.lastMu.Lock()
+	s.last[j.account] = sigs[0].Signature
+	s.lastMu.Unlock()
+
+	for i := len(sigs) - 1; i >= 0; i-- {
+		if sigs[i].Err == nil {
+			s.fetch(ctx, sigs[i].Signature)
+		}
+	}
+}
+
+func (s *Subscriber) fetch(ctx context.Context, txhash string) {
+	// a transaction that mentions several tracked accounts is notified once per subscription
+	if !s.recent.add(txhash) {
+		return
+	}
+
+	var (
+		tx  *rpcTransaction
+		err error
+	)
+	// the notification can arrive before the rpc node serves the transaction
+	for i := 0; i < 5; i++ {
+		tx, err = getTransaction(ctx, s.cfg.HttpEndpoint, txhash, s.commitment)
+		if err == nil && tx != nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(i+1) * 500 * time.Millisecond):
+		}
+	}
+	if err == nil && tx == nil {
+		err = fmt.Errorf("transaction not found")
+	}
+
+	var raw *handler.RawTransaction
+	if err == nil {
+		raw, err = tx.toRawTransaction()
+	}
+	if err != nil {
+		s.recent.remove(txhash)
+		metrics.Incr("rpcws_fetch_failed")
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": txhash, "ErrMsg": err}).Error("rpcws getTransaction failed")
+		return
+	}
+
+	if raw.Failed {
+		return
+	}
+	if raw.BlockTime == 0 {
+		raw.BlockTime = time.Now().Unix()
+	}
+
+	data := raw.HeliusData()
+	in := []handler.HeliusData{data}
+	metrics.Incr("rpcws_tx_received")
+
+	body, err := json.Marshal(&in)
+	if err == nil {
+		err = archive.Append(body)
+	}
+	if err != nil {
+		metrics.Incr("archive_append_failed")
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": data.Signature, "ErrMsg": err}).Error("rpcws archive raw data failed")
+	}
+
+	err = s.handle(in)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": data.Signature, "ErrMsg": err}).Error("rpcws handle transaction failed")
+	}
+}
+
+// recentSet remembers the last n signatures in insertion order
+type recentSet struct {
+	mu   sync.Mutex
+	set  map[string]struct{}
+	ring []string
+	next int
+}
+
+func newRecentSet(n int) *recentSet {
+	return &recentSet{set: make(map[string]struct{}, n), ring: make([]string, n)}
+}
+
+// add reports false when the signature is already there
+func (r *recentSet) add(sig string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.set[sig]; ok {
+		return false
+	}
+	if old := r.ring[r.next]; old != "" {
+		delete(r.set, old)
+	}
+	r.ring[r.next] = sig
+	r.next = (r.next + 1) % len(r.ring)
+	r.set[sig] = struct{}{}
+	return true
+}
+
+// remove lets a later notification retry a signature whose fetch failed
+func (r *recentSet) remove(sig string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.set, sig)
+}
+
+// getTransaction does not serve processed, so neither do the subscriptions
+func commitmentLevel(s string) (string, error) {
+	switch s {
+	case "", "confirmed":
+		return "confirmed", nil
+	case "finalized":
+		return "finalized", nil
+	}
+	return "", fmt.Errorf("unknown rpcws commitment %s", s)
+}
+
+// Start subscribes in the background, feed the tracked addresses through UpdateAccounts
+func Start(ctx context.Context) (*Subscriber, error) {
+	cfg := config.GetRpcWSConfig()
+	if cfg.WsEndpoint == "" || cfg.HttpEndpoint == "" {
+		return nil, fmt.Errorf("rpcws endpoint is empty")
+	}
+
+	s, err := NewSubscriber(cfg, handler.Submit)
+	if err != nil {
+		return nil, err
+	}
+	go s.Run(ctx)
+	return s, nil
+}
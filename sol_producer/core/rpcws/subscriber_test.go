@@ -0,0 +1,340 @@
+package rpcws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const (
+	sellSignature = "ZtQWM4ZkTjPu3yo5EWyQgbJJzstEfc7THPKmxFUsvWNHGzWA9pTawwKXVQXR1MQ"
+	owner         = "C3x9ivNXsXqiixFyyCmyhHEAo1WfZSEfZCcUyZnKRJAA"
+	pool          = "GpMZbSM2GgvTKHJirzeGfMFoaZ8UR2X7F4v8vHTvxFbL"
+)
+
+type receivedRequest struct {
+	ID     int               `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// account reads the address out of a logsSubscribe or accountSubscribe request
+func (r *receivedRequest) account() string {
+	var mentions struct {
+		Mentions []string `json:"mentions"`
+	}
+	if json.Unmarshal(r.Params[0], &mentions) == nil && len(mentions.Mentions) == 1 {
+		return mentions.Mentions[0]
+	}
+
+	var account string
+	json.Unmarshal(r.Params[0], &account)
+	return account
+}
+
+type wsEvent struct {
+	conn int
+	req  receivedRequest
+	sub  uint64
+}
+
+type mockConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *mockConn) write(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// mockRPC serves the websocket subscriptions and the http calls of a solana rpc node on one address
+type mockRPC struct {
+	t      *testing.T
+	tx     json.RawMessage
+	events chan wsEvent
+	calls  chan receivedRequest
+
+	mu      sync.Mutex
+	conns   []*mockConn
+	nextSub uint64
+}
+
+func startMockRPC(t *testing.T) (*mockRPC, *httptest.Server) {
+	t.Helper()
+
+	tx, err := os.ReadFile(filepath.Join("testdata", "raydium_cpmm_sell.json"))
+	if err != nil {
+		t.Fatalf("read fixture failed: %v", err)
+	}
+
+	m := &mockRPC{t: t, tx: tx, events: make(chan wsEvent, 64), calls: make(chan receivedRequest, 64)}
+	server := httptest.NewServer(m)
+	t.Cleanup(server.Close)
+	return m, server
+}
+
+func (m *mockRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		m.serveWS(w, r)
+		return
+	}
+
+	var req receivedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.calls <- req
+
+	result := json.RawMessage("null")
+	switch req.Method {
+	case "getTransaction":
+		var sig string
+		json.Unmarshal(req.Params[0], &sig)
+		if sig == sellSignature {
+			result = m.tx
+		}
+	case "getSignaturesForAddress":
+		result = json.RawMessage(`[{"signature":"` + sellSignature + `","err":null,"slot":290000200}]`)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func (m *mockRPC) serveWS(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	c := &mockConn{conn: conn}
+	m.conns = append(m.conns, c)
+	idx := len(m.conns) - 1
+	m.mu.Unlock()
+
+	for {
+		var req receivedRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		var result interface{} = true
+		event := wsEvent{conn: idx, req: req}
+		if strings.HasSuffix(req.Method, "Subscribe") {
+			m.mu.Lock()
+			m.nextSub++
+			event.sub = m.nextSub
+			m.mu.Unlock()
+			result = event.sub
+		}
+
+		c.write(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		m.events <- event
+	}
+}
+
+func (m *mockRPC) notify(conn int, method string, sub uint64, value interface{}) {
+	m.mu.Lock()
+	c := m.conns[conn]
+	m.mu.Unlock()
+
+	err := c.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params": map[string]interface{}{
+			"subscription": sub,
+			"result":       map[string]interface{}{"context": map[string]interface{}{"slot": 290000200}, "value": value},
+		},
+	})
+	if err != nil {
+		m.t.Fatalf("notify failed: %v", err)
+	}
+}
+
+// drop closes the socket under the client, as a provider does on maintenance
+func (m *mockRPC) drop(conn int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conns[conn].conn.Close()
+}
+
+func (m *mockRPC) waitEvent(t *testing.T) wsEvent {
+	t.Helper()
+
+	select {
+	case e := <-m.events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no websocket request")
+		return wsEvent{}
+	}
+}
+
+func waitData(t *testing.T, received chan handler.HeliusData) handler.HeliusData {
+	t.Helper()
+
+	select {
+	case data := <-received:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transaction received")
+		return handler.HeliusData{}
+	}
+}
+
+func TestSubscriberLogs(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "rpcws.log"))
+
+	m, server := startMockRPC(t)
+
+	received := make(chan handler.HeliusData, 4)
+	s, err := NewSubscriber(config.RpcWSConfig{
+		WsEndpoint:     "ws" + strings.TrimPrefix(server.URL, "http"),
+		HttpEndpoint:   server.URL,
+		MaxSubsPerConn: 1,
+	}, func(in []handler.HeliusData) error {
+		received <- in[0]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateAccounts([]string{pool, owner})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	// one subscription per connection
+	subs := make(map[string]wsEvent)
+	for i := 0; i < 2; i++ {
+		e := m.waitEvent(t)
+		if e.req.Method != "logsSubscribe" {
+			t.Fatalf("got method %s", e.req.Method)
+		}
+		subs[e.req.account()] = e
+	}
+	if len(subs) != 2 || subs[owner].conn == subs[pool].conn {
+		t.Fatalf("got subscriptions %+v", subs)
+	}
+
+	// the swap mentions both accounts, it is fetched and handled once
+	for _, e := range subs {
+		m.notify(e.conn, "logsNotification", e.sub, map[string]interface{}{"signature": sellSignature, "err": nil, "logs": []string{}})
+	}
+
+	data := waitData(t, received)
+	if data.Signature != sellSignature || data.FeePayer != owner || data.Timestamp != 1717000200 {
+		t.Errorf("got %s %s %d", data.Signature, data.FeePayer, data.Timestamp)
+	}
+
+	res, err := handler.ParseHeliusData(data)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(res) != 1 || res[0].Parser != "raydium" || res[0].FromTokenAmount != 5000 || res[0].ToTokenAmount != 2.718281828 || res[0].ToToken != handler.WSOLMint {
+		t.Errorf("got %+v", res)
+	}
+
+	select {
+	case data := <-received:
+		t.Errorf("duplicate notification handled again: %s", data.Signature)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// a failed transaction is not fetched
+	m.notify(subs[owner].conn, "logsNotification", subs[owner].sub, map[string]interface{}{"signature": "failed", "err": map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}}})
+
+	// a dropped connection reconnects and subscribes its account again
+	m.drop(subs[owner].conn)
+	e := m.waitEvent(t)
+	if e.req.Method != "logsSubscribe" || e.req.account() != owner || e.conn < 2 {
+		t.Errorf("got resubscribe %+v", e)
+	}
+
+	// removing an account unsubscribes it on the live connection
+	s.UpdateAccounts([]string{owner})
+	e = m.waitEvent(t)
+	if e.req.Method != "logsUnsubscribe" || e.conn != subs[pool].conn || string(e.req.Params[0]) != strconv.FormatUint(subs[pool].sub, 10) {
+		t.Errorf("got unsubscribe %+v", e)
+	}
+
+	for len(m.calls) > 0 {
+		req := <-m.calls
+		var sig string
+		json.Unmarshal(req.Params[0], &sig)
+		if sig == "failed" {
+			t.Errorf("failed transaction was fetched")
+		}
+	}
+}
+
+func TestSubscriberAccount(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "rpcws.log"))
+
+	m, server := startMockRPC(t)
+
+	received := make(chan handler.HeliusData, 1)
+	s, err := NewSubscriber(config.RpcWSConfig{
+		WsEndpoint:   "ws" + strings.TrimPrefix(server.URL, "http"),
+		HttpEndpoint: server.URL,
+		Mode:         "account",
+		Commitment:   "finalized",
+	}, func(in []handler.HeliusData) error {
+		received <- in[0]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateAccounts([]string{owner})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	e := m.waitEvent(t)
+	if e.req.Method != "accountSubscribe" || e.req.account() != owner {
+		t.Fatalf("got %+v", e)
+	}
+
+	// the notification carries the account data, the signature comes from getSignaturesForAddress
+	m.notify(e.conn, "accountNotification", e.sub, map[string]interface{}{"lamports": 999995000, "owner": handler.SystemProgramID})
+
+	data := waitData(t, received)
+	if data.Signature != sellSignature {
+		t.Errorf("got %s", data.Signature)
+	}
+
+	req := <-m.calls
+	var opts map[string]interface{}
+	json.Unmarshal(req.Params[1], &opts)
+	if req.Method != "getSignaturesForAddress" || req.account() != owner || opts["limit"] != float64(1) || opts["commitment"] != "finalized" {
+		t.Errorf("got %s %s", req.Method, req.Params[1])
+	}
+}
+
+func TestNewSubscriberConfig(t *testing.T) {
+	if _, err := NewSubscriber(config.RpcWSConfig{Commitment: "processed"}, nil); err == nil {
+		t.Errorf("processed commitment should fail")
+	}
+	if _, err := NewSubscriber(config.RpcWSConfig{Mode: "program"}, nil); err == nil {
+		t.Errorf("unknown mode should fail")
+	}
+}
@@ -0,0 +1,206 @@
+package rpcws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
+)
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcInstruction struct {
+	ProgramIDIndex int    `json:"programIdIndex"`
+	Accounts       []int  `json:"accounts"`
+	Data           string `json:"data"`
+}
+
+// rpcTransaction is the getTransaction result with json encoding, instructions keep their account indexes
+type rpcTransaction struct {
+	Slot      uint64 `json:"slot"`
+	BlockTime int64  `json:"blockTime"`
+	Meta      *struct {
+		Err               any      `json:"err"`
+		Fee               uint64   `json:"fee"`
+		PreBalances       []uint64 `json:"preBalances"`
+		PostBalances      []uint64 `json:"postBalances"`
+		InnerInstructions []struct {
+			Index        int              `json:"index"`
+			Instructions []rpcInstruction `json:"instructions"`
+		} `json:"innerInstructions"`
+		PreTokenBalances  []handler.TxTokenBalance `json:"preTokenBalances"`
+		PostTokenBalances []handler.TxTokenBalance `json:"postTokenBalances"`
+		LoadedAddresses   struct {
+			Writable []string `json:"writable"`
+			Readonly []string `json:"readonly"`
+		} `json:"loadedAddresses"`
+	} `json:"meta"`
+	Transaction struct {
+		Signatures []string `json:"signatures"`
+		Message    struct {
+			AccountKeys  []string         `json:"accountKeys"`
+			Instructions []rpcInstruction `json:"instructions"`
+		} `json:"message"`
+	} `json:"transaction"`
+}
+
+type signatureInfo struct {
+	Signature string `json:"signature"`
+	Err       any    `json:"err"`
+}
+
+func rpcCall(ctx context.Context, endpoint, method string, params []interface{}, out interface{}) error {
+	payload, err := json.Marshal(&handler.TxHeliusBody{Jsonrpc: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("%s, %s", method, res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var result rpcResponse
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return err
+	}
+	if result.Error != nil {
+		return fmt.Errorf("%s, %d %s", method, result.Error.Code, result.Error.Message)
+	}
+
+	return json.Unmarshal(result.Result, out)
+}
+
+// getTransaction returns nil without error while the node has not seen the transaction yet
+func getTransaction(ctx context.Context, endpoint, txhash, commitment string) (*rpcTransaction, error) {
+	var result *rpcTransaction
+	err := rpcCall(ctx, endpoint, "getTransaction", []interface{}{
+		txhash,
+		map[string]interface{}{
+			"encoding":                       "json",
+			"commitment":                     commitment,
+			"maxSupportedTransactionVersion": 0,
+		},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// getSignaturesForAddress lists signatures newest first, stopping before until when it is set
+func getSignaturesForAddress(ctx context.Context, endpoint, account, until, commitment string, limit int) ([]signatureInfo, error) {
+	opts := map[string]interface{}{
+		"limit":      limit,
+		"commitment": commitment,
+	}
+	if until != "" {
+		opts["until"] = until
+	}
+
+	var result []signatureInfo
+	err := rpcCall(ctx, endpoint, "getSignaturesForAddress", []interface{}{account, opts}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func toRawInstruction(ix rpcInstruction) (handler.RawInstruction, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return handler.RawInstruction{}, err
+	}
+	return handler.RawInstruction{ProgramIDIndex: ix.ProgramIDIndex, Accounts: ix.Accounts, Data: data}, nil
+}
+
+func toRawTokenBalances(in []handler.TxTokenBalance) []handler.RawTokenBalance {
+	res := make([]handler.RawTokenBalance, 0, len(in))
+	for _, v := range in {
+		res = append(res, handler.RawTokenBalance{
+			AccountIndex: v.AccountIndex,
+			Mint:         v.Mint,
+			Owner:        v.Owner,
+			Decimals:     v.UITokenAmount.Decimals,
+			Amount:       v.UITokenAmount.Amount,
+		})
+	}
+	return res
+}
+
+func (tx *rpcTransaction) toRawTransaction() (*handler.RawTransaction, error) {
+	if tx.Meta == nil || len(tx.Transaction.Signatures) == 0 {
+		return nil, fmt.Errorf("transaction without meta or signature")
+	}
+	meta := tx.Meta
+	msg := tx.Transaction.Message
+
+	keys := make([]string, 0, len(msg.AccountKeys)+len(meta.LoadedAddresses.Writable)+len(meta.LoadedAddresses.Readonly))
+	keys = append(keys, msg.AccountKeys...)
+	keys = append(keys, meta.LoadedAddresses.Writable...)
+	keys = append(keys, meta.LoadedAddresses.Readonly...)
+
+	res := &handler.RawTransaction{
+		Signature:         tx.Transaction.Signatures[0],
+		Slot:              tx.Slot,
+		BlockTime:         tx.BlockTime,
+		Failed:            meta.Err != nil,
+		Fee:               meta.Fee,
+		AccountKeys:       keys,
+		Instructions:      make([]handler.RawInstruction, 0, len(msg.Instructions)),
+		InnerInstructions: make(map[int][]handler.RawInstruction),
+		PreBalances:       meta.PreBalances,
+		PostBalances:      meta.PostBalances,
+		PreTokenBalances:  toRawTokenBalances(meta.PreTokenBalances),
+		PostTokenBalances: toRawTokenBalances(meta.PostTokenBalances),
+	}
+
+	for _, v := range msg.Instructions {
+		ix, err := toRawInstruction(v)
+		if err != nil {
+			return nil, err
+		}
+		res.Instructions = append(res.Instructions, ix)
+	}
+	for _, inner := range meta.InnerInstructions {
+		for _, v := range inner.Instructions {
+			ix, err := toRawInstruction(v)
+			if err != nil {
+				return nil, err
+			}
+			res.InnerInstructions[inner.Index] = append(res.InnerInstructions[inner.Index], ix)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,101 @@
+package track
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+)
+
+type HeliusBody struct {
+	WebhookURL  string   `json:"webhookURL"`
+	TxTypes     []string `json:"transactionTypes"`
+	AddressList []string `json:"accountAddresses"`
+	HookTypes   string   `json:"webhookType"`
+	AuthHeader  string   `json:"authHeader,omitempty"`
+}
+
+// heliusClient manages webhooks through the helius webhook api at cfg.Host
+type heliusClient struct {
+	cfg    config.HeliusConfig
+	client *http.Client
+}
+
+func newHeliusClient(cfg config.HeliusConfig) *heliusClient {
+	return &heliusClient{cfg: cfg, client: &http.Client{}}
+}
+
+func (c *heliusClient) body(addrs []string) HeliusBody {
+	return HeliusBody{
+		WebhookURL:  c.cfg.WebhookURL + "/sol/webhook",
+		TxTypes:     []string{c.cfg.TxTypes},
+		AddressList: addrs,
+		HookTypes:   "enhanced",
+		AuthHeader:  c.cfg.AuthHeader,
+	}
+}
+
+func (c *heliusClient) do(method, path string, in interface{}, out interface{}) error {
+	url := c.cfg.Host + path + "?api-key=" + c.cfg.APIKey
+
+	var payload io.Reader
+	if in != nil {
+		bydata, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(bydata)
+	}
+
+	req, err := http.NewRequest(method, url, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// delete answers 204 on some deployments
+	if res.StatusCode != 200 && res.StatusCode != 204 {
+		return fmt.Errorf("%s %s response failed, %s", method, path, res.Status)
+	}
+
+	if out == nil || res.StatusCode == 204 {
+		return nil
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (c *heliusClient) create(addrs []string) (string, error) {
+	var res struct {
+		WebhookID string `json:"webhookID"`
+	}
+	err := c.do("POST", "", c.body(addrs), &res)
+	if err != nil {
+		return "", err
+	}
+	if res.WebhookID == "" {
+		return "", fmt.Errorf("create webhook returned no id")
+	}
+	return res.WebhookID, nil
+}
+
+// update replaces the address list, helius has no call that adds or removes single addresses
+func (c *heliusClient) update(id string, addrs []string) error {
+	return c.do("PUT", "/"+id, c.body(addrs), nil)
+}
+
+func (c *heliusClient) delete(id string) error {
+	return c.do("DELETE", "/"+id, nil, nil)
+}
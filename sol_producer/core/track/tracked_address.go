@@ -2,25 +2,13 @@ package track
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
-	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/db"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
 )
 
-type HeliusBody struct {
-	WebhookURL  string   `json:"webhookURL"`
-	TxTypes     []string `json:"transactionTypes"`
-	AddressList []string `json:"accountAddresses"`
-	HookTypes   string   `json:"webhookType"`
-}
-
 func getTrackedAddr() ([]string, error) {
 	var resAddr []string
 	query := `
@@ -55,65 +43,52 @@ WHERE
 	return resAddr, nil
 }
 
-func updateHeliusAddressAccount(addrs []string) error {
-	cfg := config.GetHeliusConfig()
-
-	url := cfg.Host + "/" + cfg.WebhookID + "?api-key=" + cfg.APIKey
-
-	item := HeliusBody{
-		WebhookURL:  cfg.WebhookURL + "/sol/webhook",
-		TxTypes:     []string{cfg.TxTypes},
-		AddressList: addrs,
-		HookTypes:   "enhanced",
-	}
+// AddrSink receives the whole tracked address list on every sync
+type AddrSink func(addrs []string) error
 
-	bydata, err := json.Marshal(&item)
+func syncAddr(sink AddrSink, t time.Time) {
+	addrlist, err := getTrackedAddr()
 	if err != nil {
-		return err
-	}
+		logger.Logrus.WithFields(logrus.Fields{"Time": t.String(), "ErrMsg": err}).Error("AddrTask get tracked address failed")
 
-	payload := strings.NewReader(string(bydata))
-
-	client := &http.Client{}
-	req, err := http.NewRequest("PUT", url, payload)
-	if err != nil {
-		return err
+		return
 	}
-	req.Header.Add("Content-Type", "application/json")
 
-	res, err := client.Do(req)
+	err = sink(addrlist)
 	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
+		logger.Logrus.WithFields(logrus.Fields{"Time": t.String(), "ErrMsg": err}).Error("AddrTask update tracked address failed")
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("response failed, %s", res.Status)
+		return
 	}
 
-	return nil
+	logger.Logrus.WithFields(logrus.Fields{"Time": t.String(), "Data": addrlist}).Info("AddrTask update tracked address success")
 }
 
-func AddrTask() {
+// AddrTask syncs right away and again within seconds of a tracking change notification,
+// the 5 minute sync stays as a safety net for notifications lost while the listener reconnects
+func AddrTask(sink AddrSink) {
 	ticker := time.NewTicker(5 * time.Minute)
 
-	go func() {
-		for t := range ticker.C {
-			addrlist, err := getTrackedAddr()
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"Time": t.String(), "ErrMsg": err}).Error("AddrTask get tracked address failed")
-
-				continue
-			}
+	changed := make(chan struct{}, 1)
+	go coalesce(listenTracking(), trackingDebounce, func(payloads []string) {
+		logger.Logrus.WithFields(logrus.Fields{"Data": payloads}).Info("AddrTask tracking changed")
 
-			err = updateHeliusAddressAccount(addrlist)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"Time": t.String(), "ErrMsg": err}).Error("AddrTask update helius address account failed")
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
 
-				continue
+	go func() {
+		syncAddr(sink, time.Now())
+
+		for {
+			select {
+			case t := <-ticker.C:
+				syncAddr(sink, t)
+			case <-changed:
+				syncAddr(sink, time.Now())
 			}
-
-			logger.Logrus.WithFields(logrus.Fields{"Time": t.String(), "Data": addrlist}).Info("AddrTask update helius address account success")
 		}
 	}()
 }
@@ -0,0 +1,60 @@
+package track
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/db"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// TrackingChannel is notified by the trigger of sql/lmk_tracking_changed.sql on
+// lmk_address_monitor, or by hand, e.g.
+//
+//	SELECT pg_notify('lmk_tracking_changed', '{"chain":"solana","address":"<addr>"}')
+//
+// the producer syncs the whole list, the consumer refreshes the cache of that one address.
+// an empty payload asks the consumer for a full reload.
+const TrackingChannel = "lmk_tracking_changed"
+
+// trackingDebounce lets a bulk change land before the one sync that covers all of it
+const trackingDebounce = time.Second
+
+// coalesce calls fn once per burst of notifications until in is closed
+func coalesce(in <-chan pgdriver.Notification, wait time.Duration, fn func(payloads []string)) {
+	for n := range in {
+		payloads := []string{n.Payload}
+		timer := time.NewTimer(wait)
+
+	burst:
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					timer.Stop()
+					fn(payloads)
+					return
+				}
+				payloads = append(payloads, n.Payload)
+			case <-timer.C:
+				break burst
+			}
+		}
+
+		fn(payloads)
+	}
+}
+
+func listenTracking() <-chan pgdriver.Notification {
+	ln := pgdriver.NewListener(db.GetDB())
+
+	// the listener keeps the channel and listens again whenever it reconnects
+	err := ln.Listen(context.Background(), TrackingChannel)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Channel": TrackingChannel, "ErrMsg": err}).Error("AddrTask listen tracking changes failed")
+	}
+
+	return ln.Channel()
+}
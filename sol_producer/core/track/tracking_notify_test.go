@@ -0,0 +1,43 @@
+package track
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+func TestCoalesce(t *testing.T) {
+	in := make(chan pgdriver.Notification)
+	bursts := make(chan []string, 4)
+	go coalesce(in, 50*time.Millisecond, func(payloads []string) {
+		bursts <- payloads
+	})
+
+	// a bulk change is one sync
+	in <- pgdriver.Notification{Channel: TrackingChannel, Payload: "a"}
+	in <- pgdriver.Notification{Channel: TrackingChannel, Payload: "b"}
+
+	select {
+	case got := <-bursts:
+		if !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no sync after burst")
+	}
+
+	// a closed listener flushes what it still holds
+	in <- pgdriver.Notification{Channel: TrackingChannel, Payload: "c"}
+	close(in)
+
+	select {
+	case got := <-bursts:
+		if !reflect.DeepEqual(got, []string{"c"}) {
+			t.Errorf("got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no sync after close")
+	}
+}
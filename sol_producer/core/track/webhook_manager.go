@@ -0,0 +1,317 @@
+package track
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+type addrSet map[string]struct{}
+
+func (s addrSet) sorted() []string {
+	res := make([]string, 0, len(s))
+	for k := range s {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// diff lists what is in s and not in other
+func (s addrSet) diff(other addrSet) []string {
+	res := make([]string, 0)
+	for k := range s {
+		if _, ok := other[k]; !ok {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// WebhookManager spreads the tracked addresses over as many helius webhooks as the per webhook
+// limit needs. an address stays on its webhook until it is untracked, new ones fill the webhooks
+// with room first, and only webhooks whose list changed are sent.
+type WebhookManager struct {
+	client *heliusClient
+	store  WebhookStore
+	max    int
+
+	mu sync.Mutex
+	// hooks is what helius is known to hold, it only changes after a call succeeded
+	hooks map[string]addrSet
+	// folded are webhooks whose addresses moved to others but which were not deleted yet,
+	// helius still sends their addresses from both until they are deleted or sent again
+	folded map[string]bool
+}
+
+func NewWebhookManager(cfg config.HeliusConfig, store WebhookStore) (*WebhookManager, error) {
+	loaded, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// the single configured webhook predates the manager, it is adopted instead of left behind
+	if len(loaded) == 0 && cfg.WebhookID != "" {
+		err = store.SaveWebhook(cfg.WebhookID)
+		if err != nil {
+			return nil, err
+		}
+		loaded[cfg.WebhookID] = nil
+	}
+
+	max := cfg.MaxAddrPerWebhook
+	if max <= 0 {
+		max = 100000
+	}
+
+	m := &WebhookManager{
+		client: newHeliusClient(cfg),
+		store:  store,
+		max:    max,
+		hooks:  make(map[string]addrSet, len(loaded)),
+		folded: make(map[string]bool),
+	}
+	for id, addrs := range loaded {
+		set := make(addrSet, len(addrs))
+		for _, v := range addrs {
+			set[v] = struct{}{}
+		}
+		m.hooks[id] = set
+	}
+	return m, nil
+}
+
+// plan works out the target lists of the owned webhooks, the lists of webhooks to create and the
+// webhooks to delete once the others took over their addresses
+func (m *WebhookManager) plan(addrs []string) (map[string]addrSet, [][]string, []string) {
+	want := make(addrSet, len(addrs))
+	for _, v := range addrs {
+		want[v] = struct{}{}
+	}
+
+	// folded webhooks go last, an address they share with the webhook it moved to stays there
+	ids := make([]string, 0, len(m.hooks))
+	for id := range m.hooks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if m.folded[ids[i]] != m.folded[ids[j]] {
+			return !m.folded[ids[i]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	target := make(map[string]addrSet, len(m.hooks))
+	assigned := make(addrSet, len(want))
+	for _, id := range ids {
+		keep := make(addrSet, len(m.hooks[id]))
+		for k := range m.hooks[id] {
+			_, ok := want[k]
+			_, taken := assigned[k]
+			if ok && !taken {
+				keep[k] = struct{}{}
+				assigned[k] = struct{}{}
+			}
+		}
+		target[id] = keep
+	}
+
+	pending := want.diff(assigned)
+	for _, id := range ids {
+		for len(target[id]) < m.max && len(pending) > 0 {
+			target[id][pending[0]] = struct{}{}
+			pending = pending[1:]
+		}
+	}
+
+	created := make([][]string, 0)
+	for len(pending) > 0 {
+		n := m.max
+		if n > len(pending) {
+			n = len(pending)
+		}
+		created = append(created, pending[:n])
+		pending = pending[n:]
+	}
+
+	// a shrinking set is folded into fewer webhooks, the emptiest one goes first
+	need := (len(want) + m.max - 1) / m.max
+	if need < 1 {
+		need = 1
+	}
+	deleted := make([]string, 0)
+	for len(ids)-len(deleted)+len(created) > need {
+		live := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if !contains(deleted, id) {
+				live = append(live, id)
+			}
+		}
+		sort.SliceStable(live, func(i, j int) bool {
+			return len(target[live[i]]) < len(target[live[j]])
+		})
+
+		src := live[0]
+		moving := target[src].sorted()
+		for _, id := range live[1:] {
+			for len(target[id]) < m.max && len(moving) > 0 {
+				target[id][moving[0]] = struct{}{}
+				moving = moving[1:]
+			}
+		}
+		target[src] = make(addrSet)
+		deleted = append(deleted, src)
+	}
+
+	return target, created, deleted
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync is the AddrSink of the helius source
+func (m *WebhookManager) Sync(addrs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, created, deleted := m.plan(addrs)
+
+	var (
+		failed  int
+		lastErr error
+	)
+	fail := func(op, id string, err error) {
+		failed++
+		lastErr = err
+		metrics.Incr("webhook_sync_failed")
+		logger.Logrus.WithFields(logrus.Fields{"Op": op, "WebhookID": id, "ErrMsg": err}).Error("WebhookManager sync webhook failed")
+	}
+
+	for _, addrs := range created {
+		id, err := m.client.create(addrs)
+		if err != nil {
+			fail("create", "", err)
+			continue
+		}
+
+		set := make(addrSet, len(addrs))
+		for _, v := range addrs {
+			set[v] = struct{}{}
+		}
+		m.hooks[id] = set
+
+		err = m.store.SaveWebhook(id)
+		if err == nil {
+			err = m.store.Assign(id, addrs, nil)
+		}
+		if err != nil {
+			fail("save", id, err)
+		}
+		logger.Logrus.WithFields(logrus.Fields{"WebhookID": id, "Count": len(addrs)}).Info("WebhookManager created webhook")
+	}
+
+	ids := make([]string, 0, len(target))
+	for id := range target {
+		if !contains(deleted, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	updated := 0
+	for _, id := range ids {
+		added := target[id].diff(m.hooks[id])
+		removed := m.hooks[id].diff(target[id])
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		err := m.client.update(id, target[id].sorted())
+		if err != nil {
+			fail("update", id, err)
+			continue
+		}
+		m.hooks[id] = target[id]
+		delete(m.folded, id)
+		updated++
+
+		err = m.store.Assign(id, added, removed)
+		if err != nil {
+			fail("save", id, err)
+		}
+		logger.Logrus.WithFields(logrus.Fields{"WebhookID": id, "Added": len(added), "Removed": len(removed)}).Info("WebhookManager updated webhook")
+	}
+
+	// a folded webhook is only dropped when every other webhook took its addresses
+	if failed > 0 {
+		if updated > 0 {
+			for _, id := range deleted {
+				m.folded[id] = true
+			}
+		}
+		deleted = nil
+	}
+	for _, id := range deleted {
+		err := m.client.delete(id)
+		if err != nil {
+			m.folded[id] = true
+			fail("delete", id, err)
+			continue
+		}
+		delete(m.hooks, id)
+		delete(m.folded, id)
+
+		err = m.store.DeleteWebhook(id)
+		if err != nil {
+			fail("save", id, err)
+		}
+		logger.Logrus.WithFields(logrus.Fields{"WebhookID": id}).Info("WebhookManager deleted webhook")
+	}
+
+	metrics.Set("webhook_count", int64(len(m.hooks)))
+	if failed > 0 {
+		return fmt.Errorf("%d webhook calls failed, last: %w", failed, lastErr)
+	}
+	return nil
+}
+
+// Assignment returns the addresses of every owned webhook as helius holds them
+func (m *WebhookManager) Assignment() map[string][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make(map[string][]string, len(m.hooks))
+	for id, set := range m.hooks {
+		res[id] = set.sorted()
+	}
+	return res
+}
+
+var webhookManager *WebhookManager
+
+// StartWebhookManager loads the persisted assignment, the manager is then served by the admin endpoint
+func StartWebhookManager() (*WebhookManager, error) {
+	m, err := NewWebhookManager(config.GetHeliusConfig(), NewPGWebhookStore())
+	if err != nil {
+		return nil, err
+	}
+	webhookManager = m
+	return m, nil
+}
+
+// GetWebhookManager is nil unless the helius source is running
+func GetWebhookManager() *WebhookManager {
+	return webhookManager
+}
@@ -0,0 +1,254 @@
+package track
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+type memWebhookStore struct {
+	hooks map[string]map[string]bool
+}
+
+func (s *memWebhookStore) Load() (map[string][]string, error) {
+	res := make(map[string][]string)
+	for id, set := range s.hooks {
+		res[id] = make([]string, 0)
+		for k := range set {
+			res[id] = append(res[id], k)
+		}
+	}
+	return res, nil
+}
+
+func (s *memWebhookStore) SaveWebhook(id string) error {
+	if _, ok := s.hooks[id]; !ok {
+		s.hooks[id] = make(map[string]bool)
+	}
+	return nil
+}
+
+func (s *memWebhookStore) Assign(id string, added, removed []string) error {
+	for _, v := range removed {
+		delete(s.hooks[id], v)
+	}
+	for _, v := range added {
+		for _, set := range s.hooks {
+			delete(set, v)
+		}
+		s.hooks[id][v] = true
+	}
+	return nil
+}
+
+func (s *memWebhookStore) DeleteWebhook(id string) error {
+	delete(s.hooks, id)
+	return nil
+}
+
+// fakeHelius records every webhook api call as "METHOD /id addr,addr"
+type fakeHelius struct {
+	mu         sync.Mutex
+	calls      []string
+	nextID     int
+	failPUT    bool
+	failDELETE bool
+}
+
+func (f *fakeHelius) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body HeliusBody
+	json.NewDecoder(r.Body).Decode(&body)
+	f.calls = append(f.calls, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+strings.Join(body.AddressList, ",")))
+
+	switch r.Method {
+	case "POST":
+		f.nextID++
+		json.NewEncoder(w).Encode(map[string]string{"webhookID": fmt.Sprintf("hook-%d", f.nextID)})
+	case "PUT":
+		if f.failPUT {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	case "DELETE":
+		if f.failDELETE {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+func (f *fakeHelius) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res := f.calls
+	f.calls = nil
+	return res
+}
+
+func TestWebhookManagerSync(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "track.log"))
+
+	helius := &fakeHelius{}
+	server := httptest.NewServer(helius)
+	defer server.Close()
+
+	cfg := config.HeliusConfig{Host: server.URL, WebhookID: "legacy", MaxAddrPerWebhook: 2}
+	store := &memWebhookStore{hooks: make(map[string]map[string]bool)}
+	m, err := NewWebhookManager(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the configured webhook is filled first, the rest needs two new ones
+	err = m.Sync([]string{"e", "d", "c", "b", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"POST / c,d", "POST / e", "PUT /legacy a,b"}
+	if got := helius.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %v, want %v", got, want)
+	}
+
+	// nothing changed, nothing is sent
+	err = m.Sync([]string{"a", "b", "c", "d", "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := helius.take(); len(got) != 0 {
+		t.Errorf("got calls %v", got)
+	}
+
+	// only the webhook with room is sent
+	err = m.Sync([]string{"a", "b", "c", "d", "e", "f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"PUT /hook-2 e,f"}
+	if got := helius.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %v, want %v", got, want)
+	}
+
+	// a restart picks the assignment up from the store
+	m, err = NewWebhookManager(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAssign := map[string][]string{"legacy": {"a", "b"}, "hook-1": {"c", "d"}, "hook-2": {"e", "f"}}
+	if got := m.Assignment(); !reflect.DeepEqual(got, wantAssign) {
+		t.Errorf("got assignment %v", got)
+	}
+
+	// a failed update keeps the old state and folds nothing
+	helius.failPUT = true
+	err = m.Sync([]string{"a", "f"})
+	if err == nil {
+		t.Fatal("failed update should be reported")
+	}
+	if got := helius.take(); len(got) != 1 || !strings.HasPrefix(got[0], "PUT") {
+		t.Errorf("got calls %v", got)
+	}
+	if got := m.Assignment(); !reflect.DeepEqual(got, wantAssign) {
+		t.Errorf("got assignment %v", got)
+	}
+
+	// the shrunk set is folded into one webhook before the others are deleted
+	helius.failPUT = false
+	err = m.Sync([]string{"a", "f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"PUT /legacy a,f", "DELETE /hook-1", "DELETE /hook-2"}
+	if got := helius.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %v, want %v", got, want)
+	}
+	wantAssign = map[string][]string{"legacy": {"a", "f"}}
+	if got := m.Assignment(); !reflect.DeepEqual(got, wantAssign) {
+		t.Errorf("got assignment %v", got)
+	}
+	if len(store.hooks) != 1 || !store.hooks["legacy"]["f"] {
+		t.Errorf("got store %v", store.hooks)
+	}
+}
+
+func TestWebhookManagerFoldRetry(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "track.log"))
+
+	helius := &fakeHelius{}
+	server := httptest.NewServer(helius)
+	defer server.Close()
+
+	cfg := config.HeliusConfig{Host: server.URL, WebhookID: "legacy", MaxAddrPerWebhook: 2}
+	store := &memWebhookStore{hooks: make(map[string]map[string]bool)}
+	m, err := NewWebhookManager(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Sync([]string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	helius.take()
+
+	// c moves to legacy but hook-1 is not deleted, helius holds c on both
+	helius.failDELETE = true
+	err = m.Sync([]string{"a", "c"})
+	if err == nil {
+		t.Fatal("failed delete should be reported")
+	}
+	want := []string{"PUT /legacy a,c", "DELETE /hook-1"}
+	if got := helius.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %v, want %v", got, want)
+	}
+
+	// the set grew back and needs hook-1 again, c stays on legacy and hook-1 is sent without it
+	helius.failDELETE = false
+	err = m.Sync([]string{"a", "c", "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"PUT /hook-1 e"}
+	if got := helius.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %v, want %v", got, want)
+	}
+	wantAssign := map[string][]string{"legacy": {"a", "c"}, "hook-1": {"e"}}
+	if got := m.Assignment(); !reflect.DeepEqual(got, wantAssign) {
+		t.Errorf("got assignment %v", got)
+	}
+	if !store.hooks["legacy"]["c"] || store.hooks["hook-1"]["c"] {
+		t.Errorf("got store %v", store.hooks)
+	}
+
+	// a fold whose delete failed is retried on the next sync
+	helius.failDELETE = true
+	err = m.Sync([]string{"a", "e"})
+	if err == nil {
+		t.Fatal("failed delete should be reported")
+	}
+	helius.take()
+
+	helius.failDELETE = false
+	err = m.Sync([]string{"a", "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"DELETE /hook-1"}
+	if got := helius.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %v, want %v", got, want)
+	}
+	wantAssign = map[string][]string{"legacy": {"a", "e"}}
+	if got := m.Assignment(); !reflect.DeepEqual(got, wantAssign) {
+		t.Errorf("got assignment %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package track
+
+import (
+	"context"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/db"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/uptrace/bun"
+)
+
+// WebhookStore persists which webhook watches which address, so a restart sends diffs only
+type WebhookStore interface {
+	// Load returns every owned webhook with its addresses, empty webhooks included
+	Load() (map[string][]string, error)
+	SaveWebhook(id string) error
+	// Assign records added addresses on the webhook and forgets removed ones
+	Assign(id string, added, removed []string) error
+	// DeleteWebhook forgets the webhook and whatever is still assigned to it
+	DeleteWebhook(id string) error
+}
+
+type pgWebhookStore struct{}
+
+func NewPGWebhookStore() WebhookStore {
+	return &pgWebhookStore{}
+}
+
+func (s *pgWebhookStore) Load() (map[string][]string, error) {
+	var hooks []model.WebhookRecord
+	err := db.GetDB().NewSelect().Model(&hooks).Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []model.WebhookAddressRecord
+	err = db.GetDB().NewSelect().Model(&addrs).Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]string, len(hooks))
+	for _, v := range hooks {
+		res[v.WebhookID] = make([]string, 0)
+	}
+	for _, v := range addrs {
+		res[v.WebhookID] = append(res[v.WebhookID], v.Address)
+	}
+	return res, nil
+}
+
+func (s *pgWebhookStore) SaveWebhook(id string) error {
+	record := &model.WebhookRecord{WebhookID: id, CreatedAt: time.Now()}
+	_, err := db.GetDB().NewInsert().Model(record).On("CONFLICT (webhook_id) DO NOTHING").Exec(context.Background())
+	return err
+}
+
+func (s *pgWebhookStore) Assign(id string, added, removed []string) error {
+	return db.GetDB().RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if len(removed) > 0 {
+			_, err := tx.NewDelete().Model((*model.WebhookAddressRecord)(nil)).
+				Where("webhook_id = ?", id).
+				Where("address IN (?)", bun.In(removed)).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(added) > 0 {
+			records := make([]model.WebhookAddressRecord, 0, len(added))
+			for _, v := range added {
+				records = append(records, model.WebhookAddressRecord{Address: v, WebhookID: id})
+			}
+			// an address moved off a compacted webhook is still recorded there until that one is deleted
+			_, err := tx.NewInsert().Model(&records).
+				On("CONFLICT (address) DO UPDATE").
+				Set("webhook_id = EXCLUDED.webhook_id").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *pgWebhookStore) DeleteWebhook(id string) error {
+	return db.GetDB().RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*model.WebhookAddressRecord)(nil)).Where("webhook_id = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewDelete().Model((*model.WebhookRecord)(nil)).Where("webhook_id = ?", id).Exec(ctx)
+		return err
+	})
+}
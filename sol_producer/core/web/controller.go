@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
 )
@@ -33,7 +35,18 @@ func ServerRoute() *gin.Engine {
 	router.Use(MiddleLogger("./log/visit.log"), gin.RecoveryWithWriter(recoverFile))
 
 	// http router
-	router.POST("/sol/webhook", handler.HeliusWebHookHandler)
+	router.POST("/sol/webhook", handler.HeliusWebhookAuth, handler.HeliusWebHookHandler)
+
+	// metrics and admin routes expose wallets and positions, they need the admin token
+	admin := router.Group("", handler.AdminAuth)
+	admin.GET("/debug/vars", gin.WrapH(metrics.Handler()))
+	admin.GET("/admin/webhooks", handler.WebhookAssignmentHandler)
+	admin.GET("/admin/rpc", handler.RPCStatsHandler)
+	admin.GET("/admin/holdings", handler.HoldingHandler)
+	admin.GET("/admin/backfill", handler.BackfillListHandler)
+	admin.POST("/admin/backfill", handler.BackfillSubmitHandler)
+	admin.GET("/admin/backfill/:id", handler.BackfillGetHandler)
+	admin.POST("/admin/backfill/:id/retry", handler.BackfillRetryHandler)
 
 	return router
 }
@@ -48,31 +61,43 @@ func Run() {
 			WriteTimeout: 120 * time.Second,
 		}
 
-		go func() {
-			err := server.ListenAndServe()
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Fatal("Server start failed")
-			}
-		}()
-
-		// Wait for interrupt signal to gracefully shutdown the server with
-		// a timeout of 5 seconds.
-		quit := make(chan os.Signal)
+		// Wait for interrupt signal to gracefully shutdown the server
+		quit := make(chan os.Signal, 1)
 		// kill (no param) default send syscall.SIGTERM
 		// kill -2 is syscall.SIGINT
 		// kill -9 is syscall.SIGKILL but can't be caught, so don't need to add it
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
 
-		// The context is used to inform the server it has 5 seconds to finish
-		// the request it is currently handling
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		// the server takes no more webhooks, what they queued is published before exit
+		serve(server, quit, func() {
+			handler.StopEnricher()
+			handler.StopRelay()
+		})
+	}
+}
 
-		if err := server.Shutdown(ctx); err != nil {
-			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err.Error()}).Error("Server forced to shutdown")
+// serve runs server until quit, then shuts it down and runs drain. the listener returning
+// because of the shutdown is not a failure, drain has to run before the process exits
+func serve(server *http.Server, quit <-chan os.Signal, drain func()) {
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Fatal("Server start failed")
 		}
+	}()
 
-		logger.Logrus.Info("Server start success")
+	<-quit
+
+	// The context is used to inform the server it has 5 seconds to finish
+	// the request it is currently handling
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err.Error()}).Error("Server forced to shutdown")
 	}
+
+	drain()
+
+	logger.Logrus.Info("Server stopped")
 }
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/web/handler"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+// TestServeDrainsQueuedWork stops the server while the enricher still has queued batches, they
+// are all handled before serve returns
+func TestServeDrainsQueuedWork(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "web.log"))
+
+	var handled int32
+	e := handler.NewEnricher(config.EnrichConfig{Workers: 2}, func(in []handler.HeliusData) error {
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	e.Start()
+
+	batch := make([]handler.HeliusData, 0)
+	for i := 0; i < 40; i++ {
+		batch = append(batch, handler.HeliusData{Signature: fmt.Sprintf("sig%02d", i), FeePayer: fmt.Sprintf("w%d", i%4)})
+	}
+	if err := e.Enqueue(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()}
+	quit := make(chan os.Signal, 1)
+	quit <- os.Interrupt
+
+	// a Fatal on the closed listener would exit the test binary here
+	serve(server, quit, func() {
+		if err := e.Stop(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if got := atomic.LoadInt32(&handled); got != 40 {
+		t.Errorf("handled %d of 40 queued", got)
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+// verifyAdminRequest wants the bearer token of the admin config, nothing passes without one
+func verifyAdminRequest(cfg config.AdminConfig, header http.Header) bool {
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || cfg.Token == "" {
+		return false
+	}
+	return checkAuthHeader(token, []string{cfg.Token})
+}
+
+// AdminAuth guards the admin and metrics routes, config is read per call so rotation needs no restart
+func AdminAuth(c *gin.Context) {
+	if !verifyAdminRequest(config.GetAdminConfig(), c.Request.Header) {
+		metrics.Incr("admin_unauthorized")
+
+		logger.Logrus.WithFields(logrus.Fields{"ClientIP": c.ClientIP(), "Path": c.Request.URL.Path}).Warn("AdminAuth reject unauthenticated call")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, &Response{Code: http.StatusUnauthorized, Message: "unauthorized"})
+		return
+	}
+
+	c.Next()
+}
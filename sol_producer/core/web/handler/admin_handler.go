@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/ledger"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/track"
+)
+
+type WebhookAssignment struct {
+	WebhookID string   `json:"webhook_id"`
+	Count     int      `json:"count"`
+	Addresses []string `json:"addresses"`
+}
+
+// WebhookAssignmentHandler lists the owned helius webhooks, ?address= narrows it to the one watching that address
+func WebhookAssignmentHandler(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+
+	m := track.GetWebhookManager()
+	if m == nil {
+		r.Code = http.StatusNotFound
+		r.Message = "webhook manager is not running"
+		c.JSON(http.StatusOK, r)
+		return
+	}
+
+	address := c.Query("address")
+
+	res := make([]WebhookAssignment, 0)
+	for id, addrs := range m.Assignment() {
+		// the lists come sorted
+		if i := sort.SearchStrings(addrs, address); address != "" && (i == len(addrs) || addrs[i] != address) {
+			continue
+		}
+		res = append(res, WebhookAssignment{WebhookID: id, Count: len(addrs), Addresses: addrs})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].WebhookID < res[j].WebhookID
+	})
+
+	r.Data = res
+	c.JSON(http.StatusOK, r)
+}
+
+// RPCStatsHandler lists the solana rpc endpoints with the health the router scores them by
+func RPCStatsHandler(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		r.Code = http.StatusInternalServerError
+		r.Message = err.Error()
+		c.JSON(http.StatusOK, r)
+		return
+	}
+
+	r.Data = client.Stats()
+	c.JSON(http.StatusOK, r)
+}
+
+// HoldingHandler shows what the ledger holds for ?wallet= and ?mint=, the pair the next label reads
+func HoldingHandler(c *gin.Context) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+
+	h, ok := ledger.GetLedger().Get(c.Query("wallet"), c.Query("mint"))
+	if !ok {
+		r.Code = http.StatusNotFound
+		r.Message = "pair is not seeded"
+		c.JSON(http.StatusOK, r)
+		return
+	}
+
+	r.Data = h
+	c.JSON(http.StatusOK, r)
+}
+
+type BackfillRequest struct {
+	Address         string `json:"address"`
+	TargetTxs       int    `json:"target_txs"`
+	TargetTimestamp int64  `json:"target_timestamp"`
+}
+
+// backfillResponse answers every backfill endpoint, fn fills r or returns the status and error to report
+func backfillResponse(c *gin.Context, fn func(r *Response) (int64, error)) {
+	r := &Response{
+		Code:    http.StatusOK,
+		Message: "success",
+	}
+
+	if backfiller == nil {
+		r.Code = http.StatusNotFound
+		r.Message = "backfiller is not running"
+		c.JSON(http.StatusOK, r)
+		return
+	}
+
+	code, err := fn(r)
+	if err != nil {
+		r.Code = code
+		r.Message = err.Error()
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// BackfillListHandler lists jobs newest first, ?status= and ?address= filter, ?limit= defaults to 100
+func BackfillListHandler(c *gin.Context) {
+	backfillResponse(c, func(r *Response) (int64, error) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		jobs, err := backfiller.List(c.Query("status"), c.Query("address"), limit)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		r.Data = jobs
+		return 0, nil
+	})
+}
+
+func BackfillGetHandler(c *gin.Context) {
+	backfillResponse(c, func(r *Response) (int64, error) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		job, err := backfiller.Get(id)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if job == nil {
+			return http.StatusNotFound, fmt.Errorf("job %d not found", id)
+		}
+		r.Data = job
+		return 0, nil
+	})
+}
+
+// BackfillSubmitHandler queues a job, the running one is returned when the address already has one
+func BackfillSubmitHandler(c *gin.Context) {
+	backfillResponse(c, func(r *Response) (int64, error) {
+		var req BackfillRequest
+		err := c.ShouldBindJSON(&req)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		job, err := backfiller.Submit(req.Address, req.TargetTxs, req.TargetTimestamp)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		r.Data = job
+		return 0, nil
+	})
+}
+
+func BackfillRetryHandler(c *gin.Context) {
+	backfillResponse(c, func(r *Response) (int64, error) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		job, err := backfiller.Retry(id)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		r.Data = job
+		return 0, nil
+	})
+}
@@ -43,8 +43,15 @@ func (t *DCAOpenData) String() string {
 	return string(bytes)
 }
 
+// ParseOpenDCA decodes open_dca (12 accounts) and open_dca_v2 (13 accounts, separate payer)
 func ParseOpenDCA(in *InstructionsData) (*DCAOpenData, error) {
-	if len(in.Accounts) != 13 {
+	accounts := in.Accounts
+	switch len(accounts) {
+	case 12:
+		// v1 has no payer, insert the user so both versions share the offsets below
+		accounts = append([]string{accounts[0], accounts[1], accounts[1]}, accounts[2:]...)
+	case 13:
+	default:
 		return nil, fmt.Errorf("account length not match, %d", len(in.Accounts))
 	}
 
@@ -53,7 +60,8 @@ func ParseOpenDCA(in *InstructionsData) (*DCAOpenData, error) {
 		return nil, err
 	}
 
-	if len(data) < binary.Size(InstructData{}) {
+	// 5 fixed u64 plus the 3 option tags, the options themselves are checked when read
+	if len(data) < 5*8+3 {
 		return nil, fmt.Errorf("data length not match")
 	}
 
@@ -71,7 +79,7 @@ func ParseOpenDCA(in *InstructionsData) (*DCAOpenData, error) {
 	}
 
 	readOptionalUint64 := func() *uint64 {
-		if data[offset] == 0 {
+		if len(data) < offset+9 || data[offset] == 0 {
 			offset++
 			return nil
 		}
@@ -81,7 +89,7 @@ func ParseOpenDCA(in *InstructionsData) (*DCAOpenData, error) {
 	}
 
 	readOptionalInt64 := func() *int64 {
-		if data[offset] == 0 {
+		if len(data) < offset+9 || data[offset] == 0 {
 			offset++
 			return nil
 		}
@@ -102,22 +110,29 @@ func ParseOpenDCA(in *InstructionsData) (*DCAOpenData, error) {
 	}
 
 	res := &DCAOpenData{
-		DCAAddress:       in.Accounts[0],
-		User:             in.Accounts[1],
-		Payer:            in.Accounts[2],
-		InputMint:        in.Accounts[3],
-		OutputMint:       in.Accounts[4],
-		UserAta:          in.Accounts[5],
-		InAta:            in.Accounts[6],
-		OutAta:           in.Accounts[7],
+		DCAAddress:       accounts[0],
+		User:             accounts[1],
+		Payer:            accounts[2],
+		InputMint:        accounts[3],
+		OutputMint:       accounts[4],
+		UserAta:          accounts[5],
+		InAta:            accounts[6],
+		OutAta:           accounts[7],
 		Perfix:           instruct.Perfix,
 		ApplicationIdx:   instruct.ApplicationIdx,
 		InAmount:         instruct.InAmount,
 		InAmountPerCycle: instruct.InAmountPerCycle,
 		CycleFrequency:   instruct.CycleFrequency,
-		MinOutAmount:     *instruct.MinOutAmount,
-		MaxOutAmount:     *instruct.MaxOutAmount,
-		StartAt:          *instruct.StartAt,
+	}
+
+	if instruct.MinOutAmount != nil {
+		res.MinOutAmount = *instruct.MinOutAmount
+	}
+	if instruct.MaxOutAmount != nil {
+		res.MaxOutAmount = *instruct.MaxOutAmount
+	}
+	if instruct.StartAt != nil {
+		res.StartAt = *instruct.StartAt
 	}
 
 	return res, nil
@@ -0,0 +1,397 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/alikafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/dedup"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/outbox"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+var ErrEnrichQueueFull = errors.New("enrich queue full")
+var ErrEnrichStopped = errors.New("enrich pipeline stopped")
+var ErrEnrichJournal = errors.New("enrich journal failed")
+
+// ErrUnparsed marks a transaction no parser takes, trying it again fails the same way
+var ErrUnparsed = errors.New("unparsed")
+
+const (
+	// recoverLimit caps the transactions a start takes back from the intake journal
+	recoverLimit = 1000000
+	maxRetryWait = 30 * time.Second
+)
+
+type enrichTx struct {
+	data     HeliusData
+	queuedAt time.Time
+	// intakeID is the journal record of the transaction, 0 without a journal
+	intakeID int64
+}
+
+// Enricher takes raw webhook batches off the request path and parses, labels and publishes them
+// on a pool of workers. a transaction goes to the worker its fee payer hashes to, so the ledger
+// sees the trades of one wallet in the order helius sent them.
+type Enricher struct {
+	cfg    config.EnrichConfig
+	queues []chan enrichTx
+	handle func(in []HeliusData) error
+
+	// mu keeps Stop from closing a queue under a pending enqueue
+	mu      sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+
+	// inflight holds the signatures queued or being handled, a helius retry of a batch that was
+	// partly queued does not run them twice
+	inflightMu sync.Mutex
+	inflight   map[string]bool
+
+	// intake journals a transaction before Enqueue returns, it is removed once handled. a
+	// transaction queued when the process dies is taken back by Recover
+	intake outbox.Store
+	// reclaim drops the dedup claim of a recovered transaction, the process that claimed it may
+	// have died before publishing it
+	reclaim func(signature string)
+}
+
+func NewEnricher(cfg config.EnrichConfig, handle func(in []HeliusData) error) *Enricher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 8
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.EnqueueTimeoutMs <= 0 {
+		cfg.EnqueueTimeoutMs = 1000
+	}
+	if cfg.DrainSeconds <= 0 {
+		cfg.DrainSeconds = 30
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.RetryMs <= 0 {
+		cfg.RetryMs = 1000
+	}
+
+	e := &Enricher{
+		cfg:      cfg,
+		queues:   make([]chan enrichTx, cfg.Workers),
+		handle:   handle,
+		inflight: make(map[string]bool),
+	}
+	for i := range e.queues {
+		e.queues[i] = make(chan enrichTx, cfg.QueueSize)
+	}
+	return e
+}
+
+func (e *Enricher) Start() {
+	for _, queue := range e.queues {
+		e.wg.Add(1)
+		go func(queue chan enrichTx) {
+			defer e.wg.Done()
+			for tx := range queue {
+				e.run(tx)
+			}
+		}(queue)
+	}
+}
+
+func (e *Enricher) queueFor(v HeliusData) chan enrichTx {
+	key := v.FeePayer
+	if key == "" {
+		key = v.Signature
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return e.queues[h.Sum32()%uint32(len(e.queues))]
+}
+
+func (e *Enricher) queueLen() int64 {
+	total := 0
+	for _, queue := range e.queues {
+		total += len(queue)
+	}
+	return int64(total)
+}
+
+// claim returns false when the signature is already queued or being handled
+func (e *Enricher) claim(signature string) bool {
+	e.inflightMu.Lock()
+	defer e.inflightMu.Unlock()
+
+	if e.inflight[signature] {
+		return false
+	}
+	e.inflight[signature] = true
+	return true
+}
+
+func (e *Enricher) release(signature string) {
+	e.inflightMu.Lock()
+	defer e.inflightMu.Unlock()
+	delete(e.inflight, signature)
+}
+
+// Enqueue journals and queues every transaction of the batch, waiting up to EnqueueTimeoutMs for
+// room in a full queue. on ErrEnrichQueueFull or ErrEnrichJournal the part queued before stays
+// queued, the retry of the batch skips it.
+func (e *Enricher) Enqueue(in []HeliusData) error {
+	timer := time.NewTimer(time.Duration(e.cfg.EnqueueTimeoutMs) * time.Millisecond)
+	defer timer.Stop()
+	return e.enqueue(in, timer.C)
+}
+
+// Submit is Enqueue for a stream, which has nobody to retry a rejected batch. it waits for room
+// in a full queue as long as it takes, the read loop of the stream slows down with the workers
+func (e *Enricher) Submit(in []HeliusData) error {
+	return e.enqueue(in, nil)
+}
+
+// enqueue gives up on a full queue when timeout fires, a nil timeout waits
+func (e *Enricher) enqueue(in []HeliusData, timeout <-chan time.Time) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.stopped {
+		return ErrEnrichStopped
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.Add("enrich_enqueue_wait_ms", time.Since(start).Milliseconds())
+		metrics.Set("enrich_queue_len", e.queueLen())
+	}()
+
+	for _, v := range in {
+		if !e.claim(v.Signature) {
+			metrics.Incr("enrich_inflight_dropped")
+			continue
+		}
+
+		tx := enrichTx{data: v, queuedAt: time.Now()}
+		err := e.journal(&tx)
+		if err != nil {
+			e.release(v.Signature)
+			return fmt.Errorf("%w: %v", ErrEnrichJournal, err)
+		}
+
+		select {
+		case e.queueFor(v) <- tx:
+			metrics.Incr("enrich_enqueued")
+		case <-timeout:
+			e.forget(tx)
+			e.release(v.Signature)
+			metrics.Incr("enrich_rejected")
+			return ErrEnrichQueueFull
+		}
+	}
+	return nil
+}
+
+func (e *Enricher) journal(tx *enrichTx) error {
+	if e.intake == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(&tx.data)
+	if err != nil {
+		return err
+	}
+
+	r := &outbox.Record{Topic: "helius", Key: tx.data.Signature, Value: data}
+	err = e.intake.Add(r)
+	if err != nil {
+		return err
+	}
+
+	tx.intakeID = r.ID
+	return nil
+}
+
+// forget removes the handled or unparsable transaction from the journal
+func (e *Enricher) forget(tx enrichTx) {
+	if e.intake == nil || tx.intakeID == 0 {
+		return
+	}
+
+	err := e.intake.Done([]int64{tx.intakeID})
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": tx.data.Signature, "ErrMsg": err}).Error("Enricher remove journaled tx failed")
+	}
+}
+
+// Recover queues the journaled transactions a previous process did not handle, before the
+// webhook takes new ones. their claims are dropped first, so one that was claimed and not yet
+// published is not skipped. one that was published and not yet removed goes out again, the
+// ledger does not move twice for it
+func (e *Enricher) Recover() (int, error) {
+	if e.intake == nil {
+		return 0, nil
+	}
+
+	records, err := e.intake.Pending(recoverLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.stopped {
+		return 0, ErrEnrichStopped
+	}
+
+	count := 0
+	for _, r := range records {
+		var v HeliusData
+		err := json.Unmarshal(r.Value, &v)
+		if err != nil || !e.claim(v.Signature) {
+			e.forget(enrichTx{data: v, intakeID: r.ID})
+			continue
+		}
+
+		if e.reclaim != nil {
+			e.reclaim(v.Signature)
+		}
+		e.queueFor(v) <- enrichTx{data: v, queuedAt: time.Now(), intakeID: r.ID}
+		count++
+	}
+	return count, nil
+}
+
+// attempt runs the handler once, a panic is a parser bug and comes back as ErrUnparsed
+func (e *Enricher) attempt(tx enrichTx) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Logrus.WithFields(logrus.Fields{"TxHash": tx.data.Signature, "ErrMsg": p, "Stack": PrintStack()}).Error("Enricher handle panic")
+			err = fmt.Errorf("%w, panic %v", ErrUnparsed, p)
+		}
+	}()
+
+	return e.handle([]HeliusData{tx.data})
+}
+
+// run retries a transaction that failed to publish in place, the later trades of its wallet wait
+// behind it. one that still fails stays in the journal, helius does not send it again after the ack
+func (e *Enricher) run(tx enrichTx) {
+	defer e.release(tx.data.Signature)
+
+	metrics.Set("enrich_lag_ms", time.Since(tx.queuedAt).Milliseconds())
+	metrics.Set("enrich_queue_len", e.queueLen())
+
+	wait := time.Duration(e.cfg.RetryMs) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := e.attempt(tx)
+		if err == nil {
+			metrics.Incr("enrich_done")
+			e.forget(tx)
+			return
+		}
+
+		fields := logrus.Fields{"TxHash": tx.data.Signature, "Attempt": attempt, "ErrMsg": err}
+		if errors.Is(err, ErrUnparsed) {
+			metrics.Incr("enrich_unparsed")
+			logger.Logrus.WithFields(fields).Info("Enricher skip unparsed tx")
+			e.forget(tx)
+			return
+		}
+		if attempt >= e.cfg.MaxAttempts {
+			metrics.Incr("enrich_failed")
+			logger.Logrus.WithFields(fields).Error("Enricher handle data failed, tx kept in the journal")
+			return
+		}
+
+		metrics.Incr("enrich_retried")
+		logger.Logrus.WithFields(fields).Warn("Enricher handle data failed, retrying")
+		time.Sleep(wait)
+		wait = min(wait*2, maxRetryWait)
+	}
+}
+
+// Stop refuses new batches and waits for the workers to drain the queues, the error is the
+// context one when ctx ends first
+func (e *Enricher) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.stopped {
+		e.stopped = true
+		for _, queue := range e.queues {
+			close(queue)
+		}
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var enricher *Enricher
+
+// StartEnricher runs the live pipeline behind the helius webhook and the stream subscribers
+func StartEnricher() *Enricher {
+	e := NewEnricher(config.GetEnrichConfig(), HandleData)
+	e.intake = outbox.GetIntake()
+	e.reclaim = func(signature string) { dedup.Release(dedup.ScopeLive, signature) }
+	e.Start()
+
+	count, err := e.Recover()
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Fatal("StartEnricher recover journaled txs failed")
+	}
+	if count > 0 {
+		logger.Logrus.WithFields(logrus.Fields{"Count": count}).Info("StartEnricher recovered journaled txs")
+	}
+
+	enricher = e
+	return e
+}
+
+// Submit hands a batch of a stream subscriber to the live pipeline, without one it is handled in
+// place
+func Submit(in []HeliusData) error {
+	if enricher == nil {
+		return HandleData(in)
+	}
+	return enricher.Submit(in)
+}
+
+// StopEnricher drains the live pipeline, what it published is acked or waiting in the outbox for
+// StopRelay
+func StopEnricher() {
+	if enricher == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(enricher.cfg.DrainSeconds)*time.Second)
+	defer cancel()
+
+	err := enricher.Stop(ctx)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Left": enricher.queueLen()}).Error("StopEnricher drain timed out")
+	}
+
+	left := alikafka.GetKafkaInst().Flush(5000)
+	logger.Logrus.WithFields(logrus.Fields{"Unflushed": left}).Info("StopEnricher done")
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/outbox"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+func TestEnricher(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "enrich.log"))
+
+	var mu sync.Mutex
+	seen := make(map[string][]string)
+	e := NewEnricher(config.EnrichConfig{Workers: 4}, func(in []HeliusData) error {
+		if in[0].Signature == "boom" {
+			panic("parse")
+		}
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		seen[in[0].FeePayer] = append(seen[in[0].FeePayer], in[0].Signature)
+		return nil
+	})
+	e.Start()
+
+	batch := make([]HeliusData, 0)
+	for i := 0; i < 20; i++ {
+		batch = append(batch, HeliusData{Signature: fmt.Sprintf("w%d-%02d", i%3, i), FeePayer: fmt.Sprintf("w%d", i%3)})
+	}
+	batch = append(batch, HeliusData{Signature: "boom", FeePayer: "w0"})
+	if err := e.Enqueue(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	// stopping drains what was queued, a panic does not take its worker down
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Enqueue(batch); !errors.Is(err, ErrEnrichStopped) {
+		t.Errorf("got %v", err)
+	}
+
+	// the trades of one wallet are handled in the order they came
+	total := 0
+	for wallet, sigs := range seen {
+		total += len(sigs)
+		for i := 1; i < len(sigs); i++ {
+			if sigs[i-1] > sigs[i] {
+				t.Errorf("%s handled out of order: %v", wallet, sigs)
+			}
+		}
+	}
+	if total != 20 {
+		t.Errorf("handled %d", total)
+	}
+}
+
+func TestEnricherBackpressure(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "enrich.log"))
+
+	release := make(chan struct{})
+	calls := 0
+	e := NewEnricher(config.EnrichConfig{Workers: 1, QueueSize: 1, EnqueueTimeoutMs: 20}, func(in []HeliusData) error {
+		<-release
+		calls++
+		return nil
+	})
+
+	// nothing drains the queue yet, the second transaction waits and the batch is rejected
+	batch := []HeliusData{{Signature: "a"}, {Signature: "b"}}
+	start := time.Now()
+	if err := e.Enqueue(batch); !errors.Is(err, ErrEnrichQueueFull) {
+		t.Fatalf("got %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("rejected without waiting")
+	}
+
+	// the retry skips the part still in flight and fits once a worker runs
+	e.Start()
+	for e.queueLen() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := e.Enqueue(batch); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls", calls)
+	}
+}
+
+// TestEnricherSubmit waits on a full queue where Enqueue gives up, a stream has nobody to retry
+func TestEnricherSubmit(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "enrich.log"))
+
+	var mu sync.Mutex
+	got := make([]string, 0)
+	e := NewEnricher(config.EnrichConfig{Workers: 1, QueueSize: 1, EnqueueTimeoutMs: 20}, func(in []HeliusData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, in[0].Signature)
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- e.Submit([]HeliusData{{Signature: "a"}, {Signature: "b"}, {Signature: "c"}})
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("returned on a full queue, %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	e.Start()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestEnricherRecover(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "enrich.log"))
+
+	store, err := outbox.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the process dies with the batch acked but not handled, it is still in the journal
+	stuck := make(chan struct{})
+	defer close(stuck)
+	dead := NewEnricher(config.EnrichConfig{Workers: 1}, func(in []HeliusData) error {
+		<-stuck
+		return nil
+	})
+	dead.intake = store
+	batch := []HeliusData{{Signature: "a", FeePayer: "w1"}, {Signature: "b", FeePayer: "w1"}, {Signature: "c", FeePayer: "w2"}}
+	if err := dead.Enqueue(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	handled := make([]string, 0)
+	e := NewEnricher(config.EnrichConfig{Workers: 1}, func(in []HeliusData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, in[0].Signature)
+		return nil
+	})
+	e.intake = store
+	e.Start()
+
+	count, err := e.Recover()
+	if err != nil || count != 3 {
+		t.Fatalf("recovered %d, %v", count, err)
+	}
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(handled, []string{"a", "b", "c"}) {
+		t.Errorf("handled %v", handled)
+	}
+	if left, _ := store.Pending(10); len(left) != 0 {
+		t.Errorf("journal kept %d", len(left))
+	}
+}
+
+func TestEnricherRetry(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "enrich.log"))
+
+	store, err := outbox.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	tries := make(map[string]int)
+	e := NewEnricher(config.EnrichConfig{Workers: 1, MaxAttempts: 3, RetryMs: 1}, func(in []HeliusData) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sig := in[0].Signature
+		tries[sig]++
+		switch {
+		case sig == "flaky" && tries[sig] < 3:
+			return errors.New("outbox add failed")
+		case sig == "down":
+			return errors.New("broker down")
+		case sig == "junk":
+			return fmt.Errorf("%w, no parser", ErrUnparsed)
+		}
+		return nil
+	})
+	e.intake = store
+	e.Start()
+
+	batch := []HeliusData{{Signature: "flaky"}, {Signature: "down"}, {Signature: "junk"}}
+	if err := e.Enqueue(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a transient failure is retried, an unparsable tx is not, one still failing stays journaled
+	if tries["flaky"] != 3 || tries["down"] != 3 || tries["junk"] != 1 {
+		t.Errorf("tries %v", tries)
+	}
+	left, _ := store.Pending(10)
+	if len(left) != 1 || left[0].Key != "down" {
+		t.Errorf("journal kept %+v", left)
+	}
+}
+
+// TestEnricherRecoverClaimed crashes between the dedup claim and the outbox add, the recovered tx
+// is published instead of dropped as a duplicate
+func TestEnricherRecoverClaimed(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "enrich.log"))
+
+	store, err := outbox.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	claims := make(map[string]bool)
+	published := make([]string, 0)
+	claim := func(sig string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if claims[sig] {
+			return false
+		}
+		claims[sig] = true
+		return true
+	}
+
+	// the dead process claimed the tx and never got to the add
+	crash := make(chan struct{})
+	claimed := make(chan struct{})
+	defer close(crash)
+	dead := NewEnricher(config.EnrichConfig{Workers: 1}, func(in []HeliusData) error {
+		claim(in[0].Signature)
+		close(claimed)
+		<-crash
+		return nil
+	})
+	dead.intake = store
+	dead.Start()
+	if err := dead.Enqueue([]HeliusData{{Signature: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	<-claimed
+
+	e := NewEnricher(config.EnrichConfig{Workers: 1}, func(in []HeliusData) error {
+		if !claim(in[0].Signature) {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, in[0].Signature)
+		return nil
+	})
+	e.intake = store
+	e.reclaim = func(sig string) {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(claims, sig)
+	}
+	e.Start()
+
+	if count, err := e.Recover(); err != nil || count != 1 {
+		t.Fatalf("recovered %d, %v", count, err)
+	}
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(published, []string{"a"}) {
+		t.Errorf("published %v", published)
+	}
+}
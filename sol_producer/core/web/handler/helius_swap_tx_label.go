@@ -5,13 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"net/http"
-	"strings"
 
-	"github.com/gagliardetto/solana-go"
-	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/sirupsen/logrus"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/ledger"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
 )
@@ -126,376 +127,215 @@ type ParamsOptions struct {
 	MaxSupportedTransactionVersion int    `json:"maxSupportedTransactionVersion"`
 }
 
-func getHeliusTransaction(txhash string) (*TransactionddData, error) {
-	apiKey := config.GetHeliusConfig().APIKey
-	url := "https://mainnet.helius-rpc.com/?api-key=" + apiKey
-	method := "POST"
-
-	inplay := fmt.Sprintf(`{
-		"jsonrpc": "2.0",
-		"id": 1,
-		"method": "getTransaction",
-		"params": [
-			"%s",
-			{
-				"encoding": "jsonParsed",
-				"commitment": "confirmed",
-				"maxSupportedTransactionVersion": 0
-			}
-		]
-	}`, txhash)
-
-	payload := strings.NewReader(inplay)
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, payload)
-
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/json")
-
-	res, err := client.Do(req)
+// getRPCTransaction reads the jsonParsed transaction, the result stays empty when it is not found
+func getRPCTransaction(txhash string) (*TransactionddData, error) {
+	client, err := getRPCClient()
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("%s, %s", txhash, res.Status)
-	}
 
-	body, err := io.ReadAll(res.Body)
+	result := TransactionddData{Jsonrpc: "2.0", ID: 1}
+	err = client.CallForInto(context.Background(), &result.Result, "getTransaction", []interface{}{
+		txhash,
+		map[string]interface{}{
+			"encoding":                       "jsonParsed",
+			"commitment":                     "confirmed",
+			"maxSupportedTransactionVersion": 0,
+		},
+	})
 	if err != nil {
-		return nil, err
-	}
-
-	logger.Logrus.WithFields(logrus.Fields{"TxHash": txhash, "URL": url, "Payload": payload, "Body": string(body)}).Info("getHeliusTransaction data")
-
-	var result TransactionddData
-
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s, %w", txhash, err)
 	}
 
 	return &result, nil
 }
 
-func getSignatureForAddress(tokenAccount, txhash string, limit int) ([]*rpc.TransactionSignature, error) {
-	headers := make(map[string]string)
-
-	headers["Content-Type"] = "application/json"
-
-	url := "https://autumn-wispy-patron.solana-mainnet.quiknode.pro/8fdd796d1b326aebb7481d0731424a3d71c60c82/"
-	client := rpc.NewWithHeaders(url, headers)
-
-	pubKey := solana.MustPublicKeyFromBase58(tokenAccount)
+// preHolding reads what owner held of mint right before txhash, summed over its token accounts.
+// held is false when none of them existed yet. swapped out in tests.
+var preHolding = func(txhash, owner, mint string) (tokenAmount, bool, error) {
+	balance := rawAmount("0", 0)
 
-	before := solana.MustSignatureFromBase58(txhash)
-	opts := rpc.GetSignaturesForAddressOpts{
-		Limit:      &limit,
-		Before:     before,
-		Commitment: rpc.CommitmentConfirmed,
-	}
-	out, err := client.GetSignaturesForAddressWithOpts(
-		context.Background(),
-		pubKey,
-		&opts,
-	)
+	txdetail, err := getRPCTransaction(txhash)
 	if err != nil {
-		return nil, err
+		return balance, false, err
+	}
+	if txdetail.Result.Slot == 0 {
+		return balance, false, fmt.Errorf("%s not found", txhash)
 	}
 
-	res := make([]*rpc.TransactionSignature, 0)
-	for _, val := range out {
-		if val.Err == nil {
-			res = append(res, val)
+	held := false
+	for _, v := range txdetail.Result.Meta.PreTokenBalances {
+		if v.Owner == owner && v.Mint == mint {
+			held = true
+			balance = balance.add(rawAmount(v.UITokenAmount.Amount, v.UITokenAmount.Decimals))
 		}
 	}
-
-	return res, nil
+	return balance, held, nil
 }
 
-func getQuickNodeTransaction(txhash string) (*TransactionddData, error) {
-	url := "https://autumn-wispy-patron.solana-mainnet.quiknode.pro/8fdd796d1b326aebb7481d0731424a3d71c60c82/"
-	method := "POST"
-
-	inplay := fmt.Sprintf(`{
-		"jsonrpc": "2.0",
-		"id": 1,
-		"method": "getTransaction",
-		"params": [
-			"%s",
-			{
-				"encoding": "jsonParsed",
-				"commitment": "confirmed",
-				"maxSupportedTransactionVersion": 0
-			}
-		]
-	}`, txhash)
-
-	payload := strings.NewReader(inplay)
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, payload)
-
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/json")
-
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("%s, %s", txhash, res.Status)
+// labelSide is true when the label is about the sold leg, a swap into a configured quote token
+// sells the from token, anything else buys the to token
+func labelSide(val model.SolSwapData) bool {
+	tokenRule := make(map[string]bool)
+	for _, v := range config.GetHeliusConfig().ThreadData {
+		tokenRule[v.ContractAddress] = true
 	}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
+	return tokenRule[val.ToToken] && !tokenRule[val.FromToken]
+}
 
-	var result TransactionddData
+// defaultFlipWindow is how soon after the position opened a sell all counts as a round trip
+const defaultFlipWindow = 600
 
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return nil, err
+func flipWindow() int {
+	if w := config.GetLedgerConfig().FlipWindowSeconds; w > 0 {
+		return w
 	}
-
-	return &result, nil
+	return defaultFlipWindow
 }
 
-func getParsedTransaction(txhash string) (*rpc.GetParsedTransactionResult, error) {
-	headers := make(map[string]string)
-	headers["Content-Type"] = "application/json"
+// labelFromHolding decides the label from what the wallet held before the swap, the position
+// history of h is only known for pairs the ledger followed
+func labelFromHolding(val model.SolSwapData, sell bool, h ledger.Holding) (string, *model.PositionInfo) {
+	balance := holdingAmount(h)
 
-	url := "https://autumn-wispy-patron.solana-mainnet.quiknode.pro/8fdd796d1b326aebb7481d0731424a3d71c60c82/"
-	client := rpc.NewWithHeaders(url, headers)
+	if sell {
+		if balance.sign() <= 0 {
+			return model.LabelNone, nil
+		}
 
-	version := uint64(0)
+		amount := fromAmountOf(val)
+		if amount.cmp(balance) >= 0 {
+			pos := &model.PositionInfo{BuyCount: h.PositionBuys, SellPercent: 100}
+			if h.OpenedAt > 0 && val.Timestamp >= h.OpenedAt {
+				pos.HoldSeconds = val.Timestamp - h.OpenedAt
+				if pos.HoldSeconds <= flipWindow() {
+					return model.LabelRoundTrip, pos
+				}
+			}
+			return model.LabelSellAll, pos
+		}
 
-	sig, err := solana.SignatureFromBase58(txhash)
-	if err != nil {
-		return nil, err
+		share, _ := new(big.Rat).Quo(amount.rat(), balance.rat()).Float64()
+		percent := math.Round(share*10000) / 100
+		return model.LabelPartialSell, &model.PositionInfo{BuyCount: h.PositionBuys, SellPercent: percent}
 	}
 
-	out, err := client.GetParsedTransaction(
-		context.Background(),
-		sig,
-		&rpc.GetParsedTransactionOpts{
-			Commitment:                     rpc.CommitmentConfirmed,
-			MaxSupportedTransactionVersion: &version,
-		},
-	)
-	if err != nil {
-		return nil, err
+	if !h.Held {
+		return model.LabelFirstBuy, &model.PositionInfo{BuyCount: 1}
+	}
+	if balance.sign() == 0 {
+		if h.Exits > 0 {
+			return model.LabelReEntry, &model.PositionInfo{BuyCount: 1}
+		}
+		return model.LabelFreshBuy, &model.PositionInfo{BuyCount: 1}
 	}
 
-	return out, nil
-}
-
-type SPLCreateAccountInfo struct {
-	Info struct {
-		Lamports float64 `json:"lamports"`
-		Account  string  `json:"newAccount"`
-		Owner    string  `json:"owner"`
-		Source   string  `json:"source"`
-		Space    float64 `json:"space"`
-	} `json:"info"`
-	Type string `json:"type"`
+	// the buys of a position opened before the ledger saw the pair are unknown
+	pos := &model.PositionInfo{}
+	if h.PositionBuys > 0 {
+		pos.BuyCount = h.PositionBuys + 1
+	}
+	return model.LabelAccumulate, pos
 }
 
-type SPLTransferInfo struct {
-	Info struct {
-		Source      string      `json:"source"`
-		Destination string      `json:"destination"`
-		Authority   string      `json:"authority"`
-		Amount      interface{} `json:"amount"`
-	} `json:"info"`
-	Type string `json:"type"`
+func holdingAmount(h ledger.Holding) tokenAmount {
+	return tokenAmount{raw: h.Balance, decimals: h.Decimals}
 }
 
-func checkFirstBuy(tokenAccount string, in []*rpc.TransactionSignature) bool {
-	if len(in) == 0 {
-		return true
+func tradeLeg(val model.SolSwapData, sell bool) (string, string) {
+	if sell {
+		return val.FromUserAccount, val.FromToken
 	}
+	return val.ToUserAccount, val.ToToken
+}
 
-	if len(in) > 1 {
-		return false
+// applyLedger moves the seeded pairs of both legs, a swap out of the from token and into the to token
+func applyLedger(l *ledger.Ledger, val model.SolSwapData) {
+	fromMove, toMove := ledger.MoveSell, ledger.MoveBuy
+	if val.Type == "TRANSFER" {
+		fromMove, toMove = ledger.MoveTransfer, ledger.MoveTransfer
 	}
 
-	sig := in[0]
-
-	headers := make(map[string]string)
-	headers["Content-Type"] = "application/json"
-
-	url := "https://autumn-wispy-patron.solana-mainnet.quiknode.pro/8fdd796d1b326aebb7481d0731424a3d71c60c82/"
-	client := rpc.NewWithHeaders(url, headers)
+	from, to := fromAmountOf(val).neg(), toAmountOf(val)
+	l.Apply(val.FromUserAccount, val.FromToken, from.value(), from.decimals, fromMove, val.TxHash, val.Timestamp)
+	l.Apply(val.ToUserAccount, val.ToToken, to.value(), to.decimals, toMove, val.TxHash, val.Timestamp)
+}
 
-	version := uint64(0)
+// fillTradeLabel labels a live swap from the holdings ledger, only a pair the ledger has not
+// seen yet costs one rpc call to seed it
+func fillTradeLabel(l *ledger.Ledger, val model.SolSwapData) model.SolSwapData {
+	val.TradeLabel = model.LabelNone
 
-	out, err := client.GetParsedTransaction(
-		context.Background(),
-		sig.Signature,
-		&rpc.GetParsedTransactionOpts{
-			Commitment:                     rpc.CommitmentConfirmed,
-			MaxSupportedTransactionVersion: &version,
-		},
-	)
-	if err != nil {
-		return false
+	if val.Type == "TRANSFER" {
+		applyLedger(l, val)
+		return val
+	}
+	if val.Type != "SWAP" {
+		return val
 	}
 
-	isATAInit := false
-	isTokenTransfer := false
-
-	innerinstruct := out.Meta.InnerInstructions
-	for _, finst := range innerinstruct {
-		innerinst := finst.Instructions
-		for _, inst := range innerinst {
-			logger.Logrus.WithFields(logrus.Fields{"Data": inst}).Info("check data")
-
-			if inst.ProgramId == solana.SystemProgramID {
-				if inst.Parsed != nil {
-					insinfo, err := inst.Parsed.MarshalJSON()
-					if err != nil {
-						continue
-					}
-
-					var obj SPLCreateAccountInfo
-					err = json.Unmarshal(insinfo, &obj)
-					if err != nil {
-						continue
-					}
-
-					if obj.Type == "createAccount" && obj.Info.Account == tokenAccount {
-						isATAInit = true
-					}
-				}
-			}
-
-			if inst.ProgramId == solana.TokenProgramID || inst.ProgramId == solana.Token2022ProgramID {
-				if inst.Parsed != nil {
-					insinfo, err := inst.Parsed.MarshalJSON()
-					if err != nil {
-						continue
-					}
-
-					var obj SPLTransferInfo
-					err = json.Unmarshal(insinfo, &obj)
-					if err != nil {
-						continue
-					}
-
-					if (obj.Type == "transfer" || obj.Type == "transferChecked") && (obj.Info.Source == tokenAccount || obj.Info.Destination == tokenAccount) {
-						isTokenTransfer = true
-					}
-				}
-			}
+	sell := labelSide(val)
+	wallet, mint := tradeLeg(val, sell)
+
+	h, ok := l.GetBefore(wallet, mint, val.TxHash)
+	if ok {
+		metrics.Incr("ledger_hit")
+	} else {
+		balance, held, err := preHolding(val.TxHash, wallet, mint)
+		if err != nil {
+			metrics.Incr("ledger_seed_failed")
+			logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("FillTradeLabel seed holding failed")
+			return val
 		}
-	}
 
-	if isATAInit && !isTokenTransfer {
-		return true
+		metrics.Incr("ledger_seed")
+		h = l.Seed(wallet, mint, balance.value(), balance.decimals, held)
 	}
 
-	return false
+	val.TradeLabel, val.Position = labelFromHolding(val, sell, h)
+	applyLedger(l, val)
+
+	return val
 }
 
-func fillTradeLabel(val model.SolSwapData) model.SolSwapData {
+// fillHistoryTradeLabel labels a past swap from its own pre balances, history and replays run
+// out of order so they stay away from the live ledger
+func fillHistoryTradeLabel(val model.SolSwapData) model.SolSwapData {
 	val.TradeLabel = model.LabelNone
-	if val.Type == "SWAP" {
-		tokenRule := make(map[string]bool)
-
-		cfg := config.GetHeliusConfig()
-		for _, v := range cfg.ThreadData {
-			tokenRule[v.ContractAddress] = true
-		}
-
-		_, fromok := tokenRule[val.FromToken]
-		_, took := tokenRule[val.ToToken]
-
-		if took && !fromok {
-			//sold
-
-			txdetail, err := getQuickNodeTransaction(val.TxHash)
-			if err != nil {
-				txdetail, err = getQuickNodeTransaction(val.TxHash)
-				if err != nil {
-					logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("FillTradeLabel getQuickNodeTransaction sold failed")
-					return val
-				}
-			}
-
-			logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "TxDetails": txdetail}).Info("FillTradeLabel getQuickNodeTransaction sold data")
-
-			meta := txdetail.Result.Meta
-			for _, v := range meta.PreTokenBalances {
-				if val.FromToken == v.Mint && val.FromUserAccount == v.Owner && val.FromTokenAmount == v.UITokenAmount.UIAmount {
-					val.TradeLabel = model.LabelSellAll
-					return val
-				}
-			}
-		} else {
-			//bought
-			datas, err := getSignatureForAddress(val.ToTokenAccount, val.TxHash, 5)
-			if err != nil {
-				datas, err = getSignatureForAddress(val.ToTokenAccount, val.TxHash, 5)
-				if err != nil {
-					logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("FillTradeLabel getSignatureForAddress buy failed")
-					return val
-				}
-			}
-
-			if len(datas) == 0 {
-				datas, err = getSignatureForAddress(val.ToTokenAccount, val.TxHash, 5)
-				if err != nil {
-					logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("FillTradeLabel getSignatureForAddress buy failed")
-					return val
-				}
-			}
+	if val.Type != "SWAP" {
+		return val
+	}
 
-			logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "TxLength": len(datas)}).Info("FillTradeLabel getSignatureForAddress buy data")
+	sell := labelSide(val)
+	wallet, mint := tradeLeg(val, sell)
 
-			isfirst := checkFirstBuy(val.ToTokenAccount, datas)
-			if isfirst {
-				val.TradeLabel = model.LabelFirstBuy
-				return val
-			}
+	balance, held, err := preHolding(val.TxHash, wallet, mint)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("FillHistoryLabel read holding failed")
+		return val
+	}
 
-			txdetail, err := getQuickNodeTransaction(val.TxHash)
-			if err != nil {
-				txdetail, err = getQuickNodeTransaction(val.TxHash)
-				if err != err {
-					logger.Logrus.WithFields(logrus.Fields{"Data": val, "ErrMsg": err}).Error("FillTradeLabel getQuickNodeTransaction buy failed")
-					return val
-				}
-			}
+	val.TradeLabel, val.Position = labelFromHolding(val, sell, ledger.Holding{Balance: balance.value(), Decimals: balance.decimals, Held: held})
+	return val
+}
 
-			logger.Logrus.WithFields(logrus.Fields{"TxHash": val.TxHash, "TxDetail": txdetail}).Info("FillTradeLabel getQuickNodeTransaction buy data")
+func FillLabel(ins []model.SolSwapData) []model.SolSwapData {
+	l := ledger.GetLedger()
 
-			meta := txdetail.Result.Meta
-			for _, v := range meta.PreTokenBalances {
-				if val.ToToken == v.Mint && val.ToUserAccount == v.Owner && v.UITokenAmount.UIAmount == 0 {
-					val.TradeLabel = model.LabelFreshBuy
-					return val
-				}
-			}
-		}
+	result := make([]model.SolSwapData, 0)
+	for _, val := range ins {
+		nval := fillTradeLabel(l, val)
+		result = append(result, nval)
 	}
 
-	return val
+	return result
 }
 
-func FillLabel(ins []model.SolSwapData) []model.SolSwapData {
+func FillHistoryLabel(ins []model.SolSwapData) []model.SolSwapData {
 	result := make([]model.SolSwapData, 0)
 	for _, val := range ins {
-		nval := fillTradeLabel(val)
+		nval := fillHistoryTradeLabel(val)
 		result = append(result, nval)
 	}
 
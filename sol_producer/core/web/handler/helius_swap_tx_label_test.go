@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/ledger"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const labelMint = "cbbtcf3aa214zXHbiAZQwf4122FBYbraNdFqgw4iMij"
+
+func labelSwap(sig, wallet string, buy bool, amount float64, ts int) model.SolSwapData {
+	val := model.SolSwapData{TxHash: sig, Type: "SWAP", Timestamp: ts, FromUserAccount: wallet, ToUserAccount: wallet}
+	if buy {
+		val.FromToken, val.FromTokenAmount = WSOLMint, 1
+		val.ToToken, val.ToTokenAmount = labelMint, amount
+	} else {
+		val.FromToken, val.FromTokenAmount = labelMint, amount
+		val.ToToken, val.ToTokenAmount = WSOLMint, 1
+	}
+	return val
+}
+
+// loadLabelConfig makes wsol the only quote token
+func loadLabelConfig(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	logger.Init(filepath.Join(dir, "label.log"))
+
+	yaml := "HeliusConfig:\n  ThreadData:\n    - ContractAddress: " + WSOLMint + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadConf(dir + "/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFillTradeLabel(t *testing.T) {
+	loadLabelConfig(t)
+
+	type seed struct {
+		balance float64
+		held    bool
+	}
+	seeds := map[string]seed{"seller": {3, true}}
+	calls := 0
+
+	lookup := preHolding
+	preHolding = func(txhash, owner, mint string) (tokenAmount, bool, error) {
+		calls++
+		if mint != labelMint {
+			t.Errorf("seeded %s", mint)
+		}
+		if owner == "broken" {
+			return tokenAmount{}, false, fmt.Errorf("rpc down")
+		}
+		if owner == "whale" {
+			return rawAmount("1000000000000000001", 9), true, nil
+		}
+		s := seeds[owner]
+		return floatAmount(s.balance, 0), s.held, nil
+	}
+	defer func() { preHolding = lookup }()
+
+	l := ledger.New(0, 0)
+	steps := []struct {
+		val   model.SolSwapData
+		label string
+		pos   *model.PositionInfo
+		calls int
+	}{
+		// the first trade of the pair seeds it, the rest is local
+		{labelSwap("s1", "buyer", true, 10, 100), model.LabelFirstBuy, &model.PositionInfo{BuyCount: 1}, 1},
+		{labelSwap("s2", "buyer", true, 5, 200), model.LabelAccumulate, &model.PositionInfo{BuyCount: 2}, 1},
+		{labelSwap("s3", "buyer", false, 7.5, 300), model.LabelPartialSell, &model.PositionInfo{BuyCount: 2, SellPercent: 50}, 1},
+		// out within the flip window of the buy that opened the position
+		{labelSwap("s4", "buyer", false, 7.5, 400), model.LabelRoundTrip, &model.PositionInfo{BuyCount: 2, SellPercent: 100, HoldSeconds: 300}, 1},
+		{labelSwap("s5", "buyer", true, 2, 5000), model.LabelReEntry, &model.PositionInfo{BuyCount: 1}, 1},
+		// a position older than the ledger has no buy count and no holding time
+		{labelSwap("s6", "seller", false, 3, 5000), model.LabelSellAll, &model.PositionInfo{SellPercent: 100}, 2},
+		{labelSwap("s7", "broken", true, 1, 5000), model.LabelNone, nil, 3},
+		{labelSwap("s8", "broken", true, 1, 5000), model.LabelNone, nil, 4},
+	}
+	for _, step := range steps {
+		got := fillTradeLabel(l, step.val)
+		if got.TradeLabel != step.label || calls != step.calls {
+			t.Errorf("%s got %s after %d calls, want %s after %d", step.val.TxHash, got.TradeLabel, calls, step.label, step.calls)
+		}
+		if !samePosition(got.Position, step.pos) {
+			t.Errorf("%s got position %+v, want %+v", step.val.TxHash, got.Position, step.pos)
+		}
+	}
+
+	// a token received by transfer is part of what a later sell empties
+	transfer := model.SolSwapData{TxHash: "s9", Type: "TRANSFER", FromUserAccount: "friend", FromToken: labelMint, FromTokenAmount: 4, ToUserAccount: "buyer", ToToken: labelMint, ToTokenAmount: 4}
+	fillTradeLabel(l, transfer)
+	got := fillTradeLabel(l, labelSwap("s10", "buyer", false, 6, 10000))
+	if got.TradeLabel != model.LabelSellAll || got.Position.HoldSeconds != 5000 || calls != 4 {
+		t.Errorf("got %s %+v after %d calls", got.TradeLabel, got.Position, calls)
+	}
+
+	// a redelivery of the last sell labels the same and leaves the ledger where it was
+	if got := fillTradeLabel(l, labelSwap("s10", "buyer", false, 6, 10000)); got.TradeLabel != model.LabelSellAll {
+		t.Errorf("redelivery got %s", got.TradeLabel)
+	}
+
+	h, _ := l.Get("buyer", labelMint)
+	if h.Buys != 3 || h.Sells != 3 || h.Exits != 2 || h.Balance.Sign() != 0 || h.PositionBuys != 0 {
+		t.Errorf("got %+v", h)
+	}
+
+	// one base unit left over is not a sell all, however small next to the balance
+	whale := labelSwap("s11", "whale", false, 1e9, 10000)
+	whale.FromTokenRawAmount, whale.FromTokenDecimals = "1000000000000000000", 9
+	if got := fillTradeLabel(l, whale); got.TradeLabel != model.LabelPartialSell || got.Position.SellPercent != 100 {
+		t.Errorf("got %s %+v", got.TradeLabel, got.Position)
+	}
+	if h, _ := l.Get("whale", labelMint); h.Balance.Cmp(big.NewInt(1)) != 0 || h.Decimals != 9 || h.Exits != 0 {
+		t.Errorf("got %+v", h)
+	}
+
+	// history labels from the trade's own balances and leaves the ledger alone
+	seeds["late"] = seed{0, true}
+	if got := fillHistoryTradeLabel(labelSwap("h1", "late", true, 1, 100)); got.TradeLabel != model.LabelFreshBuy || calls != 6 {
+		t.Errorf("got %s after %d calls", got.TradeLabel, calls)
+	}
+	seeds["late"] = seed{4, true}
+	if got := fillHistoryTradeLabel(labelSwap("h2", "late", true, 1, 200)); got.TradeLabel != model.LabelAccumulate || got.Position.BuyCount != 0 {
+		t.Errorf("got %s %+v", got.TradeLabel, got.Position)
+	}
+	if got := fillHistoryTradeLabel(labelSwap("h3", "late", false, 1, 300)); got.TradeLabel != model.LabelPartialSell || got.Position.SellPercent != 25 {
+		t.Errorf("got %s %+v", got.TradeLabel, got.Position)
+	}
+	if _, ok := l.Get("late", labelMint); ok {
+		t.Errorf("history trade seeded the ledger")
+	}
+}
+
+func samePosition(a, b *model.PositionInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -3,7 +3,6 @@ package handler
 import (
 	"context"
 	"fmt"
-	"math"
 	"strings"
 	"time"
 
@@ -15,6 +14,32 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
 )
 
+func init() {
+	RegisterParser(&funcParser{
+		name:       "goble_dex",
+		priority:   30,
+		programIDs: dexProgramIDs,
+		match:      notPumpCreate,
+		parse:      parseGobleDEX,
+	})
+
+	RegisterParser(&funcParser{
+		name:     "common_transfer",
+		priority: 100,
+		match:    func(v HeliusData) bool { return !isSwapType(v) },
+		parse:    parseCommonTransfer,
+	})
+}
+
+func dexProgramIDs() []string {
+	dexmap := config.GetDexConfig()
+	res := make([]string, 0, len(dexmap))
+	for _, v := range dexmap {
+		res = append(res, v.ContractAddress)
+	}
+	return res
+}
+
 func getMonitorAddress(addrlist string) (*model.MonitorAddressRecord, error) {
 	var resAddr model.MonitorAddressRecord
 
@@ -88,13 +113,6 @@ func parseHeliusTransfers(datas []HeliusData) ([]model.SolSwapData, error) {
 	return results, nil
 }
 
-func addBigFloat(a, b float64, precision uint) float64 {
-	multiplier := math.Pow(10, float64(precision))
-	result := math.Round(a*multiplier) + math.Round(b*multiplier)
-	res := result / multiplier
-	return res
-}
-
 func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 	if data.Type != "TRANSFER" {
 		return nil, fmt.Errorf("%s is not TRANSFER", data.Type)
@@ -114,38 +132,6 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 	for _, item := range data.Instructions {
 		if item.ProgramID == DCAProgramedID {
 			isDCATrade = true
-
-			dcaobj, err := ParseOpenDCA(&item)
-			if err != nil {
-				continue
-			}
-
-			dcadata := dcaobj.String()
-
-			item := model.SolSwapData{
-				TxHash:    data.Signature,
-				Source:    "DCA Program",
-				Timestamp: data.Timestamp,
-				Type:      "OPENDCA",
-				Date:      timeString,
-
-				FromToken:        "",
-				FromTokenAccount: "",
-				FromUserAccount:  "",
-				FromTokenAmount:  0,
-
-				ToToken:         "",
-				ToTokenAccount:  "",
-				ToUserAccount:   "",
-				ToTokenAmount:   0,
-				TradeLabel:      "",
-				IsDCATrade:      isDCATrade,
-				WalletCounts:    1,
-				TransferDetails: make([]model.SolSwapData, 0),
-				DCAOpenData:     dcadata,
-			}
-
-			return []model.SolSwapData{item}, nil
 		}
 	}
 
@@ -169,7 +155,7 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 
 		for from, toitem := range coinmap {
 			for to, val := range toitem {
-				amount := toDecimal(fmt.Sprintf("%d", val), 9)
+				amount := uintAmount(uint64(val), 9)
 				item := model.SolSwapData{
 					TxHash:    data.Signature,
 					Source:    data.Source,
@@ -180,17 +166,17 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 					FromToken:        "So11111111111111111111111111111111111111112",
 					FromTokenAccount: from,
 					FromUserAccount:  from,
-					FromTokenAmount:  amount,
 
 					ToToken:         "So11111111111111111111111111111111111111112",
 					ToTokenAccount:  to,
 					ToUserAccount:   to,
-					ToTokenAmount:   amount,
 					TradeLabel:      "",
 					IsDCATrade:      isDCATrade,
 					WalletCounts:    1,
 					TransferDetails: make([]model.SolSwapData, 0),
 				}
+				setFromAmount(&item, amount)
+				setToAmount(&item, amount)
 
 				if CheckTxValid(item.FromUserAccount, item.ToUserAccount) {
 					resData = append(resData, item)
@@ -217,17 +203,18 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 				FromToken:        v.Mint,
 				FromTokenAccount: v.FromTokenAccount,
 				FromUserAccount:  v.FromUserAccount,
-				FromTokenAmount:  v.TokenAmount,
 
 				ToToken:         v.Mint,
 				ToTokenAccount:  v.ToTokenAccount,
 				ToUserAccount:   v.ToUserAccount,
-				ToTokenAmount:   v.TokenAmount,
 				TradeLabel:      "",
 				IsDCATrade:      isDCATrade,
 				WalletCounts:    1,
 				TransferDetails: make([]model.SolSwapData, 0),
 			}
+			amount := transferAmount(data, v)
+			setFromAmount(&item, amount)
+			setToAmount(&item, amount)
 
 			if CheckTxValid(item.FromUserAccount, item.ToUserAccount) {
 				resData = append(resData, item)
@@ -276,14 +263,14 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 				continue
 			}
 
-			frommap := make(map[string]float64)
-			tomap := make(map[string]float64)
-			totalamount := float64(0.0)
+			frommap := make(map[string]tokenAmount)
+			tomap := make(map[string]tokenAmount)
+			totalamount := tokenAmount{}
 
 			for _, v := range list {
-				frommap[v.FromUserAccount] += v.FromTokenAmount
-				tomap[v.ToUserAccount] += v.ToTokenAmount
-				totalamount += v.ToTokenAmount
+				frommap[v.FromUserAccount] = frommap[v.FromUserAccount].add(fromAmountOf(v))
+				tomap[v.ToUserAccount] = tomap[v.ToUserAccount].add(toAmountOf(v))
+				totalamount = totalamount.add(toAmountOf(v))
 			}
 
 			fromcount := len(frommap)
@@ -292,16 +279,16 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 			if fromcount == 1 && tocount > 1 {
 				for addr, amt := range frommap {
 					fromaddr := addr
-					if totalamount != amt {
+					if totalamount.cmp(amt) != 0 {
 						continue
 					}
 
 					if CheckTxValid(fromaddr, fromaddr) {
 						item := list[0]
-						item.FromTokenAmount = amt
+						setFromAmount(&item, amt)
 						item.ToTokenAccount = ""
 						item.ToUserAccount = ""
-						item.ToTokenAmount = amt
+						setToAmount(&item, amt)
 						item.WalletCounts = len(list)
 						item.TransferDetails = list
 
@@ -318,7 +305,7 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 			} else if fromcount > 1 && tocount == 1 {
 				for addr, amt := range tomap {
 					toaddr := addr
-					if totalamount != amt {
+					if totalamount.cmp(amt) != 0 {
 						continue
 					}
 
@@ -326,8 +313,8 @@ func parseCommonTransfer(data HeliusData) ([]model.SolSwapData, error) {
 						item := list[0]
 						item.FromTokenAccount = ""
 						item.FromUserAccount = ""
-						item.FromTokenAmount = amt
-						item.ToTokenAmount = amt
+						setFromAmount(&item, amt)
+						setToAmount(&item, amt)
 						item.WalletCounts = len(list)
 						item.TransferDetails = list
 
@@ -360,10 +347,10 @@ func parseGobleSwap(in HeliusData) (*model.SolSwapData, error) {
 
 		if feeplayer == tokenTransfer.ToUserAccount {
 			//buy other coin using sol
-			fromAmt := float64(0.0)
+			fromAmt := uintAmount(0, 9)
 			for _, v := range in.NativeTransfers {
 				if v.FromUserAccount == tokenTransfer.ToUserAccount && v.ToUserAccount == tokenTransfer.FromUserAccount {
-					fromAmt = float64(v.Amount) / math.Pow10(9)
+					fromAmt = uintAmount(uint64(v.Amount), 9)
 				}
 			}
 
@@ -376,24 +363,28 @@ func parseGobleSwap(in HeliusData) (*model.SolSwapData, error) {
 				FromToken:        "So11111111111111111111111111111111111111112",
 				FromTokenAccount: feeplayer,
 				FromUserAccount:  feeplayer,
-				FromTokenAmount:  fromAmt,
 				ToToken:          tokenTransfer.Mint,
 				ToTokenAccount:   tokenTransfer.ToTokenAccount,
 				ToUserAccount:    feeplayer,
-				ToTokenAmount:    tokenTransfer.TokenAmount,
 				TradeLabel:       "",
 				IsDCATrade:       false,
 				WalletCounts:     1,
 				TransferDetails:  make([]model.SolSwapData, 0),
 			}
+			setFromAmount(item, fromAmt)
+			setToAmount(item, transferAmount(in, tokenTransfer))
 
 			return item, nil
 		} else if feeplayer == tokenTransfer.FromUserAccount {
 			//sell other coin using sol
-			toAmt := float64(0)
+			toAmt := uintAmount(0, 9)
 			for _, v := range in.AccountData {
 				if v.Account == tokenTransfer.ToUserAccount {
-					toAmt = float64(math.Abs(float64(v.NativeBalanceChange))) / math.Pow10(9)
+					change := v.NativeBalanceChange
+					if change < 0 {
+						change = -change
+					}
+					toAmt = uintAmount(uint64(change), 9)
 				}
 			}
 
@@ -406,16 +397,16 @@ func parseGobleSwap(in HeliusData) (*model.SolSwapData, error) {
 				FromToken:        tokenTransfer.Mint,
 				FromTokenAccount: tokenTransfer.FromTokenAccount,
 				FromUserAccount:  feeplayer,
-				FromTokenAmount:  tokenTransfer.TokenAmount,
 				ToToken:          "So11111111111111111111111111111111111111112",
 				ToTokenAccount:   feeplayer,
 				ToUserAccount:    feeplayer,
-				ToTokenAmount:    toAmt,
 				TradeLabel:       "",
 				IsDCATrade:       false,
 				WalletCounts:     1,
 				TransferDetails:  make([]model.SolSwapData, 0),
 			}
+			setFromAmount(item, transferAmount(in, tokenTransfer))
+			setToAmount(item, toAmt)
 
 			return item, nil
 		}
@@ -424,7 +415,7 @@ func parseGobleSwap(in HeliusData) (*model.SolSwapData, error) {
 	}
 
 	feePayer := in.FeePayer
-	transferSummary := make(map[string]map[string]float64)
+	transferSummary := make(map[string]map[string]tokenAmount)
 
 	addrlookup := make(map[string]map[string]string)
 
@@ -458,7 +449,7 @@ func parseGobleSwap(in HeliusData) (*model.SolSwapData, error) {
 			continue
 		}
 
-		amount := transfer.TokenAmount
+		amount := transferAmount(in, transfer)
 
 		//save token account
 		if _, ok := addrlookup[transfer.Mint]; !ok {
@@ -467,28 +458,28 @@ func parseGobleSwap(in HeliusData) (*model.SolSwapData, error) {
 
 		//
 		if _, ok := transferSummary[transfer.Mint]; !ok {
-			transferSummary[transfer.Mint] = make(map[string]float64)
+			transferSummary[transfer.Mint] = make(map[string]tokenAmount)
 		}
 
 		if transfer.FromUserAccount == feePayer {
-			transferSummary[transfer.Mint]["sent"] = addBigFloat(transferSummary[transfer.Mint]["sent"], amount, 18)
+			transferSummary[transfer.Mint]["sent"] = transferSummary[transfer.Mint]["sent"].add(amount)
 
 			addrlookup[transfer.Mint][feePayer] = transfer.FromTokenAccount
 		} else if transfer.ToUserAccount == feePayer {
-			transferSummary[transfer.Mint]["received"] = addBigFloat(transferSummary[transfer.Mint]["received"], amount, 18)
+			transferSummary[transfer.Mint]["received"] = transferSummary[transfer.Mint]["received"].add(amount)
 
 			addrlookup[transfer.Mint][feePayer] = transfer.ToTokenAccount
 		}
 
 	}
 
-	formattedSummary := make(map[string]map[string]float64)
+	formattedSummary := make(map[string]map[string]tokenAmount)
 	for mint, summary := range transferSummary {
-		netChange := summary["received"] - summary["sent"]
-		if netChange > 0 {
-			formattedSummary[mint] = map[string]float64{"received": netChange}
-		} else if netChange < 0 {
-			formattedSummary[mint] = map[string]float64{"sent": netChange}
+		netChange := summary["received"].add(summary["sent"].neg())
+		if netChange.sign() > 0 {
+			formattedSummary[mint] = map[string]tokenAmount{"received": netChange}
+		} else if netChange.sign() < 0 {
+			formattedSummary[mint] = map[string]tokenAmount{"sent": netChange}
 		}
 	}
 
@@ -498,19 +489,19 @@ func parseGobleSwap(in HeliusData) (*model.SolSwapData, error) {
 
 	for mint, v := range formattedSummary {
 		toamtChange := v["received"]
-		if toamtChange > 0 {
+		if toamtChange.sign() > 0 {
 			res.ToToken = mint
 			res.ToUserAccount = feePayer
 			res.ToTokenAccount = addrlookup[mint][feePayer]
-			res.ToTokenAmount = toamtChange
+			setToAmount(res, toamtChange)
 		}
 
 		fromamtChange := v["sent"]
-		if fromamtChange < 0 {
+		if fromamtChange.sign() < 0 {
 			res.FromToken = mint
 			res.FromUserAccount = feePayer
 			res.FromTokenAccount = addrlookup[mint][feePayer]
-			res.FromTokenAmount = float64(0) - fromamtChange
+			setFromAmount(res, fromamtChange.neg())
 		}
 	}
 
@@ -585,14 +576,12 @@ func parseGobleDEX(data HeliusData) ([]model.SolSwapData, error) {
 }
 
 func handTransferData(v HeliusData) error {
-	datait, err := parseGobleDEX(v)
+	datait, err := ParseHeliusData(v)
 	if err != nil {
-		datait, err = parseCommonTransfer(v)
-		if err != nil {
-			return fmt.Errorf("parse transfer data, %v", err)
-		}
+		return fmt.Errorf("%w, %v", ErrUnparsed, err)
 	}
 
+	datait = FillPoolParams(v, datait)
 	datalist := FillLabel(datait)
 
 	err = sendKafkaMsg(datalist)
@@ -16,6 +16,7 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/alikafka"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/db"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/ledger"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
 )
@@ -168,7 +169,7 @@ func TestSendTxHis(t *testing.T) {
 
 		// logger.Logrus.WithFields(logrus.Fields{"Data": item}).Info("handTransferData send transfer kafka data")
 
-		ndata := fillTradeLabel(item)
+		ndata := fillHistoryTradeLabel(item)
 
 		logger.Logrus.WithFields(logrus.Fields{"Data": ndata}).Info("FillTradeLabel data")
 
@@ -210,7 +211,7 @@ func TestSendTg(t *testing.T) {
 		TradeLabel:       model.LabelNone,
 	}
 
-	ndata := fillTradeLabel(item)
+	ndata := fillTradeLabel(ledger.New(0, 0), item)
 
 	logger.Logrus.WithFields(logrus.Fields{"Data": ndata}).Info("FillTradeLabel data")
 	select {}
@@ -262,7 +263,7 @@ func TestGetTxParse(t *testing.T) {
 	}
 
 	txhash := "fatFjqVwLQLRkNtL1HCs5pJchCov9ChW9BJmUb1n6jWZaQqmCbjfTSYCcDkoWszMobATRFFW4HaZ5zTR1HcS1JH"
-	obj, err := getQuickNodeTransaction(txhash)
+	obj, err := getRPCTransaction(txhash)
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
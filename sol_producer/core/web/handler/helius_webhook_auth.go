@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const (
+	defaultSignatureHeader = "X-Helius-Signature"
+	defaultMaxBodyBytes    = 32 << 20
+)
+
+func checkAuthHeader(got string, secrets []string) bool {
+	if got == "" {
+		return false
+	}
+
+	for _, v := range secrets {
+		if v == "" {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(got), []byte(v)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkBodySignature(body []byte, signature string, secrets []string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+
+	for _, v := range secrets {
+		if v == "" {
+			continue
+		}
+
+		mac := hmac.New(sha256.New, []byte(v))
+		mac.Write(body)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyHeliusRequest accepts the request when either the auth header or the body hmac
+// matches a current or retired secret. config is read per call so rotation needs no restart.
+// with no secret set it fails closed unless AllowUnauthenticated
+func verifyHeliusRequest(cfg config.HeliusConfig, header http.Header, body []byte) bool {
+	if cfg.AuthHeader == "" && cfg.HMACSecret == "" {
+		return cfg.AllowUnauthenticated
+	}
+
+	authSecrets := append([]string{cfg.AuthHeader}, cfg.RetiredAuthHeaders...)
+	hmacSecrets := append([]string{cfg.HMACSecret}, cfg.RetiredHMACSecrets...)
+
+	if cfg.AuthHeader != "" && checkAuthHeader(header.Get("Authorization"), authSecrets) {
+		return true
+	}
+
+	if cfg.HMACSecret != "" {
+		sigHeader := cfg.SignatureHeader
+		if sigHeader == "" {
+			sigHeader = defaultSignatureHeader
+		}
+
+		if checkBodySignature(body, header.Get(sigHeader), hmacSecrets) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readWebhookBody reads the body up to limit bytes, a larger one fails with *http.MaxBytesError
+// before it is buffered
+func readWebhookBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	return io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+}
+
+func HeliusWebhookAuth(c *gin.Context) {
+	cfg := config.GetHeliusConfig()
+
+	body, err := readWebhookBody(c.Writer, c.Request, cfg.MaxBodyBytes)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		metrics.Incr("webhook_too_large")
+		logger.Logrus.WithFields(logrus.Fields{"ClientIP": c.ClientIP(), "Limit": tooLarge.Limit}).Warn("HeliusWebhookAuth reject large body")
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, &Response{Code: http.StatusRequestEntityTooLarge, Message: "body too large"})
+		return
+	}
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("HeliusWebhookAuth read body failed")
+		c.AbortWithStatusJSON(http.StatusBadRequest, &Response{Code: http.StatusBadRequest, Message: "invalid body"})
+		return
+	}
+
+	if !verifyHeliusRequest(cfg, c.Request.Header, body) {
+		metrics.Incr("webhook_unauthorized")
+
+		logger.Logrus.WithFields(logrus.Fields{"ClientIP": c.ClientIP(), "Length": len(body)}).Warn("HeliusWebhookAuth reject unauthenticated call")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, &Response{Code: http.StatusUnauthorized, Message: "unauthorized"})
+		return
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Next()
+}
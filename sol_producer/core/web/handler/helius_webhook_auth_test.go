@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+)
+
+func TestVerifyHeliusRequest(t *testing.T) {
+	body := []byte(`[{"signature":"abc"}]`)
+
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	cfg := config.HeliusConfig{
+		AuthHeader:         "current",
+		RetiredAuthHeaders: []string{"old"},
+		HMACSecret:         "hmac-current",
+		RetiredHMACSecrets: []string{"hmac-old"},
+	}
+
+	cases := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{"no header", http.Header{}, false},
+		{"current auth", http.Header{"Authorization": {"current"}}, true},
+		{"retired auth", http.Header{"Authorization": {"old"}}, true},
+		{"wrong auth", http.Header{"Authorization": {"forged"}}, false},
+		{"current hmac", http.Header{defaultSignatureHeader: {sign("hmac-current")}}, true},
+		{"retired hmac", http.Header{defaultSignatureHeader: {"sha256=" + sign("hmac-old")}}, true},
+		{"wrong hmac", http.Header{defaultSignatureHeader: {sign("forged")}}, false},
+	}
+
+	for _, c := range cases {
+		if got := verifyHeliusRequest(cfg, c.header, body); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	if verifyHeliusRequest(config.HeliusConfig{}, http.Header{}, body) {
+		t.Errorf("unconfigured secrets should reject")
+	}
+	if !verifyHeliusRequest(config.HeliusConfig{AllowUnauthenticated: true}, http.Header{}, body) {
+		t.Errorf("unconfigured secrets with AllowUnauthenticated should accept")
+	}
+	// the flag does not loosen a configured secret
+	if verifyHeliusRequest(config.HeliusConfig{AuthHeader: "current", AllowUnauthenticated: true}, http.Header{}, body) {
+		t.Errorf("configured secret should reject a missing header")
+	}
+}
+
+func TestReadWebhookBody(t *testing.T) {
+	body := strings.Repeat("x", 64)
+
+	got, err := readWebhookBody(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/sol/webhook", strings.NewReader(body)), 64)
+	if err != nil || string(got) != body {
+		t.Fatalf("got %d bytes, %v", len(got), err)
+	}
+
+	// one byte over the limit fails before the body is buffered
+	var tooLarge *http.MaxBytesError
+	_, err = readWebhookBody(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/sol/webhook", strings.NewReader(body+"x")), 64)
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestVerifyAdminRequest(t *testing.T) {
+	cfg := config.AdminConfig{Token: "admin"}
+
+	cases := []struct {
+		name   string
+		cfg    config.AdminConfig
+		header http.Header
+		want   bool
+	}{
+		{"bearer", cfg, http.Header{"Authorization": {"Bearer admin"}}, true},
+		{"no header", cfg, http.Header{}, false},
+		{"bare token", cfg, http.Header{"Authorization": {"admin"}}, false},
+		{"wrong token", cfg, http.Header{"Authorization": {"Bearer forged"}}, false},
+		{"no token configured", config.AdminConfig{}, http.Header{"Authorization": {"Bearer "}}, false},
+	}
+
+	for _, c := range cases {
+		if got := verifyAdminRequest(c.cfg, c.header); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -2,20 +2,22 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
-	"math/big"
 	"net/http"
 	"runtime"
 	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/alikafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/archive"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/dedup"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
 )
 
 type HeliusData struct {
@@ -114,6 +116,8 @@ type TokenDetails struct {
 	ToUserAccount    string  `json:"toUserAccount"`
 	TokenAmount      float64 `json:"tokenAmount"`
 	TokenStandard    string  `json:"tokenStandard"`
+	// amountText is the exact literal of tokenAmount, as helius wrote it or from the raw amount
+	amountText string
 }
 
 func PrintStack() string {
@@ -122,19 +126,13 @@ func PrintStack() string {
 	return string(buf[:n])
 }
 
-func toDecimal(amount string, decimal int) float64 {
-	da, ok := new(big.Float).SetString(amount)
-	if !ok {
-		return float64(0)
-	}
-
-	precision := new(big.Float).SetFloat64(math.Pow10(decimal))
-
-	// 计算不带精度的小数值
-	result := new(big.Float).Quo(da, precision)
-	res, _ := result.Float64()
-
-	return res
+func init() {
+	RegisterParser(&funcParser{
+		name:     "helius_swap",
+		priority: 20,
+		match:    func(v HeliusData) bool { return isSwapType(v) && v.Source != "PUMP_FUN" },
+		parse:    generateSwapData,
+	})
 }
 
 func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
@@ -147,16 +145,10 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 	DCAProgramedID := cfg.DCAProgrameID
 	isDCATrade := false
 
-	DCARecived := ""
-	DCAUser := ""
+	// dca fills are resolved to the dca user by parseJupiterDCA, this is only the fallback
 	for _, item := range in.Instructions {
 		if item.ProgramID == DCAProgramedID {
 			isDCATrade = true
-
-			if len(item.Accounts) == 12 {
-				DCAUser = item.Accounts[2]
-				DCARecived = item.Accounts[5]
-			}
 		}
 	}
 
@@ -183,13 +175,13 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 					item.FromToken = v.TokenInputs[0].Mint
 					item.FromTokenAccount = v.TokenInputs[0].FromTokenAccount
 					item.FromUserAccount = v.TokenInputs[0].FromUserAccount
-					item.FromTokenAmount = v.TokenInputs[0].TokenAmount
+					setFromAmount(&item, transferAmount(in, v.TokenInputs[0]))
 				}
 				if len(v.TokenOutputs) == 1 {
 					item.ToToken = v.TokenOutputs[0].Mint
 					item.ToTokenAccount = v.TokenOutputs[0].ToTokenAccount
 					item.ToUserAccount = v.TokenOutputs[0].ToUserAccount
-					item.ToTokenAmount = v.TokenOutputs[0].TokenAmount
+					setToAmount(&item, transferAmount(in, v.TokenOutputs[0]))
 				}
 
 				if item.FromUserAccount != "" && item.FromUserAccount == item.ToUserAccount && item.ToTokenAmount != 0 {
@@ -216,33 +208,27 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 			first.FromToken = swapdata.TokenInputs[0].Mint
 			first.FromTokenAccount = swapdata.TokenInputs[0].TokenAccount
 			first.FromUserAccount = swapdata.TokenInputs[0].UserAccount
-			first.FromTokenAmount = toDecimal(swapdata.TokenInputs[0].RawTokenAmount.TokenAmount, swapdata.TokenInputs[0].RawTokenAmount.Decimals)
+			setFromAmount(&first, rawAmount(swapdata.TokenInputs[0].RawTokenAmount.TokenAmount, swapdata.TokenInputs[0].RawTokenAmount.Decimals))
 		} else if len(swapdata.TokenInputs) == 0 {
 			first.FromToken = "So11111111111111111111111111111111111111112"
 			first.FromTokenAccount = swapdata.NativeInput.Account
 			first.FromUserAccount = swapdata.NativeInput.Account
-			first.FromTokenAmount = toDecimal(swapdata.NativeInput.Amount, 9)
+			setFromAmount(&first, rawAmount(swapdata.NativeInput.Amount, 9))
 		}
 
 		if len(swapdata.TokenOutputs) == 1 {
 			first.ToToken = swapdata.TokenOutputs[0].Mint
 			first.ToTokenAccount = swapdata.TokenOutputs[0].TokenAccount
 			first.ToUserAccount = swapdata.TokenOutputs[0].UserAccount
-			first.ToTokenAmount = toDecimal(swapdata.TokenOutputs[0].RawTokenAmount.TokenAmount, swapdata.TokenOutputs[0].RawTokenAmount.Decimals)
+			setToAmount(&first, rawAmount(swapdata.TokenOutputs[0].RawTokenAmount.TokenAmount, swapdata.TokenOutputs[0].RawTokenAmount.Decimals))
 		} else if len(swapdata.TokenOutputs) == 0 {
 			first.ToToken = "So11111111111111111111111111111111111111112"
 			first.ToTokenAccount = swapdata.NativeOutput.Account
 			first.ToUserAccount = swapdata.NativeOutput.Account
-			first.ToTokenAmount = toDecimal(swapdata.NativeOutput.Amount, 9)
+			setToAmount(&first, rawAmount(swapdata.NativeOutput.Amount, 9))
 		}
 
 		if first.FromUserAccount != "" && first.FromUserAccount == first.ToUserAccount {
-			if first.IsDCATrade && DCARecived != "" {
-				first.FromTokenAccount = ""
-				first.FromUserAccount = DCAUser
-				first.ToTokenAccount = DCARecived
-				first.ToUserAccount = DCAUser
-			}
 			res = append(res, first)
 		} else {
 			logger.Logrus.WithFields(logrus.Fields{"Data": in.TokenTransfers}).Info("generateSwapData raw swap data info")
@@ -252,22 +238,22 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 					first.FromToken = in.TokenTransfers[0].Mint
 					first.FromTokenAccount = in.TokenTransfers[0].FromTokenAccount
 					first.FromUserAccount = in.TokenTransfers[0].FromUserAccount
-					first.FromTokenAmount = in.TokenTransfers[0].TokenAmount
+					setFromAmount(&first, transferAmount(in, in.TokenTransfers[0]))
 
 					first.ToToken = in.TokenTransfers[1].Mint
 					first.ToTokenAccount = in.TokenTransfers[1].ToTokenAccount
 					first.ToUserAccount = in.TokenTransfers[1].ToUserAccount
-					first.ToTokenAmount = in.TokenTransfers[1].TokenAmount
+					setToAmount(&first, transferAmount(in, in.TokenTransfers[1]))
 				} else if in.TokenTransfers[1].ToUserAccount == in.TokenTransfers[0].FromUserAccount {
 					first.FromToken = in.TokenTransfers[1].Mint
 					first.FromTokenAccount = in.TokenTransfers[1].FromTokenAccount
 					first.FromUserAccount = in.TokenTransfers[1].FromUserAccount
-					first.FromTokenAmount = in.TokenTransfers[1].TokenAmount
+					setFromAmount(&first, transferAmount(in, in.TokenTransfers[1]))
 
 					first.ToToken = in.TokenTransfers[0].Mint
 					first.ToTokenAccount = in.TokenTransfers[0].ToTokenAccount
 					first.ToUserAccount = in.TokenTransfers[0].ToUserAccount
-					first.ToTokenAmount = in.TokenTransfers[0].TokenAmount
+					setToAmount(&first, transferAmount(in, in.TokenTransfers[0]))
 				}
 
 				if first.FromUserAccount != "" && first.FromUserAccount == first.ToUserAccount {
@@ -279,7 +265,7 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 				}
 			} else {
 				feePayer := in.FeePayer
-				transferSummary := make(map[string]map[string]float64)
+				transferSummary := make(map[string]map[string]tokenAmount)
 
 				addrlookup := make(map[string]map[string]string)
 
@@ -317,7 +303,7 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 						continue
 					}
 
-					amount := transfer.TokenAmount
+					amount := transferAmount(in, transfer)
 
 					//save token account
 					if _, ok := addrlookup[transfer.Mint]; !ok {
@@ -326,29 +312,29 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 
 					//
 					if _, ok := transferSummary[transfer.Mint]; !ok {
-						transferSummary[transfer.Mint] = make(map[string]float64)
+						transferSummary[transfer.Mint] = make(map[string]tokenAmount)
 					}
 
 					//no need calucate decimals
 					if transfer.FromUserAccount == feePayer {
-						transferSummary[transfer.Mint]["sent"] = transferSummary[transfer.Mint]["sent"] + amount
+						transferSummary[transfer.Mint]["sent"] = transferSummary[transfer.Mint]["sent"].add(amount)
 
 						addrlookup[transfer.Mint][feePayer] = transfer.FromTokenAccount
 					} else if transfer.ToUserAccount == feePayer {
-						transferSummary[transfer.Mint]["received"] = transferSummary[transfer.Mint]["received"] + amount
+						transferSummary[transfer.Mint]["received"] = transferSummary[transfer.Mint]["received"].add(amount)
 
 						addrlookup[transfer.Mint][feePayer] = transfer.ToTokenAccount
 					}
 
 				}
 
-				formattedSummary := make(map[string]map[string]float64)
+				formattedSummary := make(map[string]map[string]tokenAmount)
 				for mint, summary := range transferSummary {
-					netChange := summary["received"] - summary["sent"]
-					if netChange > 0 {
-						formattedSummary[mint] = map[string]float64{"received": netChange}
-					} else if netChange < 0 {
-						formattedSummary[mint] = map[string]float64{"sent": netChange}
+					netChange := summary["received"].add(summary["sent"].neg())
+					if netChange.sign() > 0 {
+						formattedSummary[mint] = map[string]tokenAmount{"received": netChange}
+					} else if netChange.sign() < 0 {
+						formattedSummary[mint] = map[string]tokenAmount{"sent": netChange}
 					}
 				}
 
@@ -358,19 +344,19 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 
 				for mint, v := range formattedSummary {
 					toamtChange := v["received"]
-					if toamtChange > 0 {
+					if toamtChange.sign() > 0 {
 						resData.ToToken = mint
 						resData.ToUserAccount = feePayer
 						resData.ToTokenAccount = addrlookup[mint][feePayer]
-						resData.ToTokenAmount = toamtChange
+						setToAmount(&resData, toamtChange)
 					}
 
 					fromamtChange := v["sent"]
-					if fromamtChange < 0 {
+					if fromamtChange.sign() < 0 {
 						resData.FromToken = mint
 						resData.FromUserAccount = feePayer
 						resData.FromTokenAccount = addrlookup[mint][feePayer]
-						resData.FromTokenAmount = float64(0) - fromamtChange
+						setFromAmount(&resData, fromamtChange.neg())
 					}
 				}
 
@@ -389,49 +375,51 @@ func generateSwapData(in HeliusData) ([]model.SolSwapData, error) {
 }
 
 func sendKafkaMsg(in []model.SolSwapData) error {
-	keyStr := "default"
-	if len(in) > 0 {
-		keyStr = in[0].TxHash
-	} else {
+	if len(in) == 0 {
 		return nil
 	}
 
-	data, err := json.Marshal(&in)
+	data, headers, err := solmsg.Marshal(solmsg.SolSwapBatch, in)
 	if err != nil {
 		return err
 	}
 
 	cfg := config.GetKafkaConfig()
-	err = alikafka.GetKafkaInst().Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: &cfg.Topic, Partition: kafka.PartitionAny},
-		Key:            []byte(keyStr),
-		Value:          []byte(data),
-	}, nil)
-	if err != nil {
-		return fmt.Errorf("failed to produce message: %w", err)
-	}
-
-	alikafka.GetKafkaInst().Flush(5000)
-
-	return nil
+	return publish(alikafka.GetKafkaInst(), cfg.Topic, walletKey(in), data, headers)
 }
 
 func HandleData(in []HeliusData) error {
 	for _, v := range in {
 		logger.Logrus.WithFields(logrus.Fields{"Data": v}).Info("HandleData raw data")
 
+		// the claim comes before the ledger sees the trade, so a second delivery never labels and
+		// moves it again while the first one is in flight. a released claim is retried, the
+		// ledger skips what it applied already
+		if !dedup.Claim(dedup.ScopeLive, v.Signature) {
+			logger.Logrus.WithFields(logrus.Fields{"TxHash": v.Signature}).Info("HandleData skip duplicate signature")
+			continue
+		}
+
 		if v.Type == "SWAP" || v.Type == "CREATE" {
 			err := handSwapData(v)
 			if err != nil {
+				dedup.Release(dedup.ScopeLive, v.Signature)
 				logger.Logrus.WithFields(logrus.Fields{"Data": v, "ErrMsg": err}).Error("HandleData handle swap data failed")
 
 				return err
 			}
 		} else {
+			// most other txs are nothing the parsers know, only a failed publish stops the batch
 			err := handTransferData(v)
+			if errors.Is(err, ErrUnparsed) {
+				dedup.Release(dedup.ScopeLive, v.Signature)
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": v.Signature, "ErrMsg": err}).Info("HandleData skip unparsed transfer")
+				continue
+			}
 			if err != nil {
+				dedup.Release(dedup.ScopeLive, v.Signature)
 				logger.Logrus.WithFields(logrus.Fields{"Data": v, "ErrMsg": err}).Error("HandleData handle transfer data failed")
-				continue
+				return err
 			}
 		}
 	}
@@ -449,13 +437,24 @@ func HeliusWebHookHandler(c *gin.Context) {
 		if err != nil {
 			logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err, "Stack": PrintStack()}).Error("HeliusWebHookHandler panic")
 			c.JSON(http.StatusInternalServerError, r)
+		} else if r.Code == http.StatusServiceUnavailable {
+			// helius retries a batch it got no 200 for
+			c.JSON(http.StatusServiceUnavailable, r)
 		} else {
 			c.JSON(http.StatusOK, r)
 		}
 	}(r)
 
+	body, err := c.GetRawData()
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("HeliusWebHookHandler read body failed")
+		r.Code = http.StatusBadRequest
+		r.Message = "invalid input parameters"
+		return
+	}
+
 	var inp []HeliusData
-	err := c.ShouldBind(&inp)
+	err = json.Unmarshal(body, &inp)
 	if err != nil {
 		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("HeliusWebHookHandler parse parmeter failed")
 		r.Code = http.StatusBadRequest
@@ -470,7 +469,25 @@ func HeliusWebHookHandler(c *gin.Context) {
 		return
 	}
 
-	err = HandleData(inp)
+	err = archive.Append(body)
+	if err != nil {
+		metrics.Incr("archive_append_failed")
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("HeliusWebHookHandler archive raw data failed")
+	}
+
+	// parsing, labelling and publishing run behind the enricher, the request only journals and
+	// queues the batch. helius gets the ack once every transaction is journaled
+	if enricher == nil {
+		err = HandleData(inp)
+	} else {
+		err = enricher.Enqueue(inp)
+	}
+	if errors.Is(err, ErrEnrichQueueFull) || errors.Is(err, ErrEnrichStopped) || errors.Is(err, ErrEnrichJournal) {
+		logger.Logrus.WithFields(logrus.Fields{"Count": len(inp), "ErrMsg": err}).Error("HeliusWebHookHandler queue batch failed")
+		r.Code = http.StatusServiceUnavailable
+		r.Message = err.Error()
+		return
+	}
 	if err != nil {
 		logger.Logrus.WithFields(logrus.Fields{"Data": inp, "ErrMsg": err}).Error("HeliusWebHookHandler handle swap data failed")
 		r.Code = http.StatusInternalServerError
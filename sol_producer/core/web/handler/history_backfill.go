@@ -0,0 +1,396 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/db"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/metrics"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"golang.org/x/time/rate"
+)
+
+// BackfillStore keeps the backfill jobs and their cursors
+type BackfillStore interface {
+	Create(job *model.BackfillJob) error
+	Save(job *model.BackfillJob) error
+	// Get returns nil when the job does not exist
+	Get(id int64) (*model.BackfillJob, error)
+	// Active returns the queued or running job of the address, nil when there is none
+	Active(address string) (*model.BackfillJob, error)
+	// List filters by status and address when they are set, newest first, limit <= 0 means all
+	List(status, address string, limit int) ([]model.BackfillJob, error)
+}
+
+type pgBackfillStore struct{}
+
+func NewPGBackfillStore() BackfillStore {
+	return &pgBackfillStore{}
+}
+
+func (s *pgBackfillStore) Create(job *model.BackfillJob) error {
+	_, err := db.GetDB().NewInsert().Model(job).Returning("id").Exec(context.Background())
+	return err
+}
+
+func (s *pgBackfillStore) Save(job *model.BackfillJob) error {
+	_, err := db.GetDB().NewUpdate().Model(job).WherePK().Exec(context.Background())
+	return err
+}
+
+func (s *pgBackfillStore) Get(id int64) (*model.BackfillJob, error) {
+	job := &model.BackfillJob{}
+	err := db.GetDB().NewSelect().Model(job).Where("id = ?", id).Scan(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *pgBackfillStore) Active(address string) (*model.BackfillJob, error) {
+	job := &model.BackfillJob{}
+	err := db.GetDB().NewSelect().Model(job).
+		Where("address = ?", address).
+		Where("status IN (?, ?)", model.BackfillQueued, model.BackfillRunning).
+		Limit(1).
+		Scan(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *pgBackfillStore) List(status, address string, limit int) ([]model.BackfillJob, error) {
+	var jobs []model.BackfillJob
+	q := db.GetDB().NewSelect().Model(&jobs).Order("id DESC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if address != "" {
+		q = q.Where("address = ?", address)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	err := q.Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Backfiller runs history backfill jobs page by page under one helius request budget. the cursor
+// is saved after every page, so a restart or a failed page resumes where the job stopped.
+type Backfiller struct {
+	cfg     config.BackfillConfig
+	store   BackfillStore
+	limiter *rate.Limiter
+
+	fetch func(addr, before string, limit int) ([]HeliusData, string, error)
+	// handle publishes one transaction, an error fails the page and it is fetched again
+	handle func(v HeliusData) error
+	// retryDelay is the backoff unit after a failed page
+	retryDelay time.Duration
+
+	// mu keeps two submits of one address from creating two jobs
+	mu    sync.Mutex
+	queue chan int64
+}
+
+func NewBackfiller(cfg config.BackfillConfig, store BackfillStore) *Backfiller {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 20
+	}
+	if cfg.RequestsPerSecond <= 0 {
+		cfg.RequestsPerSecond = 10
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 50
+	}
+	if cfg.TargetTxs <= 0 {
+		cfg.TargetTxs = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	return &Backfiller{
+		cfg:        cfg,
+		store:      store,
+		limiter:    rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), 1),
+		fetch:      getBeforeHistoryTxs,
+		handle:     handleHistoryTx,
+		retryDelay: time.Second,
+		queue:      make(chan int64, 10000),
+	}
+}
+
+// Start resumes the jobs a previous run left queued or running and starts the workers
+func (b *Backfiller) Start(ctx context.Context) error {
+	for i := 0; i < b.cfg.Workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-b.queue:
+					b.run(ctx, id)
+				}
+			}
+		}()
+	}
+
+	resume := make([]int64, 0)
+	for _, status := range []string{model.BackfillRunning, model.BackfillQueued} {
+		jobs, err := b.store.List(status, "", 0)
+		if err != nil {
+			return err
+		}
+		// oldest first, List is newest first
+		for i := len(jobs) - 1; i >= 0; i-- {
+			resume = append(resume, jobs[i].ID)
+		}
+	}
+
+	logger.Logrus.WithFields(logrus.Fields{"Count": len(resume)}).Info("Backfiller resume jobs")
+
+	go func() {
+		for _, id := range resume {
+			b.enqueue(id)
+		}
+	}()
+	return nil
+}
+
+func (b *Backfiller) enqueue(id int64) {
+	b.queue <- id
+	metrics.Set("backfill_queue_len", int64(len(b.queue)))
+}
+
+// Submit queues a job for the address, the job already queued or running for it is returned instead.
+// zero targets fall back to the configured depth.
+func (b *Backfiller) Submit(address string, targetTxs int, targetTimestamp int64) (*model.BackfillJob, error) {
+	if address == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+	if targetTxs <= 0 && targetTimestamp <= 0 {
+		targetTxs = b.cfg.TargetTxs
+	}
+
+	b.mu.Lock()
+	active, err := b.store.Active(address)
+	if err != nil || active != nil {
+		b.mu.Unlock()
+		return active, err
+	}
+
+	now := time.Now()
+	job := &model.BackfillJob{
+		Address:         address,
+		Status:          model.BackfillQueued,
+		TargetTxs:       targetTxs,
+		TargetTimestamp: targetTimestamp,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	err = b.store.Create(job)
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	b.enqueue(job.ID)
+	return job, nil
+}
+
+// Retry queues a failed job again from its cursor
+func (b *Backfiller) Retry(id int64) (*model.BackfillJob, error) {
+	job, err := b.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("job %d not found", id)
+	}
+	if job.Status != model.BackfillFailed {
+		return nil, fmt.Errorf("job %d is %s", id, job.Status)
+	}
+
+	job.Status = model.BackfillQueued
+	job.Attempts = 0
+	job.Error = ""
+	err = b.save(job)
+	if err != nil {
+		return nil, err
+	}
+
+	b.enqueue(job.ID)
+	return job, nil
+}
+
+func (b *Backfiller) Get(id int64) (*model.BackfillJob, error) {
+	return b.store.Get(id)
+}
+
+func (b *Backfiller) List(status, address string, limit int) ([]model.BackfillJob, error) {
+	return b.store.List(status, address, limit)
+}
+
+func (b *Backfiller) save(job *model.BackfillJob) error {
+	job.UpdatedAt = time.Now()
+	err := b.store.Save(job)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"JobID": job.ID, "Address": job.Address, "ErrMsg": err}).Error("Backfiller save job failed")
+	}
+	return err
+}
+
+func (b *Backfiller) reached(job *model.BackfillJob) bool {
+	if job.TargetTxs > 0 && job.Fetched >= job.TargetTxs {
+		return true
+	}
+	return job.TargetTimestamp > 0 && job.OldestTimestamp > 0 && job.OldestTimestamp <= job.TargetTimestamp
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// handlePage publishes the transactions of the page within the target, it stops at the first
+// failure. the cursor stays before the page, the transactions published already are dropped by
+// the dedup when it is fetched again
+func (b *Backfiller) handlePage(job *model.BackfillJob, page []HeliusData) error {
+	for _, v := range page {
+		if job.TargetTimestamp > 0 && int64(v.Timestamp) < job.TargetTimestamp {
+			continue
+		}
+
+		err := b.handle(v)
+		if err != nil {
+			return fmt.Errorf("%s, %v", v.Signature, err)
+		}
+	}
+	return nil
+}
+
+// failPage counts a failed attempt of the page, the job fails at MaxAttempts. false when the
+// job stops here
+func (b *Backfiller) failPage(ctx context.Context, job *model.BackfillJob, err error) bool {
+	job.Attempts++
+	job.Error = err.Error()
+
+	if job.Attempts >= b.cfg.MaxAttempts {
+		job.Status = model.BackfillFailed
+		b.save(job)
+		return false
+	}
+	b.save(job)
+
+	return sleepCtx(ctx, time.Duration(job.Attempts)*b.retryDelay)
+}
+
+func (b *Backfiller) run(ctx context.Context, id int64) {
+	job, err := b.store.Get(id)
+	if err != nil || job == nil {
+		logger.Logrus.WithFields(logrus.Fields{"JobID": id, "ErrMsg": err}).Error("Backfiller load job failed")
+		return
+	}
+	if job.Status != model.BackfillQueued && job.Status != model.BackfillRunning {
+		return
+	}
+
+	job.Status = model.BackfillRunning
+	b.save(job)
+
+	for !b.reached(job) {
+		limit := b.cfg.PageSize
+		if job.TargetTxs > 0 && job.TargetTxs-job.Fetched < limit {
+			limit = job.TargetTxs - job.Fetched
+		}
+
+		// a stopped process leaves the job running, the next start resumes it
+		if err := b.limiter.Wait(ctx); err != nil {
+			return
+		}
+
+		page, before, err := b.fetch(job.Address, job.BeforeSig, limit)
+		if errors.Is(err, ErrHeliusRateLimited) {
+			// the budget is set too high, back off without failing the job
+			metrics.Incr("backfill_rate_limited")
+			if !sleepCtx(ctx, 5*b.retryDelay) {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			metrics.Incr("backfill_fetch_failed")
+			logger.Logrus.WithFields(logrus.Fields{"JobID": job.ID, "Address": job.Address, "Attempts": job.Attempts + 1, "ErrMsg": err}).Error("Backfiller fetch page failed")
+			if !b.failPage(ctx, job, err) {
+				return
+			}
+			continue
+		}
+
+		if len(page) == 0 {
+			break
+		}
+
+		err = b.handlePage(job, page)
+		if err != nil {
+			metrics.Incr("backfill_publish_failed")
+			logger.Logrus.WithFields(logrus.Fields{"JobID": job.ID, "Address": job.Address, "Attempts": job.Attempts + 1, "ErrMsg": err}).Error("Backfiller publish page failed")
+			if !b.failPage(ctx, job, err) {
+				return
+			}
+			continue
+		}
+
+		job.Attempts = 0
+		job.Error = ""
+		job.Fetched += len(page)
+		job.Pages++
+		job.BeforeSig = before
+		job.OldestTimestamp = int64(page[len(page)-1].Timestamp)
+		b.save(job)
+	}
+
+	job.Status = model.BackfillDone
+	b.save(job)
+
+	metrics.Incr("backfill_done")
+	logger.Logrus.WithFields(logrus.Fields{"JobID": job.ID, "Address": job.Address, "Fetched": job.Fetched, "Pages": job.Pages}).Info("Backfiller job done")
+}
+
+var backfiller *Backfiller
+
+// StartBackfiller runs the postgres backed backfiller, the admin endpoints serve it afterwards
+func StartBackfiller() *Backfiller {
+	b := NewBackfiller(config.GetBackfillConfig(), NewPGBackfillStore())
+
+	err := b.Start(context.Background())
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("Backfiller resume jobs failed")
+	}
+
+	backfiller = b
+	return b
+}
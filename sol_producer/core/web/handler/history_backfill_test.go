@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+type memBackfillStore struct {
+	mu   sync.Mutex
+	jobs []*model.BackfillJob
+}
+
+func (s *memBackfillStore) Create(job *model.BackfillJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.ID = int64(len(s.jobs) + 1)
+	copied := *job
+	s.jobs = append(s.jobs, &copied)
+	return nil
+}
+
+func (s *memBackfillStore) Save(job *model.BackfillJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *job
+	s.jobs[job.ID-1] = &copied
+	return nil
+}
+
+func (s *memBackfillStore) Get(id int64) (*model.BackfillJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || int(id) > len(s.jobs) {
+		return nil, nil
+	}
+	copied := *s.jobs[id-1]
+	return &copied, nil
+}
+
+func (s *memBackfillStore) Active(address string) (*model.BackfillJob, error) {
+	list, _ := s.List(model.BackfillQueued, address, 0)
+	running, _ := s.List(model.BackfillRunning, address, 0)
+	list = append(list, running...)
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return &list[0], nil
+}
+
+func (s *memBackfillStore) List(status, address string, limit int) ([]model.BackfillJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]model.BackfillJob, 0)
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		v := s.jobs[i]
+		if (status == "" || v.Status == status) && (address == "" || v.Address == address) {
+			res = append(res, *v)
+		}
+	}
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// fakeHistory serves 120 transactions per address, sig-0 the newest, with one 429 on the first
+// page after sig-49 and a 500 for the broken address until it is fixed
+type fakeHistory struct {
+	mu        sync.Mutex
+	limited   bool
+	broken    bool
+	requested []string
+}
+
+func (f *fakeHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := r.URL.Query().Get("before")
+	f.requested = append(f.requested, r.URL.Path+" "+before)
+
+	if r.URL.Path == "/v0/addresses/broken/transactions" && f.broken {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if before == "sig-49" && !f.limited {
+		f.limited = true
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	start := 0
+	if before != "" {
+		n, _ := strconv.Atoi(before[4:])
+		start = n + 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	page := make([]HeliusData, 0)
+	for i := start; i < start+limit && i < 120; i++ {
+		page = append(page, HeliusData{Signature: fmt.Sprintf("sig-%d", i), Timestamp: 1717000000 - i, Type: "TRANSFER"})
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func waitJob(t *testing.T, b *Backfiller, id int64, status string) *model.BackfillJob {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, _ := b.Get(id)
+		if job != nil && job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	job, _ := b.Get(id)
+	t.Fatalf("job %d got %+v, want %s", id, job, status)
+	return nil
+}
+
+func TestBackfiller(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "backfill.log"))
+
+	helius := &fakeHistory{broken: true}
+	server := httptest.NewServer(helius)
+	defer server.Close()
+
+	host := heliusAPIHost
+	heliusAPIHost = server.URL
+	defer func() { heliusAPIHost = host }()
+
+	// a job a crashed run left half done
+	store := &memBackfillStore{}
+	store.Create(&model.BackfillJob{Address: "resumed", Status: model.BackfillRunning, BeforeSig: "sig-49", Fetched: 50, Pages: 1, TargetTxs: 100})
+
+	b := NewBackfiller(config.BackfillConfig{Workers: 2, RequestsPerSecond: 1000, PageSize: 50, MaxAttempts: 2}, store)
+	b.retryDelay = time.Millisecond
+
+	var mu sync.Mutex
+	handled := make(map[string][]string)
+	b.handle = func(v HeliusData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[v.Signature] = append(handled[v.Signature], v.Signature)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := b.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// resumed from the cursor through a 429, the pages before it are not fetched again
+	job := waitJob(t, b, 1, model.BackfillDone)
+	if job.Fetched != 100 || job.Pages != 2 || job.BeforeSig != "sig-99" || job.OldestTimestamp != 1717000000-99 {
+		t.Errorf("got %+v", job)
+	}
+	mu.Lock()
+	if len(handled) != 50 || handled["sig-49"] != nil || len(handled["sig-50"]) != 1 || len(handled["sig-99"]) != 1 {
+		t.Errorf("got %d handled", len(handled))
+	}
+	mu.Unlock()
+
+	// a depth in time ends the job at the first page past it
+	job, err := b.Submit("dated", 0, 1717000000-60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job = waitJob(t, b, job.ID, model.BackfillDone)
+	if job.Fetched != 100 || job.TargetTxs != 0 {
+		t.Errorf("got %+v", job)
+	}
+
+	// a page failing past the attempts fails the job, retry resumes it
+	job, err = b.Submit("broken", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := b.Submit("broken", 0, 0)
+	if again.ID != job.ID {
+		t.Errorf("second submit created job %d", again.ID)
+	}
+
+	job = waitJob(t, b, job.ID, model.BackfillFailed)
+	if job.Attempts != 2 || job.Error == "" || job.Fetched != 0 || job.TargetTxs != 100 {
+		t.Errorf("got %+v", job)
+	}
+
+	helius.mu.Lock()
+	helius.broken = false
+	helius.mu.Unlock()
+
+	if _, err := b.Retry(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	job = waitJob(t, b, job.ID, model.BackfillDone)
+	if job.Fetched != 100 || job.Attempts != 0 || job.Error != "" {
+		t.Errorf("got %+v", job)
+	}
+
+	if _, err := b.Retry(job.ID); err == nil {
+		t.Errorf("retry of a done job should fail")
+	}
+}
+
+// TestBackfillerPublishFailed keeps the cursor before a page that failed to publish, the failure
+// counts toward the attempts of the job
+func TestBackfillerPublishFailed(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "backfill.log"))
+
+	server := httptest.NewServer(&fakeHistory{limited: true})
+	defer server.Close()
+
+	host := heliusAPIHost
+	heliusAPIHost = server.URL
+	defer func() { heliusAPIHost = host }()
+
+	b := NewBackfiller(config.BackfillConfig{Workers: 1, RequestsPerSecond: 1000, PageSize: 50, MaxAttempts: 2}, &memBackfillStore{})
+	b.retryDelay = time.Millisecond
+
+	var mu sync.Mutex
+	down := true
+	handled := make(map[string]int)
+	b.handle = func(v HeliusData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if v.Signature == "sig-60" && down {
+			return errors.New("broker down")
+		}
+		handled[v.Signature]++
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := b.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := b.Submit("wallet", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job = waitJob(t, b, job.ID, model.BackfillFailed)
+	if job.Attempts != 2 || job.Error == "" || job.Fetched != 50 || job.BeforeSig != "sig-49" {
+		t.Errorf("got %+v", job)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+
+	if _, err := b.Retry(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	job = waitJob(t, b, job.ID, model.BackfillDone)
+	if job.Fetched != 100 || job.BeforeSig != "sig-99" {
+		t.Errorf("got %+v", job)
+	}
+
+	// the part of the failed page before sig-60 was handed over again, the dedup drops it
+	mu.Lock()
+	defer mu.Unlock()
+	if handled["sig-49"] != 1 || handled["sig-50"] != 3 || handled["sig-60"] != 1 || handled["sig-99"] != 1 {
+		t.Errorf("got %v", handled)
+	}
+}
@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+const (
+	TokenProgramID     = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
+	Token2022ProgramID = "TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb"
+
+	WSOLMint = "So11111111111111111111111111111111111111112"
+	USDCMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	USDTMint = "Es9vMFrzaCERmJfrF4H2FYD4KConky3ynDr9X2n1jBrq"
+
+	DirectionBuy  = "buy"
+	DirectionSell = "sell"
+	DirectionSwap = "swap"
+)
+
+var quoteMints = map[string]bool{
+	WSOLMint: true,
+	USDCMint: true,
+	USDTMint: true,
+}
+
+// anchorDiscriminator is the first 8 bytes of sha256("global:<name>")
+func anchorDiscriminator(name string) [8]byte {
+	var res [8]byte
+	sum := sha256.Sum256([]byte("global:" + name))
+	copy(res[:], sum[:8])
+	return res
+}
+
+// anchorEventIxTag prefixes the self cpi anchor programs emit events through
+var anchorEventIxTag = [8]byte{0xe4, 0x45, 0xa5, 0x2e, 0x51, 0xcb, 0x9a, 0x1d}
+
+// anchorEventDiscriminator is the first 8 bytes of sha256("event:<Name>")
+func anchorEventDiscriminator(name string) [8]byte {
+	var res [8]byte
+	sum := sha256.Sum256([]byte("event:" + name))
+	copy(res[:], sum[:8])
+	return res
+}
+
+// anchorEvents returns the payload of every emit_cpi event of a program in tx order
+func anchorEvents(in HeliusData, programID string, disc [8]byte) [][]byte {
+	res := make([][]byte, 0)
+	for _, ix := range flattenInstructions(in) {
+		if ix.ProgramID != programID || !ix.Nested {
+			continue
+		}
+
+		data, err := base58.Decode(ix.Data)
+		if err != nil || !hasDiscriminator(data, anchorEventIxTag) || !hasDiscriminator(data[8:], disc) {
+			continue
+		}
+		res = append(res, data[16:])
+	}
+	return res
+}
+
+func hasDiscriminator(data []byte, disc [8]byte) bool {
+	return len(data) >= 8 && [8]byte(data[:8]) == disc
+}
+
+// flatInstruction is a top level or inner instruction together with
+// the inner instructions executed after it, helius flattens cpi depth
+type flatInstruction struct {
+	ProgramID string
+	Accounts  []string
+	Data      string
+	Following []InnerInstructionsData
+	Nested    bool
+}
+
+func flattenInstructions(in HeliusData) []flatInstruction {
+	res := make([]flatInstruction, 0)
+	for _, ix := range in.Instructions {
+		res = append(res, flatInstruction{
+			ProgramID: ix.ProgramID,
+			Accounts:  ix.Accounts,
+			Data:      ix.Data,
+			Following: ix.InnerInstructions,
+		})
+
+		for i, inner := range ix.InnerInstructions {
+			res = append(res, flatInstruction{
+				ProgramID: inner.ProgramID,
+				Accounts:  inner.Accounts,
+				Data:      inner.Data,
+				Following: ix.InnerInstructions[i+1:],
+				Nested:    true,
+			})
+		}
+	}
+	return res
+}
+
+type tokenTransfer struct {
+	Source    string
+	Dest      string
+	Authority string
+	Mint      string
+	Amount    uint64
+	Decimals  int // -1 when the instruction does not carry it
+}
+
+// decodeTokenTransfer reads spl Transfer and TransferChecked from either token program
+func decodeTokenTransfer(ix InnerInstructionsData) (*tokenTransfer, bool) {
+	if ix.ProgramID != TokenProgramID && ix.ProgramID != Token2022ProgramID {
+		return nil, false
+	}
+
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) < 9 {
+		return nil, false
+	}
+
+	switch data[0] {
+	case 3:
+		if len(ix.Accounts) < 3 {
+			return nil, false
+		}
+		return &tokenTransfer{
+			Source:    ix.Accounts[0],
+			Dest:      ix.Accounts[1],
+			Authority: ix.Accounts[2],
+			Amount:    binary.LittleEndian.Uint64(data[1:9]),
+			Decimals:  -1,
+		}, true
+	case 12:
+		if len(ix.Accounts) < 4 || len(data) < 10 {
+			return nil, false
+		}
+		return &tokenTransfer{
+			Source:    ix.Accounts[0],
+			Mint:      ix.Accounts[1],
+			Dest:      ix.Accounts[2],
+			Authority: ix.Accounts[3],
+			Amount:    binary.LittleEndian.Uint64(data[1:9]),
+			Decimals:  int(data[9]),
+		}, true
+	}
+
+	return nil, false
+}
+
+// findSwapTransfers picks the first transfer out of userSource and the first into userDest.
+// with inDests every transfer out of userSource into one of them before the output counts,
+// that covers programs which take their fee as a separate transfer.
+func findSwapTransfers(following []InnerInstructionsData, userSource, userDest string, inDests []string) (*tokenTransfer, *tokenTransfer, error) {
+	allowed := make(map[string]bool, len(inDests))
+	for _, v := range inDests {
+		allowed[v] = true
+	}
+
+	var in, out *tokenTransfer
+	for _, ix := range following {
+		transfer, ok := decodeTokenTransfer(ix)
+		if !ok {
+			continue
+		}
+
+		if transfer.Source == userSource && (in == nil || allowed[transfer.Dest]) {
+			if in == nil {
+				in = transfer
+			} else {
+				in.Amount += transfer.Amount
+			}
+		} else if out == nil && transfer.Dest == userDest {
+			out = transfer
+		}
+
+		if in != nil && out != nil {
+			return in, out, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("swap transfers not found, %s -> %s", userSource, userDest)
+}
+
+// tokenAccountMint resolves the mint and decimals of a token account from the helius balance changes
+func tokenAccountMint(in HeliusData, account string) (string, int) {
+	for _, v := range in.AccountData {
+		for _, change := range v.TokenBalanceChanges {
+			if change.TokenAccount == account {
+				return change.Mint, change.RawTokenAmount.Decimals
+			}
+		}
+	}
+
+	for _, v := range in.TokenTransfers {
+		if v.FromTokenAccount == account || v.ToTokenAccount == account {
+			return v.Mint, -1
+		}
+	}
+
+	return "", -1
+}
+
+func mintDecimals(in HeliusData, mint string, raw uint64) int {
+	if mint == WSOLMint {
+		return 9
+	}
+
+	for _, v := range in.AccountData {
+		for _, change := range v.TokenBalanceChanges {
+			if change.Mint == mint {
+				return change.RawTokenAmount.Decimals
+			}
+		}
+	}
+
+	// derive from the ui amount helius already scaled
+	for _, v := range in.TokenTransfers {
+		if v.Mint == mint && v.TokenAmount > 0 && raw > 0 {
+			return int(math.Round(math.Log10(float64(raw) / v.TokenAmount)))
+		}
+	}
+
+	return -1
+}
+
+// resolveTransferMint fills mint and decimals of one swap leg
+func resolveTransferMint(in HeliusData, transfer *tokenTransfer, userAccount, knownMint string) (string, int, error) {
+	mint, decimals := knownMint, transfer.Decimals
+	if mint == "" {
+		mint = transfer.Mint
+	}
+
+	if mint == "" || decimals < 0 {
+		accMint, accDecimals := tokenAccountMint(in, userAccount)
+		if mint == "" {
+			mint = accMint
+		}
+		if decimals < 0 && accMint == mint {
+			decimals = accDecimals
+		}
+	}
+
+	if mint == "" {
+		return "", 0, fmt.Errorf("mint of %s not found", userAccount)
+	}
+
+	if decimals < 0 {
+		decimals = mintDecimals(in, mint, transfer.Amount)
+	}
+	if decimals < 0 {
+		return "", 0, fmt.Errorf("decimals of %s not found", mint)
+	}
+
+	return mint, decimals, nil
+}
+
+func swapDirection(inMint, outMint string) string {
+	if quoteMints[inMint] && !quoteMints[outMint] {
+		return DirectionBuy
+	}
+	if quoteMints[outMint] && !quoteMints[inMint] {
+		return DirectionSell
+	}
+	return DirectionSwap
+}
+
+// decodedSwap is one dex swap instruction with the accounts the decoder resolved
+type decodedSwap struct {
+	Source     string
+	Pool       string
+	Owner      string
+	UserSource string
+	UserDest   string
+	InMint     string
+	OutMint    string
+	// InDests are the accounts all input transfers go to, empty means take the first one
+	InDests []string
+}
+
+// buildSwapData matches the inner transfers of a decoded swap and emits exact amounts
+func buildSwapData(in HeliusData, ix flatInstruction, swap decodedSwap) (*model.SolSwapData, error) {
+	inTransfer, outTransfer, err := findSwapTransfers(ix.Following, swap.UserSource, swap.UserDest, swap.InDests)
+	if err != nil {
+		return nil, err
+	}
+
+	inMint, inDecimals, err := resolveTransferMint(in, inTransfer, swap.UserSource, swap.InMint)
+	if err != nil {
+		return nil, err
+	}
+
+	outMint, outDecimals, err := resolveTransferMint(in, outTransfer, swap.UserDest, swap.OutMint)
+	if err != nil {
+		return nil, err
+	}
+
+	// routed swaps move funds through aggregator owned accounts, the trader is the fee payer
+	owner := swap.Owner
+	if ix.Nested || owner == "" {
+		owner = in.FeePayer
+	}
+
+	t := time.Unix(int64(in.Timestamp), 0)
+	timeString := t.Format("2006-01-02 15:04:05")
+
+	res := &model.SolSwapData{
+		TxHash:    in.Signature,
+		Source:    swap.Source,
+		Timestamp: in.Timestamp,
+		Type:      "SWAP",
+		Date:      timeString,
+
+		FromToken:        inMint,
+		FromTokenAccount: swap.UserSource,
+		FromUserAccount:  owner,
+
+		ToToken:         outMint,
+		ToTokenAccount:  swap.UserDest,
+		ToUserAccount:   owner,
+		TradeLabel:      "",
+		IsDCATrade:      false,
+		WalletCounts:    1,
+		TransferDetails: make([]model.SolSwapData, 0),
+		Pool:            swap.Pool,
+		Direction:       swapDirection(inMint, outMint),
+	}
+	setFromAmount(res, uintAmount(inTransfer.Amount, inDecimals))
+	setToAmount(res, uintAmount(outTransfer.Amount, outDecimals))
+
+	return res, nil
+}
+
+type swapDecoder struct {
+	decode func(ix flatInstruction) (*decodedSwap, error)
+	// params reads fee settings from the pool account, nil when the program has none worth reading.
+	// FillPoolParams uses it after parsing, decoding never reads an account
+	params poolParamsDecoder
+}
+
+var swapDecoders = make(map[string]swapDecoder)
+
+func registerSwapDecoder(programID string, decode func(ix flatInstruction) (*decodedSwap, error), params poolParamsDecoder) {
+	swapDecoders[programID] = swapDecoder{decode: decode, params: params}
+}
+
+type swapLeg struct {
+	Program string
+	Data    *model.SolSwapData
+}
+
+// decodeSwapLegs decodes every swap instruction of the given programs, all registered ones when empty
+func decodeSwapLegs(in HeliusData, programIDs ...string) ([]swapLeg, error) {
+	allowed := make(map[string]bool, len(programIDs))
+	for _, v := range programIDs {
+		allowed[v] = true
+	}
+
+	res := make([]swapLeg, 0)
+	for _, ix := range flattenInstructions(in) {
+		d, ok := swapDecoders[ix.ProgramID]
+		if !ok || (len(allowed) > 0 && !allowed[ix.ProgramID]) {
+			continue
+		}
+
+		swap, err := d.decode(ix)
+		if err != nil {
+			continue
+		}
+
+		item, err := buildSwapData(in, ix, *swap)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, swapLeg{Program: ix.ProgramID, Data: item})
+	}
+
+	return res, nil
+}
+
+func parseDecodedSwaps(in HeliusData, name string, programIDs ...string) ([]model.SolSwapData, error) {
+	legs, err := decodeSwapLegs(in, programIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(legs) < 1 {
+		return nil, fmt.Errorf("no %s swap, %s", name, in.Signature)
+	}
+
+	res := make([]model.SolSwapData, 0, len(legs))
+	for _, v := range legs {
+		res = append(res, *v.Data)
+	}
+	return res, nil
+}
@@ -0,0 +1,407 @@
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const (
+	JupiterDCAProgramID = "DCA265Vj8a9CEuX1eb1LWRnDT7uK6q1xMipnNyatn23M"
+
+	dcaSource = "DCA Program"
+)
+
+// dca account layout after the 8 byte anchor discriminator
+const (
+	dcaUserOffset             = 8
+	dcaInputMintOffset        = 40
+	dcaOutputMintOffset       = 72
+	dcaInDepositedOffset      = 120
+	dcaInWithdrawnOffset      = 128
+	dcaOutWithdrawnOffset     = 136
+	dcaInUsedOffset           = 144
+	dcaOutReceivedOffset      = 152
+	dcaInAmountPerCycleOffset = 160
+	dcaCycleFrequencyOffset   = 168
+	dcaAccountMinLen          = 176
+)
+
+var (
+	dcaOpenDisc             = anchorDiscriminator("open_dca")
+	dcaOpenV2Disc           = anchorDiscriminator("open_dca_v2")
+	dcaCloseDisc            = anchorDiscriminator("close_dca")
+	dcaWithdrawDisc         = anchorDiscriminator("withdraw")
+	dcaFulfillFlashFillDisc = anchorDiscriminator("fulfill_flash_fill")
+	dcaFulfillDlmmFillDisc  = anchorDiscriminator("fulfill_dlmm_fill")
+	dcaEndAndCloseDisc      = anchorDiscriminator("end_and_close")
+)
+
+func init() {
+	RegisterParser(&funcParser{
+		name:       "jupiter_dca",
+		priority:   11,
+		programIDs: func() []string { return []string{dcaProgramID()} },
+		match:      notPumpCreate,
+		parse:      parseJupiterDCA,
+	})
+}
+
+// dcaProgramID is the configured program, the mainnet one when unset
+func dcaProgramID() string {
+	if id := config.GetHeliusConfig().DCAProgrameID; id != "" {
+		return id
+	}
+	return JupiterDCAProgramID
+}
+
+// DCAState is the part of the on-chain dca account needed for progress
+type DCAState struct {
+	User             string
+	InputMint        string
+	OutputMint       string
+	InDeposited      uint64
+	InWithdrawn      uint64
+	OutWithdrawn     uint64
+	InUsed           uint64
+	OutReceived      uint64
+	InAmountPerCycle uint64
+	CycleFrequency   int64
+}
+
+func decodeDCAState(data []byte) (*DCAState, error) {
+	if len(data) < dcaAccountMinLen {
+		return nil, fmt.Errorf("dca account too short, %d", len(data))
+	}
+
+	u64 := func(offset int) uint64 {
+		return binary.LittleEndian.Uint64(data[offset : offset+8])
+	}
+
+	return &DCAState{
+		User:             base58.Encode(data[dcaUserOffset : dcaUserOffset+32]),
+		InputMint:        base58.Encode(data[dcaInputMintOffset : dcaInputMintOffset+32]),
+		OutputMint:       base58.Encode(data[dcaOutputMintOffset : dcaOutputMintOffset+32]),
+		InDeposited:      u64(dcaInDepositedOffset),
+		InWithdrawn:      u64(dcaInWithdrawnOffset),
+		OutWithdrawn:     u64(dcaOutWithdrawnOffset),
+		InUsed:           u64(dcaInUsedOffset),
+		OutReceived:      u64(dcaOutReceivedOffset),
+		InAmountPerCycle: u64(dcaInAmountPerCycleOffset),
+		CycleFrequency:   int64(u64(dcaCycleFrequencyOffset)),
+	}, nil
+}
+
+// dcaAccountLookup is swapped out in tests, the default reads the account over rpc
+var dcaAccountLookup = lookupDCAAccount
+
+func lookupDCAAccount(address string) (*DCAState, error) {
+	data, err := fetchAccountData(address)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDCAState(data)
+}
+
+func dcaCycles(amount, perCycle uint64) int {
+	if perCycle == 0 {
+		return 0
+	}
+	return int((amount + perCycle - 1) / perCycle)
+}
+
+// fillDCAProgress is best effort, the account is already gone after close and end_and_close
+func fillDCAProgress(event *model.DCAEvent) {
+	state, err := dcaAccountLookup(event.DCAAccount)
+	if err != nil {
+		return
+	}
+
+	if event.User == "" {
+		event.User = state.User
+	}
+	if event.InputMint == "" {
+		event.InputMint = state.InputMint
+	}
+	if event.OutputMint == "" {
+		event.OutputMint = state.OutputMint
+	}
+
+	event.InAmountPerCycle = state.InAmountPerCycle
+	event.CycleFrequency = state.CycleFrequency
+	event.CyclesTotal = dcaCycles(state.InDeposited, state.InAmountPerCycle)
+	event.CyclesFilled = dcaCycles(state.InUsed, state.InAmountPerCycle)
+	if state.InDeposited > state.InWithdrawn+state.InUsed {
+		event.InRemaining = state.InDeposited - state.InWithdrawn - state.InUsed
+	}
+	event.Completed = event.CyclesTotal > 0 && event.InRemaining == 0
+}
+
+// allTokenTransfers are the spl transfers of the whole tx, fills move funds across several instructions
+func allTokenTransfers(in HeliusData) []tokenTransfer {
+	res := make([]tokenTransfer, 0)
+	for _, ix := range in.Instructions {
+		if v, ok := decodeTokenTransfer(InnerInstructionsData{ProgramID: ix.ProgramID, Accounts: ix.Accounts, Data: ix.Data}); ok {
+			res = append(res, *v)
+		}
+		for _, inner := range ix.InnerInstructions {
+			if v, ok := decodeTokenTransfer(inner); ok {
+				res = append(res, *v)
+			}
+		}
+	}
+	return res
+}
+
+// netTransfers sums what left and what reached an account
+func netTransfers(transfers []tokenTransfer, account string) (uint64, uint64) {
+	var out, in uint64
+	for _, v := range transfers {
+		if v.Source == account {
+			out += v.Amount
+		}
+		if v.Dest == account {
+			in += v.Amount
+		}
+	}
+	return out, in
+}
+
+// dcaDecimals is false when neither the tx nor the mint account tells the decimals, a zero amount
+// needs none and the mint is not read for it
+func dcaDecimals(in HeliusData, mint string, raw uint64) (int, bool) {
+	if decimals := mintDecimals(in, mint, raw); decimals >= 0 {
+		return decimals, true
+	}
+	if raw == 0 {
+		return 0, true
+	}
+
+	decimals, err := mintDecimalsLookup(mint)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": in.Signature, "Mint": mint, "ErrMsg": err}).Error("dcaDecimals mint decimals unknown, amount left empty")
+		return 0, false
+	}
+	return decimals, true
+}
+
+// newDCARecord leaves an amount of unknown decimals empty rather than scaling it wrong
+func newDCARecord(in HeliusData, typ string, event *model.DCAEvent) model.SolSwapData {
+	t := time.Unix(int64(in.Timestamp), 0)
+	timeString := t.Format("2006-01-02 15:04:05")
+
+	var inOK, outOK bool
+	event.InDecimals, inOK = dcaDecimals(in, event.InputMint, event.InAmount)
+	event.OutDecimals, outOK = dcaDecimals(in, event.OutputMint, event.OutAmount)
+
+	res := model.SolSwapData{
+		TxHash:    in.Signature,
+		Source:    dcaSource,
+		Timestamp: in.Timestamp,
+		Type:      typ,
+		Date:      timeString,
+
+		FromToken:        event.InputMint,
+		FromTokenAccount: "",
+		FromUserAccount:  event.User,
+
+		ToToken:         event.OutputMint,
+		ToTokenAccount:  "",
+		ToUserAccount:   event.User,
+		TradeLabel:      "",
+		IsDCATrade:      true,
+		WalletCounts:    1,
+		TransferDetails: make([]model.SolSwapData, 0),
+		DCA:             event,
+	}
+	if inOK {
+		setFromAmount(&res, uintAmount(event.InAmount, event.InDecimals))
+	}
+	if outOK {
+		setToAmount(&res, uintAmount(event.OutAmount, event.OutDecimals))
+	}
+
+	return res
+}
+
+func parseDCAOpenIx(in HeliusData, ix flatInstruction) (*model.SolSwapData, error) {
+	obj, err := ParseOpenDCA(&InstructionsData{ProgramID: ix.ProgramID, Accounts: ix.Accounts, Data: ix.Data})
+	if err != nil {
+		return nil, err
+	}
+
+	event := &model.DCAEvent{
+		Event:            model.DCAEventOpen,
+		DCAAccount:       obj.DCAAddress,
+		User:             obj.User,
+		InputMint:        obj.InputMint,
+		OutputMint:       obj.OutputMint,
+		InAmount:         obj.InAmount,
+		InAmountPerCycle: obj.InAmountPerCycle,
+		CycleFrequency:   obj.CycleFrequency,
+		CyclesTotal:      dcaCycles(obj.InAmount, obj.InAmountPerCycle),
+		InRemaining:      obj.InAmount,
+	}
+
+	res := newDCARecord(in, "OPENDCA", event)
+	res.FromTokenAccount = obj.UserAta
+	res.DCAOpenData = obj.String()
+	return &res, nil
+}
+
+// parseDCAFillIx reads fulfill_flash_fill and fulfill_dlmm_fill, the input left in_ata
+// during initiate and the output lands in out_ata minus the fee sent on from there
+func parseDCAFillIx(in HeliusData, ix flatInstruction, transfers []tokenTransfer) (*model.SolSwapData, error) {
+	if len(ix.Accounts) < 7 {
+		return nil, fmt.Errorf("fill account length not match, %d", len(ix.Accounts))
+	}
+
+	inAta, outAta := ix.Accounts[5], ix.Accounts[6]
+	inAmount, _ := netTransfers(transfers, inAta)
+	outSent, outReceived := netTransfers(transfers, outAta)
+	if inAmount == 0 || outReceived <= outSent {
+		return nil, fmt.Errorf("fill transfers not found, %s", ix.Accounts[1])
+	}
+
+	event := &model.DCAEvent{
+		Event:      model.DCAEventFill,
+		DCAAccount: ix.Accounts[1],
+		InputMint:  ix.Accounts[2],
+		OutputMint: ix.Accounts[3],
+		InAmount:   inAmount,
+		OutAmount:  outReceived - outSent,
+	}
+
+	// the keeper signs fills, only the dca account knows whose order it is
+	fillDCAProgress(event)
+	if event.User == "" {
+		return nil, fmt.Errorf("dca user of %s not found", event.DCAAccount)
+	}
+
+	res := newDCARecord(in, "SWAP", event)
+	res.FromTokenAccount = inAta
+	res.ToTokenAccount = outAta
+	res.Direction = swapDirection(event.InputMint, event.OutputMint)
+	return &res, nil
+}
+
+// parseDCACloseIx covers close_dca by the user and end_and_close by the keeper,
+// whatever is left in the dca token accounts goes back to the user
+func parseDCACloseIx(in HeliusData, ix flatInstruction, transfers []tokenTransfer, endAndClose bool) (*model.SolSwapData, error) {
+	event := &model.DCAEvent{Event: model.DCAEventClose}
+
+	var inAta, outAta string
+	if endAndClose {
+		if len(ix.Accounts) < 8 {
+			return nil, fmt.Errorf("end and close account length not match, %d", len(ix.Accounts))
+		}
+		event.Event = model.DCAEventEndAndClose
+		event.DCAAccount, event.User = ix.Accounts[1], ix.Accounts[6]
+		event.InputMint, event.OutputMint = ix.Accounts[2], ix.Accounts[3]
+		inAta, outAta = ix.Accounts[4], ix.Accounts[5]
+	} else {
+		if len(ix.Accounts) < 6 {
+			return nil, fmt.Errorf("close account length not match, %d", len(ix.Accounts))
+		}
+		event.User, event.DCAAccount = ix.Accounts[0], ix.Accounts[1]
+		event.InputMint, event.OutputMint = ix.Accounts[2], ix.Accounts[3]
+		inAta, outAta = ix.Accounts[4], ix.Accounts[5]
+	}
+
+	event.InAmount, _ = netTransfers(transfers, inAta)
+	event.OutAmount, _ = netTransfers(transfers, outAta)
+
+	fillDCAProgress(event)
+	if endAndClose {
+		event.Completed = true
+	}
+
+	res := newDCARecord(in, "CLOSEDCA", event)
+	return &res, nil
+}
+
+func parseDCAWithdrawIx(in HeliusData, ix flatInstruction) (*model.SolSwapData, error) {
+	if len(ix.Accounts) < 5 {
+		return nil, fmt.Errorf("withdraw account length not match, %d", len(ix.Accounts))
+	}
+
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 17 {
+		return nil, fmt.Errorf("withdraw data length not match, %d", len(data))
+	}
+
+	event := &model.DCAEvent{
+		Event:      model.DCAEventWithdraw,
+		User:       ix.Accounts[0],
+		DCAAccount: ix.Accounts[1],
+		InputMint:  ix.Accounts[2],
+		OutputMint: ix.Accounts[3],
+	}
+
+	// withdrawal is 0 for the input side and 1 for the output side
+	amount := binary.LittleEndian.Uint64(data[8:16])
+	if data[16] == 0 {
+		event.InAmount = amount
+	} else {
+		event.OutAmount = amount
+	}
+
+	fillDCAProgress(event)
+
+	res := newDCARecord(in, "WITHDRAWDCA", event)
+	res.ToTokenAccount = ix.Accounts[4]
+	return &res, nil
+}
+
+// parseJupiterDCA emits one record per dca instruction, txs without a known one fall through
+func parseJupiterDCA(in HeliusData) ([]model.SolSwapData, error) {
+	programID := dcaProgramID()
+	transfers := allTokenTransfers(in)
+
+	res := make([]model.SolSwapData, 0)
+	for _, ix := range flattenInstructions(in) {
+		if ix.ProgramID != programID {
+			continue
+		}
+
+		data, err := base58.Decode(ix.Data)
+		if err != nil {
+			continue
+		}
+
+		var item *model.SolSwapData
+		switch {
+		case hasDiscriminator(data, dcaOpenDisc), hasDiscriminator(data, dcaOpenV2Disc):
+			item, err = parseDCAOpenIx(in, ix)
+		case hasDiscriminator(data, dcaFulfillFlashFillDisc), hasDiscriminator(data, dcaFulfillDlmmFillDisc):
+			item, err = parseDCAFillIx(in, ix, transfers)
+		case hasDiscriminator(data, dcaCloseDisc):
+			item, err = parseDCACloseIx(in, ix, transfers, false)
+		case hasDiscriminator(data, dcaEndAndCloseDisc):
+			item, err = parseDCACloseIx(in, ix, transfers, true)
+		case hasDiscriminator(data, dcaWithdrawDisc):
+			item, err = parseDCAWithdrawIx(in, ix)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *item)
+	}
+
+	if len(res) < 1 {
+		return nil, fmt.Errorf("no dca instruction, %s", in.Signature)
+	}
+	return res, nil
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const (
+	dcaTestUser    = "EYTAQDCxSLyxQD8TwEMhMAcxgBmvYe1zoW5X6ftV8Y6"
+	dcaTestMint    = "FTUf6mWA8MDVFBpCQVxxDJE7YSGAuTfseZ9QdoyfsT1c"
+	dcaTestAccount = "C9dpfSbEu65zj6ekQr8CcRf3czdseQJ4Aj1mBqg851C7"
+)
+
+// stubDCAAccount serves the dca account from in-memory data, closed accounts are not found
+func stubDCAAccount(t *testing.T, accounts map[string][]byte) {
+	t.Helper()
+
+	old := dcaAccountLookup
+	dcaAccountLookup = func(address string) (*DCAState, error) {
+		data, ok := accounts[address]
+		if !ok {
+			return nil, fmt.Errorf("account %s not found", address)
+		}
+		return decodeDCAState(data)
+	}
+	t.Cleanup(func() { dcaAccountLookup = old })
+}
+
+func dcaAccountData(t *testing.T, user, inMint, outMint string, deposited, used, perCycle uint64) []byte {
+	t.Helper()
+
+	data := make([]byte, 289)
+	for offset, v := range map[int]string{dcaUserOffset: user, dcaInputMintOffset: inMint, dcaOutputMintOffset: outMint} {
+		key, err := base58.Decode(v)
+		if err != nil || len(key) != 32 {
+			t.Fatalf("bad key %s: %v", v, err)
+		}
+		copy(data[offset:], key)
+	}
+	binary.LittleEndian.PutUint64(data[dcaInDepositedOffset:], deposited)
+	binary.LittleEndian.PutUint64(data[dcaInUsedOffset:], used)
+	binary.LittleEndian.PutUint64(data[dcaInAmountPerCycleOffset:], perCycle)
+	binary.LittleEndian.PutUint64(data[dcaCycleFrequencyOffset:], 3600)
+	return data
+}
+
+func TestParseJupiterDCA(t *testing.T) {
+	stubDCAAccount(t, map[string][]byte{
+		dcaTestAccount: dcaAccountData(t, dcaTestUser, WSOLMint, dcaTestMint, 50000000000, 15000000000, 5000000000),
+	})
+
+	cases := []struct {
+		fixture   string
+		typ       string
+		event     model.DCAEvent
+		inAmount  float64
+		outAmount float64
+	}{
+		{"jupiter_dca_open_v2.json", "OPENDCA", model.DCAEvent{Event: model.DCAEventOpen, InAmount: 50000000000,
+			InAmountPerCycle: 5000000000, CycleFrequency: 3600, CyclesTotal: 10, InRemaining: 50000000000, InDecimals: 9}, 50, 0},
+		{"jupiter_dca_fill.json", "SWAP", model.DCAEvent{Event: model.DCAEventFill, InAmount: 5000000000, OutAmount: 1233333323,
+			InAmountPerCycle: 5000000000, CycleFrequency: 3600, CyclesTotal: 10, CyclesFilled: 3, InRemaining: 35000000000,
+			InDecimals: 9, OutDecimals: 6}, 5, 1233.333323},
+		{"jupiter_dca_withdraw.json", "WITHDRAWDCA", model.DCAEvent{Event: model.DCAEventWithdraw, OutAmount: 1000000000,
+			InAmountPerCycle: 5000000000, CycleFrequency: 3600, CyclesTotal: 10, CyclesFilled: 3, InRemaining: 35000000000,
+			InDecimals: 9, OutDecimals: 6}, 0, 1000},
+	}
+
+	for _, c := range cases {
+		res, err := parseJupiterDCA(loadHeliusFixture(t, c.fixture))
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", c.fixture, err)
+			continue
+		}
+		if len(res) != 1 || res[0].DCA == nil {
+			t.Errorf("%s: got %d records, want 1 dca record", c.fixture, len(res))
+			continue
+		}
+
+		v := res[0]
+		c.event.DCAAccount, c.event.User = dcaTestAccount, dcaTestUser
+		c.event.InputMint, c.event.OutputMint = WSOLMint, dcaTestMint
+		if v.Type != c.typ || !v.IsDCATrade || *v.DCA != c.event {
+			t.Errorf("%s: got %s %+v, want %s %+v", c.fixture, v.Type, *v.DCA, c.typ, c.event)
+		}
+		if v.FromUserAccount != dcaTestUser || v.ToUserAccount != dcaTestUser {
+			t.Errorf("%s: got user %s/%s", c.fixture, v.FromUserAccount, v.ToUserAccount)
+		}
+		if v.FromTokenAmount != c.inAmount || v.ToTokenAmount != c.outAmount {
+			t.Errorf("%s: got amounts %v -> %v, want %v -> %v", c.fixture, v.FromTokenAmount, v.ToTokenAmount, c.inAmount, c.outAmount)
+		}
+	}
+}
+
+// TestParseJupiterDCAUnknownDecimals leaves an amount empty when its mint decimals are unknown
+func TestParseJupiterDCAUnknownDecimals(t *testing.T) {
+	logger.Init(filepath.Join(t.TempDir(), "dca.log"))
+	stubDCAAccount(t, map[string][]byte{
+		dcaTestAccount: dcaAccountData(t, dcaTestUser, WSOLMint, dcaTestMint, 50000000000, 15000000000, 5000000000),
+	})
+
+	old := mintDecimalsLookup
+	mintDecimalsLookup = func(address string) (int, error) {
+		return 0, fmt.Errorf("mint %s not found", address)
+	}
+	t.Cleanup(func() { mintDecimalsLookup = old })
+
+	// the tx says nothing about the output mint
+	in := loadHeliusFixture(t, "jupiter_dca_withdraw.json")
+	in.TokenTransfers = nil
+	for i := range in.AccountData {
+		in.AccountData[i].TokenBalanceChanges = nil
+	}
+
+	res, err := parseJupiterDCA(in)
+	if err != nil || len(res) != 1 || res[0].DCA == nil {
+		t.Fatalf("got %d records, %v", len(res), err)
+	}
+
+	v := res[0]
+	if v.DCA.OutAmount != 1000000000 || v.DCA.OutDecimals != 0 || v.ToTokenRawAmount != "" || v.ToTokenAmount != 0 {
+		t.Errorf("got out %d/%d, amount %q (%v)", v.DCA.OutAmount, v.DCA.OutDecimals, v.ToTokenRawAmount, v.ToTokenAmount)
+	}
+}
+
+func TestParseJupiterDCAEndAndClose(t *testing.T) {
+	// the account is closed by the time the tx is parsed
+	stubDCAAccount(t, map[string][]byte{})
+
+	res, err := parseJupiterDCA(loadHeliusFixture(t, "jupiter_dca_end_and_close.json"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(res) != 1 || res[0].DCA == nil {
+		t.Fatalf("got %d records, want 1 dca record", len(res))
+	}
+
+	v := res[0]
+	if v.Type != "CLOSEDCA" || v.DCA.Event != model.DCAEventEndAndClose || !v.DCA.Completed {
+		t.Errorf("got %s %+v", v.Type, *v.DCA)
+	}
+	if v.DCA.User != dcaTestUser || v.DCA.OutAmount != 12345678900 || v.ToTokenAmount != 12345.6789 {
+		t.Errorf("got user %s out %d (%v)", v.DCA.User, v.DCA.OutAmount, v.ToTokenAmount)
+	}
+}
+
+func TestParseJupiterDCAFillWithoutAccount(t *testing.T) {
+	// without the dca account the keeper swap falls through to the generic parsers
+	stubDCAAccount(t, map[string][]byte{})
+
+	if _, err := parseJupiterDCA(loadHeliusFixture(t, "jupiter_dca_fill.json")); err == nil {
+		t.Errorf("want error when the dca user is unknown")
+	}
+}
@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+const (
+	JupiterLimitOrderProgramID = "jupoNjAxXgZ4rjzxzPMP4oxduvQsQtZzyknqvzYNrNu"
+
+	limitOrderSource = "Jupiter Limit Order"
+
+	// spl mint layout, decimals follow the optional authority and the supply
+	mintDecimalsOffset = 44
+)
+
+var (
+	limitInitializeOrderDisc = anchorDiscriminator("initialize_order")
+	limitCancelOrderDisc     = anchorDiscriminator("cancel_order")
+	limitFillOrderDisc       = anchorDiscriminator("fill_order")
+	limitFlashFillOrderDisc  = anchorDiscriminator("flash_fill_order")
+)
+
+func init() {
+	RegisterParser(&funcParser{
+		name:       "jupiter_limit_order",
+		priority:   11,
+		programIDs: func() []string { return []string{JupiterLimitOrderProgramID} },
+		parse:      parseJupiterLimitOrder,
+	})
+}
+
+var mintDecimalsCache sync.Map
+
+// mintDecimalsLookup is swapped out in tests, the default reads the mint account over rpc
+var mintDecimalsLookup = lookupMintDecimals
+
+func lookupMintDecimals(mint string) (int, error) {
+	if v, ok := mintDecimalsCache.Load(mint); ok {
+		return v.(int), nil
+	}
+
+	data, err := fetchAccountData(mint)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) <= mintDecimalsOffset {
+		return 0, fmt.Errorf("mint %s too short, %d", mint, len(data))
+	}
+
+	decimals := int(data[mintDecimalsOffset])
+	mintDecimalsCache.Store(mint, decimals)
+	return decimals, nil
+}
+
+// txMintDecimals prefers what the tx already tells and reads the mint otherwise,
+// the output side of a new order or a single sided lp deposit moves nothing
+func txMintDecimals(in HeliusData, mint string, raw uint64) (int, error) {
+	if decimals := mintDecimals(in, mint, raw); decimals >= 0 {
+		return decimals, nil
+	}
+	return mintDecimalsLookup(mint)
+}
+
+// firstTransfer finds the first transfer out of source or into dest, empty matches any
+func firstTransfer(transfers []tokenTransfer, source, dest string) *tokenTransfer {
+	for i, v := range transfers {
+		if (source == "" || v.Source == source) && (dest == "" || v.Dest == dest) {
+			return &transfers[i]
+		}
+	}
+	return nil
+}
+
+func newLimitOrderRecord(in HeliusData, typ string, event *model.LimitOrderEvent) (*model.SolSwapData, error) {
+	var err error
+	event.InDecimals, err = txMintDecimals(in, event.InputMint, event.MakingAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	fromAmount := uintAmount(event.MakingAmount, event.InDecimals)
+	var toAmount *tokenAmount
+	if event.OutputMint != "" {
+		event.OutDecimals, err = txMintDecimals(in, event.OutputMint, event.TakingAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		out := uintAmount(event.TakingAmount, event.OutDecimals)
+		toAmount = &out
+		if fromAmount.sign() > 0 {
+			event.Price = out.float() / fromAmount.float()
+		}
+	}
+
+	t := time.Unix(int64(in.Timestamp), 0)
+	timeString := t.Format("2006-01-02 15:04:05")
+
+	res := &model.SolSwapData{
+		TxHash:    in.Signature,
+		Source:    limitOrderSource,
+		Timestamp: in.Timestamp,
+		Type:      typ,
+		Date:      timeString,
+
+		FromToken:        event.InputMint,
+		FromTokenAccount: "",
+		FromUserAccount:  event.Maker,
+
+		ToToken:         event.OutputMint,
+		ToTokenAccount:  "",
+		ToUserAccount:   event.Maker,
+		TradeLabel:      "",
+		IsDCATrade:      false,
+		WalletCounts:    1,
+		TransferDetails: make([]model.SolSwapData, 0),
+		Direction:       swapDirection(event.InputMint, event.OutputMint),
+		LimitOrder:      event,
+	}
+	setFromAmount(res, fromAmount)
+	if toAmount != nil {
+		setToAmount(res, *toAmount)
+	}
+
+	return res, nil
+}
+
+// initialize_order(making_amount u64, taking_amount u64, expired_at Option<i64>)
+func parseLimitPlaceIx(in HeliusData, ix flatInstruction, data []byte) (*model.SolSwapData, error) {
+	if len(ix.Accounts) < 10 {
+		return nil, fmt.Errorf("initialize order account length not match, %d", len(ix.Accounts))
+	}
+	if len(data) < 25 {
+		return nil, fmt.Errorf("initialize order data length not match, %d", len(data))
+	}
+
+	event := &model.LimitOrderEvent{
+		Event:        model.LimitOrderEventPlace,
+		OrderAccount: ix.Accounts[2],
+		Maker:        ix.Accounts[1],
+		InputMint:    ix.Accounts[8],
+		OutputMint:   ix.Accounts[9],
+		MakingAmount: binary.LittleEndian.Uint64(data[8:16]),
+		TakingAmount: binary.LittleEndian.Uint64(data[16:24]),
+	}
+	if data[24] == 1 && len(data) >= 33 {
+		event.ExpiredAt = int64(binary.LittleEndian.Uint64(data[25:33]))
+	}
+
+	res, err := newLimitOrderRecord(in, "PLACELIMITORDER", event)
+	if err != nil {
+		return nil, err
+	}
+	res.FromTokenAccount = ix.Accounts[4]
+	res.ToTokenAccount = ix.Accounts[6]
+	return res, nil
+}
+
+// cancel_order refunds what is left in the reserve, the output mint is not part of the instruction
+func parseLimitCancelIx(in HeliusData, ix flatInstruction, transfers []tokenTransfer) (*model.SolSwapData, error) {
+	if len(ix.Accounts) < 5 {
+		return nil, fmt.Errorf("cancel order account length not match, %d", len(ix.Accounts))
+	}
+
+	event := &model.LimitOrderEvent{
+		Event:        model.LimitOrderEventCancel,
+		OrderAccount: ix.Accounts[0],
+		Maker:        ix.Accounts[1],
+		InputMint:    ix.Accounts[4],
+	}
+	event.MakingAmount, _ = netTransfers(transfers, ix.Accounts[2])
+
+	res, err := newLimitOrderRecord(in, "CANCELLIMITORDER", event)
+	if err != nil {
+		return nil, err
+	}
+
+	// the refund goes back in the input mint, the record reads reserve -> maker
+	res.FromTokenAccount = ix.Accounts[2]
+	res.ToToken = event.InputMint
+	res.ToTokenAccount = ix.Accounts[3]
+	res.ToTokenAmount = res.FromTokenAmount
+	res.ToTokenRawAmount, res.ToTokenDecimals = res.FromTokenRawAmount, res.FromTokenDecimals
+	res.Direction = ""
+	return res, nil
+}
+
+// parseLimitFillIx reads fill_order and flash_fill_order, the input leaves the reserve
+// and the output reaches the maker, amounts are what actually moved
+func parseLimitFillIx(in HeliusData, ix flatInstruction, transfers []tokenTransfer, flash bool) (*model.SolSwapData, error) {
+	event := &model.LimitOrderEvent{Event: model.LimitOrderEventFill}
+
+	var reserve, makerOutput string
+	if flash {
+		if len(ix.Accounts) < 12 {
+			return nil, fmt.Errorf("flash fill order account length not match, %d", len(ix.Accounts))
+		}
+		reserve, makerOutput = ix.Accounts[1], ix.Accounts[4]
+		event.InputMint, event.OutputMint = ix.Accounts[9], ix.Accounts[11]
+	} else {
+		if len(ix.Accounts) < 6 {
+			return nil, fmt.Errorf("fill order account length not match, %d", len(ix.Accounts))
+		}
+		reserve, makerOutput = ix.Accounts[1], ix.Accounts[5]
+	}
+	event.OrderAccount, event.Maker, event.Taker = ix.Accounts[0], ix.Accounts[2], ix.Accounts[3]
+
+	event.MakingAmount, _ = netTransfers(transfers, reserve)
+	_, event.TakingAmount = netTransfers(transfers, makerOutput)
+	if event.MakingAmount == 0 || event.TakingAmount == 0 {
+		return nil, fmt.Errorf("fill transfers not found, %s", event.OrderAccount)
+	}
+
+	var err error
+	if event.InputMint == "" {
+		event.InputMint, _, err = resolveTransferMint(in, firstTransfer(transfers, reserve, ""), reserve, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if event.OutputMint == "" {
+		event.OutputMint, _, err = resolveTransferMint(in, firstTransfer(transfers, "", makerOutput), makerOutput, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := newLimitOrderRecord(in, "FILLLIMITORDER", event)
+	if err != nil {
+		return nil, err
+	}
+	res.ToTokenAccount = makerOutput
+	return res, nil
+}
+
+// parseJupiterLimitOrder emits one record per order instruction of the v1 limit order program
+func parseJupiterLimitOrder(in HeliusData) ([]model.SolSwapData, error) {
+	transfers := allTokenTransfers(in)
+
+	res := make([]model.SolSwapData, 0)
+	for _, ix := range flattenInstructions(in) {
+		if ix.ProgramID != JupiterLimitOrderProgramID {
+			continue
+		}
+
+		data, err := base58.Decode(ix.Data)
+		if err != nil {
+			continue
+		}
+
+		var item *model.SolSwapData
+		switch {
+		case hasDiscriminator(data, limitInitializeOrderDisc):
+			item, err = parseLimitPlaceIx(in, ix, data)
+		case hasDiscriminator(data, limitCancelOrderDisc):
+			item, err = parseLimitCancelIx(in, ix, transfers)
+		case hasDiscriminator(data, limitFillOrderDisc):
+			item, err = parseLimitFillIx(in, ix, transfers, false)
+		case hasDiscriminator(data, limitFlashFillOrderDisc):
+			item, err = parseLimitFillIx(in, ix, transfers, true)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *item)
+	}
+
+	if len(res) < 1 {
+		return nil, fmt.Errorf("no limit order instruction, %s", in.Signature)
+	}
+	return res, nil
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func TestParseJupiterLimitOrder(t *testing.T) {
+	maker := "7Ywvr3QnDuCXoV23a543WoHd1iwy3kkrx7PwtUeVL7Y5"
+	taker := "DUQCzwoc1Xqxa9C6F2nisKQ7ko5k642GcTgc1WTheUGj"
+	mint := "CuZ2sWgDAnRj1nmBE7pGRFSRks5pTkjGNaMYk46FLxQH"
+	order := "LqjGQcaPM8v5aVTf8qMex2SCPhoPQGfNPChBreg8rY5"
+
+	old := mintDecimalsLookup
+	mintDecimalsLookup = func(address string) (int, error) {
+		if address == mint {
+			return 6, nil
+		}
+		return 0, fmt.Errorf("mint %s not found", address)
+	}
+	t.Cleanup(func() { mintDecimalsLookup = old })
+
+	cases := []struct {
+		fixture   string
+		typ       string
+		event     model.LimitOrderEvent
+		inAmount  float64
+		outAmount float64
+	}{
+		{"jupiter_limit_place.json", "PLACELIMITORDER", model.LimitOrderEvent{Event: model.LimitOrderEventPlace, OutputMint: mint,
+			MakingAmount: 2000000000, TakingAmount: 4000000000, OutDecimals: 6, Price: 2000, ExpiredAt: 1717186400}, 2, 4000},
+		{"jupiter_limit_fill.json", "FILLLIMITORDER", model.LimitOrderEvent{Event: model.LimitOrderEventFill, Taker: taker, OutputMint: mint,
+			MakingAmount: 1000000000, TakingAmount: 2000000000, OutDecimals: 6, Price: 2000}, 1, 2000},
+		{"jupiter_limit_flash_fill.json", "FILLLIMITORDER", model.LimitOrderEvent{Event: model.LimitOrderEventFill, Taker: taker, OutputMint: mint,
+			MakingAmount: 1000000000, TakingAmount: 2050000000, OutDecimals: 6, Price: 2050}, 1, 2050},
+		{"jupiter_limit_cancel.json", "CANCELLIMITORDER", model.LimitOrderEvent{Event: model.LimitOrderEventCancel,
+			MakingAmount: 500000000}, 0.5, 0.5},
+	}
+
+	for _, c := range cases {
+		res, err := parseJupiterLimitOrder(loadHeliusFixture(t, c.fixture))
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", c.fixture, err)
+			continue
+		}
+		if len(res) != 1 || res[0].LimitOrder == nil {
+			t.Errorf("%s: got %d records, want 1 limit order record", c.fixture, len(res))
+			continue
+		}
+
+		v := res[0]
+		c.event.OrderAccount, c.event.Maker = order, maker
+		c.event.InputMint, c.event.InDecimals = WSOLMint, 9
+		if v.Type != c.typ || *v.LimitOrder != c.event {
+			t.Errorf("%s: got %s %+v, want %s %+v", c.fixture, v.Type, *v.LimitOrder, c.typ, c.event)
+		}
+		if v.FromUserAccount != maker || v.ToUserAccount != maker {
+			t.Errorf("%s: got user %s/%s", c.fixture, v.FromUserAccount, v.ToUserAccount)
+		}
+		if v.FromTokenAmount != c.inAmount || v.ToTokenAmount != c.outAmount {
+			t.Errorf("%s: got amounts %v -> %v, want %v -> %v", c.fixture, v.FromTokenAmount, v.ToTokenAmount, c.inAmount, c.outAmount)
+		}
+	}
+}
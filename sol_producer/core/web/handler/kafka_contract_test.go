@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+// TestSwapContract runs every parser fixture through the registry and the kafka contract, a field
+// the parsers fill that the schema does not know fails here before it reaches the consumer
+func TestSwapContract(t *testing.T) {
+	// parsing is deterministic, pool accounts are only read by FillPoolParams
+	old := poolParamsLookup
+	poolParamsLookup = func(pool string, decode poolParamsDecoder) (*PoolParams, error) {
+		t.Errorf("parser looked up pool %s", pool)
+		return &PoolParams{}, nil
+	}
+	t.Cleanup(func() { poolParamsLookup = old })
+
+	files, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		res, err := ParseHeliusData(loadHeliusFixture(t, f.Name()))
+		if err != nil || len(res) == 0 {
+			continue
+		}
+
+		_, headers, err := solmsg.Marshal(solmsg.SolSwapBatch, res)
+		if err != nil {
+			t.Errorf("%s: %v", filepath.Base(f.Name()), err)
+			continue
+		}
+		if len(headers) != 2 {
+			t.Errorf("%s: headers %v", f.Name(), headers)
+		}
+		checked++
+	}
+
+	if checked < len(files)/2 {
+		t.Errorf("only %d of %d fixtures parsed", checked, len(files))
+	}
+}
+
+func TestWalletKey(t *testing.T) {
+	cases := []struct {
+		in   []model.SolSwapData
+		want string
+	}{
+		{[]model.SolSwapData{{TxHash: "tx", FromUserAccount: "wallet", ToUserAccount: "wallet"}}, "wallet"},
+		// a received transfer only names the receiver
+		{[]model.SolSwapData{{TxHash: "tx", ToUserAccount: "receiver"}}, "receiver"},
+		{[]model.SolSwapData{{TxHash: "tx"}}, "tx"},
+	}
+
+	for _, c := range cases {
+		if got := walletKey(c.in); got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+}
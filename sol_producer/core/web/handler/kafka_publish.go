@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/alikafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/outbox"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+const relayStopTimeout = 15 * time.Second
+
+var relay *outbox.Relay
+
+// walletKey is the kafka key of a batch, the wallet that made the trade, so the trades of one
+// wallet share a partition and keep their order. a batch is one transaction of one signer
+func walletKey(in []model.SolSwapData) string {
+	for _, v := range in {
+		if v.FromUserAccount != "" {
+			return v.FromUserAccount
+		}
+	}
+	for _, v := range in {
+		if v.ToUserAccount != "" {
+			return v.ToUserAccount
+		}
+	}
+	return in[0].TxHash
+}
+
+func contractMessage(topic, key string, data []byte, headers []solmsg.Header) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte(key),
+		Value:          data,
+		Headers:        alikafka.Headers(headers),
+	}
+}
+
+// publish keeps the message in the outbox for the relay, without an outbox it is sent on p and
+// publish returns once kafka acked it
+func publish(p *kafka.Producer, topic, key string, data []byte, headers []solmsg.Header) error {
+	store := outbox.GetStore()
+	if store == nil {
+		return alikafka.Publish(p, contractMessage(topic, key, data, headers))
+	}
+
+	err := store.Add(&outbox.Record{Topic: topic, Key: key, Value: data, Headers: headers})
+	if err != nil {
+		return err
+	}
+
+	if relay != nil {
+		relay.Notify()
+	}
+	return nil
+}
+
+func sendOutbox(records []outbox.Record) []error {
+	msgs := make([]*kafka.Message, 0, len(records))
+	for _, v := range records {
+		msgs = append(msgs, contractMessage(v.Topic, v.Key, v.Value, v.Headers))
+	}
+	return alikafka.PublishAll(alikafka.GetKafkaInst(), msgs)
+}
+
+// StartRelay drains the outbox into kafka, records left by the last run go first. nothing is
+// started without an outbox
+func StartRelay() {
+	store := outbox.GetStore()
+	if store == nil {
+		return
+	}
+
+	relay = outbox.NewRelay(config.GetOutboxConfig(), store, sendOutbox)
+	relay.Start()
+}
+
+// StopRelay sends what the drained enricher left in the outbox, call it after StopEnricher
+func StopRelay() {
+	if relay == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayStopTimeout)
+	defer cancel()
+
+	err := relay.Stop(ctx)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"ErrMsg": err}).Error("StopRelay timed out, the outbox keeps the rest")
+	}
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func init() {
+	RegisterParser(&funcParser{
+		name:       "liquidity",
+		priority:   16,
+		programIDs: liquidityProgramIDs,
+		match:      notPumpCreate,
+		parse:      parseLiquidity,
+	})
+}
+
+// decodedLiquidity is one lp deposit or withdraw instruction with the accounts the decoder resolved
+type decodedLiquidity struct {
+	Source string
+	Event  string
+	Pool   string
+	Owner  string
+	UserA  string
+	UserB  string
+	// MintA and MintB are empty when the instruction does not carry them
+	MintA    string
+	MintB    string
+	LpMint   string
+	UserLp   string
+	Position string
+}
+
+var liquidityDecoders = make(map[string]func(ix flatInstruction) (*decodedLiquidity, error))
+
+func registerLiquidityDecoder(programID string, decode func(ix flatInstruction) (*decodedLiquidity, error)) {
+	liquidityDecoders[programID] = decode
+}
+
+func liquidityProgramIDs() []string {
+	res := make([]string, 0, len(liquidityDecoders))
+	for k := range liquidityDecoders {
+		res = append(res, k)
+	}
+	return res
+}
+
+// liquiditySide resolves mint, decimals and the amount that moved between the user account and the pool
+func liquiditySide(in HeliusData, ix flatInstruction, event, account, knownMint string) (string, int, uint64, error) {
+	out, received := uint64(0), uint64(0)
+	decimals := -1
+	for _, v := range ix.Following {
+		transfer, ok := decodeTokenTransfer(v)
+		if !ok {
+			continue
+		}
+		if transfer.Source != account && transfer.Dest != account {
+			continue
+		}
+
+		if transfer.Source == account {
+			out += transfer.Amount
+		} else {
+			received += transfer.Amount
+		}
+		if knownMint == "" && transfer.Mint != "" {
+			knownMint = transfer.Mint
+		}
+		if transfer.Decimals >= 0 && (transfer.Mint == "" || transfer.Mint == knownMint) {
+			decimals = transfer.Decimals
+		}
+	}
+
+	amount := out
+	if event == model.LiquidityEventRemove {
+		amount = received
+	}
+
+	mint := knownMint
+	if mint == "" {
+		mint, _ = tokenAccountMint(in, account)
+	}
+	if mint == "" {
+		if amount > 0 {
+			return "", 0, 0, fmt.Errorf("mint of %s not found", account)
+		}
+		return "", 0, 0, nil
+	}
+
+	if decimals >= 0 {
+		return mint, decimals, amount, nil
+	}
+
+	decimals, err := txMintDecimals(in, mint, amount)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return mint, decimals, amount, nil
+}
+
+// buildLiquidityData reads both pool sides and the lp mint or burn from the inner instructions
+func buildLiquidityData(in HeliusData, ix flatInstruction, lp decodedLiquidity) (*model.SolSwapData, error) {
+	event := &model.LiquidityEvent{
+		Event:    lp.Event,
+		Pool:     lp.Pool,
+		Owner:    lp.Owner,
+		LpMint:   lp.LpMint,
+		Position: lp.Position,
+	}
+	if event.Owner == "" {
+		event.Owner = in.FeePayer
+	}
+
+	var err error
+	event.MintA, event.DecimalsA, event.AmountA, err = liquiditySide(in, ix, lp.Event, lp.UserA, lp.MintA)
+	if err != nil {
+		return nil, err
+	}
+	event.MintB, event.DecimalsB, event.AmountB, err = liquiditySide(in, ix, lp.Event, lp.UserB, lp.MintB)
+	if err != nil {
+		return nil, err
+	}
+	if event.AmountA == 0 && event.AmountB == 0 {
+		return nil, fmt.Errorf("liquidity transfers not found, %s", lp.Pool)
+	}
+
+	if lp.UserLp != "" {
+		for _, v := range ix.Following {
+			supply, ok := decodeMintBurn(v)
+			if ok && supply.TokenAccount == lp.UserLp {
+				event.LpAmount += supply.Amount
+			}
+		}
+	}
+
+	t := time.Unix(int64(in.Timestamp), 0)
+	timeString := t.Format("2006-01-02 15:04:05")
+
+	typ := "ADDLIQUIDITY"
+	if lp.Event == model.LiquidityEventRemove {
+		typ = "REMOVELIQUIDITY"
+	}
+
+	// both sides move the same way, the record keeps token a as from and token b as to
+	res := &model.SolSwapData{
+		TxHash:    in.Signature,
+		Source:    lp.Source,
+		Timestamp: in.Timestamp,
+		Type:      typ,
+		Date:      timeString,
+
+		FromToken:        event.MintA,
+		FromTokenAccount: lp.UserA,
+		FromUserAccount:  event.Owner,
+
+		ToToken:         event.MintB,
+		ToTokenAccount:  lp.UserB,
+		ToUserAccount:   event.Owner,
+		TradeLabel:      "",
+		IsDCATrade:      false,
+		WalletCounts:    1,
+		TransferDetails: make([]model.SolSwapData, 0),
+		Pool:            lp.Pool,
+		Liquidity:       event,
+	}
+	setFromAmount(res, uintAmount(event.AmountA, event.DecimalsA))
+	setToAmount(res, uintAmount(event.AmountB, event.DecimalsB))
+
+	return res, nil
+}
+
+// parseLiquidity emits one record per lp deposit or withdraw of the supported amms
+func parseLiquidity(in HeliusData) ([]model.SolSwapData, error) {
+	res := make([]model.SolSwapData, 0)
+	for _, ix := range flattenInstructions(in) {
+		decode, ok := liquidityDecoders[ix.ProgramID]
+		if !ok {
+			continue
+		}
+
+		lp, err := decode(ix)
+		if err != nil {
+			continue
+		}
+
+		item, err := buildLiquidityData(in, ix, *lp)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *item)
+	}
+
+	if len(res) < 1 {
+		return nil, fmt.Errorf("no liquidity instruction, %s", in.Signature)
+	}
+	return res, nil
+}
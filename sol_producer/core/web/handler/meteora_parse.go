@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+const (
+	MeteoraDLMMProgramID       = "LBUZKhRxPF3XUpBCjp4YzTKgLccjZhTSDM9YuVaPwxo"
+	MeteoraDynamicAMMProgramID = "Eo7WjKq67rjJQSZxS6z3YkapzY3eMj6Xy8X5EQVn5UaB"
+
+	meteoraDLMMSource       = "METEORA_DLMM"
+	meteoraDynamicAMMSource = "METEORA"
+
+	// base_factor opens the static parameters, bin_step follows both parameter blocks, bump, seed, pair type and active id
+	dlmmBaseFactorOffset = 8
+	dlmmBinStepOffset    = 8 + 32 + 32 + 1 + 2 + 1 + 4
+	dlmmFeePrecision     = 1e9
+
+	// trade fee follows seven pubkeys, bump, enabled, two protocol fee accounts, fee_last_updated_at and padding
+	dynamicAMMTradeFeeOffset = 8 + 7*32 + 1 + 1 + 2*32 + 8 + 24
+)
+
+var (
+	dlmmSwapInstruction = map[[8]byte]bool{
+		anchorDiscriminator("swap"):                    true,
+		anchorDiscriminator("swap_exact_out"):          true,
+		anchorDiscriminator("swap_with_price_impact"):  true,
+		anchorDiscriminator("swap2"):                   true,
+		anchorDiscriminator("swap_exact_out2"):         true,
+		anchorDiscriminator("swap_with_price_impact2"): true,
+	}
+	dynamicAMMSwap = anchorDiscriminator("swap")
+
+	// every add and remove variant of both programs shares the leading accounts
+	dlmmLiquidityInstruction = map[[8]byte]string{
+		anchorDiscriminator("add_liquidity"):             model.LiquidityEventAdd,
+		anchorDiscriminator("add_liquidity_by_weight"):   model.LiquidityEventAdd,
+		anchorDiscriminator("add_liquidity_by_strategy"): model.LiquidityEventAdd,
+		anchorDiscriminator("remove_liquidity"):          model.LiquidityEventRemove,
+		anchorDiscriminator("remove_liquidity_by_range"): model.LiquidityEventRemove,
+		anchorDiscriminator("remove_all_liquidity"):      model.LiquidityEventRemove,
+	}
+	dynamicAMMLiquidityInstruction = map[[8]byte]string{
+		anchorDiscriminator("add_balance_liquidity"):    model.LiquidityEventAdd,
+		anchorDiscriminator("add_imbalance_liquidity"):  model.LiquidityEventAdd,
+		anchorDiscriminator("remove_balance_liquidity"): model.LiquidityEventRemove,
+	}
+)
+
+func init() {
+	registerSwapDecoder(MeteoraDLMMProgramID, decodeMeteoraDLMM, decodeDLMMParams)
+	registerSwapDecoder(MeteoraDynamicAMMProgramID, decodeMeteoraDynamicAMM, decodeDynamicAMMParams)
+
+	registerLiquidityDecoder(MeteoraDLMMProgramID, decodeDLMMLiquidity)
+	registerLiquidityDecoder(MeteoraDynamicAMMProgramID, decodeDynamicAMMLiquidity)
+
+	RegisterParser(&funcParser{
+		name:     "meteora",
+		priority: 15,
+		programIDs: func() []string {
+			return []string{MeteoraDLMMProgramID, MeteoraDynamicAMMProgramID}
+		},
+		match: notPumpCreate,
+		parse: parseMeteora,
+	})
+}
+
+func decodeMeteoraDLMM(ix flatInstruction) (*decodedSwap, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) < 8 {
+		return nil, fmt.Errorf("dlmm data invalid")
+	}
+
+	if !dlmmSwapInstruction[[8]byte(data[:8])] {
+		return nil, fmt.Errorf("dlmm instruction is not swap")
+	}
+
+	if len(ix.Accounts) < 11 {
+		return nil, fmt.Errorf("dlmm account length not match, %d", len(ix.Accounts))
+	}
+
+	return &decodedSwap{
+		Source:     meteoraDLMMSource,
+		Pool:       ix.Accounts[0],
+		Owner:      ix.Accounts[10],
+		UserSource: ix.Accounts[4],
+		UserDest:   ix.Accounts[5],
+	}, nil
+}
+
+func decodeMeteoraDynamicAMM(ix flatInstruction) (*decodedSwap, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasDiscriminator(data, dynamicAMMSwap) {
+		return nil, fmt.Errorf("dynamic amm instruction is not swap")
+	}
+
+	if len(ix.Accounts) < 13 {
+		return nil, fmt.Errorf("dynamic amm account length not match, %d", len(ix.Accounts))
+	}
+
+	// input is split into the protocol fee and the vault deposit
+	return &decodedSwap{
+		Source:     meteoraDynamicAMMSource,
+		Pool:       ix.Accounts[0],
+		Owner:      ix.Accounts[12],
+		UserSource: ix.Accounts[1],
+		UserDest:   ix.Accounts[2],
+		InDests:    []string{ix.Accounts[5], ix.Accounts[6], ix.Accounts[11]},
+	}, nil
+}
+
+// decodeDLMMLiquidity reads position changes, bins have no lp token
+func decodeDLMMLiquidity(ix flatInstruction) (*decodedLiquidity, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) < 8 {
+		return nil, fmt.Errorf("dlmm data invalid")
+	}
+
+	event, ok := dlmmLiquidityInstruction[[8]byte(data[:8])]
+	if !ok {
+		return nil, fmt.Errorf("dlmm instruction is not liquidity")
+	}
+
+	if len(ix.Accounts) < 12 {
+		return nil, fmt.Errorf("dlmm liquidity account length not match, %d", len(ix.Accounts))
+	}
+
+	return &decodedLiquidity{
+		Source:   meteoraDLMMSource,
+		Event:    event,
+		Pool:     ix.Accounts[1],
+		Owner:    ix.Accounts[11],
+		Position: ix.Accounts[0],
+		UserA:    ix.Accounts[3],
+		UserB:    ix.Accounts[4],
+		MintA:    ix.Accounts[7],
+		MintB:    ix.Accounts[8],
+	}, nil
+}
+
+// decodeDynamicAMMLiquidity reads deposits and withdraws, the tokens pass through the vault program
+func decodeDynamicAMMLiquidity(ix flatInstruction) (*decodedLiquidity, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) < 8 {
+		return nil, fmt.Errorf("dynamic amm data invalid")
+	}
+
+	event, ok := dynamicAMMLiquidityInstruction[[8]byte(data[:8])]
+	if !ok {
+		return nil, fmt.Errorf("dynamic amm instruction is not liquidity")
+	}
+
+	if len(ix.Accounts) < 14 {
+		return nil, fmt.Errorf("dynamic amm liquidity account length not match, %d", len(ix.Accounts))
+	}
+
+	return &decodedLiquidity{
+		Source: meteoraDynamicAMMSource,
+		Event:  event,
+		Pool:   ix.Accounts[0],
+		LpMint: ix.Accounts[1],
+		UserLp: ix.Accounts[2],
+		UserA:  ix.Accounts[11],
+		UserB:  ix.Accounts[12],
+		Owner:  ix.Accounts[13],
+	}, nil
+}
+
+func decodeDLMMParams(data []byte) (*PoolParams, error) {
+	if len(data) < dlmmBinStepOffset+2 {
+		return nil, fmt.Errorf("dlmm account length not match, %d", len(data))
+	}
+
+	baseFactor := binary.LittleEndian.Uint16(data[dlmmBaseFactorOffset:])
+	binStep := binary.LittleEndian.Uint16(data[dlmmBinStepOffset:])
+
+	return &PoolParams{
+		FeeRate: float64(baseFactor) * float64(binStep) * 10 / dlmmFeePrecision,
+		BinStep: int(binStep),
+	}, nil
+}
+
+func decodeDynamicAMMParams(data []byte) (*PoolParams, error) {
+	if len(data) < dynamicAMMTradeFeeOffset+16 {
+		return nil, fmt.Errorf("dynamic amm account length not match, %d", len(data))
+	}
+
+	numerator := binary.LittleEndian.Uint64(data[dynamicAMMTradeFeeOffset:])
+	denominator := binary.LittleEndian.Uint64(data[dynamicAMMTradeFeeOffset+8:])
+	if denominator == 0 {
+		return nil, fmt.Errorf("dynamic amm fee denominator is zero")
+	}
+
+	return &PoolParams{FeeRate: float64(numerator) / float64(denominator)}, nil
+}
+
+func parseMeteora(in HeliusData) ([]model.SolSwapData, error) {
+	return parseDecodedSwaps(in, "meteora", MeteoraDLMMProgramID, MeteoraDynamicAMMProgramID)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseMeteora(t *testing.T) {
+	lbPair := make([]byte, 904)
+	binary.LittleEndian.PutUint16(lbPair[dlmmBaseFactorOffset:], 10000)
+	binary.LittleEndian.PutUint16(lbPair[dlmmBinStepOffset:], 25)
+
+	dynamicPool := make([]byte, 944)
+	binary.LittleEndian.PutUint64(dynamicPool[dynamicAMMTradeFeeOffset:], 25)
+	binary.LittleEndian.PutUint64(dynamicPool[dynamicAMMTradeFeeOffset+8:], 10000)
+
+	stubPoolAccounts(t, map[string][]byte{
+		"GzmpxsLF9RTekCkmqWeZoz6CVt2iKkc1NTF5tKkeawBh": lbPair,
+		"7RiwH3JFdgKGhSh349YuLjBDUpsTAWfaBguhr2S9hweE": dynamicPool,
+	})
+
+	dlmm := checkSwapFixtures(t, withPoolParams(parseMeteora), meteoraDLMMSource, []swapExpect{
+		{"meteora_dlmm_sell.json", "GzmpxsLF9RTekCkmqWeZoz6CVt2iKkc1NTF5tKkeawBh", "57dhURTRB1RAHB75hqVtoNjBtzYM3oBYfWfg9PijhCYb",
+			"9j3YCvRwThiXjW3mmrv3aD4GwzNWEqD5fzx9SbjUyX2f", WSOLMint, 88, 0.456789012, DirectionSell},
+	})
+	for _, v := range dlmm {
+		if v.BinStep != 25 || v.FeeRate != 0.0025 {
+			t.Errorf("dlmm: got bin step %d fee rate %v, want 25 0.0025", v.BinStep, v.FeeRate)
+		}
+	}
+
+	// the protocol fee transfer is part of the input amount
+	dynamic := checkSwapFixtures(t, withPoolParams(parseMeteora), meteoraDynamicAMMSource, []swapExpect{
+		{"meteora_dynamic_amm_buy.json", "7RiwH3JFdgKGhSh349YuLjBDUpsTAWfaBguhr2S9hweE", "JAxkwbbDfLKy3q5NgwdBrG8y28emgDkf1pAQZrSXusvA",
+			USDCMint, "5GtkmyuubwNhhwnrmiBN1mEE7oALd8gP4whwNTKRFqi2", 50, 25000, DirectionBuy},
+	})
+	for _, v := range dynamic {
+		if v.FeeRate != 0.0025 {
+			t.Errorf("dynamic amm: got fee rate %v, want 0.0025", v.FeeRate)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func init() {
+	// lp deposits and swaps through vaults mint too, the dex parsers run first and claim those
+	RegisterParser(&funcParser{
+		name:       "mint_burn",
+		priority:   90,
+		programIDs: func() []string { return []string{TokenProgramID, Token2022ProgramID} },
+		parse:      parseMintBurn,
+	})
+}
+
+// decodeMintBurn reads spl MintTo, MintToChecked, Burn and BurnChecked from either token program
+func decodeMintBurn(ix InnerInstructionsData) (*model.MintBurnEvent, bool) {
+	if ix.ProgramID != TokenProgramID && ix.ProgramID != Token2022ProgramID {
+		return nil, false
+	}
+
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) < 9 || len(ix.Accounts) < 3 {
+		return nil, false
+	}
+
+	res := &model.MintBurnEvent{
+		Authority: ix.Accounts[2],
+		Amount:    binary.LittleEndian.Uint64(data[1:9]),
+		Decimals:  -1,
+	}
+
+	switch data[0] {
+	case 7, 14:
+		res.Event, res.Mint, res.TokenAccount = model.MintBurnEventMint, ix.Accounts[0], ix.Accounts[1]
+	case 8, 15:
+		res.Event, res.Mint, res.TokenAccount = model.MintBurnEventBurn, ix.Accounts[1], ix.Accounts[0]
+	default:
+		return nil, false
+	}
+
+	if (data[0] == 14 || data[0] == 15) && len(data) >= 10 {
+		res.Decimals = int(data[9])
+	}
+	return res, true
+}
+
+// tokenAccountOwner resolves the wallet behind a token account from the helius balance changes
+func tokenAccountOwner(in HeliusData, account string) string {
+	for _, v := range in.AccountData {
+		for _, change := range v.TokenBalanceChanges {
+			if change.TokenAccount == account && change.UserAccount != "" {
+				return change.UserAccount
+			}
+		}
+	}
+
+	for _, v := range in.TokenTransfers {
+		if v.FromTokenAccount == account && v.FromUserAccount != "" {
+			return v.FromUserAccount
+		}
+		if v.ToTokenAccount == account && v.ToUserAccount != "" {
+			return v.ToUserAccount
+		}
+	}
+
+	return ""
+}
+
+// newMintBurnRecord reads mint as authority -> receiver and burn as owner -> nobody
+func newMintBurnRecord(in HeliusData, event *model.MintBurnEvent) (*model.SolSwapData, error) {
+	if event.Decimals < 0 {
+		var err error
+		event.Decimals, err = txMintDecimals(in, event.Mint, event.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t := time.Unix(int64(in.Timestamp), 0)
+	timeString := t.Format("2006-01-02 15:04:05")
+
+	amount := uintAmount(event.Amount, event.Decimals)
+	res := &model.SolSwapData{
+		TxHash:    in.Signature,
+		Source:    in.Source,
+		Timestamp: in.Timestamp,
+		Type:      "MINT",
+		Date:      timeString,
+
+		FromToken:        event.Mint,
+		FromTokenAccount: "",
+		FromUserAccount:  event.Authority,
+
+		ToToken:         event.Mint,
+		ToTokenAccount:  event.TokenAccount,
+		ToUserAccount:   tokenAccountOwner(in, event.TokenAccount),
+		TradeLabel:      "",
+		IsDCATrade:      false,
+		WalletCounts:    1,
+		TransferDetails: make([]model.SolSwapData, 0),
+		MintBurn:        event,
+	}
+	setFromAmount(res, amount)
+	setToAmount(res, amount)
+
+	if event.Event == model.MintBurnEventBurn {
+		res.Type = "BURN"
+		res.FromTokenAccount = event.TokenAccount
+		res.ToTokenAccount = ""
+		res.ToUserAccount = ""
+	}
+
+	return res, nil
+}
+
+// parseMintBurn emits one record per supply change, top level or inside a cpi
+func parseMintBurn(in HeliusData) ([]model.SolSwapData, error) {
+	res := make([]model.SolSwapData, 0)
+	for _, ix := range flattenInstructions(in) {
+		event, ok := decodeMintBurn(InnerInstructionsData{ProgramID: ix.ProgramID, Accounts: ix.Accounts, Data: ix.Data})
+		if !ok || event.Amount == 0 {
+			continue
+		}
+
+		item, err := newMintBurnRecord(in, event)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *item)
+	}
+
+	if len(res) < 1 {
+		return nil, fmt.Errorf("no mint or burn instruction, %s", in.Signature)
+	}
+	return res, nil
+}
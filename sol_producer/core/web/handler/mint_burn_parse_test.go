@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func TestParseMintBurn(t *testing.T) {
+	mint := "HqB7uswoVg4suaQiDP3wjxob1G5WdZ144zhdStwMCq7e"
+	authority := "Fq3mT8vBzXcN2kLpY7dRsG5jWhE9aUoK4iQ6tHbV1xZe"
+
+	res, err := parseMintBurn(loadHeliusFixture(t, "spl_mint_burn.json"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(res) != 2 || res[0].MintBurn == nil || res[1].MintBurn == nil {
+		t.Fatalf("got %d records, want a mint and a burn", len(res))
+	}
+
+	minted := res[0]
+	want := model.MintBurnEvent{Event: model.MintBurnEventMint, Mint: mint, TokenAccount: "2mJzVh4tT5cxgQbP8dLkEr9nWsY3aFuRj6oGvXHbD1iK",
+		Authority: authority, Amount: 1000000000000, Decimals: 6}
+	if minted.Type != "MINT" || *minted.MintBurn != want {
+		t.Errorf("mint: got %s %+v, want MINT %+v", minted.Type, *minted.MintBurn, want)
+	}
+	if minted.FromUserAccount != authority || minted.ToUserAccount != "8xVQjJ2sWkTq5dPZ4mYbHcRfGn7aLuE3oKiD9tXzBv6N" || minted.ToTokenAmount != 1000000 {
+		t.Errorf("mint: got %s -> %s %v", minted.FromUserAccount, minted.ToUserAccount, minted.ToTokenAmount)
+	}
+
+	// plain burn carries no decimals, they come from the balance changes of the same mint
+	burned := res[1]
+	want = model.MintBurnEvent{Event: model.MintBurnEventBurn, Mint: mint, TokenAccount: "6tYkP3wNvB8zHcQ2mLsD5jRfXgE7aUoK9iT4bVxZ1qWe",
+		Authority: authority, Amount: 250000000, Decimals: 6}
+	if burned.Type != "BURN" || *burned.MintBurn != want {
+		t.Errorf("burn: got %s %+v, want BURN %+v", burned.Type, *burned.MintBurn, want)
+	}
+	if burned.FromUserAccount != authority || burned.ToUserAccount != "" || burned.FromTokenAmount != 250 {
+		t.Errorf("burn: got %s -> %s %v", burned.FromUserAccount, burned.ToUserAccount, burned.FromTokenAmount)
+	}
+}
+
+func TestParseLiquidity(t *testing.T) {
+	owner := "C3x9ivNXsXqiixFyyCmyhHEAo1WfZSEfZCcUyZnKRJAA"
+
+	in := loadHeliusFixture(t, "raydium_cpmm_deposit.json")
+	if _, err := parseRaydium(in); err == nil {
+		t.Errorf("raydium swap parser should not claim a deposit")
+	}
+
+	res, err := parseLiquidity(in)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(res) != 1 || res[0].Liquidity == nil {
+		t.Fatalf("got %d records, want 1 liquidity record", len(res))
+	}
+
+	v := res[0]
+	want := model.LiquidityEvent{Event: model.LiquidityEventAdd, Pool: "8maQgC4UabF6p5PKXBxKFxXb14YkkztQhGaLx7nG3uTm", Owner: owner,
+		MintA: "94Wy4LLrYXACTacPG7WkFwoHKgqdRyQySPbjTwqeN3Cz", MintB: WSOLMint, DecimalsA: 6, DecimalsB: 9,
+		AmountA: 5000000000, AmountB: 2000000000, LpMint: "5sMZGWbY8vhw3ztjmGg2hm9UVbmPUtd9mfoTzSKkzYHE", LpAmount: 1000}
+	if v.Type != "ADDLIQUIDITY" || *v.Liquidity != want {
+		t.Errorf("got %s %+v, want ADDLIQUIDITY %+v", v.Type, *v.Liquidity, want)
+	}
+	if v.FromUserAccount != owner || v.FromTokenAmount != 5000 || v.ToTokenAmount != 2 {
+		t.Errorf("got %s %v / %v", v.FromUserAccount, v.FromTokenAmount, v.ToTokenAmount)
+	}
+}
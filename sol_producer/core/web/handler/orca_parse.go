@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+const (
+	OrcaWhirlpoolProgramID = "whirLbMiicVdio4qvUfM5KAg6Ct8VwpYzGff3uctyCc"
+
+	orcaSource = "ORCA"
+
+	// a_to_b follows amount, threshold, sqrt price limit and amount_specified_is_input
+	whirlpoolAToBOffset = 8 + 8 + 8 + 16 + 1
+	// fee_rate follows discriminator, config, bump, tick_spacing and its seed
+	whirlpoolFeeRateOffset = 8 + 32 + 1 + 2 + 2
+)
+
+var (
+	whirlpoolSwap   = anchorDiscriminator("swap")
+	whirlpoolSwapV2 = anchorDiscriminator("swap_v2")
+
+	whirlpoolIncreaseLiquidity   = anchorDiscriminator("increase_liquidity")
+	whirlpoolIncreaseLiquidityV2 = anchorDiscriminator("increase_liquidity_v2")
+	whirlpoolDecreaseLiquidity   = anchorDiscriminator("decrease_liquidity")
+	whirlpoolDecreaseLiquidityV2 = anchorDiscriminator("decrease_liquidity_v2")
+)
+
+func init() {
+	registerSwapDecoder(OrcaWhirlpoolProgramID, decodeWhirlpoolSwap, decodeWhirlpoolParams)
+	registerLiquidityDecoder(OrcaWhirlpoolProgramID, decodeWhirlpoolLiquidity)
+
+	RegisterParser(&funcParser{
+		name:       "orca_whirlpool",
+		priority:   15,
+		programIDs: func() []string { return []string{OrcaWhirlpoolProgramID} },
+		match:      notPumpCreate,
+		parse:      parseOrcaWhirlpool,
+	})
+}
+
+func decodeWhirlpoolSwap(ix flatInstruction) (*decodedSwap, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) <= whirlpoolAToBOffset {
+		return nil, fmt.Errorf("whirlpool data invalid")
+	}
+
+	aToB := data[whirlpoolAToBOffset] == 1
+
+	// owner, pool, then token account a and b
+	var idx [4]int
+	switch {
+	case hasDiscriminator(data, whirlpoolSwap):
+		if len(ix.Accounts) < 11 {
+			return nil, fmt.Errorf("whirlpool swap account length not match, %d", len(ix.Accounts))
+		}
+		idx = [4]int{1, 2, 3, 5}
+	case hasDiscriminator(data, whirlpoolSwapV2):
+		if len(ix.Accounts) < 15 {
+			return nil, fmt.Errorf("whirlpool swap_v2 account length not match, %d", len(ix.Accounts))
+		}
+		idx = [4]int{3, 4, 7, 9}
+	default:
+		return nil, fmt.Errorf("whirlpool instruction is not swap")
+	}
+
+	res := &decodedSwap{
+		Source:     orcaSource,
+		Owner:      ix.Accounts[idx[0]],
+		Pool:       ix.Accounts[idx[1]],
+		UserSource: ix.Accounts[idx[2]],
+		UserDest:   ix.Accounts[idx[3]],
+	}
+	if !aToB {
+		res.UserSource, res.UserDest = res.UserDest, res.UserSource
+	}
+
+	return res, nil
+}
+
+// decodeWhirlpoolLiquidity reads position changes, v2 adds the token programs, memo and both mints
+func decodeWhirlpoolLiquidity(ix flatInstruction) (*decodedLiquidity, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	event := ""
+	v2 := false
+	switch {
+	case hasDiscriminator(data, whirlpoolIncreaseLiquidity):
+		event = model.LiquidityEventAdd
+	case hasDiscriminator(data, whirlpoolIncreaseLiquidityV2):
+		event, v2 = model.LiquidityEventAdd, true
+	case hasDiscriminator(data, whirlpoolDecreaseLiquidity):
+		event = model.LiquidityEventRemove
+	case hasDiscriminator(data, whirlpoolDecreaseLiquidityV2):
+		event, v2 = model.LiquidityEventRemove, true
+	default:
+		return nil, fmt.Errorf("whirlpool instruction is not liquidity")
+	}
+
+	if v2 {
+		if len(ix.Accounts) < 13 {
+			return nil, fmt.Errorf("whirlpool liquidity_v2 account length not match, %d", len(ix.Accounts))
+		}
+		return &decodedLiquidity{
+			Source:   orcaSource,
+			Event:    event,
+			Pool:     ix.Accounts[0],
+			Owner:    ix.Accounts[4],
+			Position: ix.Accounts[5],
+			MintA:    ix.Accounts[7],
+			MintB:    ix.Accounts[8],
+			UserA:    ix.Accounts[9],
+			UserB:    ix.Accounts[10],
+		}, nil
+	}
+
+	if len(ix.Accounts) < 9 {
+		return nil, fmt.Errorf("whirlpool liquidity account length not match, %d", len(ix.Accounts))
+	}
+	return &decodedLiquidity{
+		Source:   orcaSource,
+		Event:    event,
+		Pool:     ix.Accounts[0],
+		Owner:    ix.Accounts[2],
+		Position: ix.Accounts[3],
+		UserA:    ix.Accounts[5],
+		UserB:    ix.Accounts[6],
+	}, nil
+}
+
+func decodeWhirlpoolParams(data []byte) (*PoolParams, error) {
+	if len(data) < whirlpoolFeeRateOffset+2 {
+		return nil, fmt.Errorf("whirlpool account length not match, %d", len(data))
+	}
+
+	// fee_rate is in hundredths of a basis point
+	feeRate := binary.LittleEndian.Uint16(data[whirlpoolFeeRateOffset:])
+	return &PoolParams{FeeRate: float64(feeRate) / 1e6}, nil
+}
+
+func parseOrcaWhirlpool(in HeliusData) ([]model.SolSwapData, error) {
+	return parseDecodedSwaps(in, "whirlpool", OrcaWhirlpoolProgramID)
+}
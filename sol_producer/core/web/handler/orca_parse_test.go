@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+// stubPoolAccounts serves pool params from in-memory account data instead of rpc
+func stubPoolAccounts(t *testing.T, accounts map[string][]byte) {
+	t.Helper()
+
+	old := poolParamsLookup
+	poolParamsLookup = func(pool string, decode poolParamsDecoder) (*PoolParams, error) {
+		return decode(accounts[pool])
+	}
+	t.Cleanup(func() { poolParamsLookup = old })
+}
+
+// withPoolParams runs the parser and then the enrich step that reads the pool accounts
+func withPoolParams(parse func(HeliusData) ([]model.SolSwapData, error)) func(HeliusData) ([]model.SolSwapData, error) {
+	return func(v HeliusData) ([]model.SolSwapData, error) {
+		res, err := parse(v)
+		if err != nil {
+			return nil, err
+		}
+		return FillPoolParams(v, res), nil
+	}
+}
+
+func TestParseOrcaWhirlpool(t *testing.T) {
+	whirlpool := make([]byte, 653)
+	binary.LittleEndian.PutUint16(whirlpool[whirlpoolFeeRateOffset:], 3000)
+
+	stubPoolAccounts(t, map[string][]byte{
+		"9YnFH4y1Wimo7r2ooR7g7DGV9QNMtKVTMt7ZFKMTwK7W": whirlpool,
+		"HwEMMaCusxyDuH4fDQimWELt82kWiksN1g3VwrGc5y7U": whirlpool,
+	})
+
+	cases := []swapExpect{
+		{"orca_whirlpool_buy.json", "9YnFH4y1Wimo7r2ooR7g7DGV9QNMtKVTMt7ZFKMTwK7W", "8oHqUUcdQoRAVhEpHgrXiz5z8BJCTji1mMvZxK1uWM9q",
+			WSOLMint, "HvMWVVNacPnKNSk8d69VYsi2Hax4zv5kkdqnu9CtEpMG", 2, 31415.926535, DirectionBuy},
+		{"orca_whirlpool_v2_sell.json", "HwEMMaCusxyDuH4fDQimWELt82kWiksN1g3VwrGc5y7U", "4U6gk3pFsS4qBkaDo4gpQpRV3oDpCneNwtj7EanhaeZz",
+			"3JFNYacQPvdBayC1Mcb6f4YVCY7cm3jvqzRWvvYDdN78", USDCMint, 7000, 123.45, DirectionSell},
+	}
+
+	res := checkSwapFixtures(t, withPoolParams(parseOrcaWhirlpool), orcaSource, cases)
+	for _, v := range res {
+		if v.FeeRate != 0.003 {
+			t.Errorf("%s: got fee rate %v, want 0.003", v.TxHash, v.FeeRate)
+		}
+	}
+}
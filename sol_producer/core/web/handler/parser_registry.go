@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+// TxParser turns one helius transaction into swap data.
+// a new protocol is one file that registers its parser in init.
+type TxParser interface {
+	Name() string
+	// Priority orders candidates, lower runs first
+	Priority() int
+	// ProgramIDs the parser is keyed by, empty means it is tried for every tx
+	ProgramIDs() []string
+	// Match filters candidates further by helius type/source
+	Match(v HeliusData) bool
+	Parse(v HeliusData) ([]model.SolSwapData, error)
+}
+
+// funcParser adapts plain parse functions to TxParser
+type funcParser struct {
+	name       string
+	priority   int
+	programIDs func() []string
+	match      func(v HeliusData) bool
+	parse      func(v HeliusData) ([]model.SolSwapData, error)
+}
+
+func (p *funcParser) Name() string {
+	return p.name
+}
+
+func (p *funcParser) Priority() int {
+	return p.priority
+}
+
+func (p *funcParser) ProgramIDs() []string {
+	if p.programIDs == nil {
+		return nil
+	}
+	return p.programIDs()
+}
+
+func (p *funcParser) Match(v HeliusData) bool {
+	if p.match == nil {
+		return true
+	}
+	return p.match(v)
+}
+
+func (p *funcParser) Parse(v HeliusData) ([]model.SolSwapData, error) {
+	return p.parse(v)
+}
+
+type parserRegistry struct {
+	mu      sync.RWMutex
+	parsers []TxParser
+}
+
+func newParserRegistry() *parserRegistry {
+	return &parserRegistry{parsers: make([]TxParser, 0)}
+}
+
+func (r *parserRegistry) register(p TxParser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.parsers = append(r.parsers, p)
+	sort.SliceStable(r.parsers, func(i, j int) bool {
+		return r.parsers[i].Priority() < r.parsers[j].Priority()
+	})
+}
+
+func txProgramIDs(v HeliusData) map[string]bool {
+	res := make(map[string]bool)
+	for _, instruction := range v.Instructions {
+		res[instruction.ProgramID] = true
+
+		for _, item := range instruction.InnerInstructions {
+			res[item.ProgramID] = true
+		}
+	}
+	return res
+}
+
+// candidates returns the parsers keyed by one of the tx program ids plus the wildcard ones, by priority
+func (r *parserRegistry) candidates(v HeliusData) []TxParser {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	programs := txProgramIDs(v)
+	res := make([]TxParser, 0)
+	for _, p := range r.parsers {
+		ids := p.ProgramIDs()
+		keyed := len(ids) == 0
+		for _, id := range ids {
+			if programs[id] {
+				keyed = true
+				break
+			}
+		}
+
+		if keyed && p.Match(v) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (r *parserRegistry) parse(v HeliusData) ([]model.SolSwapData, error) {
+	candidates := r.candidates(v)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no parser for %s", v.Signature)
+	}
+
+	errs := make([]string, 0, len(candidates))
+	for _, p := range candidates {
+		datait, err := p.Parse(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+
+		for i := range datait {
+			datait[i].Parser = p.Name()
+			for j := range datait[i].TransferDetails {
+				datait[i].TransferDetails[j].Parser = p.Name()
+			}
+		}
+		return datait, nil
+	}
+
+	return nil, fmt.Errorf("parse %s failed, %s", v.Signature, strings.Join(errs, "; "))
+}
+
+var parsers = newParserRegistry()
+
+func RegisterParser(p TxParser) {
+	parsers.register(p)
+}
+
+// ParseHeliusData runs the registered parsers keyed by the tx program ids
+func ParseHeliusData(v HeliusData) ([]model.SolSwapData, error) {
+	return parsers.parse(v)
+}
+
+func isSwapType(v HeliusData) bool {
+	return v.Type == "SWAP" || v.Type == "CREATE"
+}
+
+// pump.fun create txs go to the pump_fun_create parser only
+func isPumpCreate(v HeliusData) bool {
+	return v.Source == "PUMP_FUN" && v.Type == "CREATE"
+}
+
+func notPumpCreate(v HeliusData) bool {
+	return !isPumpCreate(v)
+}
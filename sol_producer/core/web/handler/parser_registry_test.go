@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func TestParserRegistry(t *testing.T) {
+	r := newParserRegistry()
+
+	calls := make([]string, 0)
+	fake := func(name string, priority int, ids []string, ok bool) *funcParser {
+		return &funcParser{
+			name:       name,
+			priority:   priority,
+			programIDs: func() []string { return ids },
+			parse: func(v HeliusData) ([]model.SolSwapData, error) {
+				calls = append(calls, name)
+				if !ok {
+					return nil, fmt.Errorf("%s failed", name)
+				}
+				return []model.SolSwapData{{TxHash: v.Signature}}, nil
+			},
+		}
+	}
+
+	r.register(fake("fallback", 100, nil, true))
+	r.register(fake("other_program", 5, []string{"Other111"}, true))
+	r.register(fake("keyed_fail", 20, []string{"Prog111"}, false))
+	r.register(fake("keyed_inner", 30, []string{"Inner111"}, true))
+
+	in := HeliusData{
+		Signature: "sig",
+		Instructions: []InstructionsData{
+			{ProgramID: "Prog111", InnerInstructions: []InnerInstructionsData{{ProgramID: "Inner111"}}},
+		},
+	}
+
+	res, err := r.parse(in)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	if len(calls) != 2 || calls[0] != "keyed_fail" || calls[1] != "keyed_inner" {
+		t.Errorf("got calls %v, want [keyed_fail keyed_inner]", calls)
+	}
+
+	if len(res) != 1 || res[0].Parser != "keyed_inner" {
+		t.Errorf("got %+v, want parser keyed_inner", res)
+	}
+
+	calls = calls[:0]
+	res, err = r.parse(HeliusData{Signature: "none"})
+	if err != nil || len(res) != 1 || res[0].Parser != "fallback" {
+		t.Errorf("got %+v %v, want fallback parser", res, err)
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/redis"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+)
+
+const poolParamsExpire = 24 * time.Hour
+
+// PoolParams are the static fee settings of a pool, read once from its account
+type PoolParams struct {
+	FeeRate float64 `json:"fee_rate"`
+	BinStep int     `json:"bin_step"`
+}
+
+type poolParamsDecoder func(data []byte) (*PoolParams, error)
+
+var poolParamsCache sync.Map
+
+// poolParamsLookup is swapped out in tests, the default goes local cache, redis then rpc
+var poolParamsLookup = lookupPoolParams
+
+func fetchAccountData(address string) ([]byte, error) {
+	pubKey, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := client.RPC().GetAccountInfoWithOpts(context.Background(), pubKey, &rpc.GetAccountInfoOpts{
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if out == nil || out.Value == nil || out.Value.Data == nil {
+		return nil, fmt.Errorf("account %s not found", address)
+	}
+
+	return out.Value.Data.GetBinary(), nil
+}
+
+func lookupPoolParams(pool string, decode poolParamsDecoder) (*PoolParams, error) {
+	if v, ok := poolParamsCache.Load(pool); ok {
+		return v.(*PoolParams), nil
+	}
+
+	ctx := context.Background()
+	key := fmt.Sprintf("pool:solana:params:%s", pool)
+	val, err := redis.Get(ctx, key)
+	if err == nil {
+		var res PoolParams
+		if err := json.Unmarshal([]byte(val), &res); err == nil {
+			poolParamsCache.Store(pool, &res)
+			return &res, nil
+		}
+	}
+
+	data, err := fetchAccountData(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, _ := json.Marshal(res)
+	err = redis.Set(ctx, key, string(bytes), poolParamsExpire)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Pool": pool, "ErrMsg": err}).Error("lookupPoolParams set redis failed")
+	}
+
+	poolParamsCache.Store(pool, res)
+	return res, nil
+}
+
+// fillPoolParams is best effort, a swap is still published without fee settings
+func fillPoolParams(item *model.SolSwapData, decode poolParamsDecoder) {
+	if item.Pool == "" {
+		return
+	}
+
+	params, err := poolParamsLookup(item.Pool, decode)
+	if err != nil {
+		logger.Logrus.WithFields(logrus.Fields{"Pool": item.Pool, "ErrMsg": err}).Error("fillPoolParams lookup pool failed")
+		return
+	}
+
+	item.FeeRate = params.FeeRate
+	item.BinStep = params.BinStep
+}
+
+// poolDecoders maps the pools the swap instructions of in go through to the decoder of their fee
+// settings, read from the instructions alone
+func poolDecoders(in HeliusData) map[string]poolParamsDecoder {
+	res := make(map[string]poolParamsDecoder)
+	for _, ix := range flattenInstructions(in) {
+		d, ok := swapDecoders[ix.ProgramID]
+		if !ok || d.params == nil {
+			continue
+		}
+
+		swap, err := d.decode(ix)
+		if err != nil || swap.Pool == "" {
+			continue
+		}
+		res[swap.Pool] = d.params
+	}
+	return res
+}
+
+// FillPoolParams adds the fee settings of the pools to the swaps parsed from in. it reads pool
+// accounts, so it runs after the parsers and stays out of a deterministic replay
+func FillPoolParams(in HeliusData, ins []model.SolSwapData) []model.SolSwapData {
+	decoders := poolDecoders(in)
+	if len(decoders) == 0 {
+		return ins
+	}
+
+	for i := range ins {
+		if decode, ok := decoders[ins[i].Pool]; ok {
+			fillPoolParams(&ins[i], decode)
+		}
+	}
+	return ins
+}
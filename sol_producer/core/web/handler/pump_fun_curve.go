@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+const (
+	pumpTokenDecimals = 6
+
+	// a fresh curve starts with 1073M virtual and 793.1M real tokens, 6 decimals
+	pumpInitialRealTokenReserves = 793100000000000
+	pumpVirtualTokenOffset       = 279900000000000
+
+	// TradeEvent fields after the event discriminator, the real reserves came later
+	pumpTradeEventLen         = 105
+	pumpTradeEventWithRealLen = 121
+	pumpCompleteEventLen      = 104
+
+	PumpMigrateRaydium  = "raydium"
+	PumpMigratePumpSwap = "pumpswap"
+)
+
+var (
+	pumpTradeEventDisc    = anchorEventDiscriminator("TradeEvent")
+	pumpCompleteEventDisc = anchorEventDiscriminator("CompleteEvent")
+
+	pumpBuyDisc      = anchorDiscriminator("buy")
+	pumpSellDisc     = anchorDiscriminator("sell")
+	pumpCreateDisc   = anchorDiscriminator("create")
+	pumpWithdrawDisc = anchorDiscriminator("withdraw")
+	pumpMigrateDisc  = anchorDiscriminator("migrate")
+)
+
+func init() {
+	RegisterParser(&funcParser{
+		name:       "pump_fun_curve",
+		priority:   13,
+		programIDs: func() []string { return []string{PumpFunProgramID} },
+		match:      notPumpCreate,
+		parse:      parsePumpFunCurve,
+	})
+}
+
+type pumpTrade struct {
+	Mint                 string
+	SolAmount            uint64
+	TokenAmount          uint64
+	IsBuy                bool
+	User                 string
+	VirtualSolReserves   uint64
+	VirtualTokenReserves uint64
+	RealSolReserves      uint64
+	RealTokenReserves    uint64
+}
+
+func decodePumpTrade(data []byte) (*pumpTrade, bool) {
+	if len(data) < pumpTradeEventLen {
+		return nil, false
+	}
+
+	res := &pumpTrade{
+		Mint:                 base58.Encode(data[0:32]),
+		SolAmount:            binary.LittleEndian.Uint64(data[32:40]),
+		TokenAmount:          binary.LittleEndian.Uint64(data[40:48]),
+		IsBuy:                data[48] == 1,
+		User:                 base58.Encode(data[49:81]),
+		VirtualSolReserves:   binary.LittleEndian.Uint64(data[89:97]),
+		VirtualTokenReserves: binary.LittleEndian.Uint64(data[97:105]),
+	}
+
+	if len(data) >= pumpTradeEventWithRealLen {
+		res.RealSolReserves = binary.LittleEndian.Uint64(data[105:113])
+		res.RealTokenReserves = binary.LittleEndian.Uint64(data[113:121])
+	} else if res.VirtualTokenReserves > pumpVirtualTokenOffset {
+		res.RealTokenReserves = res.VirtualTokenReserves - pumpVirtualTokenOffset
+	}
+
+	return res, true
+}
+
+func pumpTrades(in HeliusData) []pumpTrade {
+	res := make([]pumpTrade, 0)
+	for _, data := range anchorEvents(in, PumpFunProgramID, pumpTradeEventDisc) {
+		if v, ok := decodePumpTrade(data); ok {
+			res = append(res, *v)
+		}
+	}
+	return res
+}
+
+// pumpCurveProgress is the share of the curve tokens already sold, in percent
+func pumpCurveProgress(realTokenReserves uint64) float64 {
+	if realTokenReserves >= pumpInitialRealTokenReserves {
+		return 0
+	}
+	return 100 - float64(realTokenReserves)*100/pumpInitialRealTokenReserves
+}
+
+// pumpBondingCurve finds the curve account of a mint in the pump instructions of the tx
+func pumpBondingCurve(in HeliusData, mint string) string {
+	for _, ix := range flattenInstructions(in) {
+		if ix.ProgramID != PumpFunProgramID || len(ix.Accounts) < 4 {
+			continue
+		}
+
+		data, err := base58.Decode(ix.Data)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case hasDiscriminator(data, pumpCreateDisc) && ix.Accounts[0] == mint:
+			return ix.Accounts[2]
+		case hasDiscriminator(data, pumpBuyDisc) || hasDiscriminator(data, pumpSellDisc) ||
+			hasDiscriminator(data, pumpWithdrawDisc) || hasDiscriminator(data, pumpMigrateDisc):
+			if ix.Accounts[2] == mint {
+				return ix.Accounts[3]
+			}
+		}
+	}
+	return ""
+}
+
+func newPumpCurve(in HeliusData, trade pumpTrade) *model.PumpCurve {
+	event := model.PumpCurveEventSell
+	if trade.IsBuy {
+		event = model.PumpCurveEventBuy
+	}
+
+	return &model.PumpCurve{
+		Event:                event,
+		Mint:                 trade.Mint,
+		BondingCurve:         pumpBondingCurve(in, trade.Mint),
+		SolAmount:            trade.SolAmount,
+		TokenAmount:          trade.TokenAmount,
+		VirtualSolReserves:   trade.VirtualSolReserves,
+		VirtualTokenReserves: trade.VirtualTokenReserves,
+		RealSolReserves:      trade.RealSolReserves,
+		RealTokenReserves:    trade.RealTokenReserves,
+		Progress:             pumpCurveProgress(trade.RealTokenReserves),
+	}
+}
+
+func newPumpRecord(in HeliusData, typ, user string, curve *model.PumpCurve) model.SolSwapData {
+	t := time.Unix(int64(in.Timestamp), 0)
+	timeString := t.Format("2006-01-02 15:04:05")
+
+	solAmount := uintAmount(curve.SolAmount, 9)
+	tokenAmount := uintAmount(curve.TokenAmount, pumpTokenDecimals)
+
+	res := model.SolSwapData{
+		TxHash:    in.Signature,
+		Source:    in.Source,
+		Timestamp: in.Timestamp,
+		Type:      typ,
+		Date:      timeString,
+
+		FromToken:        WSOLMint,
+		FromTokenAccount: user,
+		FromUserAccount:  user,
+
+		ToToken:         curve.Mint,
+		ToTokenAccount:  "",
+		ToUserAccount:   user,
+		TradeLabel:      "",
+		IsDCATrade:      false,
+		WalletCounts:    1,
+		TransferDetails: make([]model.SolSwapData, 0),
+		Pool:            curve.BondingCurve,
+		Direction:       DirectionBuy,
+		PumpCurve:       curve,
+	}
+
+	setFromAmount(&res, solAmount)
+	setToAmount(&res, tokenAmount)
+
+	if typ != "SWAP" {
+		res.Direction = ""
+	}
+
+	if curve.Event == model.PumpCurveEventSell {
+		res.FromToken, res.ToToken = curve.Mint, WSOLMint
+		setFromAmount(&res, tokenAmount)
+		setToAmount(&res, solAmount)
+		res.ToTokenAccount = user
+		res.FromTokenAccount = ""
+		res.Direction = DirectionSell
+	}
+
+	return res
+}
+
+// pumpCompletions turns CompleteEvent into graduated records for the buyer that filled the curve
+func pumpCompletions(in HeliusData, trades []pumpTrade) []model.SolSwapData {
+	res := make([]model.SolSwapData, 0)
+	for _, data := range anchorEvents(in, PumpFunProgramID, pumpCompleteEventDisc) {
+		if len(data) < pumpCompleteEventLen {
+			continue
+		}
+
+		user, mint := base58.Encode(data[0:32]), base58.Encode(data[32:64])
+		curve := &model.PumpCurve{Mint: mint, BondingCurve: base58.Encode(data[64:96])}
+		for _, v := range trades {
+			if v.Mint == mint {
+				curve = newPumpCurve(in, v)
+				curve.BondingCurve = base58.Encode(data[64:96])
+			}
+		}
+
+		curve.Event = model.PumpCurveEventComplete
+		curve.Complete = true
+		curve.Progress = 100
+		res = append(res, newPumpRecord(in, "GRADUATE", user, curve))
+	}
+	return res
+}
+
+// pumpMigrations reads withdraw (the raydium migration) and migrate (pumpswap),
+// both move the curve liquidity out, the amounts are what left the curve accounts.
+// the user signing is account 6 of withdraw, after its token account, and 5 of migrate
+func pumpMigrations(in HeliusData) []model.SolSwapData {
+	transfers := allTokenTransfers(in)
+
+	res := make([]model.SolSwapData, 0)
+	for _, ix := range flattenInstructions(in) {
+		if ix.ProgramID != PumpFunProgramID || len(ix.Accounts) < 6 {
+			continue
+		}
+
+		data, err := base58.Decode(ix.Data)
+		if err != nil {
+			continue
+		}
+
+		migratedTo, user, pool := "", "", ""
+		if hasDiscriminator(data, pumpWithdrawDisc) && len(ix.Accounts) > 6 {
+			migratedTo = PumpMigrateRaydium
+			user = ix.Accounts[6]
+		} else if hasDiscriminator(data, pumpMigrateDisc) {
+			migratedTo = PumpMigratePumpSwap
+			user = ix.Accounts[5]
+			if len(ix.Accounts) > 9 {
+				pool = ix.Accounts[9]
+			}
+		} else {
+			continue
+		}
+
+		curve := &model.PumpCurve{
+			Event:        model.PumpCurveEventMigrate,
+			Mint:         ix.Accounts[2],
+			BondingCurve: ix.Accounts[3],
+			Progress:     100,
+			Complete:     true,
+			MigratedTo:   migratedTo,
+		}
+		curve.TokenAmount, _ = netTransfers(transfers, ix.Accounts[4])
+		for _, v := range in.AccountData {
+			if v.Account == curve.BondingCurve && v.NativeBalanceChange < 0 {
+				curve.SolAmount = uint64(-v.NativeBalanceChange)
+			}
+		}
+
+		item := newPumpRecord(in, "GRADUATE", user, curve)
+		if pool != "" {
+			item.Pool = pool
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+// parsePumpFunCurve reads exact amounts and reserves from the pump TradeEvent,
+// txs from before the event was emitted fall through to parsePumpFun
+func parsePumpFunCurve(in HeliusData) ([]model.SolSwapData, error) {
+	trades := pumpTrades(in)
+
+	res := make([]model.SolSwapData, 0)
+	for _, v := range trades {
+		res = append(res, newPumpRecord(in, "SWAP", v.User, newPumpCurve(in, v)))
+	}
+	res = append(res, pumpCompletions(in, trades)...)
+	res = append(res, pumpMigrations(in)...)
+
+	if len(res) < 1 {
+		return nil, fmt.Errorf("no pump.fun curve event, %s", in.Signature)
+	}
+	return res, nil
+}
+
+// pumpCreateCurve fills exact amounts and the curve into the create record of the creator buy
+func pumpCreateCurve(in HeliusData, item *model.SolSwapData) {
+	for _, v := range pumpTrades(in) {
+		if !v.IsBuy || v.Mint != item.ToToken || v.User != item.ToUserAccount {
+			continue
+		}
+
+		item.PumpCurve = newPumpCurve(in, v)
+		setFromAmount(item, uintAmount(v.SolAmount, 9))
+		setToAmount(item, uintAmount(v.TokenAmount, pumpTokenDecimals))
+		item.Pool = item.PumpCurve.BondingCurve
+		return
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"math"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func TestParsePumpFunCurve(t *testing.T) {
+	res, err := parsePumpFunCurve(loadHeliusFixture(t, "pump_fun_complete_buy.json"))
+	if err != nil {
+		t.Fatalf("complete buy: parse failed: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("complete buy: got %d records, want swap and graduate", len(res))
+	}
+
+	user, mint, bc := "BdCbScX1tx4g54LWaqykUWyLXGeSZubSjcgnDKEoph3Z", "7D2C6WaUBiku7Rob9xDth9yVt9nhCEKTnUh7Y7L1qM7V", "ELWtQgMMhMQPGxPGtkwiYdjTVSsfnm1ARM7wvofXQLLG"
+	buy := res[0]
+	if buy.Type != "SWAP" || buy.Direction != DirectionBuy || buy.FromUserAccount != user || buy.ToToken != mint || buy.Pool != bc {
+		t.Errorf("complete buy: got %s %s user %s token %s pool %s", buy.Type, buy.Direction, buy.FromUserAccount, buy.ToToken, buy.Pool)
+	}
+	if buy.FromTokenAmount != 3 || buy.ToTokenAmount != 20000000 || buy.PumpCurve.Progress != 100 || buy.PumpCurve.RealSolReserves != 85005359056 {
+		t.Errorf("complete buy: got %v -> %v, curve %+v", buy.FromTokenAmount, buy.ToTokenAmount, *buy.PumpCurve)
+	}
+
+	graduate := res[1]
+	if graduate.Type != "GRADUATE" || graduate.FromUserAccount != user || graduate.PumpCurve.Event != model.PumpCurveEventComplete ||
+		!graduate.PumpCurve.Complete || graduate.PumpCurve.BondingCurve != bc {
+		t.Errorf("complete buy: got graduate %s user %s curve %+v", graduate.Type, graduate.FromUserAccount, *graduate.PumpCurve)
+	}
+
+	res, err = parsePumpFunCurve(loadHeliusFixture(t, "pump_fun_sell_legacy.json"))
+	if err != nil || len(res) != 1 {
+		t.Fatalf("legacy sell: got %d records, err %v", len(res), err)
+	}
+
+	sell := res[0]
+	if sell.Direction != DirectionSell || sell.FromToken != "GqxkNAJ4PhjB7Cn2P6zkTZPne87xQ5Tkw52B4Jxx89jM" || sell.ToToken != WSOLMint ||
+		sell.FromTokenAmount != 35000000 || sell.ToTokenAmount != 1.23456789 {
+		t.Errorf("legacy sell: got %s %s %v -> %s %v", sell.Direction, sell.FromToken, sell.FromTokenAmount, sell.ToToken, sell.ToTokenAmount)
+	}
+	// real token reserves derived from the virtual ones, 520.1M of 793.1M left
+	if sell.PumpCurve.RealTokenReserves != 520100000000000 || math.Abs(sell.PumpCurve.Progress-34.4219) > 1e-3 {
+		t.Errorf("legacy sell: got curve %+v", *sell.PumpCurve)
+	}
+
+	res, err = parsePumpFunCurve(loadHeliusFixture(t, "pump_fun_migrate.json"))
+	if err != nil || len(res) != 1 {
+		t.Fatalf("migrate: got %d records, err %v", len(res), err)
+	}
+
+	migrate := res[0]
+	if migrate.Type != "GRADUATE" || migrate.PumpCurve.MigratedTo != PumpMigratePumpSwap || migrate.Pool != "8c5nVhdeMHHY1tYbVEwrvTSKkXLP5saMVE13uDxM7DWV" ||
+		migrate.PumpCurve.SolAmount != 84990359057 || migrate.PumpCurve.TokenAmount != 206900000000000 {
+		t.Errorf("migrate: got %s pool %s curve %+v", migrate.Type, migrate.Pool, *migrate.PumpCurve)
+	}
+	if migrate.FromUserAccount != "39azUYFWPz3VHgKCf3VChUwbpURdCHRxjWVowf5jUJjg" {
+		t.Errorf("migrate: got user %s", migrate.FromUserAccount)
+	}
+
+	// withdraw has the token account of the user before the user
+	res, err = parsePumpFunCurve(loadHeliusFixture(t, "pump_fun_withdraw.json"))
+	if err != nil || len(res) != 1 {
+		t.Fatalf("withdraw: got %d records, err %v", len(res), err)
+	}
+
+	withdraw := res[0]
+	if withdraw.Type != "GRADUATE" || withdraw.PumpCurve.MigratedTo != PumpMigrateRaydium || withdraw.FromUserAccount != "39azUYFWPz3VHgKCf3VChUwbpURdCHRxjWVowf5jUJjg" ||
+		withdraw.PumpCurve.SolAmount != 84990359057 || withdraw.PumpCurve.TokenAmount != 206900000000000 {
+		t.Errorf("withdraw: got %s user %s curve %+v", withdraw.Type, withdraw.FromUserAccount, *withdraw.PumpCurve)
+	}
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"fmt"
-	"math"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -10,6 +9,28 @@ import (
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
 )
 
+const PumpFunProgramID = "6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P"
+
+func init() {
+	pumpFunIDs := func() []string { return []string{PumpFunProgramID} }
+
+	RegisterParser(&funcParser{
+		name:       "pump_fun_create",
+		priority:   10,
+		programIDs: pumpFunIDs,
+		match:      isPumpCreate,
+		parse:      parsePumpFunCreate,
+	})
+
+	RegisterParser(&funcParser{
+		name:       "pump_fun",
+		priority:   40,
+		programIDs: pumpFunIDs,
+		match:      func(v HeliusData) bool { return v.Source == "PUMP_FUN" && v.Type == "SWAP" },
+		parse:      parsePumpFun,
+	})
+}
+
 func parsePumpFun(in HeliusData) ([]model.SolSwapData, error) {
 	feeplayer := in.FeePayer
 
@@ -25,10 +46,10 @@ func parsePumpFun(in HeliusData) ([]model.SolSwapData, error) {
 
 	if feeplayer == tokenTransfer.ToUserAccount {
 		//buy other coin using sol
-		fromAmt := float64(0.0)
+		fromAmt := uintAmount(0, 9)
 		for _, v := range in.NativeTransfers {
 			if v.FromUserAccount == tokenTransfer.ToUserAccount && v.ToUserAccount == tokenTransfer.FromUserAccount {
-				fromAmt = float64(v.Amount) / math.Pow10(9)
+				fromAmt = uintAmount(uint64(v.Amount), 9)
 			}
 		}
 
@@ -41,25 +62,29 @@ func parsePumpFun(in HeliusData) ([]model.SolSwapData, error) {
 			FromToken:        "So11111111111111111111111111111111111111112",
 			FromTokenAccount: feeplayer,
 			FromUserAccount:  feeplayer,
-			FromTokenAmount:  fromAmt,
 			ToToken:          tokenTransfer.Mint,
 			ToTokenAccount:   tokenTransfer.ToTokenAccount,
 			ToUserAccount:    feeplayer,
-			ToTokenAmount:    tokenTransfer.TokenAmount,
 			TradeLabel:       "",
 			IsDCATrade:       false,
 			WalletCounts:     1,
 			TransferDetails:  make([]model.SolSwapData, 0),
 		}
+		setFromAmount(&item, fromAmt)
+		setToAmount(&item, transferAmount(in, tokenTransfer))
 
 		result = append(result, item)
 
 	} else if feeplayer == tokenTransfer.FromUserAccount {
 		//sell other coin using sol
-		toAmt := float64(0)
+		toAmt := uintAmount(0, 9)
 		for _, v := range in.AccountData {
 			if v.Account == tokenTransfer.ToUserAccount {
-				toAmt = float64(math.Abs(float64(v.NativeBalanceChange))) / math.Pow10(9)
+				change := v.NativeBalanceChange
+				if change < 0 {
+					change = -change
+				}
+				toAmt = uintAmount(uint64(change), 9)
 			}
 		}
 
@@ -72,16 +97,16 @@ func parsePumpFun(in HeliusData) ([]model.SolSwapData, error) {
 			FromToken:        tokenTransfer.Mint,
 			FromTokenAccount: tokenTransfer.FromTokenAccount,
 			FromUserAccount:  feeplayer,
-			FromTokenAmount:  tokenTransfer.TokenAmount,
 			ToToken:          "So11111111111111111111111111111111111111112",
 			ToTokenAccount:   feeplayer,
 			ToUserAccount:    feeplayer,
-			ToTokenAmount:    toAmt,
 			TradeLabel:       "",
 			IsDCATrade:       false,
 			WalletCounts:     1,
 			TransferDetails:  make([]model.SolSwapData, 0),
 		}
+		setFromAmount(&item, transferAmount(in, tokenTransfer))
+		setToAmount(&item, toAmt)
 
 		result = append(result, item)
 	}
@@ -132,7 +157,6 @@ func parsePumpFunCreate(in HeliusData) ([]model.SolSwapData, error) {
 			continue
 		}
 
-		amount := toDecimal(fmt.Sprintf("%d", amt), 9)
 		item := model.SolSwapData{
 			TxHash:           in.Signature,
 			Source:           in.Source,
@@ -142,17 +166,18 @@ func parsePumpFunCreate(in HeliusData) ([]model.SolSwapData, error) {
 			FromToken:        "So11111111111111111111111111111111111111112",
 			FromTokenAccount: v.FromUserAccount,
 			FromUserAccount:  v.FromUserAccount,
-			FromTokenAmount:  amount,
 			ToToken:          v.Mint,
 			ToTokenAccount:   v.ToTokenAccount,
 			ToUserAccount:    v.ToUserAccount,
-			ToTokenAmount:    v.TokenAmount,
 			TradeLabel:       "",
 			IsDCATrade:       false,
 			WalletCounts:     1,
 			TransferDetails:  make([]model.SolSwapData, 0),
 		}
+		setFromAmount(&item, uintAmount(uint64(amt), 9))
+		setToAmount(&item, transferAmount(in, v))
 
+		pumpCreateCurve(in, &item)
 		result = append(result, item)
 	}
 
@@ -160,50 +185,20 @@ func parsePumpFunCreate(in HeliusData) ([]model.SolSwapData, error) {
 }
 
 func handSwapData(v HeliusData) error {
-	if v.Source == "PUMP_FUN" {
-		var datait []model.SolSwapData
-		var err error
-		if v.Type == "CREATE" {
-			datait, err = parsePumpFunCreate(v)
-			if err != nil {
-				return fmt.Errorf("parse pump.fun create failed, %v", err)
-			}
-		} else {
-			datait, err = parseGobleDEX(v)
-			if err != nil {
-				datait, err = parsePumpFun(v)
-				if err != nil {
-					return fmt.Errorf("parse pump.fun failed, %v", err)
-				}
-			}
-		}
-
-		datalist := FillLabel(datait)
-
-		err = sendKafkaMsg(datalist)
-		if err != nil {
-			return fmt.Errorf("send pump.fun kafka failed, %v", err)
-		}
-
-		logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handSwapData send pump.fun kafka data")
-	} else {
-		datait, err := generateSwapData(v)
-		if err != nil {
-			datait, err = parseGobleDEX(v)
-			if err != nil {
-				return fmt.Errorf("parse swap failed, %v", err)
-			}
-		}
-
-		datalist := FillLabel(datait)
+	datait, err := ParseHeliusData(v)
+	if err != nil {
+		return fmt.Errorf("%w, %v", ErrUnparsed, err)
+	}
 
-		err = sendKafkaMsg(datalist)
-		if err != nil {
-			return fmt.Errorf("send kafka failed, %v", err)
-		}
+	datait = FillPoolParams(v, datait)
+	datalist := FillLabel(datait)
 
-		logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handSwapData send swap kafka data")
+	err = sendKafkaMsg(datalist)
+	if err != nil {
+		return fmt.Errorf("send kafka failed, %v", err)
 	}
 
+	logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handSwapData send swap kafka data")
+
 	return nil
 }
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/mr-tron/base58"
+)
+
+const SystemProgramID = "11111111111111111111111111111111"
+
+// RawTransaction is a transaction as the validator reports it, without helius enrichment.
+// sources other than the helius webhook fill it and run it through the same parsers.
+type RawTransaction struct {
+	Signature string
+	Slot      uint64
+	BlockTime int64
+	Failed    bool
+	Fee       uint64
+	// AccountKeys are the static keys followed by the writable and readonly lookup table keys
+	AccountKeys       []string
+	Instructions      []RawInstruction
+	InnerInstructions map[int][]RawInstruction
+	PreBalances       []uint64
+	PostBalances      []uint64
+	PreTokenBalances  []RawTokenBalance
+	PostTokenBalances []RawTokenBalance
+}
+
+type RawInstruction struct {
+	ProgramIDIndex int
+	Accounts       []int
+	Data           []byte
+}
+
+type RawTokenBalance struct {
+	AccountIndex int
+	Mint         string
+	Owner        string
+	Decimals     int
+	Amount       string
+}
+
+func (r *RawTransaction) key(i int) string {
+	if i < 0 || i >= len(r.AccountKeys) {
+		return ""
+	}
+	return r.AccountKeys[i]
+}
+
+func (r *RawTransaction) instruction(ix RawInstruction) (string, []string, string) {
+	accounts := make([]string, 0, len(ix.Accounts))
+	for _, v := range ix.Accounts {
+		accounts = append(accounts, r.key(v))
+	}
+	return r.key(ix.ProgramIDIndex), accounts, base58.Encode(ix.Data)
+}
+
+// tokenBalanceChanges diffs pre and post token balances per account like helius does, unchanged ones are dropped
+func (r *RawTransaction) tokenBalanceChanges() map[int][]TokenBalanceChange {
+	pre := make(map[int]RawTokenBalance, len(r.PreTokenBalances))
+	for _, v := range r.PreTokenBalances {
+		pre[v.AccountIndex] = v
+	}
+
+	post := make(map[int]RawTokenBalance, len(r.PostTokenBalances))
+	for _, v := range r.PostTokenBalances {
+		post[v.AccountIndex] = v
+	}
+	// closed accounts only show up before
+	for k, v := range pre {
+		if _, ok := post[k]; !ok {
+			post[k] = RawTokenBalance{AccountIndex: k, Mint: v.Mint, Owner: v.Owner, Decimals: v.Decimals, Amount: "0"}
+		}
+	}
+
+	res := make(map[int][]TokenBalanceChange)
+	for k, after := range post {
+		diff, ok := new(big.Int).SetString(after.Amount, 10)
+		if !ok {
+			continue
+		}
+		if before, ok := pre[k]; ok {
+			amount, ok := new(big.Int).SetString(before.Amount, 10)
+			if ok {
+				diff.Sub(diff, amount)
+			}
+		}
+		if diff.Sign() == 0 {
+			continue
+		}
+
+		res[k] = append(res[k], TokenBalanceChange{
+			Mint:           after.Mint,
+			RawTokenAmount: RawTokenAmount{Decimals: after.Decimals, TokenAmount: diff.String()},
+			TokenAccount:   r.key(k),
+			UserAccount:    after.Owner,
+		})
+	}
+	return res
+}
+
+func (r *RawTransaction) tokenBalance(account string) (RawTokenBalance, bool) {
+	for _, list := range [][]RawTokenBalance{r.PostTokenBalances, r.PreTokenBalances} {
+		for _, v := range list {
+			if r.key(v.AccountIndex) == account {
+				return v, true
+			}
+		}
+	}
+	return RawTokenBalance{}, false
+}
+
+// transfers derives the helius token and native transfer lists from spl and system transfer instructions
+func (r *RawTransaction) transfers(ixs []InnerInstructionsData) ([]TokenDetails, []NativeTransfersDetail) {
+	tokens := make([]TokenDetails, 0)
+	natives := make([]NativeTransfersDetail, 0)
+	for _, ix := range ixs {
+		if ix.ProgramID == SystemProgramID {
+			data, err := base58.Decode(ix.Data)
+			if err == nil && len(data) >= 12 && binary.LittleEndian.Uint32(data[:4]) == 2 && len(ix.Accounts) >= 2 {
+				natives = append(natives, NativeTransfersDetail{
+					FromUserAccount: ix.Accounts[0],
+					ToUserAccount:   ix.Accounts[1],
+					Amount:          int(binary.LittleEndian.Uint64(data[4:12])),
+				})
+			}
+			continue
+		}
+
+		transfer, ok := decodeTokenTransfer(ix)
+		if !ok || transfer.Amount == 0 {
+			continue
+		}
+
+		from, _ := r.tokenBalance(transfer.Source)
+		to, _ := r.tokenBalance(transfer.Dest)
+		mint, decimals := transfer.Mint, transfer.Decimals
+		if mint == "" {
+			mint = from.Mint
+		}
+		if mint == "" {
+			mint = to.Mint
+		}
+		if decimals < 0 {
+			decimals = from.Decimals
+			if from.Mint == "" {
+				decimals = to.Decimals
+			}
+		}
+
+		amount := uintAmount(transfer.Amount, decimals)
+		tokens = append(tokens, TokenDetails{
+			FromTokenAccount: transfer.Source,
+			FromUserAccount:  from.Owner,
+			Mint:             mint,
+			ToTokenAccount:   transfer.Dest,
+			ToUserAccount:    to.Owner,
+			TokenAmount:      amount.float(),
+			TokenStandard:    "Fungible",
+			amountText:       amount.text(),
+		})
+	}
+	return tokens, natives
+}
+
+// HeliusData shapes the raw transaction like an enhanced webhook item, type and source stay UNKNOWN
+// so only the program keyed parsers and the transfer path pick it up
+func (r *RawTransaction) HeliusData() HeliusData {
+	res := HeliusData{
+		Fee:          int(r.Fee),
+		FeePayer:     r.key(0),
+		Signature:    r.Signature,
+		Slot:         int(r.Slot),
+		Source:       "UNKNOWN",
+		Timestamp:    int(r.BlockTime),
+		Type:         "UNKNOWN",
+		Instructions: make([]InstructionsData, 0, len(r.Instructions)),
+	}
+	if r.Failed {
+		res.TransactionError = "failed"
+	}
+
+	flat := make([]InnerInstructionsData, 0)
+	for i, ix := range r.Instructions {
+		programID, accounts, data := r.instruction(ix)
+		item := InstructionsData{
+			ProgramID:         programID,
+			Accounts:          accounts,
+			Data:              data,
+			InnerInstructions: make([]InnerInstructionsData, 0, len(r.InnerInstructions[i])),
+		}
+		flat = append(flat, InnerInstructionsData{ProgramID: programID, Accounts: accounts, Data: data})
+
+		for _, inner := range r.InnerInstructions[i] {
+			programID, accounts, data := r.instruction(inner)
+			v := InnerInstructionsData{ProgramID: programID, Accounts: accounts, Data: data}
+			item.InnerInstructions = append(item.InnerInstructions, v)
+			flat = append(flat, v)
+		}
+		res.Instructions = append(res.Instructions, item)
+	}
+	res.TokenTransfers, res.NativeTransfers = r.transfers(flat)
+
+	changes := r.tokenBalanceChanges()
+	res.AccountData = make([]AccountData, 0, len(r.AccountKeys))
+	for i, key := range r.AccountKeys {
+		item := AccountData{Account: key, TokenBalanceChanges: changes[i]}
+		if i < len(r.PreBalances) && i < len(r.PostBalances) {
+			item.NativeBalanceChange = int64(r.PostBalances[i]) - int64(r.PreBalances[i])
+		}
+		if item.TokenBalanceChanges == nil {
+			item.TokenBalanceChanges = make([]TokenBalanceChange, 0)
+		}
+		res.AccountData = append(res.AccountData, item)
+	}
+
+	return res
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/mr-tron/base58"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+const (
+	RaydiumAMMV4ProgramID = "675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"
+	RaydiumCLMMProgramID  = "CAMMCzo5YL8w4VFF8KVHrK22GGUsp5VTaW7grrKgrWqK"
+	RaydiumCPMMProgramID  = "CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C"
+
+	raydiumSource = "RAYDIUM"
+)
+
+var (
+	raydiumCLMMSwap             = anchorDiscriminator("swap")
+	raydiumCLMMSwapV2           = anchorDiscriminator("swap_v2")
+	raydiumCPMMSwapBaseInput    = anchorDiscriminator("swap_base_input")
+	raydiumCPMMSwapBaseOutput   = anchorDiscriminator("swap_base_output")
+	raydiumAMMV4SwapInstruction = map[byte]bool{
+		9:  true, // swapBaseIn
+		11: true, // swapBaseOut
+		16: true, // swapBaseInV2
+		17: true, // swapBaseOutV2
+	}
+
+	raydiumCLMMIncreaseLiquidity   = anchorDiscriminator("increase_liquidity")
+	raydiumCLMMIncreaseLiquidityV2 = anchorDiscriminator("increase_liquidity_v2")
+	raydiumCLMMDecreaseLiquidity   = anchorDiscriminator("decrease_liquidity")
+	raydiumCLMMDecreaseLiquidityV2 = anchorDiscriminator("decrease_liquidity_v2")
+	raydiumCPMMDeposit             = anchorDiscriminator("deposit")
+	raydiumCPMMWithdraw            = anchorDiscriminator("withdraw")
+)
+
+const (
+	raydiumAMMV4Deposit  = 3
+	raydiumAMMV4Withdraw = 4
+)
+
+func init() {
+	registerSwapDecoder(RaydiumAMMV4ProgramID, decodeRaydiumAMMV4, nil)
+	registerSwapDecoder(RaydiumCLMMProgramID, decodeRaydiumCLMM, nil)
+	registerSwapDecoder(RaydiumCPMMProgramID, decodeRaydiumCPMM, nil)
+
+	registerLiquidityDecoder(RaydiumAMMV4ProgramID, decodeRaydiumAMMV4Liquidity)
+	registerLiquidityDecoder(RaydiumCLMMProgramID, decodeRaydiumCLMMLiquidity)
+	registerLiquidityDecoder(RaydiumCPMMProgramID, decodeRaydiumCPMMLiquidity)
+
+	RegisterParser(&funcParser{
+		name:     "raydium",
+		priority: 15,
+		programIDs: func() []string {
+			return []string{RaydiumAMMV4ProgramID, RaydiumCLMMProgramID, RaydiumCPMMProgramID}
+		},
+		match: notPumpCreate,
+		parse: parseRaydium,
+	})
+}
+
+// decodeRaydiumAMMV4 keeps user accounts at the tail, the legacy layout carries serum market accounts before them
+func decodeRaydiumAMMV4(ix flatInstruction) (*decodedSwap, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) < 17 {
+		return nil, fmt.Errorf("amm v4 data invalid")
+	}
+
+	if !raydiumAMMV4SwapInstruction[data[0]] {
+		return nil, fmt.Errorf("amm v4 instruction %d is not swap", data[0])
+	}
+
+	n := len(ix.Accounts)
+	if n < 8 {
+		return nil, fmt.Errorf("amm v4 account length not match, %d", n)
+	}
+
+	return &decodedSwap{
+		Source:     raydiumSource,
+		Pool:       ix.Accounts[1],
+		Owner:      ix.Accounts[n-1],
+		UserSource: ix.Accounts[n-3],
+		UserDest:   ix.Accounts[n-2],
+	}, nil
+}
+
+func decodeRaydiumCLMM(ix flatInstruction) (*decodedSwap, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case hasDiscriminator(data, raydiumCLMMSwap):
+		if len(ix.Accounts) < 9 {
+			return nil, fmt.Errorf("clmm swap account length not match, %d", len(ix.Accounts))
+		}
+		return &decodedSwap{
+			Source:     raydiumSource,
+			Pool:       ix.Accounts[2],
+			Owner:      ix.Accounts[0],
+			UserSource: ix.Accounts[3],
+			UserDest:   ix.Accounts[4],
+		}, nil
+	case hasDiscriminator(data, raydiumCLMMSwapV2):
+		if len(ix.Accounts) < 13 {
+			return nil, fmt.Errorf("clmm swap_v2 account length not match, %d", len(ix.Accounts))
+		}
+		return &decodedSwap{
+			Source:     raydiumSource,
+			Pool:       ix.Accounts[2],
+			Owner:      ix.Accounts[0],
+			UserSource: ix.Accounts[3],
+			UserDest:   ix.Accounts[4],
+			InMint:     ix.Accounts[11],
+			OutMint:    ix.Accounts[12],
+		}, nil
+	}
+
+	return nil, fmt.Errorf("clmm instruction is not swap")
+}
+
+func decodeRaydiumCPMM(ix flatInstruction) (*decodedSwap, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasDiscriminator(data, raydiumCPMMSwapBaseInput) && !hasDiscriminator(data, raydiumCPMMSwapBaseOutput) {
+		return nil, fmt.Errorf("cpmm instruction is not swap")
+	}
+
+	if len(ix.Accounts) < 12 {
+		return nil, fmt.Errorf("cpmm account length not match, %d", len(ix.Accounts))
+	}
+
+	return &decodedSwap{
+		Source:     raydiumSource,
+		Pool:       ix.Accounts[3],
+		Owner:      ix.Accounts[0],
+		UserSource: ix.Accounts[4],
+		UserDest:   ix.Accounts[5],
+		InMint:     ix.Accounts[10],
+		OutMint:    ix.Accounts[11],
+	}, nil
+}
+
+// decodeRaydiumAMMV4Liquidity counts user accounts from the tail, withdraw lost two accounts over the versions
+func decodeRaydiumAMMV4Liquidity(ix flatInstruction) (*decodedLiquidity, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil || len(data) < 1 {
+		return nil, fmt.Errorf("amm v4 data invalid")
+	}
+
+	n := len(ix.Accounts)
+	switch data[0] {
+	case raydiumAMMV4Deposit:
+		if n < 13 {
+			return nil, fmt.Errorf("amm v4 deposit account length not match, %d", n)
+		}
+		return &decodedLiquidity{
+			Source: raydiumSource,
+			Event:  model.LiquidityEventAdd,
+			Pool:   ix.Accounts[1],
+			LpMint: ix.Accounts[5],
+			Owner:  ix.Accounts[n-2],
+			UserLp: ix.Accounts[n-3],
+			UserA:  ix.Accounts[n-5],
+			UserB:  ix.Accounts[n-4],
+		}, nil
+	case raydiumAMMV4Withdraw:
+		if n < 20 {
+			return nil, fmt.Errorf("amm v4 withdraw account length not match, %d", n)
+		}
+		return &decodedLiquidity{
+			Source: raydiumSource,
+			Event:  model.LiquidityEventRemove,
+			Pool:   ix.Accounts[1],
+			LpMint: ix.Accounts[5],
+			Owner:  ix.Accounts[n-4],
+			UserLp: ix.Accounts[n-7],
+			UserA:  ix.Accounts[n-6],
+			UserB:  ix.Accounts[n-5],
+		}, nil
+	}
+
+	return nil, fmt.Errorf("amm v4 instruction %d is not liquidity", data[0])
+}
+
+// decodeRaydiumCLMMLiquidity reads position changes, the position nft stands for the lp token
+func decodeRaydiumCLMMLiquidity(ix flatInstruction) (*decodedLiquidity, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(ix.Accounts)
+	switch {
+	case hasDiscriminator(data, raydiumCLMMIncreaseLiquidity), hasDiscriminator(data, raydiumCLMMIncreaseLiquidityV2):
+		if n < 11 {
+			return nil, fmt.Errorf("clmm increase liquidity account length not match, %d", n)
+		}
+		res := &decodedLiquidity{
+			Source:   raydiumSource,
+			Event:    model.LiquidityEventAdd,
+			Pool:     ix.Accounts[2],
+			Owner:    ix.Accounts[0],
+			Position: ix.Accounts[4],
+			UserA:    ix.Accounts[7],
+			UserB:    ix.Accounts[8],
+		}
+		if hasDiscriminator(data, raydiumCLMMIncreaseLiquidityV2) && n >= 15 {
+			res.MintA, res.MintB = ix.Accounts[13], ix.Accounts[14]
+		}
+		return res, nil
+	case hasDiscriminator(data, raydiumCLMMDecreaseLiquidity), hasDiscriminator(data, raydiumCLMMDecreaseLiquidityV2):
+		if n < 11 {
+			return nil, fmt.Errorf("clmm decrease liquidity account length not match, %d", n)
+		}
+		res := &decodedLiquidity{
+			Source:   raydiumSource,
+			Event:    model.LiquidityEventRemove,
+			Pool:     ix.Accounts[3],
+			Owner:    ix.Accounts[0],
+			Position: ix.Accounts[2],
+			UserA:    ix.Accounts[9],
+			UserB:    ix.Accounts[10],
+		}
+		if hasDiscriminator(data, raydiumCLMMDecreaseLiquidityV2) && n >= 16 {
+			res.MintA, res.MintB = ix.Accounts[14], ix.Accounts[15]
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("clmm instruction is not liquidity")
+}
+
+// decodeRaydiumCPMMLiquidity reads deposit and withdraw, both share the leading accounts
+func decodeRaydiumCPMMLiquidity(ix flatInstruction) (*decodedLiquidity, error) {
+	data, err := base58.Decode(ix.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	event := model.LiquidityEventAdd
+	if hasDiscriminator(data, raydiumCPMMWithdraw) {
+		event = model.LiquidityEventRemove
+	} else if !hasDiscriminator(data, raydiumCPMMDeposit) {
+		return nil, fmt.Errorf("cpmm instruction is not liquidity")
+	}
+
+	if len(ix.Accounts) < 13 {
+		return nil, fmt.Errorf("cpmm liquidity account length not match, %d", len(ix.Accounts))
+	}
+
+	return &decodedLiquidity{
+		Source: raydiumSource,
+		Event:  event,
+		Pool:   ix.Accounts[2],
+		Owner:  ix.Accounts[0],
+		UserLp: ix.Accounts[3],
+		UserA:  ix.Accounts[4],
+		UserB:  ix.Accounts[5],
+		MintA:  ix.Accounts[10],
+		MintB:  ix.Accounts[11],
+		LpMint: ix.Accounts[12],
+	}, nil
+}
+
+// parseRaydium decodes every raydium swap in the tx, top level or routed through an aggregator
+func parseRaydium(in HeliusData) ([]model.SolSwapData, error) {
+	return parseDecodedSwaps(in, "raydium", RaydiumAMMV4ProgramID, RaydiumCLMMProgramID, RaydiumCPMMProgramID)
+}
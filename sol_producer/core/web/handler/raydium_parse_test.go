@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func loadHeliusFixture(t *testing.T, name string) HeliusData {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture %s failed: %v", name, err)
+	}
+
+	var inp []HeliusData
+	if err := json.Unmarshal(body, &inp); err != nil || len(inp) != 1 {
+		t.Fatalf("decode fixture %s failed: %v", name, err)
+	}
+	return inp[0]
+}
+
+type swapExpect struct {
+	fixture   string
+	pool      string
+	user      string
+	inMint    string
+	outMint   string
+	inAmount  float64
+	outAmount float64
+	direction string
+}
+
+func checkSwapFixtures(t *testing.T, parse func(HeliusData) ([]model.SolSwapData, error), source string, cases []swapExpect) []model.SolSwapData {
+	t.Helper()
+
+	parsed := make([]model.SolSwapData, 0)
+
+	for _, c := range cases {
+		res, err := parse(loadHeliusFixture(t, c.fixture))
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", c.fixture, err)
+			continue
+		}
+
+		if len(res) != 1 {
+			t.Errorf("%s: got %d swaps, want 1", c.fixture, len(res))
+			continue
+		}
+
+		v := res[0]
+		if v.Pool != c.pool || v.FromUserAccount != c.user || v.ToUserAccount != c.user {
+			t.Errorf("%s: got pool %s user %s/%s", c.fixture, v.Pool, v.FromUserAccount, v.ToUserAccount)
+		}
+		if v.FromToken != c.inMint || v.ToToken != c.outMint {
+			t.Errorf("%s: got mints %s -> %s", c.fixture, v.FromToken, v.ToToken)
+		}
+		if math.Abs(v.FromTokenAmount-c.inAmount) > 1e-9 || math.Abs(v.ToTokenAmount-c.outAmount) > 1e-9 {
+			t.Errorf("%s: got amounts %v -> %v", c.fixture, v.FromTokenAmount, v.ToTokenAmount)
+		}
+		if v.Direction != c.direction || v.Source != source || v.Type != "SWAP" {
+			t.Errorf("%s: got direction %s source %s type %s", c.fixture, v.Direction, v.Source, v.Type)
+		}
+		parsed = append(parsed, v)
+	}
+
+	return parsed
+}
+
+func TestParseRaydium(t *testing.T) {
+	cases := []swapExpect{
+		{"raydium_amm_v4_buy.json", "AUH6c4QLMr2qQr9N5Kkpz5astDM9gBNroXCSxQiFTGQv", "6anbDQNCcVh2f6okexjaX1VGj6tEnizJ1kV5UTBS8Zhi",
+			WSOLMint, "Borqy3dEjw9az7Uj9nW69A9ZDansFGHWEggUx7tkv44f", 1.5, 1234567.89012, DirectionBuy},
+		{"raydium_clmm_sell.json", "AXCMJrLwKw8A2gDZQPeHH6eTLmvf5vJmXavphdfKCzn4", "Aghn9c2qSyU2dtLbc9zk8nf4W8QsEXVTiAsX36jRznmD",
+			"GDkx2juvSvbRP96E1Vj7UTPJwHgjZzPvNjcPfTq5Utvq", USDCMint, 250, 41.234567, DirectionSell},
+		{"raydium_cpmm_sell.json", "3UT4chPbHWXzkdyBVW7YpSupZYUA2fFE4yYUiayBXChF", "C3x9ivNXsXqiixFyyCmyhHEAo1WfZSEfZCcUyZnKRJAA",
+			"94Wy4LLrYXACTacPG7WkFwoHKgqdRyQySPbjTwqeN3Cz", WSOLMint, 5000, 2.718281828, DirectionSell},
+		{"raydium_routed_buy.json", "EkUGncYsDVrbM7rcCwXq63XQKYuEFm26SZEghUr3uknC", "2tgb9zrG3vo1yVcnrvj85742VADa4tme1WEKTt5W4GBY",
+			USDCMint, "HWzNcLvpF6C2C4qjgPqvKxFoBnJSt6cCBUpLFG5jt1rG", 100, 987.654321, DirectionBuy},
+	}
+
+	checkSwapFixtures(t, parseRaydium, raydiumSource, cases)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/alikafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/archive"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
+)
+
+type ReplayOptions struct {
+	From      time.Time
+	To        time.Time
+	Signature string
+	// Topic is used when Out is nil
+	Topic string
+	Out   io.Writer
+	// Label runs FillHistoryLabel, it queries live rpc so output is no longer deterministic
+	Label bool
+	// PoolParams runs FillPoolParams, it reads pool accounts from rpc, same as Label
+	PoolParams bool
+}
+
+type ReplayStats struct {
+	Batches   int
+	Txs       int
+	Published int
+	Failed    int
+}
+
+func publishReplay(opts ReplayOptions, in []model.SolSwapData) error {
+	data, headers, err := solmsg.Marshal(solmsg.SolSwapBatch, in)
+	if err != nil {
+		return err
+	}
+
+	if opts.Out != nil {
+		_, err = fmt.Fprintln(opts.Out, string(data))
+		return err
+	}
+
+	return alikafka.GetKafkaInst().Produce(contractMessage(opts.Topic, walletKey(in), data, headers), nil)
+}
+
+// Replay feeds archived webhook batches in [From, To] back through the parser registry, without dedup
+func Replay(opts ReplayOptions) (*ReplayStats, error) {
+	store := archive.GetStore()
+	if store == nil {
+		return nil, fmt.Errorf("archive is disabled")
+	}
+
+	if opts.Out == nil && opts.Topic == "" {
+		return nil, fmt.Errorf("replay needs a topic or stdout")
+	}
+
+	stats := &ReplayStats{}
+	err := store.Scan(opts.From, opts.To, func(b archive.Batch) error {
+		stats.Batches++
+
+		var inp []HeliusData
+		err := json.Unmarshal(b.Payload, &inp)
+		if err != nil {
+			logger.Logrus.WithFields(logrus.Fields{"ReceivedAt": b.ReceivedAt, "ErrMsg": err}).Error("Replay unmarshal archived batch failed")
+			stats.Failed++
+			return nil
+		}
+
+		for _, v := range inp {
+			if opts.Signature != "" && v.Signature != opts.Signature {
+				continue
+			}
+			stats.Txs++
+
+			datalist, err := ParseHeliusData(v)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"TxHash": v.Signature, "ErrMsg": err}).Error("Replay parse data failed")
+				stats.Failed++
+				continue
+			}
+
+			if len(datalist) == 0 {
+				continue
+			}
+
+			if opts.PoolParams {
+				datalist = FillPoolParams(v, datalist)
+			}
+			if opts.Label {
+				datalist = FillHistoryLabel(datalist)
+			}
+
+			err = publishReplay(opts, datalist)
+			if err != nil {
+				return fmt.Errorf("publish %s failed, %v", v.Signature, err)
+			}
+			stats.Published++
+		}
+
+		return nil
+	})
+
+	if opts.Out == nil {
+		alikafka.GetKafkaInst().Flush(15000)
+	}
+
+	return stats, err
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+// intermediate mints net out to dust, anything below this share of the flow is not a route end
+const routeDustRatio = 1e-6
+
+const (
+	JupiterV6ProgramID = "JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4"
+	JupiterV4ProgramID = "JUP4Fb2cqiRUcaTHdrPC8h2gNsA2ETXiPDD33WcGuJB"
+)
+
+func init() {
+	// only aggregator txs are routes, decoding the legs of any other tx costs pool lookups
+	RegisterParser(&funcParser{
+		name:       "route",
+		priority:   12,
+		programIDs: func() []string { return []string{JupiterV6ProgramID, JupiterV4ProgramID} },
+		match:      isRouteCandidate,
+		parse:      parseRoute,
+	})
+}
+
+// dca fills are signed by the keeper and go to parseJupiterDCA which resolves the dca user
+func isRouteCandidate(v HeliusData) bool {
+	if isPumpCreate(v) {
+		return false
+	}
+
+	programID := dcaProgramID()
+	for _, ix := range v.Instructions {
+		if ix.ProgramID == programID {
+			return false
+		}
+	}
+
+	return true
+}
+
+// routeLeg is a model leg with its exact amounts, the model only keeps the floats
+type routeLeg struct {
+	model.RouteLeg
+	in, out tokenAmount
+}
+
+func newRouteLeg(leg model.RouteLeg, in, out tokenAmount) routeLeg {
+	leg.InAmount, leg.OutAmount = in.float(), out.float()
+	return routeLeg{RouteLeg: leg, in: in, out: out}
+}
+
+func sumTokenDetails(tx HeliusData, in []TokenDetails) (string, tokenAmount, bool) {
+	mint, amount := "", tokenAmount{}
+	for _, v := range in {
+		if mint != "" && v.Mint != mint {
+			return "", tokenAmount{}, false
+		}
+		mint = v.Mint
+		amount = amount.add(transferAmount(tx, v))
+	}
+	return mint, amount, mint != ""
+}
+
+// heliusRouteLegs reads the legs helius already resolved in events.swap.innerSwaps
+func heliusRouteLegs(in HeliusData) ([]routeLeg, bool) {
+	legs := make(map[string]int)
+	for _, v := range in.Events.Swap.InnerSwaps {
+		legs[v.ProgramInfo.Account]++
+	}
+
+	pools := decodedPools(in)
+	seen := make(map[string]int)
+	res := make([]routeLeg, 0)
+	for _, v := range in.Events.Swap.InnerSwaps {
+		inMint, inAmount, ok := sumTokenDetails(in, v.TokenInputs)
+		if !ok {
+			return nil, false
+		}
+
+		outMint, outAmount, ok := sumTokenDetails(in, v.TokenOutputs)
+		if !ok {
+			return nil, false
+		}
+
+		// the receiver of the input is a vault authority, only a decoded instruction names the pool
+		program := v.ProgramInfo.Account
+		pool := ""
+		if q := pools[program]; len(q) == legs[program] {
+			pool = q[seen[program]]
+		}
+		seen[program]++
+
+		res = append(res, newRouteLeg(model.RouteLeg{
+			Program: program,
+			Source:  v.ProgramInfo.Source,
+			Pool:    pool,
+			InMint:  inMint,
+			OutMint: outMint,
+		}, inAmount, outAmount))
+	}
+	return res, true
+}
+
+// decodedPools lists the pools of the swap instructions we can decode per program, in tx order.
+// a helius leg only takes its pool from here when the program has as many decoded swaps as legs
+func decodedPools(in HeliusData) map[string][]string {
+	res := make(map[string][]string)
+	for _, ix := range flattenInstructions(in) {
+		d, ok := swapDecoders[ix.ProgramID]
+		if !ok {
+			continue
+		}
+
+		swap, err := d.decode(ix)
+		if err != nil {
+			continue
+		}
+		res[ix.ProgramID] = append(res[ix.ProgramID], swap.Pool)
+	}
+	return res
+}
+
+// decodedRouteLegs falls back to our own instruction decoders
+func decodedRouteLegs(in HeliusData) ([]routeLeg, error) {
+	legs, err := decodeSwapLegs(in)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]routeLeg, 0, len(legs))
+	for _, v := range legs {
+		res = append(res, newRouteLeg(model.RouteLeg{
+			Program: v.Program,
+			Source:  v.Data.Source,
+			Pool:    v.Data.Pool,
+			InMint:  v.Data.FromToken,
+			OutMint: v.Data.ToToken,
+		}, fromAmountOf(*v.Data), toAmountOf(*v.Data)))
+	}
+	return res, nil
+}
+
+// normalizeRoute collapses the leg graph into the single mint consumed and the single mint produced
+func normalizeRoute(legs []routeLeg) (string, tokenAmount, string, tokenAmount, error) {
+	consumed := make(map[string]tokenAmount)
+	produced := make(map[string]tokenAmount)
+	for _, v := range legs {
+		consumed[v.InMint] = consumed[v.InMint].add(v.in)
+		produced[v.OutMint] = produced[v.OutMint].add(v.out)
+	}
+
+	mints := make(map[string]bool)
+	for k := range consumed {
+		mints[k] = true
+	}
+	for k := range produced {
+		mints[k] = true
+	}
+
+	inMint, outMint := "", ""
+	inAmount, outAmount := tokenAmount{}, tokenAmount{}
+	for mint := range mints {
+		net := produced[mint].add(consumed[mint].neg())
+		if isRouteDust(net, produced[mint], consumed[mint]) {
+			continue
+		}
+
+		if net.sign() < 0 {
+			if inMint != "" {
+				return "", tokenAmount{}, "", tokenAmount{}, fmt.Errorf("route has more than one input mint")
+			}
+			inMint, inAmount = mint, net.neg()
+		} else {
+			if outMint != "" {
+				return "", tokenAmount{}, "", tokenAmount{}, fmt.Errorf("route has more than one output mint")
+			}
+			outMint, outAmount = mint, net
+		}
+	}
+
+	if inMint == "" || outMint == "" {
+		return "", tokenAmount{}, "", tokenAmount{}, fmt.Errorf("route has no net input or output")
+	}
+
+	return inMint, inAmount, outMint, outAmount, nil
+}
+
+// isRouteDust is true when net is at most routeDustRatio of the larger flow of the mint
+func isRouteDust(net, produced, consumed tokenAmount) bool {
+	flow := produced
+	if consumed.cmp(produced) > 0 {
+		flow = consumed
+	}
+	if flow.sign() == 0 {
+		return true
+	}
+
+	limit := new(big.Rat).Mul(flow.rat(), new(big.Rat).SetFloat64(routeDustRatio))
+	return new(big.Rat).Abs(net.rat()).Cmp(limit) <= 0
+}
+
+func routeModel(legs []routeLeg) []model.RouteLeg {
+	res := make([]model.RouteLeg, 0, len(legs))
+	for _, v := range legs {
+		res = append(res, v.RouteLeg)
+	}
+	return res
+}
+
+func userTokenAccount(in HeliusData, mint string, sent bool) string {
+	for _, v := range in.TokenTransfers {
+		if v.Mint != mint {
+			continue
+		}
+		if sent && v.FromUserAccount == in.FeePayer {
+			return v.FromTokenAccount
+		}
+		if !sent && v.ToUserAccount == in.FeePayer {
+			return v.ToTokenAccount
+		}
+	}
+
+	// native sol never touches a token account of the user
+	return in.FeePayer
+}
+
+// parseRoute only claims multi hop swaps, single hops are left to the dex decoders
+func parseRoute(in HeliusData) ([]model.SolSwapData, error) {
+	legs, ok := heliusRouteLegs(in)
+	if !ok || len(legs) < 2 {
+		decoded, err := decodedRouteLegs(in)
+		if err != nil {
+			return nil, err
+		}
+		legs = decoded
+	}
+
+	if len(legs) < 2 {
+		return nil, fmt.Errorf("not a multi hop route, %s", in.Signature)
+	}
+
+	inMint, inAmount, outMint, outAmount, err := normalizeRoute(legs)
+	if err != nil {
+		return nil, fmt.Errorf("normalize route %s failed, %v", in.Signature, err)
+	}
+
+	source := in.Source
+	if source == "" || source == "UNKNOWN" {
+		source = legs[0].Source
+	}
+
+	t := time.Unix(int64(in.Timestamp), 0)
+	timeString := t.Format("2006-01-02 15:04:05")
+
+	item := model.SolSwapData{
+		TxHash:    in.Signature,
+		Source:    source,
+		Timestamp: in.Timestamp,
+		Type:      "SWAP",
+		Date:      timeString,
+
+		FromToken:        inMint,
+		FromTokenAccount: userTokenAccount(in, inMint, true),
+		FromUserAccount:  in.FeePayer,
+
+		ToToken:         outMint,
+		ToTokenAccount:  userTokenAccount(in, outMint, false),
+		ToUserAccount:   in.FeePayer,
+		TradeLabel:      "",
+		IsDCATrade:      false,
+		WalletCounts:    1,
+		TransferDetails: make([]model.SolSwapData, 0),
+		Direction:       swapDirection(inMint, outMint),
+		Route:           routeModel(legs),
+	}
+	setFromAmount(&item, inAmount)
+	setToAmount(&item, outAmount)
+
+	return []model.SolSwapData{item}, nil
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"math"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func testLeg(inMint, in, outMint, out string) routeLeg {
+	return newRouteLeg(model.RouteLeg{InMint: inMint, OutMint: outMint}, uiAmount(in, -1), uiAmount(out, -1))
+}
+
+func TestNormalizeRoute(t *testing.T) {
+	// split route, half direct and half through an intermediate mint
+	legs := []routeLeg{
+		testLeg("USDC", "50", "BONK", "1000"),
+		testLeg("USDC", "50", "SOL", "0.3"),
+		testLeg("SOL", "0.3", "BONK", "990"),
+	}
+
+	inMint, inAmount, outMint, outAmount, err := normalizeRoute(legs)
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if inMint != "USDC" || inAmount.float() != 100 || outMint != "BONK" || outAmount.float() != 1990 {
+		t.Errorf("got %s %v -> %s %v", inMint, inAmount.float(), outMint, outAmount.float())
+	}
+
+	// a missing hop leaves two dangling mints on one side
+	_, _, _, _, err = normalizeRoute(legs[1:2])
+	if err != nil {
+		t.Errorf("single leg should normalize: %v", err)
+	}
+	_, _, _, _, err = normalizeRoute([]routeLeg{legs[0], legs[2]})
+	if err == nil {
+		t.Errorf("broken route should fail")
+	}
+
+	// amounts past float precision stay exact
+	legs = []routeLeg{
+		testLeg("SOL", "1", "MEME", "123456789012.345678901"),
+		testLeg("MEME", "23456789012.345678901", "USDC", "150"),
+	}
+	_, _, outMint, outAmount, err = normalizeRoute(legs)
+	if err == nil {
+		t.Errorf("two outputs should fail, got %s", outMint)
+	}
+	_, _, _, outAmount, _ = normalizeRoute(legs[:1])
+	if outAmount.value().String() != "123456789012345678901" || outAmount.decimals != 9 {
+		t.Errorf("got %s at %d decimals", outAmount.value(), outAmount.decimals)
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	cases := []struct {
+		fixture   string
+		user      string
+		inMint    string
+		inAmount  float64
+		inAccount string
+		outMint   string
+		outAmount float64
+		pools     []string
+	}{
+		{"route_helius_3hop.json", "GwJ3vK3qNhgzdVNWNQq2EytQokSNGQwGkBMbZ9qVkz7n",
+			USDCMint, 150, "6uBqexuxyWuWZXbowgCPUmap7Tpx7qe4f9KbLGvAMHqR",
+			"9A2mHY9KAvMsSszKYTk5Us9E5jM64Y1XqfJGdSesxn1k", 777.777, []string{"", "", ""}},
+		{"route_decoded_3hop.json", "GX1NmjxsxhD3G4F8UndhcV6kiQBN3YGd583U3U9r1MLU",
+			WSOLMint, 3, "HKCfeTJAimP1JwamEURSB77oHSmWK7fdBpiC9DX5dAXA",
+			"Hpc88Ab7qE7cLvQ7CMcZH96QDLAkWcafcrCSTyLGyRRY", 999,
+			[]string{"Hz8NDGy27evMYte3Wwteo9j99YCaXJxNBwPahP25surk", "DZZjVpTNBuLk1UTjs9JZ53PUj7wQmsfQEyfeqEHjzEp6", "AMCs1f1zr6EiQBksQreXcD6UYvRE85vrswd3fKnFpGmN"}},
+	}
+
+	for _, c := range cases {
+		res, err := parseRoute(loadHeliusFixture(t, c.fixture))
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", c.fixture, err)
+			continue
+		}
+
+		if len(res) != 1 {
+			t.Errorf("%s: got %d swaps, want 1", c.fixture, len(res))
+			continue
+		}
+
+		v := res[0]
+		if v.FromUserAccount != c.user || v.ToUserAccount != c.user || v.FromTokenAccount != c.inAccount {
+			t.Errorf("%s: got user %s/%s account %s", c.fixture, v.FromUserAccount, v.ToUserAccount, v.FromTokenAccount)
+		}
+		if v.FromToken != c.inMint || v.ToToken != c.outMint {
+			t.Errorf("%s: got mints %s -> %s", c.fixture, v.FromToken, v.ToToken)
+		}
+		if math.Abs(v.FromTokenAmount-c.inAmount) > 1e-9 || math.Abs(v.ToTokenAmount-c.outAmount) > 1e-9 {
+			t.Errorf("%s: got amounts %v -> %v", c.fixture, v.FromTokenAmount, v.ToTokenAmount)
+		}
+		if len(v.Route) != 3 {
+			t.Errorf("%s: got %d legs, want 3", c.fixture, len(v.Route))
+			continue
+		}
+		for i, pool := range c.pools {
+			if v.Route[i].Pool != pool {
+				t.Errorf("%s: leg %d got pool %s, want %s", c.fixture, i, v.Route[i].Pool, pool)
+			}
+		}
+	}
+
+	// a single hop is left to the dex decoders
+	if _, err := parseRoute(loadHeliusFixture(t, "raydium_amm_v4_buy.json")); err == nil {
+		t.Errorf("single hop should not be claimed by the route parser")
+	}
+}
+
+func TestHeliusRouteLegPools(t *testing.T) {
+	in := loadHeliusFixture(t, "route_decoded_3hop.json")
+	decoded, err := decodeSwapLegs(in)
+	if err != nil || len(decoded) != 3 {
+		t.Fatalf("decode failed: %d legs, %v", len(decoded), err)
+	}
+
+	// the same legs as helius resolves them, the input goes to a vault authority and not the pool
+	for _, v := range decoded {
+		in.Events.Swap.InnerSwaps = append(in.Events.Swap.InnerSwaps, InnerSwapData{
+			ProgramInfo:  ProgramInfo{Account: v.Program, Source: v.Data.Source},
+			TokenInputs:  []TokenDetails{{Mint: v.Data.FromToken, TokenAmount: v.Data.FromTokenAmount, ToUserAccount: "vault authority"}},
+			TokenOutputs: []TokenDetails{{Mint: v.Data.ToToken, TokenAmount: v.Data.ToTokenAmount}},
+		})
+	}
+
+	legs, ok := heliusRouteLegs(in)
+	if !ok || len(legs) != 3 {
+		t.Fatalf("got %d helius legs, ok %v", len(legs), ok)
+	}
+	for i, v := range legs {
+		if v.Pool != decoded[i].Data.Pool {
+			t.Errorf("leg %d got pool %s, want %s", i, v.Pool, decoded[i].Data.Pool)
+		}
+	}
+
+	// one more leg than decoded swaps of its program can not be matched, its program leaves the pool empty
+	in.Events.Swap.InnerSwaps = append(in.Events.Swap.InnerSwaps, in.Events.Swap.InnerSwaps[0])
+	legs, _ = heliusRouteLegs(in)
+	for i, v := range legs {
+		if v.Program == decoded[0].Program && v.Pool != "" {
+			t.Errorf("leg %d got pool %s, want empty", i, v.Pool)
+		}
+	}
+}
+
+func TestRouteCandidate(t *testing.T) {
+	isCandidate := func(v HeliusData) bool {
+		for _, p := range parsers.candidates(v) {
+			if p.Name() == "route" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// a tx without an aggregator never reaches the leg decoders and their pool lookups
+	if isCandidate(loadHeliusFixture(t, "orca_whirlpool_buy.json")) {
+		t.Errorf("direct dex swap is a route candidate")
+	}
+	if !isCandidate(loadHeliusFixture(t, "route_decoded_3hop.json")) {
+		t.Errorf("jupiter route is not a route candidate")
+	}
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"sync"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
+	"github.com/thescopedao/solana_dex_subscribe/solrpc"
+)
+
+var (
+	rpcOnce   sync.Once
+	rpcClient *solrpc.Client
+	rpcErr    error
+)
+
+// getRPCClient builds the solana rpc client every lookup of the producer goes through, the
+// endpoint list is read once so the health scores live as long as the process
+func getRPCClient() (*solrpc.Client, error) {
+	rpcOnce.Do(func() {
+		cfg := config.GetRPCConfig()
+		if len(cfg.Endpoints) == 0 {
+			cfg.Endpoints = []solrpc.Endpoint{{URL: "https://mainnet.helius-rpc.com/?api-key=" + config.GetHeliusConfig().APIKey}}
+		}
+		rpcClient, rpcErr = solrpc.New(cfg)
+	})
+	return rpcClient, rpcErr
+}
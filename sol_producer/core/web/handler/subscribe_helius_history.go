@@ -2,27 +2,34 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"sort"
-	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/sirupsen/logrus"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/config"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/alikafka"
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/dedup"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
 	"github.com/thescopedao/solana_dex_subscribe/sol_producer/utils/logger"
+	"github.com/thescopedao/solana_dex_subscribe/solmsg"
 )
 
-func getBeforeHistoryTxs(addr, txhash string) ([]HeliusData, string, error) {
+// heliusAPIHost is swapped for a local server in tests
+var heliusAPIHost = "https://api.helius.xyz"
+
+var ErrHeliusRateLimited = errors.New("helius rate limited")
+
+// getBeforeHistoryTxs returns one page newest first and the oldest signature of it, the cursor of the next page
+func getBeforeHistoryTxs(addr, txhash string, limit int) ([]HeliusData, string, error) {
 	apiKey := config.GetHeliusConfig().APIKey
 
-	url := fmt.Sprintf("https://api.helius.xyz/v0/addresses/%s/transactions?api-key=%s&limit=50", addr, apiKey)
+	url := fmt.Sprintf("%s/v0/addresses/%s/transactions?api-key=%s&limit=%d", heliusAPIHost, addr, apiKey, limit)
 
 	if txhash != "" {
-		url = fmt.Sprintf("https://api.helius.xyz/v0/addresses/%s/transactions?api-key=%s&before=%s&limit=50", addr, apiKey, txhash)
+		url = fmt.Sprintf("%s/v0/addresses/%s/transactions?api-key=%s&before=%s&limit=%d", heliusAPIHost, addr, apiKey, txhash, limit)
 	}
 	method := "GET"
 
@@ -38,6 +45,9 @@ func getBeforeHistoryTxs(addr, txhash string) ([]HeliusData, string, error) {
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusTooManyRequests {
+		return nil, "", ErrHeliusRateLimited
+	}
 	if res.StatusCode != 200 {
 		return nil, "", fmt.Errorf("status, %v", res.Status)
 	}
@@ -65,172 +75,99 @@ func getBeforeHistoryTxs(addr, txhash string) ([]HeliusData, string, error) {
 	return result, firsttxhash, nil
 }
 
-func getHistoryTxs(addr string) ([]HeliusData, error) {
-	result := make([]HeliusData, 0)
-
-	firsttxhash := ""
-	for i := 0; i < 2; i++ {
-		tmpData, tmptxhash, err := getBeforeHistoryTxs(addr, firsttxhash)
-		if err != nil {
-			time.Sleep(1 * time.Second)
-			tmpData, tmptxhash, err = getBeforeHistoryTxs(addr, firsttxhash)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"Index": i, "Data": addr, "ErrMsg": err, "FirstHash": firsttxhash}).Error("HandleHeliusData getHistoryTxs failed")
-				return result, nil
-			}
-		}
-
-		if tmptxhash == "" || len(tmpData) == 0 {
-			break
-		}
-
-		result = append(result, tmpData...)
-
-		firsttxhash = tmptxhash
-	}
-
-	return result, nil
-}
-
 func sendHistoryKafkaMsg(in []model.SolSwapData) error {
-	keyStr := "default"
-	if len(in) > 0 {
-		keyStr = in[0].TxHash
-	} else {
+	if len(in) == 0 {
 		return nil
 	}
 
-	data, err := json.Marshal(&in)
+	data, headers, err := solmsg.Marshal(solmsg.SolSwapBatch, in)
 	if err != nil {
 		return err
 	}
 
 	cfg := config.GetKafkaConfig()
-	err = alikafka.GetKafkaHistoryInst().Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: &cfg.HistoryTopic, Partition: kafka.PartitionAny},
-		Key:            []byte(keyStr),
-		Value:          []byte(data),
-	}, nil)
-	if err != nil {
-		return fmt.Errorf("failed to produce message: %w", err)
-	}
-
-	alikafka.GetKafkaHistoryInst().Flush(15000)
-
-	return nil
+	return publish(alikafka.GetKafkaHistoryInst(), cfg.HistoryTopic, walletKey(in), data, headers)
 }
 
 func handHisSwapData(v HeliusData) error {
-	if v.Source == "PUMP_FUN" {
-		var datait []model.SolSwapData
-		var err error
-		if v.Type == "CREATE" {
-			datait, err = parsePumpFunCreate(v)
-			if err != nil {
-				return fmt.Errorf("parse pump.fun create failed, %v", err)
-			}
-		} else {
-			datait, err = parseGobleDEX(v)
-			if err != nil {
-				datait, err = parsePumpFun(v)
-				if err != nil {
-					return fmt.Errorf("parse pump.fun failed, %v", err)
-				}
-			}
-		}
-
-		datalist := FillLabel(datait)
-
-		err = sendHistoryKafkaMsg(datalist)
-		if err != nil {
-			return fmt.Errorf("send pump.fun kafka failed, %v", err)
-		}
-
-		logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handSwapData send pump.fun kafka data")
-	} else {
-		datait, err := generateSwapData(v)
-		if err != nil {
-			datait, err = parseGobleDEX(v)
-			if err != nil {
-				return fmt.Errorf("parse swap failed, %v", err)
-			}
-		}
-
-		datalist := FillLabel(datait)
+	datait, err := ParseHeliusData(v)
+	if err != nil {
+		return fmt.Errorf("%w, %v", ErrUnparsed, err)
+	}
 
-		err = sendHistoryKafkaMsg(datalist)
-		if err != nil {
-			return fmt.Errorf("send kafka failed, %v", err)
-		}
+	datait = FillPoolParams(v, datait)
+	datalist := FillHistoryLabel(datait)
 
-		logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handSwapData send swap kafka data")
+	err = sendHistoryKafkaMsg(datalist)
+	if err != nil {
+		return fmt.Errorf("send kafka failed, %v", err)
 	}
 
+	logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handHisSwapData send swap kafka data")
+
 	return nil
 }
 
 func handHisTransferData(v HeliusData) error {
-	datait, err := parseGobleDEX(v)
+	datait, err := ParseHeliusData(v)
 	if err != nil {
-		datait, err = parseCommonTransfer(v)
-		if err != nil {
-			return fmt.Errorf("parse transfer data, %v", err)
-		}
+		return fmt.Errorf("%w, %v", ErrUnparsed, err)
 	}
 
-	datalist := FillLabel(datait)
+	datait = FillPoolParams(v, datait)
+	datalist := FillHistoryLabel(datait)
 
 	err = sendHistoryKafkaMsg(datalist)
 	if err != nil {
 		return fmt.Errorf("send kafka failed, %v", err)
 	}
 
-	logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handTransferData send transfer kafka data")
+	logger.Logrus.WithFields(logrus.Fields{"Data": datalist}).Info("handHisTransferData send transfer kafka data")
 
 	return nil
 }
 
-func HandleHistoryData(addr string) error {
-	in, err := getHistoryTxs(addr)
-	if err != nil {
-		return err
-	}
+// handleHistoryTx parses one history transaction onto the history topic, the error is a failed
+// publish. a transaction no parser takes is skipped
+func handleHistoryTx(v HeliusData) error {
+	logger.Logrus.WithFields(logrus.Fields{"Data": v}).Info("handleHistoryTx raw data")
 
-	if len(in) < 1 {
+	// claimed per signature like the live path, a failed publish releases it for the retry
+	if !dedup.Claim(dedup.ScopeHistory, v.Signature) {
+		logger.Logrus.WithFields(logrus.Fields{"TxHash": v.Signature}).Info("handleHistoryTx skip duplicate signature")
 		return nil
 	}
 
-	for _, v := range in {
-		logger.Logrus.WithFields(logrus.Fields{"Data": v}).Info("HandleHistoryData raw data")
-
-		if v.Type == "SWAP" || v.Type == "CREATE" {
-			err := handHisSwapData(v)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"Data": v, "ErrMsg": err}).Error("handHisSwapData handle swap data failed")
-
-				continue
-			}
-		} else {
-			err := handHisTransferData(v)
-			if err != nil {
-				logger.Logrus.WithFields(logrus.Fields{"Data": v, "ErrMsg": err}).Error("handHisTransferData handle transfer data failed")
-				continue
-			}
-		}
+	var err error
+	if v.Type == "SWAP" || v.Type == "CREATE" {
+		err = handHisSwapData(v)
+	} else {
+		err = handHisTransferData(v)
+	}
+	if errors.Is(err, ErrUnparsed) {
+		dedup.Release(dedup.ScopeHistory, v.Signature)
+		logger.Logrus.WithFields(logrus.Fields{"Data": v, "ErrMsg": err}).Info("handleHistoryTx skip unparsed tx")
+		return nil
+	}
+	if err != nil {
+		dedup.Release(dedup.ScopeHistory, v.Signature)
+		logger.Logrus.WithFields(logrus.Fields{"Data": v, "ErrMsg": err}).Error("handleHistoryTx handle data failed")
+		return err
 	}
-
 	return nil
 }
 
+// SubAddrHistoryTxs queues a backfill job for every address message, jobs left over from the
+// last run are resumed from their cursor first
 func SubAddrHistoryTxs() {
+	b := StartBackfiller()
+
 	go func() {
 		cfg := config.GetKafkaConfig()
 		consumer := alikafka.GetKafkaAddrInst()
 
 		consumer.SubscribeTopics([]string{cfg.AddrTopic}, nil)
 
-		semaphore := make(chan struct{}, 2000)
-
 		for {
 			msg, err := consumer.ReadMessage(-1)
 			if err != nil {
@@ -250,22 +187,13 @@ func SubAddrHistoryTxs() {
 				continue
 			}
 
-			address := res.Address
-
-			semaphore <- struct{}{}
-
-			go func(addr string) {
-				defer func() { <-semaphore }()
-
-				logger.Logrus.WithFields(logrus.Fields{"Data": addr}).Info("SubAddrHistoryTxs receive kafka message success")
-				err := HandleHistoryData(addr)
-				if err != nil {
-					logger.Logrus.WithFields(logrus.Fields{"Address": addr, "ErrMsg": err}).Error("SubAddrHistoryTxs HandleHistoryData failed")
-					return
-				}
+			job, err := b.Submit(res.Address, 0, 0)
+			if err != nil {
+				logger.Logrus.WithFields(logrus.Fields{"Address": res.Address, "ErrMsg": err}).Error("SubAddrHistoryTxs queue backfill failed")
+				continue
+			}
 
-				logger.Logrus.WithFields(logrus.Fields{"Address": addr}).Info("SubAddrHistoryTxs HandleHistoryData success")
-			}(address)
+			logger.Logrus.WithFields(logrus.Fields{"Address": res.Address, "JobID": job.ID, "Status": job.Status}).Info("SubAddrHistoryTxs queue backfill success")
 		}
 	}()
 }
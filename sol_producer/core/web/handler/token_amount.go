@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+// maxScale bounds the decimals a literal without a known mint is read with
+const maxScale = 36
+
+// tokenAmount is an exact amount, raw units of 10^-decimals
+type tokenAmount struct {
+	raw      *big.Int
+	decimals int
+}
+
+// rawAmount reads base units, anything that is not an integer is zero
+func rawAmount(raw string, decimals int) tokenAmount {
+	v, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		v = new(big.Int)
+	}
+	return tokenAmount{raw: v, decimals: decimals}
+}
+
+func uintAmount(raw uint64, decimals int) tokenAmount {
+	return tokenAmount{raw: new(big.Int).SetUint64(raw), decimals: decimals}
+}
+
+// uiAmount reads a decimal literal such as the tokenAmount of helius. it is scaled to decimals
+// when that is exact, a negative decimals or a literal finer than it keeps the literal's own scale
+func uiAmount(text string, decimals int) tokenAmount {
+	r, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return tokenAmount{raw: new(big.Int), decimals: max(decimals, 0)}
+	}
+
+	for scale := max(decimals, 0); scale <= maxScale; scale++ {
+		v := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(scale)))
+		if v.IsInt() {
+			return tokenAmount{raw: v.Num(), decimals: scale}
+		}
+	}
+
+	// not a finite decimal, rounded at the finest scale
+	v := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(maxScale)))
+	return tokenAmount{raw: new(big.Int).Quo(v.Num(), v.Denom()), decimals: maxScale}
+}
+
+// floatAmount is the fallback for amounts only known as a float, the shortest literal of it
+func floatAmount(v float64, decimals int) tokenAmount {
+	return uiAmount(strconv.FormatFloat(v, 'f', -1, 64), decimals)
+}
+
+// pow10 is 1 for a negative n, an amount of unknown decimals reads as base units
+func pow10(n int) *big.Int {
+	if n <= 0 {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (a tokenAmount) value() *big.Int {
+	if a.raw == nil {
+		return new(big.Int)
+	}
+	return a.raw
+}
+
+// rescale only goes up, a finer amount is never rounded
+func (a tokenAmount) rescale(decimals int) tokenAmount {
+	if decimals <= a.decimals {
+		return a
+	}
+	raw := new(big.Int).Mul(a.value(), pow10(decimals-a.decimals))
+	return tokenAmount{raw: raw, decimals: decimals}
+}
+
+func (a tokenAmount) add(b tokenAmount) tokenAmount {
+	scale := max(a.decimals, b.decimals)
+	a, b = a.rescale(scale), b.rescale(scale)
+	return tokenAmount{raw: new(big.Int).Add(a.value(), b.value()), decimals: scale}
+}
+
+func (a tokenAmount) neg() tokenAmount {
+	return tokenAmount{raw: new(big.Int).Neg(a.value()), decimals: a.decimals}
+}
+
+func (a tokenAmount) sign() int {
+	return a.value().Sign()
+}
+
+func (a tokenAmount) rat() *big.Rat {
+	return new(big.Rat).SetFrac(a.value(), pow10(a.decimals))
+}
+
+func (a tokenAmount) cmp(b tokenAmount) int {
+	return a.rat().Cmp(b.rat())
+}
+
+// text is the exact decimal literal of the amount
+func (a tokenAmount) text() string {
+	return a.rat().FloatString(max(a.decimals, 0))
+}
+
+func (a tokenAmount) float() float64 {
+	res, _ := new(big.Rat).SetFrac(a.value(), pow10(a.decimals)).Float64()
+	return res
+}
+
+// fromAmountOf is the exact from amount of a parsed record, the float one for records without it
+func fromAmountOf(v model.SolSwapData) tokenAmount {
+	if v.FromTokenRawAmount == "" {
+		return floatAmount(v.FromTokenAmount, -1)
+	}
+	return rawAmount(v.FromTokenRawAmount, v.FromTokenDecimals)
+}
+
+func toAmountOf(v model.SolSwapData) tokenAmount {
+	if v.ToTokenRawAmount == "" {
+		return floatAmount(v.ToTokenAmount, -1)
+	}
+	return rawAmount(v.ToTokenRawAmount, v.ToTokenDecimals)
+}
+
+func setFromAmount(v *model.SolSwapData, a tokenAmount) {
+	v.FromTokenAmount = a.float()
+	v.FromTokenRawAmount = a.value().String()
+	v.FromTokenDecimals = a.decimals
+}
+
+func setToAmount(v *model.SolSwapData, a tokenAmount) {
+	v.ToTokenAmount = a.float()
+	v.ToTokenRawAmount = a.value().String()
+	v.ToTokenDecimals = a.decimals
+}
+
+// transferAmount is the exact amount of a helius token transfer
+func transferAmount(in HeliusData, t TokenDetails) tokenAmount {
+	text := t.amountText
+	if text == "" {
+		text = strconv.FormatFloat(t.TokenAmount, 'f', -1, 64)
+	}
+	return uiAmount(text, mintDecimals(in, t.Mint, 0))
+}
+
+// UnmarshalJSON keeps the literal of tokenAmount, the float of it is not exact
+func (t *TokenDetails) UnmarshalJSON(data []byte) error {
+	type plain TokenDetails
+	var v struct {
+		plain
+		TokenAmount json.Number `json:"tokenAmount"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*t = TokenDetails(v.plain)
+	t.amountText = v.TokenAmount.String()
+	if t.amountText != "" {
+		f, err := v.TokenAmount.Float64()
+		if err != nil {
+			return err
+		}
+		t.TokenAmount = f
+	}
+	return nil
+}
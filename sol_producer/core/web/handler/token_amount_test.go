@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/thescopedao/solana_dex_subscribe/sol_producer/core/model"
+)
+
+func TestUIAmount(t *testing.T) {
+	cases := []struct {
+		text     string
+		decimals int
+		raw      string
+		scale    int
+	}{
+		{"1.5", 9, "1500000000", 9},
+		{"0.1", -1, "1", 1},
+		{"123456789.123456789", 9, "123456789123456789", 9},
+		// finer than the mint, the literal scale is kept
+		{"0.0000000001", 9, "1", 10},
+		{"1e-3", 6, "1000", 6},
+		{"abc", 6, "0", 6},
+	}
+
+	for _, c := range cases {
+		a := uiAmount(c.text, c.decimals)
+		if a.value().String() != c.raw || a.decimals != c.scale {
+			t.Errorf("%s: got %s at %d", c.text, a.value(), a.decimals)
+		}
+	}
+}
+
+func TestTokenAmountSum(t *testing.T) {
+	// the float sum of these is 0.30000000000000004
+	sum := uiAmount("0.1", 9).add(uiAmount("0.2", 9))
+	if sum.cmp(uiAmount("0.3", 9)) != 0 || sum.text() != "0.300000000" {
+		t.Errorf("got %s", sum.text())
+	}
+
+	net := uintAmount(5, 0).add(uiAmount("7.25", 2).neg())
+	if net.sign() >= 0 || net.neg().text() != "2.25" {
+		t.Errorf("got %s", net.text())
+	}
+
+	var v model.SolSwapData
+	setFromAmount(&v, rawAmount("18446744073709551617", 6))
+	if v.FromTokenRawAmount != "18446744073709551617" || v.FromTokenDecimals != 6 || fromAmountOf(v).cmp(rawAmount("18446744073709551617", 6)) != 0 {
+		t.Errorf("got %+v", v)
+	}
+
+	// records without the raw amount fall back to the float
+	if toAmountOf(model.SolSwapData{ToTokenAmount: 0.25}).text() != "0.25" {
+		t.Errorf("float fallback")
+	}
+}
+
+func TestTokenDetailsAmount(t *testing.T) {
+	var in HeliusData
+	data := `{"tokenTransfers":[{"mint":"m","tokenAmount":12345678.123456789}],"accountData":[{"tokenBalanceChanges":[{"mint":"m","rawTokenAmount":{"decimals":9}}]}]}`
+	if err := json.Unmarshal([]byte(data), &in); err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := in.TokenTransfers[0]
+	if transfer.TokenAmount != 12345678.123456789 {
+		t.Errorf("float %v", transfer.TokenAmount)
+	}
+
+	a := transferAmount(in, transfer)
+	if a.value().String() != "12345678123456789" || a.decimals != 9 {
+		t.Errorf("got %s at %d", a.value(), a.decimals)
+	}
+}
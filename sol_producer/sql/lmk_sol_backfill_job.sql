@@ -0,0 +1,29 @@
+-- history backfill jobs and their cursors, see core/web/handler/history_backfill.go and
+-- model.BackfillJob
+CREATE TABLE IF NOT EXISTS lmk_sol_backfill_job (
+    id               BIGSERIAL PRIMARY KEY,
+    address          TEXT NOT NULL,
+    status           TEXT NOT NULL,
+    before_sig       TEXT NOT NULL DEFAULT '',
+    oldest_timestamp BIGINT NOT NULL DEFAULT 0,
+    target_txs       INTEGER NOT NULL DEFAULT 0,
+    target_timestamp BIGINT NOT NULL DEFAULT 0,
+    fetched          INTEGER NOT NULL DEFAULT 0,
+    pages            INTEGER NOT NULL DEFAULT 0,
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    error            TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL,
+    updated_at       TIMESTAMPTZ NOT NULL
+);
+
+-- List by status newest first, the resume on start
+CREATE INDEX IF NOT EXISTS lmk_sol_backfill_job_status_idx
+    ON lmk_sol_backfill_job (status, id);
+
+-- List and Active by address
+CREATE INDEX IF NOT EXISTS lmk_sol_backfill_job_address_idx
+    ON lmk_sol_backfill_job (address, status);
+
+-- one queued or running job per address, also across producers
+CREATE UNIQUE INDEX IF NOT EXISTS lmk_sol_backfill_job_active_idx
+    ON lmk_sol_backfill_job (address) WHERE status IN ('queued', 'running');
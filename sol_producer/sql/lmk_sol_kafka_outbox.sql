@@ -0,0 +1,27 @@
+-- the postgres outbox backend, see core/outbox and model.KafkaOutboxRecord. the outbox keeps the
+-- parsed batches until kafka acks them
+CREATE TABLE IF NOT EXISTS lmk_sol_kafka_outbox (
+    id        BIGSERIAL PRIMARY KEY,
+    topic     TEXT NOT NULL,
+    key       TEXT NOT NULL DEFAULT '',
+    value     JSONB NOT NULL,
+    headers   JSONB,
+    attempts  INTEGER NOT NULL DEFAULT 0,
+    create_at TIMESTAMPTZ
+);
+
+-- the relay sends a key in id order
+CREATE INDEX IF NOT EXISTS lmk_sol_kafka_outbox_key_idx
+    ON lmk_sol_kafka_outbox (key, id);
+
+-- the records the relay gave up on after OutboxConfig.MaxAttempts. the columns are in the order
+-- of the outbox, the move is an INSERT ... SELECT *
+CREATE TABLE IF NOT EXISTS lmk_sol_kafka_outbox_dead (
+    id        BIGINT PRIMARY KEY,
+    topic     TEXT NOT NULL,
+    key       TEXT NOT NULL DEFAULT '',
+    value     JSONB NOT NULL,
+    headers   JSONB,
+    attempts  INTEGER NOT NULL DEFAULT 0,
+    create_at TIMESTAMPTZ
+);
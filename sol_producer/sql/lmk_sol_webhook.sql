@@ -0,0 +1,16 @@
+-- the helius webhooks the producer owns and the tracked addresses each one watches, see
+-- core/track/webhook_store.go and model.WebhookRecord, model.WebhookAddressRecord
+CREATE TABLE IF NOT EXISTS lmk_sol_webhook (
+    webhook_id TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- an address is on one webhook, the primary key is the conflict target of Assign
+CREATE TABLE IF NOT EXISTS lmk_sol_webhook_address (
+    address    TEXT PRIMARY KEY,
+    webhook_id TEXT NOT NULL
+);
+
+-- Assign and DeleteWebhook remove by webhook
+CREATE INDEX IF NOT EXISTS lmk_sol_webhook_address_webhook_id_idx
+    ON lmk_sol_webhook_address (webhook_id, address);
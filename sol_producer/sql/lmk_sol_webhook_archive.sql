@@ -0,0 +1,10 @@
+-- raw helius webhook batches kept for replay, see core/archive and model.WebhookArchiveRecord.
+-- replay scans a received_at range in id order
+CREATE TABLE IF NOT EXISTS lmk_sol_webhook_archive (
+    id          BIGSERIAL PRIMARY KEY,
+    received_at TIMESTAMPTZ NOT NULL,
+    payload     JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS lmk_sol_webhook_archive_received_at_idx
+    ON lmk_sol_webhook_archive (received_at, id);
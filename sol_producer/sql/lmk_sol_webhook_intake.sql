@@ -0,0 +1,12 @@
+-- the intake journal of the postgres outbox backend, see core/outbox and the Enricher. a webhook
+-- transaction is in it before helius gets the ack and is removed once handled, the rows have the
+-- layout of lmk_sol_kafka_outbox
+CREATE TABLE IF NOT EXISTS lmk_sol_webhook_intake (
+    id        BIGSERIAL PRIMARY KEY,
+    topic     TEXT NOT NULL,
+    key       TEXT NOT NULL DEFAULT '',
+    value     JSONB NOT NULL,
+    headers   JSONB,
+    attempts  INTEGER NOT NULL DEFAULT 0,
+    create_at TIMESTAMPTZ
+);
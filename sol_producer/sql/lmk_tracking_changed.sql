@@ -0,0 +1,22 @@
+-- notifies lmk_tracking_changed on every change of a tracked address, see track.TrackingChannel.
+-- install it in the database of scope_lmk.lmk_address_monitor, the producer and the consumer
+-- listen there. the producer syncs its whole list on it, the consumer refreshes the cache of the
+-- named address. a change the consumer view sees without a row change here waits for the 10
+-- minute reload. evm addresses are not notified, neither side reads them from this channel
+CREATE OR REPLACE FUNCTION scope_lmk.lmk_notify_tracking_changed() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.address NOT LIKE '0x%' THEN
+        PERFORM pg_notify('lmk_tracking_changed', json_build_object('chain', 'solana', 'address', OLD.address)::text);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.address NOT LIKE '0x%' THEN
+        -- pg_notify drops a payload sent twice in one transaction, an update in place is one
+        PERFORM pg_notify('lmk_tracking_changed', json_build_object('chain', 'solana', 'address', NEW.address)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS lmk_tracking_changed ON scope_lmk.lmk_address_monitor;
+CREATE TRIGGER lmk_tracking_changed
+    AFTER INSERT OR UPDATE OF address OR DELETE ON scope_lmk.lmk_address_monitor
+    FOR EACH ROW EXECUTE FUNCTION scope_lmk.lmk_notify_tracking_changed();
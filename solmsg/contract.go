@@ -0,0 +1,172 @@
+package solmsg
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	HeaderSchema  = "schema"
+	HeaderVersion = "schema-version"
+)
+
+// the contracts, one per topic payload
+const (
+	SolSwapBatch  = "sol_swap_batch"
+	BitqueryTrade = "bitquery_trade"
+	TopicData     = "topic_data"
+	TgBotMessage  = "tg_message"
+)
+
+// versions holds the current version of each contract. a reader accepts it and the one before,
+// a message without the version header predates the headers and reads as version 1
+var versions = map[string]int{
+	// 2 adds the exact raw amounts and decimals of both sides
+	SolSwapBatch:  2,
+	BitqueryTrade: 1,
+	TopicData:     1,
+	TgBotMessage:  1,
+}
+
+var ErrUnknownSchema = errors.New("unknown schema")
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+var ErrInvalidMessage = errors.New("message does not match its schema")
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+var schemaCache sync.Map
+
+// Header has the shape of a kafka header, a kafka.Header converts to and from it
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Version is the version a writer of the contract sends, 0 for an unknown one
+func Version(name string) int {
+	return versions[name]
+}
+
+// Headers names the contract and its current version
+func Headers(name string) []Header {
+	return []Header{
+		{Key: HeaderSchema, Value: []byte(name)},
+		{Key: HeaderVersion, Value: []byte(strconv.Itoa(Version(name)))},
+	}
+}
+
+func schemaOf(name string, version int) (*gojsonschema.Schema, error) {
+	if _, ok := versions[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSchema, name)
+	}
+
+	file := fmt.Sprintf("schemas/%s.v%d.json", name, version)
+	if v, ok := schemaCache.Load(file); ok {
+		return v.(*gojsonschema.Schema), nil
+	}
+
+	data, err := schemaFiles.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnsupportedVersion, name, version)
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", file, err)
+	}
+
+	schemaCache.Store(file, schema)
+	return schema, nil
+}
+
+// Validate checks data against one version of the contract
+func Validate(name string, version int, data []byte) error {
+	schema, err := schemaOf(name, version)
+	if err != nil {
+		return err
+	}
+
+	res, err := schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if res.Valid() {
+		return nil
+	}
+
+	details := make([]string, 0)
+	for i, v := range res.Errors() {
+		if i == 5 {
+			details = append(details, fmt.Sprintf("%d more", len(res.Errors())-i))
+			break
+		}
+		details = append(details, v.String())
+	}
+	return fmt.Errorf("%w: %s v%d: %s", ErrInvalidMessage, name, version, strings.Join(details, "; "))
+}
+
+// Marshal encodes v as the current version of the contract, a payload the schema refuses is not
+// returned. the headers go on the kafka message
+func Marshal(name string, v any) ([]byte, []Header, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = Validate(name, Version(name), data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, Headers(name), nil
+}
+
+// Unmarshal checks data against the version its headers name and decodes it into v, the
+// version read is returned
+func Unmarshal(name string, headers []Header, data []byte, v any) (int, error) {
+	version, err := versionOf(name, headers)
+	if err != nil {
+		return 0, err
+	}
+
+	err = Validate(name, version, data)
+	if err != nil {
+		return version, err
+	}
+	return version, json.Unmarshal(data, v)
+}
+
+func versionOf(name string, headers []Header) (int, error) {
+	current, ok := versions[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownSchema, name)
+	}
+
+	version := 1
+	for _, h := range headers {
+		switch h.Key {
+		case HeaderSchema:
+			if string(h.Value) != name {
+				return 0, fmt.Errorf("%w: got %s, want %s", ErrUnknownSchema, h.Value, name)
+			}
+		case HeaderVersion:
+			v, err := strconv.Atoi(string(h.Value))
+			if err != nil {
+				return 0, fmt.Errorf("%w: %q", ErrUnsupportedVersion, h.Value)
+			}
+			version = v
+		}
+	}
+
+	if version < max(current-1, 1) || version > current {
+		return 0, fmt.Errorf("%w: %s v%d, reading v%d to v%d", ErrUnsupportedVersion, name, version, max(current-1, 1), current)
+	}
+	return version, nil
+}
@@ -0,0 +1,166 @@
+package solmsg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var contractTypes = map[string]reflect.Type{
+	SolSwapBatch:  reflect.TypeOf([]SolSwapData{}),
+	BitqueryTrade: reflect.TypeOf(RawBitqueryAltertData{}),
+	TopicData:     reflect.TypeOf(RawTopicData{}),
+	TgBotMessage:  reflect.TypeOf(TgMessage{}),
+}
+
+// TestSchemaMatchesTypes fails when a field is added to a payload type without the schema of
+// the current version, or the other way around
+func TestSchemaMatchesTypes(t *testing.T) {
+	for name, typ := range contractTypes {
+		data, err := schemaFiles.ReadFile(fmt.Sprintf("schemas/%s.v%d.json", name, Version(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var root map[string]any
+		if err := json.Unmarshal(data, &root); err != nil {
+			t.Fatal(err)
+		}
+
+		definitions, _ := root["definitions"].(map[string]any)
+		compareNode(t, name, typ, root, definitions, make(map[reflect.Type]bool))
+	}
+}
+
+func compareNode(t *testing.T, path string, typ reflect.Type, node, definitions map[string]any, seen map[reflect.Type]bool) {
+	if ref, ok := node["$ref"].(string); ok {
+		node, _ = definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]any)
+		if node == nil {
+			t.Errorf("%s: dangling %s", path, ref)
+			return
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer:
+		compareNode(t, path, typ.Elem(), node, definitions, seen)
+	case reflect.Slice:
+		items, _ := node["items"].(map[string]any)
+		if items == nil {
+			t.Errorf("%s: schema has no items", path)
+			return
+		}
+		compareNode(t, path+"[]", typ.Elem(), items, definitions, seen)
+	case reflect.Struct:
+		if seen[typ] {
+			return
+		}
+		seen[typ] = true
+
+		props, _ := node["properties"].(map[string]any)
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < typ.NumField(); i++ {
+			tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			if tag == "" || tag == "-" {
+				continue
+			}
+			fields[tag] = typ.Field(i).Type
+		}
+
+		if got, want := keys(props), keys(fields); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: schema has %v, type has %v", path, got, want)
+			return
+		}
+		for tag, ft := range fields {
+			sub, _ := props[tag].(map[string]any)
+			compareNode(t, path+"."+tag, ft, sub, definitions, seen)
+		}
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// TestGoldenMessages keeps a message of every version a reader accepts, dropping the schema of
+// the version before the current one fails here
+func TestGoldenMessages(t *testing.T) {
+	for name, typ := range contractTypes {
+		for version := max(Version(name)-1, 1); version <= Version(name); version++ {
+			data, err := os.ReadFile(fmt.Sprintf("testdata/%s.v%d.json", name, version))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			headers := []Header{{Key: HeaderSchema, Value: []byte(name)}, {Key: HeaderVersion, Value: []byte(fmt.Sprint(version))}}
+			v := reflect.New(typ).Interface()
+			got, err := Unmarshal(name, headers, data, v)
+			if err != nil || got != version {
+				t.Errorf("%s v%d: got v%d, %v", name, version, got, err)
+			}
+		}
+	}
+}
+
+func TestSwapVersions(t *testing.T) {
+	var legacy []SolSwapData
+	data, err := os.ReadFile("testdata/sol_swap_batch.v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a message without headers is from before them
+	version, err := Unmarshal(SolSwapBatch, nil, data, &legacy)
+	if err != nil || version != 1 || legacy[0].FromTokenAmount != 1.5 {
+		t.Fatalf("got v%d %+v, %v", version, legacy, err)
+	}
+
+	// written as the current version, headers included
+	legacy[0].FromTokenRawAmount, legacy[0].FromTokenDecimals = "1500000000", 9
+	out, headers, err := Marshal(SolSwapBatch, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(headers[1].Value) != "2" {
+		t.Errorf("headers %v", headers)
+	}
+
+	var back []SolSwapData
+	if _, err := Unmarshal(SolSwapBatch, headers, out, &back); err != nil || back[0].FromTokenRawAmount != "1500000000" {
+		t.Errorf("got %+v, %v", back, err)
+	}
+
+	cases := []struct {
+		name    string
+		headers []Header
+		want    error
+	}{
+		{"too old", []Header{{Key: HeaderVersion, Value: []byte("0")}}, ErrUnsupportedVersion},
+		{"too new", []Header{{Key: HeaderVersion, Value: []byte("3")}}, ErrUnsupportedVersion},
+		{"other schema", []Header{{Key: HeaderSchema, Value: []byte(TgBotMessage)}}, ErrUnknownSchema},
+	}
+	for _, c := range cases {
+		if _, err := Unmarshal(SolSwapBatch, c.headers, out, &back); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v", c.name, err)
+		}
+	}
+
+	// a raw amount that is not base units is refused on both sides
+	legacy[0].FromTokenRawAmount = "1.5"
+	if _, _, err := Marshal(SolSwapBatch, legacy); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := Unmarshal(SolSwapBatch, nil, []byte(`[{"type":"SWAP"}]`), &back); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Package solmsg is the kafka message contract of the producer and the consumer. the types are
+// the payloads, every message carries the schema name and version in its headers and is checked
+// against the json schema of that version on both sides.
+package solmsg
+
+const (
+	LabelError    string = "error"
+	LabelNone     string = "none"
+	LabelFirstBuy string = "first_buy"
+	LabelFreshBuy string = "fresh_buy"
+	LabelSellAll  string = "sell_all"
+
+	// LabelAccumulate is a buy into a position the wallet still holds
+	LabelAccumulate string = "accumulate"
+	// LabelReEntry is a buy after the wallet sold all of it before
+	LabelReEntry string = "re_entry"
+	// LabelPartialSell sells part of the position, Position.SellPercent tells how much
+	LabelPartialSell string = "partial_sell"
+	// LabelRoundTrip sells all of a position opened within the flip window
+	LabelRoundTrip string = "round_trip"
+)
+
+// SolSwapData is one parsed transaction, the swap and history topics carry a json array of them
+type SolSwapData struct {
+	TxHash    string `json:"tx_hash"`
+	Source    string `json:"source"`
+	Timestamp int    `json:"timestamp"`
+	Type      string `json:"type"`
+	Date      string `json:"date"`
+
+	FromToken        string  `json:"from_token"`
+	FromTokenAccount string  `json:"fromTokenAccount"`
+	FromUserAccount  string  `json:"fromUserAccount"`
+	FromTokenAmount  float64 `json:"from_token_amount"`
+	// FromTokenRawAmount is the exact amount in units of 10^-FromTokenDecimals, the mint base units
+	// when the decimals are known. FromTokenAmount is kept for readers of the float
+	FromTokenRawAmount string `json:"from_token_raw_amount,omitempty"`
+	FromTokenDecimals  int    `json:"from_token_decimals,omitempty"`
+
+	ToToken          string  `json:"to_token"`
+	ToTokenAccount   string  `json:"toTokenAccount"`
+	ToUserAccount    string  `json:"toUserAccount"`
+	ToTokenAmount    float64 `json:"to_token_amount"`
+	ToTokenRawAmount string  `json:"to_token_raw_amount,omitempty"`
+	ToTokenDecimals  int     `json:"to_token_decimals,omitempty"`
+
+	TradeLabel      string           `json:"trade_label"`
+	IsDCATrade      bool             `json:"is_dca_trade"`
+	WalletCounts    int              `json:"wallet_counts"`
+	TransferDetails []SolSwapData    `json:"transfer_details"`
+	DCAOpenData     string           `json:"dca_open_data"`
+	Parser          string           `json:"parser"`
+	Pool            string           `json:"pool"`
+	Direction       string           `json:"direction"`
+	FeeRate         float64          `json:"fee_rate"`
+	BinStep         int              `json:"bin_step"`
+	Route           []RouteLeg       `json:"route,omitempty"`
+	DCA             *DCAEvent        `json:"dca,omitempty"`
+	LimitOrder      *LimitOrderEvent `json:"limit_order,omitempty"`
+	PumpCurve       *PumpCurve       `json:"pump_curve,omitempty"`
+	MintBurn        *MintBurnEvent   `json:"mint_burn,omitempty"`
+	Liquidity       *LiquidityEvent  `json:"liquidity,omitempty"`
+	Position        *PositionInfo    `json:"position,omitempty"`
+}
+
+// PositionInfo backs the trade label with the position it was read from, zero values are unknown
+type PositionInfo struct {
+	// BuyCount counts the buys of the position up to this one
+	BuyCount int `json:"buy_count,omitempty"`
+	// SellPercent is the share of the position the sell took, 100 on a full exit
+	SellPercent float64 `json:"sell_percent,omitempty"`
+	// HoldSeconds is how long the position was open when it was sold
+	HoldSeconds int `json:"hold_seconds,omitempty"`
+}
+
+const (
+	DCAEventOpen        string = "open"
+	DCAEventFill        string = "fill"
+	DCAEventClose       string = "close"
+	DCAEventWithdraw    string = "withdraw"
+	DCAEventEndAndClose string = "end_and_close"
+)
+
+// DCAEvent is one step of a jupiter dca account, amounts are raw token units
+type DCAEvent struct {
+	Event            string `json:"event"`
+	DCAAccount       string `json:"dca_account"`
+	User             string `json:"user"`
+	InputMint        string `json:"input_mint"`
+	OutputMint       string `json:"output_mint"`
+	InDecimals       int    `json:"in_decimals"`
+	OutDecimals      int    `json:"out_decimals"`
+	InAmount         uint64 `json:"in_amount"`
+	OutAmount        uint64 `json:"out_amount"`
+	InAmountPerCycle uint64 `json:"in_amount_per_cycle"`
+	CycleFrequency   int64  `json:"cycle_frequency"`
+	CyclesTotal      int    `json:"cycles_total"`
+	CyclesFilled     int    `json:"cycles_filled"`
+	InRemaining      uint64 `json:"in_remaining"`
+	Completed        bool   `json:"completed"`
+}
+
+// RouteLeg is one hop of an aggregator route
+type RouteLeg struct {
+	Program   string  `json:"program"`
+	Source    string  `json:"source"`
+	Pool      string  `json:"pool"`
+	InMint    string  `json:"in_mint"`
+	InAmount  float64 `json:"in_amount"`
+	OutMint   string  `json:"out_mint"`
+	OutAmount float64 `json:"out_amount"`
+}
+
+const (
+	LimitOrderEventPlace  string = "place"
+	LimitOrderEventCancel string = "cancel"
+	LimitOrderEventFill   string = "fill"
+)
+
+// LimitOrderEvent is one step of a jupiter limit order, amounts are raw token units.
+// making is the input side and taking the output side, on fills what actually moved
+type LimitOrderEvent struct {
+	Event        string  `json:"event"`
+	OrderAccount string  `json:"order_account"`
+	Maker        string  `json:"maker"`
+	Taker        string  `json:"taker,omitempty"`
+	InputMint    string  `json:"input_mint"`
+	OutputMint   string  `json:"output_mint"`
+	InDecimals   int     `json:"in_decimals"`
+	OutDecimals  int     `json:"out_decimals"`
+	MakingAmount uint64  `json:"making_amount"`
+	TakingAmount uint64  `json:"taking_amount"`
+	Price        float64 `json:"price"`
+	ExpiredAt    int64   `json:"expired_at"`
+}
+
+const (
+	PumpCurveEventBuy      string = "buy"
+	PumpCurveEventSell     string = "sell"
+	PumpCurveEventComplete string = "complete"
+	PumpCurveEventMigrate  string = "migrate"
+)
+
+// PumpCurve is a pump.fun bonding curve right after the event, amounts are raw units
+type PumpCurve struct {
+	Event                string  `json:"event"`
+	Mint                 string  `json:"mint"`
+	BondingCurve         string  `json:"bonding_curve"`
+	SolAmount            uint64  `json:"sol_amount"`
+	TokenAmount          uint64  `json:"token_amount"`
+	VirtualSolReserves   uint64  `json:"virtual_sol_reserves"`
+	VirtualTokenReserves uint64  `json:"virtual_token_reserves"`
+	RealSolReserves      uint64  `json:"real_sol_reserves"`
+	RealTokenReserves    uint64  `json:"real_token_reserves"`
+	Progress             float64 `json:"progress"`
+	Complete             bool    `json:"complete"`
+	MigratedTo           string  `json:"migrated_to,omitempty"`
+}
+
+const (
+	MintBurnEventMint string = "mint"
+	MintBurnEventBurn string = "burn"
+)
+
+// MintBurnEvent is one spl mintTo or burn, the amount is raw token units
+type MintBurnEvent struct {
+	Event        string `json:"event"`
+	Mint         string `json:"mint"`
+	TokenAccount string `json:"token_account"`
+	Authority    string `json:"authority"`
+	Amount       uint64 `json:"amount"`
+	Decimals     int    `json:"decimals"`
+}
+
+const (
+	LiquidityEventAdd    string = "add"
+	LiquidityEventRemove string = "remove"
+)
+
+// LiquidityEvent is one lp deposit or withdraw on an amm, amounts are raw token units.
+// concentrated pools mint no lp token, the position account stands for it there
+type LiquidityEvent struct {
+	Event     string `json:"event"`
+	Pool      string `json:"pool"`
+	Owner     string `json:"owner"`
+	MintA     string `json:"mint_a"`
+	MintB     string `json:"mint_b"`
+	DecimalsA int    `json:"decimals_a"`
+	DecimalsB int    `json:"decimals_b"`
+	AmountA   uint64 `json:"amount_a"`
+	AmountB   uint64 `json:"amount_b"`
+	LpMint    string `json:"lp_mint,omitempty"`
+	LpAmount  uint64 `json:"lp_amount,omitempty"`
+	Position  string `json:"position,omitempty"`
+}
@@ -0,0 +1,46 @@
+package solmsg
+
+// RawTopicData is a message of the exchange and kol topic, Data is the json of the Type
+type RawTopicData struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// RawBitqueryAltertData is one evm transfer leg of the bitquery topic, legs of a swap share TxHash
+type RawBitqueryAltertData struct {
+	DEX string `json:"dex"`
+
+	Chain     string `json:"chain"`
+	TxHash    string `json:"hash"`
+	Timestamp string `json:"timestamp"`
+
+	FromAddress     string `json:"from_address"`
+	FromToken       string `json:"from_token_address"`
+	FromTokenSymbol string `json:"from_token_symbol"`
+	FromTokenAmount string `json:"from_token_amount"`
+	ToAddress       string `json:"to_address"`
+	ToToken         string `json:"to_token_address"`
+	ToTokenSymbol   string `json:"to_token_symbol"`
+	ToTokenAmount   string `json:"to_token_amount"`
+	Value           string `json:"value"`
+	Signer          string `json:"signer"`
+}
+
+type TgButtonInfo struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+type TgMarkup struct {
+	InlineKeyboard [][]TgButtonInfo `json:"inline_keyboard"`
+}
+
+// TgMessage is what the consumer hands the telegram sender through the producer topic
+type TgMessage struct {
+	Webhook     string   `json:"webhook"`
+	ChatID      []string `json:"chat_id"`
+	Msg         string   `json:"msg"`
+	ReplyMarkup TgMarkup `json:"reply_markup"`
+	CreateTime  int      `json:"create_time"`
+	KeepTime    int      `json:"keep_time"`
+}
@@ -0,0 +1,372 @@
+// Package solrpc is the solana json rpc client the producer and the consumer share. calls are
+// spread over the configured endpoints by weight and measured health, an endpoint failing in a
+// row is skipped until it cools down, and a failed call is retried with jitter on another one.
+package solrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+type Endpoint struct {
+	URL string
+	// Weight is the share of traffic of a healthy endpoint, empty means 1
+	Weight int
+	// RequestsPerSecond is the budget of the endpoint plan, empty means unlimited
+	RequestsPerSecond float64
+	Headers           map[string]string
+}
+
+type Config struct {
+	Endpoints []Endpoint
+	// MaxAttempts counts the first try, empty means 3
+	MaxAttempts int
+	// TimeoutMs bounds one attempt, empty means 10000
+	TimeoutMs int
+	// RetryBaseMs is the backoff unit, retry n waits a random time up to RetryBaseMs << n, empty means 100
+	RetryBaseMs int
+	// an endpoint failing FailThreshold times in a row is skipped for CooldownSeconds, empty means 3 and 30
+	FailThreshold   int
+	CooldownSeconds int
+}
+
+var ErrNoEndpoint = errors.New("solrpc: no endpoint configured")
+
+// maxBackoff caps the jittered wait between two attempts
+const maxBackoff = 2 * time.Second
+
+// Client implements the json rpc client of solana-go, RPC wraps it for the typed calls
+type Client struct {
+	endpoints   []*endpoint
+	http        *http.Client
+	maxAttempts int
+	timeout     time.Duration
+	retryBase   time.Duration
+	threshold   int
+	cooldown    time.Duration
+}
+
+func New(cfg Config) (*Client, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.TimeoutMs <= 0 {
+		cfg.TimeoutMs = 10000
+	}
+	if cfg.RetryBaseMs <= 0 {
+		cfg.RetryBaseMs = 100
+	}
+	if cfg.FailThreshold <= 0 {
+		cfg.FailThreshold = 3
+	}
+	if cfg.CooldownSeconds <= 0 {
+		cfg.CooldownSeconds = 30
+	}
+
+	c := &Client{
+		http:        &http.Client{},
+		maxAttempts: cfg.MaxAttempts,
+		timeout:     time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		retryBase:   time.Duration(cfg.RetryBaseMs) * time.Millisecond,
+		threshold:   cfg.FailThreshold,
+		cooldown:    time.Duration(cfg.CooldownSeconds) * time.Second,
+	}
+	for _, v := range cfg.Endpoints {
+		if v.URL == "" {
+			return nil, fmt.Errorf("solrpc: endpoint with empty url")
+		}
+		c.endpoints = append(c.endpoints, newEndpoint(v))
+	}
+	return c, nil
+}
+
+// RPC is the typed solana-go client on top of the routing
+func (c *Client) RPC() *rpc.Client {
+	return rpc.NewWithCustomRPCClient(c)
+}
+
+// Stats lists the endpoints in configured order
+func (c *Client) Stats() []EndpointStats {
+	res := make([]EndpointStats, 0, len(c.endpoints))
+	for _, e := range c.endpoints {
+		res = append(res, e.stats())
+	}
+	return res
+}
+
+// pick chooses by score among the endpoints this call has not tried, leaving out the ones
+// cooling down and the ones out of budget as long as something else is left
+func (c *Client) pick(tried map[*endpoint]bool) *endpoint {
+	now := time.Now()
+
+	candidates := make([]*endpoint, 0, len(c.endpoints))
+	for _, e := range c.endpoints {
+		if !tried[e] {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = c.endpoints
+	}
+
+	for _, keep := range []func(e *endpoint) bool{
+		func(e *endpoint) bool { return !e.down(now) },
+		func(e *endpoint) bool { return e.hasBudget() },
+	} {
+		filtered := make([]*endpoint, 0, len(candidates))
+		for _, e := range candidates {
+			if keep(e) {
+				filtered = append(filtered, e)
+			}
+		}
+		if len(filtered) > 0 {
+			candidates = filtered
+		}
+	}
+
+	scores := make([]float64, len(candidates))
+	total := 0.0
+	for i, e := range candidates {
+		scores[i] = e.score()
+		total += scores[i]
+	}
+
+	r := rand.Float64() * total
+	for i, e := range candidates {
+		r -= scores[i]
+		if r < 0 {
+			return e
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	max := c.retryBase << attempt
+	if max > maxBackoff || max <= 0 {
+		max = maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// retryError marks a failure of the endpoint rather than of the request, the call moves on
+type retryError struct {
+	err error
+}
+
+func (e *retryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryError) Unwrap() error {
+	return e.err
+}
+
+// retryable rpc error codes, the node is behind, overloaded or rate limiting
+var retryCodes = map[int]bool{
+	-32005: true, // node is unhealthy or behind
+	-32603: true, // internal error
+	-32016: true, // minimum context slot not reached
+	-32429: true, // rate limited, as some providers report it
+	429:    true,
+}
+
+// do sends body until handle accepts a response, handle returns a retryError when the endpoint
+// should be counted as failed and the request tried elsewhere
+func (c *Client) do(ctx context.Context, body []byte, handle func(req *http.Request, resp *http.Response) error) error {
+	tried := make(map[*endpoint]bool, len(c.endpoints))
+
+	var lastErr error
+	for attempt := 0; attempt < c.maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return lastErr
+			case <-time.After(c.backoff(attempt)):
+			}
+		}
+
+		e := c.pick(tried)
+		tried[e] = true
+
+		if e.limiter != nil {
+			if err := e.limiter.Wait(ctx); err != nil {
+				if lastErr != nil {
+					return lastErr
+				}
+				return err
+			}
+		}
+
+		start := time.Now()
+		err := c.attempt(ctx, e, body, handle)
+
+		var retry *retryError
+		if err == nil || !errors.As(err, &retry) {
+			e.observe(time.Since(start), false, c.threshold, c.cooldown)
+			return err
+		}
+
+		// the caller gave up, that says nothing about the endpoint
+		if ctx.Err() != nil {
+			return retry.err
+		}
+		e.observe(time.Since(start), true, c.threshold, c.cooldown)
+		lastErr = fmt.Errorf("%s: %w", e.name, retry.err)
+	}
+
+	return fmt.Errorf("solrpc: %d attempts failed, last: %w", c.maxAttempts, lastErr)
+}
+
+func (c *Client) attempt(ctx context.Context, e *endpoint, body []byte, handle func(req *http.Request, resp *http.Response) error) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return &retryError{err}
+	}
+	defer resp.Body.Close()
+
+	return handle(req, resp)
+}
+
+// StatusError is a response that is neither 200 nor a json rpc error
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.Code)
+}
+
+func statusError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	return &retryError{&StatusError{Code: resp.StatusCode}}
+}
+
+// decodeResponse reads the body of one attempt, transport and server side failures are retryable
+func decodeResponse(resp *http.Response, out interface{}) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &retryError{err}
+	}
+
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return statusError(resp)
+		}
+		return &retryError{fmt.Errorf("decode response: %w", err)}
+	}
+	return nil
+}
+
+func (c *Client) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	request := &jsonrpc.RPCRequest{Method: method, JSONRPC: "2.0", ID: 1}
+	if params != nil {
+		request.Params = params
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, body, func(req *http.Request, resp *http.Response) error {
+		var res jsonrpc.RPCResponse
+		err := decodeResponse(resp, &res)
+		if err != nil {
+			return err
+		}
+
+		if res.Error != nil {
+			if retryCodes[res.Error.Code] {
+				return &retryError{res.Error}
+			}
+			return res.Error
+		}
+		if err := statusError(resp); err != nil {
+			return err
+		}
+
+		if res.Result == nil {
+			res.Result = []byte("null")
+		}
+		return json.Unmarshal(res.Result, out)
+	})
+}
+
+// CallWithCallback hands the first response that is not a rate limit or a server error to callback
+func (c *Client) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	request := &jsonrpc.RPCRequest{Method: method, JSONRPC: "2.0", ID: 1}
+	if params != nil {
+		request.Params = params
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, body, func(req *http.Request, resp *http.Response) error {
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return statusError(resp)
+		}
+		return callback(req, resp)
+	})
+}
+
+func (c *Client) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	if len(requests) == 0 {
+		return nil, errors.New("empty request list")
+	}
+	for i, req := range requests {
+		req.ID = i
+		req.JSONRPC = "2.0"
+	}
+
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	var res jsonrpc.RPCResponses
+	err = c.do(ctx, body, func(req *http.Request, resp *http.Response) error {
+		res = nil
+		err := decodeResponse(resp, &res)
+		if err != nil {
+			return err
+		}
+		return statusError(resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
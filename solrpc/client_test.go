@@ -0,0 +1,221 @@
+package solrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// fakeNode answers getSlot with its slot, or with the status or rpc error it is set to fail with
+type fakeNode struct {
+	mu     sync.Mutex
+	slot   uint64
+	status int
+	rpcErr *jsonrpc.RPCError
+	calls  int
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	n.calls++
+
+	if n.status != 0 {
+		w.WriteHeader(n.status)
+		return
+	}
+
+	answer := func(req jsonrpc.RPCRequest) map[string]interface{} {
+		if n.rpcErr != nil {
+			return map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "error": n.rpcErr}
+		}
+		return map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": n.slot}
+	}
+
+	var batch []jsonrpc.RPCRequest
+	if json.Unmarshal(body, &batch) == nil {
+		res := make([]map[string]interface{}, 0, len(batch))
+		for _, req := range batch {
+			res = append(res, answer(req))
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+
+	var req jsonrpc.RPCRequest
+	json.Unmarshal(body, &req)
+	json.NewEncoder(w).Encode(answer(req))
+}
+
+func (n *fakeNode) set(status int, rpcErr *jsonrpc.RPCError) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.status = status
+	n.rpcErr = rpcErr
+}
+
+func (n *fakeNode) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls
+}
+
+func startNode(t *testing.T, slot uint64) (*fakeNode, string) {
+	t.Helper()
+
+	n := &fakeNode{slot: slot}
+	server := httptest.NewServer(n)
+	t.Cleanup(server.Close)
+	return n, server.URL + "/key/secret"
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, ErrNoEndpoint) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := New(Config{Endpoints: []Endpoint{{}}}); err == nil {
+		t.Errorf("empty url should fail")
+	}
+}
+
+func TestClientFailover(t *testing.T) {
+	good, goodURL := startNode(t, 100)
+	bad, badURL := startNode(t, 0)
+	bad.set(http.StatusBadGateway, nil)
+
+	c, err := New(Config{
+		Endpoints:   []Endpoint{{URL: badURL, Weight: 1000}, {URL: goodURL}},
+		RetryBaseMs: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every call lands on the good node, a bad pick is retried there
+	for i := 0; i < 10; i++ {
+		slot, err := c.RPC().GetSlot(context.Background(), "")
+		if err != nil || slot != 100 {
+			t.Fatalf("got %d %v", slot, err)
+		}
+	}
+	if good.count() != 10 {
+		t.Errorf("good node got %d calls", good.count())
+	}
+
+	// three failures in a row put the bad node on cooldown, it gets nothing more
+	if bad.count() < 3 {
+		t.Fatalf("bad node got %d calls", bad.count())
+	}
+	tried := bad.count()
+	for i := 0; i < 10; i++ {
+		c.RPC().GetSlot(context.Background(), "")
+	}
+	if bad.count() != tried {
+		t.Errorf("bad node called on cooldown")
+	}
+
+	stats := c.Stats()
+	if !stats[0].Down || stats[0].ErrorRate == 0 || stats[1].Down || stats[1].Errors != 0 {
+		t.Errorf("got %+v", stats)
+	}
+	if strings.Contains(stats[0].Name, "secret") || !strings.HasPrefix(badURL, stats[0].Name) {
+		t.Errorf("got name %s", stats[0].Name)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	n, url := startNode(t, 0)
+	c, err := New(Config{Endpoints: []Endpoint{{URL: url}}, RetryBaseMs: 1, FailThreshold: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an invalid request is answered, it is not retried and not held against the node
+	n.set(0, &jsonrpc.RPCError{Code: -32602, Message: "invalid params"})
+	_, err = c.RPC().GetSlot(context.Background(), "")
+	var rpcErr *jsonrpc.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 || n.count() != 1 {
+		t.Errorf("got %v after %d calls", err, n.count())
+	}
+	if c.Stats()[0].Errors != 0 {
+		t.Errorf("got %+v", c.Stats()[0])
+	}
+
+	// a node behind is retried up to the attempts
+	n.set(0, &jsonrpc.RPCError{Code: -32005, Message: "node is behind"})
+	_, err = c.RPC().GetSlot(context.Background(), "")
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32005 || n.count() != 4 {
+		t.Errorf("got %v after %d calls", err, n.count())
+	}
+
+	n.set(http.StatusTooManyRequests, nil)
+	_, err = c.RPC().GetSlot(context.Background(), "")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusTooManyRequests || n.count() != 7 {
+		t.Errorf("got %v after %d calls", err, n.count())
+	}
+	if c.Stats()[0].Errors != 6 {
+		t.Errorf("got %+v", c.Stats()[0])
+	}
+
+	// a batch goes out as one request
+	n.set(0, nil)
+	res, err := c.CallBatch(context.Background(), jsonrpc.RPCRequests{{Method: "getSlot"}, {Method: "getBlockHeight"}})
+	if err != nil || len(res) != 2 || n.count() != 8 {
+		t.Errorf("got %+v %v after %d calls", res, err, n.count())
+	}
+}
+
+func TestClientBudget(t *testing.T) {
+	limited, limitedURL := startNode(t, 1)
+	spare, spareURL := startNode(t, 2)
+
+	c, err := New(Config{
+		Endpoints: []Endpoint{{URL: limitedURL, Weight: 100, RequestsPerSecond: 1}, {URL: spareURL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the budget of the heavy endpoint is spent on the first call, the rest overflow
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := c.RPC().GetSlot(context.Background(), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("calls waited on the budget for %s", time.Since(start))
+	}
+	if limited.count() != 1 || spare.count() != 4 {
+		t.Errorf("got %d and %d calls", limited.count(), spare.count())
+	}
+}
+
+func TestClientCallerCancel(t *testing.T) {
+	n, url := startNode(t, 0)
+	n.set(http.StatusServiceUnavailable, nil)
+
+	c, err := New(Config{Endpoints: []Endpoint{{URL: url}}, RetryBaseMs: 1000, MaxAttempts: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.RPC().GetSlot(ctx, "")
+	if err == nil || n.count() > 2 {
+		t.Errorf("got %v after %d calls", err, n.count())
+	}
+}
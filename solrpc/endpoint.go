@@ -0,0 +1,140 @@
+package solrpc
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ewmaAlpha is the weight of the latest request in the latency and error averages
+const ewmaAlpha = 0.2
+
+type endpoint struct {
+	url     string
+	name    string
+	weight  float64
+	headers map[string]string
+	// limiter is nil when the endpoint has no request budget
+	limiter *rate.Limiter
+
+	mu        sync.Mutex
+	latency   float64 // ms
+	errRate   float64
+	fails     int // in a row
+	downUntil time.Time
+	requests  int64
+	errors    int64
+}
+
+func newEndpoint(cfg Endpoint) *endpoint {
+	weight := float64(cfg.Weight)
+	if weight <= 0 {
+		weight = 1
+	}
+
+	e := &endpoint{
+		url:     cfg.URL,
+		name:    redact(cfg.URL),
+		weight:  weight,
+		headers: cfg.Headers,
+	}
+	if cfg.RequestsPerSecond > 0 {
+		burst := int(cfg.RequestsPerSecond)
+		if burst < 1 {
+			burst = 1
+		}
+		e.limiter = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), burst)
+	}
+	return e
+}
+
+// redact keeps scheme and host, provider keys sit in the path or the query
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// score is the routing weight, slow and failing endpoints get less traffic but never none, so
+// they are probed again and win their share back once they recover
+func (e *endpoint) score() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := e.weight * (1 - e.errRate) / (1 + e.latency/100)
+	if min := e.weight * 0.01; s < min {
+		s = min
+	}
+	return s
+}
+
+func (e *endpoint) down(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return now.Before(e.downUntil)
+}
+
+// hasBudget reports whether a request can go out now without waiting on the limiter
+func (e *endpoint) hasBudget() bool {
+	return e.limiter == nil || e.limiter.Tokens() >= 1
+}
+
+func (e *endpoint) observe(latency time.Duration, failed bool, threshold int, cooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	if failed {
+		e.errors++
+		e.errRate = e.errRate*(1-ewmaAlpha) + ewmaAlpha
+		e.fails++
+		if e.fails >= threshold {
+			e.downUntil = time.Now().Add(cooldown)
+			e.fails = 0
+		}
+		return
+	}
+
+	ms := float64(latency) / float64(time.Millisecond)
+	if e.requests == 1 {
+		e.latency = ms
+	} else {
+		e.latency = e.latency*(1-ewmaAlpha) + ms*ewmaAlpha
+	}
+	e.errRate *= 1 - ewmaAlpha
+	e.fails = 0
+}
+
+// EndpointStats is the health of one endpoint as the router sees it
+type EndpointStats struct {
+	Name      string  `json:"name"`
+	Weight    float64 `json:"weight"`
+	Score     float64 `json:"score"`
+	LatencyMs float64 `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+	Down      bool    `json:"down"`
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+}
+
+func (e *endpoint) stats() EndpointStats {
+	score := e.score()
+	down := e.down(time.Now())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return EndpointStats{
+		Name:      e.name,
+		Weight:    e.weight,
+		Score:     score,
+		LatencyMs: e.latency,
+		ErrorRate: e.errRate,
+		Down:      down,
+		Requests:  e.requests,
+		Errors:    e.errors,
+	}
+}